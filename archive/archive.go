@@ -0,0 +1,79 @@
+// Package archive extracts and creates zip, tar, tar.gz and tar.zst archives, streaming the
+// entries instead of loading them in memory.
+//
+// Extract treats the archive as untrusted input: entries escaping the destination directory,
+// through ".." or absolute names (zip slip) or through symlinks, are rejected, and the total
+// uncompressed size and the number of entries are limited against archive bombs.
+//
+//	stats, err := archive.ExtractFile(ctx, "upload.tar.gz", "/data/in", archive.ExtractOptions{})
+//	...
+//	err = archive.CreateDir(ctx, w, "./build", archive.CreateOptions{Format: archive.Zip})
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Format of an archive.
+type Format string
+
+const (
+	Zip     Format = "zip"
+	Tar     Format = "tar"
+	TarGzip Format = "tar.gz"
+	TarZstd Format = "tar.zst"
+)
+
+var (
+	// ErrUnsupportedFormat is returned when the format of an archive cannot be detected
+	ErrUnsupportedFormat = errors.New("archive: unsupported format")
+	// ErrUnsafePath is returned for the entries that would be written outside of the destination
+	ErrUnsafePath = errors.New("archive: unsafe path")
+	// ErrTooLarge is returned when the uncompressed size exceeds ExtractOptions.MaxSize
+	ErrTooLarge = errors.New("archive: uncompressed size limit exceeded")
+	// ErrTooManyFiles is returned when the number of entries exceeds ExtractOptions.MaxFiles
+	ErrTooManyFiles = errors.New("archive: file count limit exceeded")
+)
+
+// Stats counts the entries of an extracted or created archive.
+type Stats struct {
+	Files    int
+	Dirs     int
+	Symlinks int
+	Links    int   // Hard links of tar archives
+	Bytes    int64 // Uncompressed size of the files, as written
+}
+
+// Detect returns the format of an archive from its first bytes, at least 512 for tar.
+func Detect(header []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return Zip, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return TarGzip, nil
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return TarZstd, nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return Tar, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// FormatFromName returns the format of an archive from its file extension.
+func FormatFromName(name string) (Format, error) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, nil
+	case strings.HasSuffix(name, ".tar"):
+		return Tar, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGzip, nil
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return TarZstd, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+}
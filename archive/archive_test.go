@@ -0,0 +1,240 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var modTime = time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+
+func sourceDir(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "bootstrap"), []byte("#!/bin/sh\necho hi\n"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.bin"), []byte{0, 1, 2, 0xff}, 0o640))
+	require.NoError(t, os.Symlink("bin/bootstrap", filepath.Join(dir, "run")))
+	return dir
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := sourceDir(t)
+	for _, format := range []Format{Zip, Tar, TarGzip, TarZstd} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			stats, err := CreateDir(context.Background(), &buf, src, CreateOptions{Format: format, ModTime: modTime})
+			require.NoError(t, err)
+			assert.Equal(t, Stats{Files: 2, Dirs: 1, Symlinks: 1, Bytes: 22}, stats)
+
+			detected, err := Detect(buf.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, format, detected)
+
+			dest := t.TempDir()
+			stats, err = Extract(context.Background(), bytes.NewReader(buf.Bytes()), dest, ExtractOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, Stats{Files: 2, Dirs: 1, Symlinks: 1, Bytes: 22}, stats)
+
+			content, err := os.ReadFile(filepath.Join(dest, "bin", "bootstrap"))
+			require.NoError(t, err)
+			assert.Equal(t, "#!/bin/sh\necho hi\n", string(content))
+			info, err := os.Stat(filepath.Join(dest, "bin", "bootstrap"))
+			require.NoError(t, err)
+			assert.Equal(t, fs.FileMode(0o755), info.Mode().Perm())
+			assert.True(t, info.ModTime().Equal(modTime), info.ModTime())
+
+			info, err = os.Stat(filepath.Join(dest, "data.bin"))
+			require.NoError(t, err)
+			assert.Equal(t, fs.FileMode(0o640), info.Mode().Perm())
+			info, err = os.Stat(filepath.Join(dest, "bin"))
+			require.NoError(t, err)
+			assert.True(t, info.ModTime().Equal(modTime), "directory times are set last")
+
+			target, err := os.Readlink(filepath.Join(dest, "run"))
+			require.NoError(t, err)
+			assert.Equal(t, "bin/bootstrap", target)
+		})
+	}
+}
+
+func TestCreateFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":       {Data: []byte("a"), Mode: 0o644, ModTime: modTime},
+		"skip/b.txt":  {Data: []byte("b"), Mode: 0o644, ModTime: modTime},
+		"keep/c.txt":  {Data: []byte("cc"), Mode: 0o600, ModTime: modTime},
+		"keep/d.tmp":  {Data: []byte("d"), Mode: 0o644, ModTime: modTime},
+		"keep/e/f.md": {Data: []byte("f"), Mode: 0o644, ModTime: modTime},
+	}
+	var buf bytes.Buffer
+	stats, err := Create(context.Background(), &buf, fsys, CreateOptions{
+		Format: TarGzip,
+		Prefix: "root",
+		Filter: func(name string, d fs.DirEntry) bool {
+			return name != "skip" && filepath.Ext(name) != ".tmp"
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Files)
+
+	dest := t.TempDir()
+	_, err = Extract(context.Background(), &buf, dest, ExtractOptions{StripComponents: 1})
+	require.NoError(t, err)
+	var names []string
+	require.NoError(t, filepath.WalkDir(dest, func(p string, d fs.DirEntry, err error) error {
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(dest, p)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return err
+	}))
+	assert.Equal(t, []string{"a.txt", "keep/c.txt", "keep/e/f.md"}, names)
+}
+
+type tarEntry struct {
+	name, link string
+	typ        byte
+	body       string
+}
+
+func tarGz(t *testing.T, entries ...tarEntry) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Linkname: e.link, Typeflag: e.typ, Mode: 0o644, Size: int64(len(e.body))}
+		if e.typ == 0 {
+			h.Typeflag = tar.TypeReg
+		}
+		require.NoError(t, tw.WriteHeader(h))
+		_, err := tw.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestExtractRejectsUnsafePaths(t *testing.T) {
+	cases := map[string][]tarEntry{
+		"parent":          {{name: "../evil.txt", body: "x"}},
+		"nested parent":   {{name: "a/../../evil.txt", body: "x"}},
+		"absolute":        {{name: "/tmp/evil.txt", body: "x"}},
+		"symlink escape":  {{name: "link", link: "../../etc", typ: tar.TypeSymlink}},
+		"absolute link":   {{name: "link", link: "/etc", typ: tar.TypeSymlink}},
+		"through symlink": {{name: "sub/x", body: "x"}, {name: "link", link: "sub", typ: tar.TypeSymlink}, {name: "link/evil", body: "x"}},
+		"hard link":       {{name: "link", link: "../outside", typ: tar.TypeLink}},
+		"link through link": {
+			{name: "s", link: ".", typ: tar.TypeSymlink},
+			{name: "t", link: "s/..", typ: tar.TypeSymlink},
+		},
+		"link before its parent": {
+			{name: "a", link: "b/..", typ: tar.TypeSymlink},
+			{name: "b", link: ".", typ: tar.TypeSymlink},
+		},
+	}
+	for name, entries := range cases {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			_, err := Extract(context.Background(), bytes.NewReader(tarGz(t, entries...)), dest, ExtractOptions{})
+			assert.ErrorIs(t, err, ErrUnsafePath)
+			_, err = os.Stat(filepath.Join(parent, "evil.txt"))
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+		})
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("../evil.txt")
+	require.NoError(t, err)
+	_, _ = w.Write([]byte("x"))
+	require.NoError(t, zw.Close())
+	_, err = Extract(context.Background(), bytes.NewReader(buf.Bytes()), t.TempDir(), ExtractOptions{})
+	assert.ErrorIs(t, err, ErrUnsafePath)
+}
+
+func TestExtractLinksInside(t *testing.T) {
+	dest := t.TempDir()
+	stats, err := Extract(context.Background(), bytes.NewReader(tarGz(t,
+		tarEntry{name: "a/file", body: "content"},
+		tarEntry{name: "a/link", link: "../a/file", typ: tar.TypeSymlink},
+		tarEntry{name: "a/chain", link: "link", typ: tar.TypeSymlink},
+		tarEntry{name: "hard", link: "a/file", typ: tar.TypeLink},
+	)), dest, ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Files: 1, Symlinks: 2, Links: 1, Bytes: 7}, stats)
+	content, err := os.ReadFile(filepath.Join(dest, "hard"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	content, err = os.ReadFile(filepath.Join(dest, "a", "chain"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+}
+
+func TestExtractLimits(t *testing.T) {
+	bomb := tarGz(t, tarEntry{name: "zeros", body: string(make([]byte, 1<<20))})
+	assert.Less(t, len(bomb), 4096)
+	_, err := Extract(context.Background(), bytes.NewReader(bomb), t.TempDir(), ExtractOptions{MaxSize: 1 << 19})
+	assert.ErrorIs(t, err, ErrTooLarge)
+	_, err = Extract(context.Background(), bytes.NewReader(bomb), t.TempDir(), ExtractOptions{MaxSize: 1 << 20})
+	assert.NoError(t, err)
+
+	many := tarGz(t, tarEntry{name: "1"}, tarEntry{name: "2"}, tarEntry{name: "3"})
+	_, err = Extract(context.Background(), bytes.NewReader(many), t.TempDir(), ExtractOptions{MaxFiles: 2})
+	assert.ErrorIs(t, err, ErrTooManyFiles)
+}
+
+func TestExtractExisting(t *testing.T) {
+	dest := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "f"), []byte("old"), 0o644))
+	archive := tarGz(t, tarEntry{name: "f", body: "new"})
+
+	_, err := Extract(context.Background(), bytes.NewReader(archive), dest, ExtractOptions{})
+	assert.ErrorIs(t, err, fs.ErrExist)
+	_, err = Extract(context.Background(), bytes.NewReader(archive), dest, ExtractOptions{Overwrite: true})
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dest, "f"))
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+}
+
+func TestExtractZipStream(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := CreateDir(context.Background(), &buf, sourceDir(t), CreateOptions{})
+	require.NoError(t, err)
+
+	// Without random access the zip is copied to a temporary file
+	dest := t.TempDir()
+	stats, err := Extract(context.Background(), io.MultiReader(&buf), dest, ExtractOptions{TempDir: t.TempDir()})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Files)
+}
+
+func TestExtractCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Extract(ctx, bytes.NewReader(tarGz(t, tarEntry{name: "f", body: "x"})), t.TempDir(), ExtractOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFormatFromName(t *testing.T) {
+	for name, want := range map[string]Format{"a.zip": Zip, "a.TAR": Tar, "a.tgz": TarGzip, "a.tar.gz": TarGzip, "a.tar.zst": TarZstd} {
+		got, err := FormatFromName(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := FormatFromName("a.rar")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,295 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CreateOptions holds the options of Create.
+type CreateOptions struct {
+	Format Format // default Zip
+	// Level of compression, format specific (default: the default level of the format)
+	Level int
+	// Prefix is a directory prepended to the names of the entries
+	Prefix string
+	// Filter returns false to skip an entry, and the content of a directory
+	Filter func(name string, d fs.DirEntry) bool
+	// ModTime replaces the modification times when set, for reproducible archives
+	ModTime time.Time
+}
+
+func (o *CreateOptions) setDefaults() {
+	if o.Format == "" {
+		o.Format = Zip
+	}
+}
+
+// CreateDir writes the archive of the content of dir to w. Symlinks are stored as symlinks.
+func CreateDir(ctx context.Context, w io.Writer, dir string, opts CreateOptions) (Stats, error) {
+	readlink := func(name string) (string, error) {
+		return os.Readlink(filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	return create(ctx, w, os.DirFS(dir), readlink, opts)
+}
+
+// Create writes the archive of fsys to w, streaming the files. As fs.FS cannot read symlinks,
+// they are followed: stored as the file they point to, and skipped when they point to a
+// directory. Use CreateDir to keep them.
+func Create(ctx context.Context, w io.Writer, fsys fs.FS, opts CreateOptions) (Stats, error) {
+	return create(ctx, w, fsys, nil, opts)
+}
+
+// entryWriter writes the entries of an archive
+type entryWriter interface {
+	dir(name string, info fs.FileInfo) error
+	// file returns the number of bytes of the file written
+	file(name string, info fs.FileInfo, r io.Reader) (int64, error)
+	symlink(name string, info fs.FileInfo, target string) error
+	Close() error
+}
+
+func create(ctx context.Context, w io.Writer, fsys fs.FS, readlink func(string) (string, error), opts CreateOptions) (Stats, error) {
+	opts.setDefaults()
+	var stats Stats
+	ew, err := newEntryWriter(w, opts)
+	if err != nil {
+		return stats, err
+	}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		if opts.Filter != nil && !opts.Filter(name, d) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := path.Join(opts.Prefix, name)
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if readlink != nil {
+				target, err := readlink(name)
+				if err != nil {
+					return err
+				}
+				stats.Symlinks++
+				return ew.symlink(entry, withModTime(info, opts.ModTime), target)
+			}
+			if info, err = fs.Stat(fsys, name); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+		}
+		info = withModTime(info, opts.ModTime)
+		switch {
+		case info.IsDir():
+			stats.Dirs++
+			return ew.dir(entry, info)
+		case info.Mode().IsRegular():
+			f, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			n, err := ew.file(entry, info, ctxReader{ctx, f})
+			stats.Bytes += n
+			if err != nil {
+				return err
+			}
+			stats.Files++
+			return nil
+		}
+		return nil
+	})
+	if closeErr := ew.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return stats, fmt.Errorf("archive: %w", err)
+	}
+	return stats, nil
+}
+
+func newEntryWriter(w io.Writer, opts CreateOptions) (entryWriter, error) {
+	switch opts.Format {
+	case Zip:
+		zw := zip.NewWriter(w)
+		if opts.Level != 0 {
+			zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(out, opts.Level)
+			})
+		}
+		return &zipWriter{zw: zw}, nil
+	case Tar:
+		return &tarWriter{tw: tar.NewWriter(w)}, nil
+	case TarGzip:
+		level := opts.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gz, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{tw: tar.NewWriter(gz), compressor: gz}, nil
+	case TarZstd:
+		var zopts []zstd.EOption
+		if opts.Level != 0 {
+			zopts = append(zopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.Level)))
+		}
+		zw, err := zstd.NewWriter(w, zopts...)
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{tw: tar.NewWriter(zw), compressor: zw}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, opts.Format)
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) header(name string, info fs.FileInfo) (*zip.FileHeader, error) {
+	h, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	h.Name = name
+	h.Modified = info.ModTime()
+	return h, nil
+}
+
+func (z *zipWriter) dir(name string, info fs.FileInfo) error {
+	h, err := z.header(name+"/", info)
+	if err != nil {
+		return err
+	}
+	h.Method = zip.Store
+	_, err = z.zw.CreateHeader(h)
+	return err
+}
+
+func (z *zipWriter) file(name string, info fs.FileInfo, r io.Reader) (int64, error) {
+	h, err := z.header(name, info)
+	if err != nil {
+		return 0, err
+	}
+	h.Method = zip.Deflate
+	w, err := z.zw.CreateHeader(h)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(w, r)
+}
+
+func (z *zipWriter) symlink(name string, info fs.FileInfo, target string) error {
+	h, err := z.header(name, info)
+	if err != nil {
+		return err
+	}
+	h.Method = zip.Store
+	w, err := z.zw.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, target)
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return z.zw.Close()
+}
+
+type tarWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser // Closed after the tar writer, nil for Tar
+}
+
+func (t *tarWriter) write(name, link string, info fs.FileInfo) error {
+	h, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	h.Name = name
+	h.ModTime = info.ModTime()
+	h.Uname, h.Gname = "", ""
+	return t.tw.WriteHeader(h)
+}
+
+func (t *tarWriter) dir(name string, info fs.FileInfo) error {
+	return t.write(name+"/", "", info)
+}
+
+func (t *tarWriter) file(name string, info fs.FileInfo, r io.Reader) (int64, error) {
+	if err := t.write(name, "", info); err != nil {
+		return 0, err
+	}
+	// The header holds the size of the file: fail rather than writing a corrupt archive
+	// when the file changed meanwhile
+	n, err := io.Copy(t.tw, io.LimitReader(r, info.Size()))
+	if err == nil && n != info.Size() {
+		err = fmt.Errorf("%s: size changed while archiving", name)
+	}
+	return n, err
+}
+
+func (t *tarWriter) symlink(name string, info fs.FileInfo, target string) error {
+	return t.write(name, target, info)
+}
+
+func (t *tarWriter) Close() error {
+	err := t.tw.Close()
+	if t.compressor != nil {
+		if closeErr := t.compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// withModTime overrides the modification time of an entry
+func withModTime(info fs.FileInfo, modTime time.Time) fs.FileInfo {
+	if modTime.IsZero() {
+		return info
+	}
+	return fileInfo{FileInfo: info, modTime: modTime}
+}
+
+type fileInfo struct {
+	fs.FileInfo
+	modTime time.Time
+}
+
+func (f fileInfo) ModTime() time.Time {
+	return f.modTime
+}
+
+// Sys hides the platform data, used by tar.FileInfoHeader for the owner
+func (f fileInfo) Sys() any {
+	return nil
+}
@@ -0,0 +1,533 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+// ExtractOptions holds the options of Extract.
+type ExtractOptions struct {
+	// Format of the archive, detected from its content when empty
+	Format Format
+	// MaxSize is the limit of the total uncompressed size (default 1 GiB), negative for no limit.
+	// It is enforced on the bytes actually written, not on the sizes declared by the archive.
+	MaxSize int64
+	// MaxFiles is the limit of the number of entries (default 10000), negative for no limit
+	MaxFiles int
+	// Overwrite replaces the existing files, Extract fails on them otherwise
+	Overwrite bool
+	// StripComponents removes leading path elements from the names, like tar --strip-components
+	StripComponents int
+	// TempDir holds the copy of a zip read from a stream (default os.TempDir)
+	TempDir string
+	Logger  *zap.Logger
+}
+
+func (o *ExtractOptions) setDefaults() {
+	if o.MaxSize == 0 {
+		o.MaxSize = 1 << 30
+	}
+	if o.MaxFiles == 0 {
+		o.MaxFiles = 10000
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+}
+
+// ExtractFile extracts the archive at path into destDir, see Extract.
+func ExtractFile(ctx context.Context, path, destDir string, opts ExtractOptions) (Stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer f.Close()
+	if opts.Format == "" {
+		if format, err := FormatFromName(path); err == nil {
+			opts.Format = format
+		}
+	}
+	return Extract(ctx, f, destDir, opts)
+}
+
+// Extract extracts the archive read from src into destDir, which is created if needed. Regular
+// files, directories, symlinks and tar hard links are extracted with their permissions (without
+// setuid, setgid and sticky bits) and modification times, other entries are skipped.
+//
+// Zip archives are read through their central directory: src is used directly when it is an
+// *os.File or has a Size method like *bytes.Reader, otherwise it is copied to a temporary file.
+//
+// A failed extraction leaves the entries extracted so far in destDir.
+func Extract(ctx context.Context, src io.Reader, destDir string, opts ExtractOptions) (Stats, error) {
+	opts.setDefaults()
+	dest, err := filepath.Abs(destDir)
+	if err != nil {
+		return Stats{}, err
+	}
+	if err = os.MkdirAll(dest, 0o755); err != nil {
+		return Stats{}, err
+	}
+	x := &extractor{ctx: ctx, opts: opts, dest: dest, checked: make(map[string]bool)}
+
+	format := opts.Format
+	if ra, size, ok := readerAt(src); ok {
+		if format == "" {
+			header := make([]byte, 512)
+			n, _ := ra.ReadAt(header, 0)
+			if format, err = Detect(header[:n]); err != nil {
+				return x.stats, err
+			}
+		}
+		if format == Zip {
+			return x.stats, x.finish(x.zip(ra, size))
+		}
+		src = io.NewSectionReader(ra, 0, size)
+	}
+	br := bufio.NewReader(src)
+	if format == "" {
+		header, _ := br.Peek(512)
+		if format, err = Detect(header); err != nil {
+			return x.stats, err
+		}
+	}
+
+	switch format {
+	case Zip:
+		err = x.spoolZip(br)
+	case Tar:
+		err = x.tar(br)
+	case TarGzip:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(br); err == nil {
+			err = x.tar(gz)
+		}
+	case TarZstd:
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(br); err == nil {
+			err = x.tar(zr)
+			zr.Close()
+		}
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	return x.stats, x.finish(err)
+}
+
+// readerAt returns the io.ReaderAt of the sources that provide random access
+func readerAt(src io.Reader) (io.ReaderAt, int64, bool) {
+	switch r := src.(type) {
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil, 0, false
+		}
+		return r, info.Size(), true
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return r, r.Size(), true
+	}
+	return nil, 0, false
+}
+
+type extractor struct {
+	ctx     context.Context
+	opts    ExtractOptions
+	dest    string
+	stats   Stats
+	entries int
+	checked map[string]bool // Directories verified to be real directories inside dest
+	dirs    []dirEntry      // Permissions and times applied once their content is written
+}
+
+type dirEntry struct {
+	path    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// finish applies the permissions and times of the directories, deepest first
+func (x *extractor) finish(err error) error {
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		d := x.dirs[i]
+		if chErr := os.Chmod(d.path, d.mode); chErr != nil && err == nil {
+			err = chErr
+		}
+		if !d.modTime.IsZero() {
+			_ = os.Chtimes(d.path, d.modTime, d.modTime)
+		}
+	}
+	return err
+}
+
+func (x *extractor) zip(ra io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if err = x.next(); err != nil {
+			return err
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = x.dir(f.Name, mode, f.Modified)
+		case mode&fs.ModeSymlink != 0:
+			err = x.zipSymlink(f)
+		case mode.IsRegular():
+			err = x.zipFile(f)
+		default:
+			x.opts.Logger.Debug("skipping zip entry", zap.String("name", f.Name), zap.Stringer("mode", mode))
+		}
+		if err != nil {
+			return fmt.Errorf("archive: %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (x *extractor) zipFile(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return x.file(f.Name, rc, f.Mode(), f.Modified)
+}
+
+func (x *extractor) zipSymlink(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	return x.symlink(f.Name, string(target))
+}
+
+// spoolZip copies a zip stream to a temporary file to read its central directory
+func (x *extractor) spoolZip(r io.Reader) error {
+	tmp, err := os.CreateTemp(x.opts.TempDir, "archive-*.zip")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	var reader io.Reader = ctxReader{x.ctx, r}
+	if x.opts.MaxSize > 0 {
+		// The compressed size does not exceed the uncompressed one, besides the headers
+		reader = io.LimitReader(reader, x.opts.MaxSize+1)
+	}
+	n, err := io.Copy(tmp, reader)
+	if err != nil {
+		return err
+	}
+	if x.opts.MaxSize > 0 && n > x.opts.MaxSize {
+		return ErrTooLarge
+	}
+	return x.zip(tmp, n)
+}
+
+func (x *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = x.next(); err != nil {
+			return err
+		}
+		mode := h.FileInfo().Mode()
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(h.Name, mode, h.ModTime)
+		case tar.TypeReg:
+			err = x.file(h.Name, tr, mode, h.ModTime)
+		case tar.TypeSymlink:
+			err = x.symlink(h.Name, h.Linkname)
+		case tar.TypeLink:
+			err = x.hardlink(h.Name, h.Linkname)
+		default:
+			x.opts.Logger.Debug("skipping tar entry", zap.String("name", h.Name), zap.Uint8("type", h.Typeflag))
+		}
+		if err != nil {
+			return fmt.Errorf("archive: %s: %w", h.Name, err)
+		}
+	}
+}
+
+// next counts an entry and checks the limits
+func (x *extractor) next() error {
+	if err := x.ctx.Err(); err != nil {
+		return err
+	}
+	x.entries++
+	if x.opts.MaxFiles > 0 && x.entries > x.opts.MaxFiles {
+		return ErrTooManyFiles
+	}
+	return nil
+}
+
+// entryPath returns the slash-separated path of an entry relative to dest, empty when the
+// entry is the root or is stripped
+func (x *extractor) entryPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(name) || (len(name) >= 2 && name[1] == ':') {
+		return "", ErrUnsafePath
+	}
+	name = path.Clean(name)
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", ErrUnsafePath
+	}
+	for i := 0; i < x.opts.StripComponents; i++ {
+		_, rest, ok := strings.Cut(name, "/")
+		if !ok {
+			return "", nil
+		}
+		name = rest
+	}
+	if name == "." {
+		return "", nil
+	}
+	return name, nil
+}
+
+// parent creates the parent directories of an entry, refusing to go through symlinks
+func (x *extractor) parent(rel string) error {
+	dir := path.Dir(rel)
+	if dir == "." || x.checked[dir] {
+		return nil
+	}
+	current := x.dest
+	prefix := ""
+	for _, elem := range strings.Split(dir, "/") {
+		prefix = path.Join(prefix, elem)
+		current = filepath.Join(current, elem)
+		if x.checked[prefix] {
+			continue
+		}
+		info, err := os.Lstat(current)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err = os.Mkdir(current, 0o755); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case info.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf("%w: %s is a symlink", ErrUnsafePath, prefix)
+		case !info.IsDir():
+			return fmt.Errorf("%s is not a directory", prefix)
+		}
+		x.checked[prefix] = true
+	}
+	return nil
+}
+
+// prepare returns the destination of an entry, removing the existing file when allowed
+func (x *extractor) prepare(name string) (string, string, error) {
+	rel, err := x.entryPath(name)
+	if err != nil || rel == "" {
+		return "", "", err
+	}
+	if err = x.parent(rel); err != nil {
+		return "", "", err
+	}
+	target := filepath.Join(x.dest, filepath.FromSlash(rel))
+	info, err := os.Lstat(target)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return rel, target, nil
+	case err != nil:
+		return "", "", err
+	case info.IsDir():
+		return "", "", fmt.Errorf("%w: %s is a directory", fs.ErrExist, rel)
+	case !x.opts.Overwrite:
+		return "", "", fmt.Errorf("%w: %s", fs.ErrExist, rel)
+	}
+	return rel, target, os.Remove(target)
+}
+
+func (x *extractor) dir(name string, mode fs.FileMode, modTime time.Time) error {
+	rel, err := x.entryPath(name)
+	if err != nil || rel == "" {
+		return err
+	}
+	if err = x.parent(rel + "/."); err != nil {
+		return err
+	}
+	x.stats.Dirs++
+	x.dirs = append(x.dirs, dirEntry{path: filepath.Join(x.dest, filepath.FromSlash(rel)), mode: mode.Perm(), modTime: modTime})
+	return nil
+}
+
+func (x *extractor) file(name string, r io.Reader, mode fs.FileMode, modTime time.Time) error {
+	_, target, err := x.prepare(name)
+	if err != nil || target == "" {
+		return err
+	}
+	// O_EXCL: never write through a file created meanwhile
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader = ctxReader{x.ctx, r}
+	remaining := int64(-1)
+	if x.opts.MaxSize > 0 {
+		remaining = x.opts.MaxSize - x.stats.Bytes
+		reader = io.LimitReader(reader, remaining+1)
+	}
+	n, err := io.Copy(f, reader)
+	x.stats.Bytes += n
+	if err == nil && remaining >= 0 && n > remaining {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		err = f.Chmod(mode.Perm())
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	x.stats.Files++
+	if !modTime.IsZero() {
+		return os.Chtimes(target, modTime, modTime)
+	}
+	return nil
+}
+
+// symlink creates a symlink whose target stays inside dest, see checkLink. The checks of parent
+// prevent writing through it afterwards.
+func (x *extractor) symlink(name, linkname string) error {
+	rel, target, err := x.prepare(name)
+	if err != nil || target == "" {
+		return err
+	}
+	linkname = strings.ReplaceAll(linkname, `\`, "/")
+	if linkname == "" || path.IsAbs(linkname) || (len(linkname) >= 2 && linkname[1] == ':') {
+		return fmt.Errorf("%w: symlink to %q", ErrUnsafePath, linkname)
+	}
+	if err = x.checkLink(rel, linkname); err != nil {
+		return err
+	}
+	if err = os.Symlink(filepath.FromSlash(linkname), target); err != nil {
+		return err
+	}
+	x.stats.Symlinks++
+	return nil
+}
+
+// checkLink verifies that the target of the symlink rel resolves inside dest, given the entries
+// already extracted. The resolution of ".." depends on the symlinks on the way, so the target
+// must not go through a symlink, and must not climb out of a directory not extracted yet, which
+// could be a symlink created afterwards. It may end with a symlink, which stays inside dest.
+func (x *extractor) checkLink(rel, linkname string) error {
+	var elems []string
+	for _, elem := range strings.Split(linkname, "/") {
+		if elem != "" && elem != "." {
+			elems = append(elems, elem)
+		}
+	}
+	// The parents of rel are real directories, see parent
+	var resolved []string
+	if dir := path.Dir(rel); dir != "." {
+		resolved = strings.Split(dir, "/")
+	}
+	exists := true // Whether the last element of resolved exists
+	for i, elem := range elems {
+		if elem == ".." {
+			if len(resolved) == 0 || !exists {
+				return fmt.Errorf("%w: symlink to %q", ErrUnsafePath, linkname)
+			}
+			resolved = resolved[:len(resolved)-1]
+			exists = true
+			continue
+		}
+		resolved = append(resolved, elem)
+		if !exists {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(x.dest, filepath.FromSlash(strings.Join(resolved, "/"))))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			exists = false
+		case err != nil:
+			return err
+		case info.Mode()&fs.ModeSymlink != 0 && i < len(elems)-1:
+			return fmt.Errorf("%w: symlink to %q goes through symlink %s", ErrUnsafePath, linkname, strings.Join(resolved, "/"))
+		}
+	}
+	return nil
+}
+
+// hardlink links an entry to a regular file already extracted
+func (x *extractor) hardlink(name, linkname string) error {
+	source, err := x.entryPath(linkname)
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		return fmt.Errorf("%w: hard link to %q", ErrUnsafePath, linkname)
+	}
+	if err = x.parent(source); err != nil {
+		return err
+	}
+	sourcePath := filepath.Join(x.dest, filepath.FromSlash(source))
+	info, err := os.Lstat(sourcePath)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: hard link to %q", ErrUnsafePath, linkname)
+	}
+	_, target, err := x.prepare(name)
+	if err != nil || target == "" {
+		return err
+	}
+	if err = os.Link(sourcePath, target); err != nil {
+		return err
+	}
+	x.stats.Links++
+	return nil
+}
+
+// ctxReader stops reading once its context is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
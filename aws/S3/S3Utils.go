@@ -157,7 +157,7 @@ func ListBucketObjectsDetails(bucket, prefix string) ([]types.Object, error) {
 
 	continuationToken := objects.NextContinuationToken
 	truncated := objects.IsTruncated
-	for truncated {
+	for aws.ToBool(truncated) {
 		newObjects, err := S3Client.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
 			Bucket:            aws.String(bucket),
 			Prefix:            aws.String(prefix),
@@ -173,7 +173,7 @@ func ListBucketObjectsDetails(bucket, prefix string) ([]types.Object, error) {
 	}
 
 	sort.Slice(buckets, func(i, j int) bool {
-		return buckets[i].LastModified.Before(*buckets[i].LastModified)
+		return buckets[i].LastModified.Before(*buckets[j].LastModified)
 	})
 	return buckets, nil
 }
@@ -370,7 +370,7 @@ func SyncAfterDate(bucket, prefix, localPath string, date time.Time) error {
 
 // ParseS3Path is delegated to return bucket name and filename of a given s3 path
 func ParseS3Path(p string) (string, string) {
-	p = strings.TrimLeft(p, "s3://")
+	p = strings.TrimPrefix(p, "s3://")
 	split := strings.Split(p, "/")
 	return split[0], path.Clean(strings.Join(split[1:], "/"))
 }
@@ -0,0 +1,116 @@
+package S3utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/seidu626/go-buildingblocks/keyring"
+)
+
+// KeyringMetadata is the metadata of the objects of PutObjectEncrypted holding their wrapped data key
+const KeyringMetadata = "keyring-key"
+
+// ErrNotEncrypted is returned when an object has no wrapped data key in its metadata
+var ErrNotEncrypted = errors.New("s3: object not encrypted with a keyring")
+
+// PutObjectEncrypted uploads the content of stream encrypted client-side by kr, streaming it
+// in parts. The wrapped data key is stored in the metadata of the object: the object can be
+// copied or moved, and its key rewrapped by RewrapObject without downloading it.
+func PutObjectEncrypted(ctx context.Context, bucket, filename string, stream io.Reader, kr *keyring.Keyring, contentType *string) error {
+	pr, pw := io.Pipe()
+	w, wrapped, err := kr.EncryptWriter(ctx, pw, nil)
+	if err != nil {
+		return err
+	}
+	go func() {
+		_, err := io.Copy(w, stream)
+		if err == nil {
+			err = w.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	uploader := manager.NewUploader(S3Client)
+	uploader.Concurrency = 10
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(filename),
+		Body:        pr,
+		ContentType: contentType,
+		Metadata:    map[string]string{KeyringMetadata: wrapped.String()},
+	})
+	// Unblocks the encryption when the upload failed
+	_ = pr.CloseWithError(err)
+	return err
+}
+
+// GetObjectDecrypted returns the decrypted content of an object of PutObjectEncrypted. The
+// body must be closed, Read fails when the object was modified or truncated.
+func GetObjectDecrypted(ctx context.Context, bucket, filename string, kr *keyring.Keyring) (io.ReadCloser, error) {
+	out, err := S3Client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(filename)})
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := objectKey(out.Metadata)
+	if err != nil {
+		out.Body.Close()
+		return nil, fmt.Errorf("%s: %w", path.Join(bucket, filename), err)
+	}
+	r, err := kr.DecryptReader(ctx, out.Body, wrapped, nil)
+	if err != nil {
+		out.Body.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, out.Body}, nil
+}
+
+// RewrapObject wraps the data key of an object of PutObjectEncrypted by the current master key
+// of kr, e.g. after a rotation, by copying the object on itself with the new metadata. The
+// content is not downloaded, but a copy is limited to objects of 5 GB.
+func RewrapObject(ctx context.Context, bucket, filename string, kr *keyring.Keyring) error {
+	head, err := HeadObject(bucket, filename)
+	if err != nil {
+		return err
+	}
+	wrapped, err := objectKey(head.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path.Join(bucket, filename), err)
+	}
+	rewrapped, err := kr.RewrapKey(ctx, wrapped)
+	if err != nil {
+		return err
+	}
+	metadata := make(map[string]string, len(head.Metadata))
+	for k, v := range head.Metadata {
+		metadata[k] = v
+	}
+	metadata[KeyringMetadata] = rewrapped.String()
+	_, err = S3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		CopySource:        aws.String(path.Join(bucket, filename)),
+		Key:               aws.String(filename),
+		ContentType:       head.ContentType,
+		Metadata:          metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	})
+	return err
+}
+
+// objectKey returns the wrapped data key in the metadata of an object
+func objectKey(metadata map[string]string) (keyring.WrappedKey, error) {
+	value, ok := metadata[KeyringMetadata]
+	if !ok {
+		return keyring.WrappedKey{}, ErrNotEncrypted
+	}
+	return keyring.ParseWrappedKey(value)
+}
@@ -33,8 +33,8 @@ func GetBuildStatus(pipelineName string, max int) ([]types.PipelineExecutionSumm
 		return executions, nil
 	}
 
-	for tmp.NextToken != nil || len(executions) >= max {
-		tmp, err = codepClient.ListPipelineExecutions(context.Background(), &codepipeline.ListPipelineExecutionsInput{PipelineName: aws.String(pipelineName), MaxResults: aws.Int32(int32(max - len(executions)))})
+	for tmp.NextToken != nil && len(executions) < max {
+		tmp, err = codepClient.ListPipelineExecutions(context.Background(), &codepipeline.ListPipelineExecutionsInput{PipelineName: aws.String(pipelineName), MaxResults: aws.Int32(int32(max - len(executions))), NextToken: tmp.NextToken})
 		if err != nil {
 			return nil, err
 		}
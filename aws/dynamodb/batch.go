@@ -0,0 +1,261 @@
+package dynamodbutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/seidu626/go-buildingblocks/aws/internal/retry"
+)
+
+// maxBatchWriteItems is the maximum number of requests of a BatchWriteItem call.
+const maxBatchWriteItems = 25
+
+// BatchWriteAPI is the subset of the DynamoDB client used by BatchWriter. It is satisfied by *dynamodb.Client.
+type BatchWriteAPI interface {
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+var _ BatchWriteAPI = (*dynamodb.Client)(nil)
+
+// ErrUnprocessedItems is returned when some items are still unprocessed after all the attempts.
+var ErrUnprocessedItems = errors.New("dynamodb: unprocessed items")
+
+// BatchWriterConfig holds the configuration of a BatchWriter.
+type BatchWriterConfig struct {
+	Table       string
+	MaxAttempts int           // Attempts per batch, including the first one (default 8)
+	MinBackoff  time.Duration // Delay before retrying unprocessed items (default 50ms)
+	MaxBackoff  time.Duration // Upper bound of the retry delay (default 5s)
+
+	// WriteCapacity caps the write capacity units consumed per second, 0 for no limit.
+	WriteCapacity float64
+}
+
+// BatchWriter writes items in batches of 25, retrying unprocessed items with a jittered
+// exponential backoff and throttling the writes to the configured capacity budget.
+// It is safe for concurrent use.
+type BatchWriter struct {
+	client  BatchWriteAPI
+	config  BatchWriterConfig
+	limiter *capacityLimiter
+}
+
+// NewBatchWriter creates a BatchWriter for the configured table.
+func NewBatchWriter(client BatchWriteAPI, cfg BatchWriterConfig) (*BatchWriter, error) {
+	if client == nil {
+		return nil, errors.New("dynamodb: client is required")
+	}
+	if cfg.Table == "" {
+		return nil, errors.New("dynamodb: table name is required")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 50 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Second
+	}
+	writer := &BatchWriter{client: client, config: cfg}
+	if cfg.WriteCapacity > 0 {
+		writer.limiter = newCapacityLimiter(cfg.WriteCapacity)
+	}
+	return writer, nil
+}
+
+// Put writes the items, marshalled with attributevalue.MarshalMap.
+func (w *BatchWriter) Put(ctx context.Context, items ...interface{}) error {
+	requests := make([]types.WriteRequest, len(items))
+	for i := range items {
+		item, err := attributevalue.MarshalMap(items[i])
+		if err != nil {
+			return fmt.Errorf("marshalling item %d: %w", i, err)
+		}
+		requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+	}
+	return w.Write(ctx, requests)
+}
+
+// Delete deletes the items identified by the keys.
+func (w *BatchWriter) Delete(ctx context.Context, keys ...map[string]types.AttributeValue) error {
+	requests := make([]types.WriteRequest, len(keys))
+	for i := range keys {
+		requests[i] = types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: keys[i]}}
+	}
+	return w.Write(ctx, requests)
+}
+
+// Write executes the write requests in batches of 25. It stops at the first batch whose
+// items cannot all be processed, returning an error wrapping ErrUnprocessedItems.
+func (w *BatchWriter) Write(ctx context.Context, requests []types.WriteRequest) error {
+	for start := 0; start < len(requests); start += maxBatchWriteItems {
+		end := min(start+maxBatchWriteItems, len(requests))
+		if err := w.writeBatch(ctx, requests[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *BatchWriter) writeBatch(ctx context.Context, batch []types.WriteRequest) error {
+	pending := batch
+	for attempt := 1; ; attempt++ {
+		if w.limiter != nil {
+			if err := w.limiter.wait(ctx, estimateCapacity(pending)); err != nil {
+				return err
+			}
+		}
+		out, err := w.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems:           map[string][]types.WriteRequest{w.config.Table: pending},
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		})
+		if err != nil {
+			var throughputErr *types.ProvisionedThroughputExceededException
+			if !errors.As(err, &throughputErr) || attempt >= w.config.MaxAttempts {
+				return err
+			}
+		} else {
+			if w.limiter != nil {
+				w.limiter.adjust(estimateCapacity(pending), consumedCapacity(out.ConsumedCapacity))
+			}
+			pending = out.UnprocessedItems[w.config.Table]
+			if len(pending) == 0 {
+				return nil
+			}
+			if attempt >= w.config.MaxAttempts {
+				return fmt.Errorf("%w: %d of %d after %d attempts", ErrUnprocessedItems, len(pending), len(batch), attempt)
+			}
+		}
+		if !retry.Sleep(ctx, retry.Backoff(attempt, w.config.MinBackoff, w.config.MaxBackoff)) {
+			return ctx.Err()
+		}
+	}
+}
+
+// estimateCapacity estimates the write capacity units needed by the requests: one unit per KB
+// of each put item, one unit per delete.
+func estimateCapacity(requests []types.WriteRequest) float64 {
+	units := 0.0
+	for _, request := range requests {
+		if request.PutRequest != nil {
+			units += math.Ceil(float64(itemSize(request.PutRequest.Item)) / 1024)
+		} else {
+			units++
+		}
+	}
+	return units
+}
+
+func consumedCapacity(consumed []types.ConsumedCapacity) float64 {
+	units := 0.0
+	for _, c := range consumed {
+		units += aws.ToFloat64(c.CapacityUnits)
+	}
+	return units
+}
+
+// itemSize approximates the size of an item as accounted by DynamoDB.
+func itemSize(item map[string]types.AttributeValue) int {
+	size := 0
+	for name, av := range item {
+		size += len(name) + attributeSize(av)
+	}
+	return size
+}
+
+func attributeSize(av types.AttributeValue) int {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return len(v.Value)/2 + 1
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberBOOL, *types.AttributeValueMemberNULL:
+		return 1
+	case *types.AttributeValueMemberSS:
+		size := 0
+		for _, s := range v.Value {
+			size += len(s)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		size := 0
+		for _, n := range v.Value {
+			size += len(n)/2 + 1
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		size := 0
+		for _, b := range v.Value {
+			size += len(b)
+		}
+		return size
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, e := range v.Value {
+			size += 1 + attributeSize(e)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(v.Value)
+	}
+	return 0
+}
+
+// capacityLimiter is a token bucket of capacity units, refilled at a fixed rate per second.
+type capacityLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newCapacityLimiter(rate float64) *capacityLimiter {
+	return &capacityLimiter{rate: rate, tokens: rate, last: time.Now()}
+}
+
+func (l *capacityLimiter) refill() {
+	now := time.Now()
+	l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// wait blocks until units are available, then takes them. Requests larger than the
+// bucket only wait for it to be full, and leave it in debt.
+func (l *capacityLimiter) wait(ctx context.Context, units float64) error {
+	for {
+		l.mu.Lock()
+		l.refill()
+		need := math.Min(units, l.rate)
+		if l.tokens >= need {
+			l.tokens -= units
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		if !retry.Sleep(ctx, delay) {
+			return ctx.Err()
+		}
+	}
+}
+
+// adjust corrects the bucket with the capacity actually consumed by a request.
+func (l *capacityLimiter) adjust(estimated, consumed float64) {
+	if consumed <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.tokens -= consumed - estimated
+	l.mu.Unlock()
+}
@@ -0,0 +1,233 @@
+package dynamodbutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBatchClient stores the written items and leaves the last `unprocessed` requests of every
+// first attempt unprocessed
+type fakeBatchClient struct {
+	mu          sync.Mutex
+	unprocessed int
+	always      bool
+	calls       []int
+	items       map[string]map[string]types.AttributeValue
+}
+
+func (f *fakeBatchClient) BatchWriteItem(_ context.Context, params *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}
+	for table, requests := range params.RequestItems {
+		f.calls = append(f.calls, len(requests))
+		processed := requests
+		if f.unprocessed > 0 && (f.always || len(requests) > f.unprocessed) {
+			cut := max(len(requests)-f.unprocessed, 0)
+			processed, out.UnprocessedItems[table] = requests[:cut], requests[cut:]
+		}
+		for _, request := range processed {
+			if request.PutRequest != nil {
+				id := request.PutRequest.Item["id"].(*types.AttributeValueMemberS).Value
+				f.items[id] = request.PutRequest.Item
+			} else {
+				delete(f.items, request.DeleteRequest.Key["id"].(*types.AttributeValueMemberS).Value)
+			}
+		}
+	}
+	return out, nil
+}
+
+// fakeScanClient serves the items of a table split in segments, two items per page
+type fakeScanClient struct {
+	items []map[string]types.AttributeValue
+	fail  bool
+}
+
+func (f *fakeScanClient) Scan(_ context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if f.fail {
+		return nil, errors.New("throttled")
+	}
+	segment, total := aws.ToInt32(params.Segment), max(aws.ToInt32(params.TotalSegments), 1)
+	var segmentItems []map[string]types.AttributeValue
+	for i, item := range f.items {
+		if int32(i)%total == segment {
+			segmentItems = append(segmentItems, item)
+		}
+	}
+	start := 0
+	if params.ExclusiveStartKey != nil {
+		start, _ = strconv.Atoi(params.ExclusiveStartKey["offset"].(*types.AttributeValueMemberN).Value)
+	}
+	end := min(start+2, len(segmentItems))
+	out := &dynamodb.ScanOutput{Items: segmentItems[start:end]}
+	if end < len(segmentItems) {
+		out.LastEvaluatedKey = map[string]types.AttributeValue{"offset": &types.AttributeValueMemberN{Value: strconv.Itoa(end)}}
+	}
+	return out, nil
+}
+
+type record struct {
+	ID    string `dynamodbav:"id"`
+	Value int    `dynamodbav:"value"`
+}
+
+func TestBatchWriterChunksAndRetriesUnprocessed(t *testing.T) {
+	client := &fakeBatchClient{unprocessed: 3, items: map[string]map[string]types.AttributeValue{}}
+	writer, err := NewBatchWriter(client, BatchWriterConfig{Table: "records", MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	require.NoError(t, err)
+
+	var items []interface{}
+	for i := 0; i < 60; i++ {
+		items = append(items, record{ID: fmt.Sprintf("r%d", i), Value: i})
+	}
+	require.NoError(t, writer.Put(context.Background(), items...))
+	assert.Len(t, client.items, 60)
+	assert.Equal(t, []int{25, 3, 25, 3, 10, 3}, client.calls)
+
+	_, err = NewBatchWriter(client, BatchWriterConfig{})
+	assert.Error(t, err)
+}
+
+func TestBatchWriterGivesUp(t *testing.T) {
+	client := &fakeBatchClient{unprocessed: 1, always: true, items: map[string]map[string]types.AttributeValue{}}
+	writer, err := NewBatchWriter(client, BatchWriterConfig{Table: "records", MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	require.NoError(t, err)
+
+	err = writer.Put(context.Background(), record{ID: "a"}, record{ID: "b"})
+	assert.ErrorIs(t, err, ErrUnprocessedItems)
+	assert.Equal(t, []int{2, 1, 1}, client.calls)
+}
+
+func TestBatchWriterRespectsCapacity(t *testing.T) {
+	client := &fakeBatchClient{items: map[string]map[string]types.AttributeValue{}}
+	writer, err := NewBatchWriter(client, BatchWriterConfig{Table: "records", WriteCapacity: 250})
+	require.NoError(t, err)
+
+	keys := make([]map[string]types.AttributeValue, 500)
+	for i := range keys {
+		keys[i] = map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: strconv.Itoa(i)}}
+	}
+	start := time.Now()
+	require.NoError(t, writer.Delete(context.Background(), keys...))
+	// The first 250 units are available immediately, the other 250 take a second
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+}
+
+func TestItemSize(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"id":   &types.AttributeValueMemberS{Value: strings.Repeat("x", 2000)},
+		"tags": &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+	}
+	assert.Equal(t, 2+2000+4+2, itemSize(item))
+	assert.Equal(t, 2.0, estimateCapacity([]types.WriteRequest{{PutRequest: &types.PutRequest{Item: item}}}))
+}
+
+func scanItems(n int) []map[string]types.AttributeValue {
+	items := make([]map[string]types.AttributeValue, n)
+	for i := range items {
+		items[i] = map[string]types.AttributeValue{
+			"id":    &types.AttributeValueMemberS{Value: fmt.Sprintf("r%d", i)},
+			"value": &types.AttributeValueMemberN{Value: strconv.Itoa(i)},
+		}
+	}
+	return items
+}
+
+func TestParallelScan(t *testing.T) {
+	client := &fakeScanClient{items: scanItems(21)}
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	segments := make(map[int]bool)
+	err := ParallelScan(context.Background(), client, "records", 4, func(ctx context.Context, segment int, items []map[string]types.AttributeValue) error {
+		mu.Lock()
+		defer mu.Unlock()
+		segments[segment] = true
+		for _, item := range items {
+			seen[item["id"].(*types.AttributeValueMemberS).Value]++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, seen, 21)
+	assert.Len(t, segments, 4)
+
+	table, err := NewTable[record](&fakeTableClient{fakeScanClient: client}, TableConfig{Name: "records", PartitionKey: "id"})
+	require.NoError(t, err)
+	var sum int
+	err = table.ParallelScan(context.Background(), ScanRequest{}, 3, func(ctx context.Context, items []record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, item := range items {
+			sum += item.Value
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 210, sum)
+}
+
+func TestParallelScanReturnsErrors(t *testing.T) {
+	err := ParallelScan(context.Background(), &fakeScanClient{fail: true}, "records", 3, func(context.Context, int, []map[string]types.AttributeValue) error {
+		return nil
+	})
+	assert.ErrorContains(t, err, "throttled")
+
+	stop := errors.New("stop")
+	err = ParallelScan(context.Background(), &fakeScanClient{items: scanItems(10)}, "records", 2, func(context.Context, int, []map[string]types.AttributeValue) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestExportImportJSONL(t *testing.T) {
+	items := scanItems(30)
+	items[0]["nested"] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+		"list":  &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberBOOL{Value: false}, &types.AttributeValueMemberNULL{Value: true}}},
+		"empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+		"bin":   &types.AttributeValueMemberB{Value: []byte{1, 2, 3}},
+		"nums":  &types.AttributeValueMemberNS{Value: []string{"1", "2.5"}},
+	}}
+
+	var buf bytes.Buffer
+	exported, err := ExportJSONL(context.Background(), &fakeScanClient{items: items}, "records", &buf, 3)
+	require.NoError(t, err)
+	assert.EqualValues(t, 30, exported)
+	assert.Equal(t, 30, strings.Count(buf.String(), "\n"))
+
+	client := &fakeBatchClient{items: map[string]map[string]types.AttributeValue{}}
+	writer, err := NewBatchWriter(client, BatchWriterConfig{Table: "restored"})
+	require.NoError(t, err)
+	imported, err := ImportJSONL(context.Background(), writer, &buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, 30, imported)
+	assert.Equal(t, []int{25, 5}, client.calls)
+	assert.Equal(t, items[0], client.items["r0"])
+
+	_, err = ImportJSONL(context.Background(), writer, strings.NewReader(`{"Item":{"id":{}}}`))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+// fakeTableClient is a Table API only supporting scans
+type fakeTableClient struct {
+	API
+	*fakeScanClient
+}
+
+func (f *fakeTableClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return f.fakeScanClient.Scan(ctx, params, optFns...)
+}
@@ -2,20 +2,18 @@ package dynamodbutils
 
 import (
 	"context"
-	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/pkg/errors"
 	"github.com/schollz/progressbar/v3"
-	arrayutils "github.com/seidu626/go-buildingblocks/array"
 	awsutils "github.com/seidu626/go-buildingblocks/aws"
-	"github.com/seidu626/go-buildingblocks/helper"
 	stringutils "github.com/seidu626/go-buildingblocks/string"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -23,14 +21,6 @@ import (
 var dynamoClient *dynamodb.Client = nil
 var once sync.Once
 
-type UpdateType string
-
-type Update struct {
-	Type  UpdateType `json:"type,omitempty"`
-	Key   string     `json:"key,omitempty"`
-	Value string     `json:"value,omitempty"`
-}
-
 var RETRY_ATTEMPT int64 = 1
 
 func init() {
@@ -79,7 +69,6 @@ func WriteItem(tableName string, item interface{}) error {
 	if err != nil {
 		return err
 	}
-	fmt.Println(helper.MarshalIndent(av))
 	_, err = dynamoClient.PutItem(context.TODO(), &dynamodb.PutItemInput{
 		TableName: aws.String(tableName),
 		Item:      av,
@@ -88,36 +77,15 @@ func WriteItem(tableName string, item interface{}) error {
 	return err
 }
 
+// WriteBatchItem writes the items in batches of 25, retrying the unprocessed ones up to DYNAMO_RETRY times.
 func WriteBatchItem(tableName string, items []interface{}) error {
-	var writeReqs []types.WriteRequest
-	for i := range items {
-		item, err := attributevalue.MarshalMap(items[i])
-		if err != nil {
-			panic(err)
-		}
-		writeReqs = append(writeReqs, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
-	}
-	requestItems := map[string][]types.WriteRequest{tableName: writeReqs}
-	result, err := dynamoClient.BatchWriteItem(context.Background(), &dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]types.WriteRequest{tableName: writeReqs},
-	})
+	writer, err := NewBatchWriter(dynamoClient, BatchWriterConfig{Table: tableName, MaxAttempts: int(RETRY_ATTEMPT) + 1})
 	if err != nil {
-		for i := 0; err != nil; i++ {
-			if i > int(RETRY_ATTEMPT) {
-				return err
-			}
-			time.Sleep(time.Second * time.Duration(i+1))
-			if result != nil && len(result.UnprocessedItems) != 0 {
-				requestItems = result.UnprocessedItems
-			}
-
-			result, err = dynamoClient.BatchWriteItem(context.Background(), &dynamodb.BatchWriteItemInput{
-				RequestItems: requestItems,
-			})
-		}
+		return err
 	}
-	return err
+	return writer.Put(context.Background(), items...)
 }
+
 func DeleteTable(tableName string) error {
 	_, err := dynamoClient.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{
 		TableName: aws.String(tableName),
@@ -147,6 +115,9 @@ func GetDocument(tableName string, documentQuery map[string]types.AttributeValue
 	return doc, err
 }
 
+// ScanAsync sends the pages of the table to ch, then closes it.
+//
+// Deprecated: scan errors are only logged and stop the scan; use ParallelScan, which returns them.
 func ScanAsync(tableName, projectExpression string, ch chan<- []map[string]types.AttributeValue, bar *progressbar.ProgressBar) {
 	defer close(ch)
 	scan, err := dynamoClient.Scan(context.Background(), &dynamodb.ScanInput{
@@ -155,7 +126,8 @@ func ScanAsync(tableName, projectExpression string, ch chan<- []map[string]types
 		Limit:                aws.Int32(1000),
 	})
 	if err != nil {
-		panic(err)
+		log.Println("ERROR! Scan of", tableName, "failed:", err)
+		return
 	}
 	ch <- scan.Items
 
@@ -167,77 +139,57 @@ func ScanAsync(tableName, projectExpression string, ch chan<- []map[string]types
 			Limit:                aws.Int32(1000),
 		})
 		if err != nil {
-			panic(err)
+			log.Println("ERROR! Scan of", tableName, "failed:", err)
+			return
 		}
 		bar.ChangeMax(bar.GetMax() + len(scan.Items))
 		ch <- scan.Items
 	}
 }
 
+// DeleteAllItems deletes every item of the table. projectExpression lists the key attributes of the
+// table (e.g. "pk, sk"), so that only the keys are read by the parallel scan.
 func DeleteAllItems(tableName, projectExpression string) (*string, error) {
-	bar := progressbar.Default(1)
-	n := 10
-	buffer := make(chan []map[string]types.AttributeValue, n)
-	done := make(chan bool, n)
-	go ScanAsync(tableName, projectExpression, buffer, bar)
-	for i := 0; i < n; i++ {
-		go DeleteItems(tableName, buffer, bar, done)
+	var projection []string
+	for _, attribute := range strings.Split(projectExpression, ",") {
+		if attribute = strings.TrimSpace(attribute); attribute != "" {
+			projection = append(projection, attribute)
+		}
 	}
-	for i := 0; i < n; i++ {
-		<-done
+	writer, err := NewBatchWriter(dynamoClient, BatchWriterConfig{Table: tableName, MaxAttempts: int(RETRY_ATTEMPT) + 1})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, nil
+	bar := progressbar.Default(-1)
+	defer bar.Close()
+	err = parallelScan(context.Background(), dynamoClient, tableName, ScanRequest{Projection: projection}, 10,
+		func(ctx context.Context, _ int, items []map[string]types.AttributeValue) error {
+			if err := writer.Delete(ctx, items...); err != nil {
+				return err
+			}
+			return bar.Add(len(items))
+		})
+	return nil, err
 }
 
+// DeleteItems deletes the items whose keys are received from datas, then signals done.
+//
+// Deprecated: delete errors are only logged; use BatchWriter.Delete.
 func DeleteItems(tableName string, datas <-chan []map[string]types.AttributeValue, bar *progressbar.ProgressBar, done chan bool) {
-	for data := range datas {
-		data0, dataN := arrayutils.SplitEqual(data, 25)
-		var writeReqs []types.WriteRequest = nil
-		for i := range data0 {
-			writeReqs = make([]types.WriteRequest, 0, len(data0[i]))
-			for k := range data0[i] {
-				writeReqs = append(writeReqs, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: data0[i][k]}})
-			}
-			requestItems := map[string][]types.WriteRequest{tableName: writeReqs}
-			if _, err := dynamoClient.BatchWriteItem(context.Background(), &dynamodb.BatchWriteItemInput{RequestItems: requestItems}); err != nil {
-				panic(err)
-			}
-			bar.Add(25)
-		}
-
-		writeReqs = make([]types.WriteRequest, 0, len(dataN))
-		for i := range dataN {
-			writeReqs = append(writeReqs, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: dataN[i]}})
+	defer func() { done <- true }()
+	writer, err := NewBatchWriter(dynamoClient, BatchWriterConfig{Table: tableName, MaxAttempts: int(RETRY_ATTEMPT) + 1})
+	if err != nil {
+		log.Println("ERROR! Delete from", tableName, "failed:", err)
+		for range datas {
 		}
-		requestItems := map[string][]types.WriteRequest{tableName: writeReqs}
-		if _, err := dynamoClient.BatchWriteItem(context.Background(), &dynamodb.BatchWriteItemInput{RequestItems: requestItems}); err != nil {
-			panic(err)
+		return
+	}
+	for data := range datas {
+		if err = writer.Delete(context.Background(), data...); err != nil {
+			log.Println("ERROR! Delete from", tableName, "failed:", err)
+			continue
 		}
-		bar.Add(len(dataN))
+		_ = bar.Add(len(data))
 	}
-	done <- true
 }
-
-//func UpdateItem(tableName string, key map[string]types.AttributeValue, set map[string]string) error {
-//	dynamoClient, err := New()
-//	if err != nil {
-//		return err
-//	}
-//
-//	dynamoClient.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
-//		Key:                         key,
-//		TableName:                   aws.String(tableName),
-//		AttributeUpdates:            nil,
-//		ConditionExpression:         nil,
-//		ConditionalOperator:         "",
-//		Expected:                    nil,
-//		ExpressionAttributeNames:    nil,
-//		ExpressionAttributeValues:   nil,
-//		ReturnConsumedCapacity:      "",
-//		ReturnItemCollectionMetrics: "",
-//		ReturnValues:                "",
-//		UpdateExpression:            nil,
-//	})
-//	return nil
-//}
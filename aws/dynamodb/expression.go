@@ -0,0 +1,298 @@
+package dynamodbutils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// expressionBuilder allocates the placeholders of the expressions of a single request,
+// so that condition, key, filter, projection and update expressions never collide.
+type expressionBuilder struct {
+	names     map[string]string
+	values    map[string]types.AttributeValue
+	nameIndex map[string]string
+	err       error
+}
+
+func newExpressionBuilder() *expressionBuilder {
+	return &expressionBuilder{
+		names:     make(map[string]string),
+		values:    make(map[string]types.AttributeValue),
+		nameIndex: make(map[string]string),
+	}
+}
+
+// name returns the placeholder of an attribute path. Nested paths ("address.city") and
+// list elements ("items[0]") are supported.
+func (b *expressionBuilder) name(path string) string {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		base, suffix := part, ""
+		if j := strings.IndexByte(part, '['); j >= 0 {
+			base, suffix = part[:j], part[j:]
+		}
+		placeholder, ok := b.nameIndex[base]
+		if !ok {
+			placeholder = fmt.Sprintf("#n%d", len(b.nameIndex))
+			b.nameIndex[base] = placeholder
+			b.names[placeholder] = base
+		}
+		parts[i] = placeholder + suffix
+	}
+	return strings.Join(parts, ".")
+}
+
+// value marshals v and returns its placeholder.
+func (b *expressionBuilder) value(v interface{}) string {
+	av, err := attributevalue.Marshal(v)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("marshalling expression value: %w", err)
+	}
+	placeholder := fmt.Sprintf(":v%d", len(b.values))
+	b.values[placeholder] = av
+	return placeholder
+}
+
+// projection returns the projection expression of the given attributes.
+func (b *expressionBuilder) projection(attributes []string) *string {
+	if len(attributes) == 0 {
+		return nil
+	}
+	names := make([]string, len(attributes))
+	for i := range attributes {
+		names[i] = b.name(attributes[i])
+	}
+	expr := strings.Join(names, ", ")
+	return &expr
+}
+
+func (b *expressionBuilder) condition(c Condition) *string {
+	if c.IsZero() {
+		return nil
+	}
+	expr := c.build(b)
+	return &expr
+}
+
+func (b *expressionBuilder) attributeNames() map[string]string {
+	if len(b.names) == 0 {
+		return nil
+	}
+	return b.names
+}
+
+func (b *expressionBuilder) attributeValues() map[string]types.AttributeValue {
+	if len(b.values) == 0 {
+		return nil
+	}
+	return b.values
+}
+
+// Condition is a condition, key condition or filter expression. The zero value is an empty condition.
+type Condition struct {
+	build func(b *expressionBuilder) string
+}
+
+// IsZero reports whether the condition is empty.
+func (c Condition) IsZero() bool {
+	return c.build == nil
+}
+
+func compare(name, operator string, value interface{}) Condition {
+	return Condition{build: func(b *expressionBuilder) string {
+		return b.name(name) + " " + operator + " " + b.value(value)
+	}}
+}
+
+// Equal matches items where the attribute equals value.
+func Equal(name string, value interface{}) Condition { return compare(name, "=", value) }
+
+// NotEqual matches items where the attribute differs from value.
+func NotEqual(name string, value interface{}) Condition { return compare(name, "<>", value) }
+
+// LessThan matches items where the attribute is lower than value.
+func LessThan(name string, value interface{}) Condition { return compare(name, "<", value) }
+
+// LessThanEqual matches items where the attribute is lower than or equal to value.
+func LessThanEqual(name string, value interface{}) Condition { return compare(name, "<=", value) }
+
+// GreaterThan matches items where the attribute is greater than value.
+func GreaterThan(name string, value interface{}) Condition { return compare(name, ">", value) }
+
+// GreaterThanEqual matches items where the attribute is greater than or equal to value.
+func GreaterThanEqual(name string, value interface{}) Condition { return compare(name, ">=", value) }
+
+// Between matches items where the attribute is within [lower, upper].
+func Between(name string, lower, upper interface{}) Condition {
+	return Condition{build: func(b *expressionBuilder) string {
+		return b.name(name) + " BETWEEN " + b.value(lower) + " AND " + b.value(upper)
+	}}
+}
+
+// In matches items where the attribute equals one of the values.
+func In(name string, values ...interface{}) Condition {
+	return Condition{build: func(b *expressionBuilder) string {
+		placeholders := make([]string, len(values))
+		for i := range values {
+			placeholders[i] = b.value(values[i])
+		}
+		return b.name(name) + " IN (" + strings.Join(placeholders, ", ") + ")"
+	}}
+}
+
+// BeginsWith matches items where the attribute starts with prefix.
+func BeginsWith(name, prefix string) Condition {
+	return Condition{build: func(b *expressionBuilder) string {
+		return "begins_with(" + b.name(name) + ", " + b.value(prefix) + ")"
+	}}
+}
+
+// Contains matches items where the string attribute contains value, or the set/list attribute has value as element.
+func Contains(name string, value interface{}) Condition {
+	return Condition{build: func(b *expressionBuilder) string {
+		return "contains(" + b.name(name) + ", " + b.value(value) + ")"
+	}}
+}
+
+// AttributeExists matches items having the attribute.
+func AttributeExists(name string) Condition {
+	return Condition{build: func(b *expressionBuilder) string {
+		return "attribute_exists(" + b.name(name) + ")"
+	}}
+}
+
+// AttributeNotExists matches items without the attribute.
+func AttributeNotExists(name string) Condition {
+	return Condition{build: func(b *expressionBuilder) string {
+		return "attribute_not_exists(" + b.name(name) + ")"
+	}}
+}
+
+func join(operator string, conditions []Condition) Condition {
+	var nonZero []Condition
+	for _, c := range conditions {
+		if !c.IsZero() {
+			nonZero = append(nonZero, c)
+		}
+	}
+	switch len(nonZero) {
+	case 0:
+		return Condition{}
+	case 1:
+		return nonZero[0]
+	}
+	return Condition{build: func(b *expressionBuilder) string {
+		parts := make([]string, len(nonZero))
+		for i := range nonZero {
+			parts[i] = "(" + nonZero[i].build(b) + ")"
+		}
+		return strings.Join(parts, " "+operator+" ")
+	}}
+}
+
+// And matches items satisfying all the conditions. Empty conditions are ignored.
+func And(conditions ...Condition) Condition { return join("AND", conditions) }
+
+// Or matches items satisfying at least one of the conditions. Empty conditions are ignored.
+func Or(conditions ...Condition) Condition { return join("OR", conditions) }
+
+// Not negates the condition.
+func Not(c Condition) Condition {
+	if c.IsZero() {
+		return c
+	}
+	return Condition{build: func(b *expressionBuilder) string {
+		return "NOT (" + c.build(b) + ")"
+	}}
+}
+
+// Update builds an update expression.
+//
+//	update := NewUpdate().Set("status", "shipped").Add("attempts", 1).Remove("lock")
+type Update struct {
+	set    []func(b *expressionBuilder) string
+	remove []func(b *expressionBuilder) string
+	add    []func(b *expressionBuilder) string
+	delete []func(b *expressionBuilder) string
+}
+
+// NewUpdate returns an empty update.
+func NewUpdate() *Update {
+	return &Update{}
+}
+
+// Set sets the attribute to value.
+func (u *Update) Set(name string, value interface{}) *Update {
+	u.set = append(u.set, func(b *expressionBuilder) string {
+		return b.name(name) + " = " + b.value(value)
+	})
+	return u
+}
+
+// SetIfNotExists sets the attribute to value only when the attribute is missing.
+func (u *Update) SetIfNotExists(name string, value interface{}) *Update {
+	u.set = append(u.set, func(b *expressionBuilder) string {
+		placeholder := b.name(name)
+		return placeholder + " = if_not_exists(" + placeholder + ", " + b.value(value) + ")"
+	})
+	return u
+}
+
+// Append appends the values to the list attribute, creating it when missing.
+func (u *Update) Append(name string, values ...interface{}) *Update {
+	u.set = append(u.set, func(b *expressionBuilder) string {
+		placeholder := b.name(name)
+		return placeholder + " = list_append(if_not_exists(" + placeholder + ", " + b.value([]interface{}{}) + "), " + b.value(values) + ")"
+	})
+	return u
+}
+
+// Remove removes the attribute from the item.
+func (u *Update) Remove(name string) *Update {
+	u.remove = append(u.remove, func(b *expressionBuilder) string {
+		return b.name(name)
+	})
+	return u
+}
+
+// Add adds value to a number attribute (missing attributes count as 0) or the elements of value to a set attribute.
+func (u *Update) Add(name string, value interface{}) *Update {
+	u.add = append(u.add, func(b *expressionBuilder) string {
+		return b.name(name) + " " + b.value(value)
+	})
+	return u
+}
+
+// Delete removes the elements of value from a set attribute.
+func (u *Update) Delete(name string, value interface{}) *Update {
+	u.delete = append(u.delete, func(b *expressionBuilder) string {
+		return b.name(name) + " " + b.value(value)
+	})
+	return u
+}
+
+// IsEmpty reports whether the update has no actions.
+func (u *Update) IsEmpty() bool {
+	return u == nil || len(u.set)+len(u.remove)+len(u.add)+len(u.delete) == 0
+}
+
+func (u *Update) build(b *expressionBuilder) string {
+	var clauses []string
+	for _, section := range []struct {
+		keyword string
+		actions []func(b *expressionBuilder) string
+	}{{"SET", u.set}, {"REMOVE", u.remove}, {"ADD", u.add}, {"DELETE", u.delete}} {
+		if len(section.actions) == 0 {
+			continue
+		}
+		parts := make([]string, len(section.actions))
+		for i := range section.actions {
+			parts[i] = section.actions[i](b)
+		}
+		clauses = append(clauses, section.keyword+" "+strings.Join(parts, ", "))
+	}
+	return strings.Join(clauses, " ")
+}
@@ -0,0 +1,76 @@
+package dynamodbutils
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionExpression(t *testing.T) {
+	b := newExpressionBuilder()
+	expr := b.condition(And(
+		Equal("pk", "user#1"),
+		Or(BeginsWith("sk", "order#"), Between("sk", 10, 20)),
+		Not(AttributeExists("deleted")),
+		Condition{},
+	))
+	require.NoError(t, b.err)
+	require.NotNil(t, expr)
+	assert.Equal(t, "(#n0 = :v0) AND ((begins_with(#n1, :v1)) OR (#n1 BETWEEN :v2 AND :v3)) AND (NOT (attribute_exists(#n2)))", *expr)
+	assert.Equal(t, map[string]string{"#n0": "pk", "#n1": "sk", "#n2": "deleted"}, b.attributeNames())
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "user#1"}, b.attributeValues()[":v0"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "20"}, b.attributeValues()[":v3"])
+}
+
+func TestEmptyCondition(t *testing.T) {
+	b := newExpressionBuilder()
+	assert.Nil(t, b.condition(And()))
+	assert.Nil(t, b.attributeNames())
+	assert.Nil(t, b.attributeValues())
+	assert.True(t, Not(Condition{}).IsZero())
+}
+
+func TestNestedAttributeNames(t *testing.T) {
+	b := newExpressionBuilder()
+	expr := b.condition(In("address.city", "Accra", "Kumasi"))
+	assert.Equal(t, "#n0.#n1 IN (:v0, :v1)", *expr)
+	assert.Equal(t, "#n2[0].#n1", b.name("items[0].city"))
+	assert.Equal(t, "#n0, #n2", *b.projection([]string{"address", "items"}))
+}
+
+func TestUpdateExpression(t *testing.T) {
+	update := NewUpdate().
+		Set("status", "shipped").
+		SetIfNotExists("created", 1).
+		Remove("lock").
+		Add("attempts", 1).
+		Delete("tags", []string{"new"})
+
+	b := newExpressionBuilder()
+	assert.Equal(t, "SET #n0 = :v0, #n1 = if_not_exists(#n1, :v1) REMOVE #n2 ADD #n3 :v2 DELETE #n4 :v3", update.build(b))
+	assert.False(t, update.IsEmpty())
+	assert.True(t, NewUpdate().IsEmpty())
+}
+
+func TestPaginationToken(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"pk":  &types.AttributeValueMemberS{Value: "user#1"},
+		"sk":  &types.AttributeValueMemberN{Value: "42"},
+		"bin": &types.AttributeValueMemberB{Value: []byte{0, 1, 2}},
+	}
+	token, err := EncodeToken(key)
+	require.NoError(t, err)
+
+	decoded, err := DecodeToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, key, decoded)
+
+	_, err = DecodeToken("not a token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	token, err = EncodeToken(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, token)
+}
@@ -0,0 +1,274 @@
+package dynamodbutils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ScanAPI is the subset of the DynamoDB client used by the scans. It is satisfied by *dynamodb.Client.
+type ScanAPI interface {
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// PageFunc processes a page of scanned items. During a parallel scan it is called concurrently by the segments.
+type PageFunc func(ctx context.Context, segment int, items []map[string]types.AttributeValue) error
+
+// ParallelScan scans the whole table splitting it in the given number of segments, each scanned
+// by its own goroutine. The first error, either from DynamoDB or from fn, stops all the segments
+// and is returned.
+func ParallelScan(ctx context.Context, client ScanAPI, table string, segments int, fn PageFunc) error {
+	return parallelScan(ctx, client, table, ScanRequest{}, segments, fn)
+}
+
+// ParallelScan scans the table matching the request with the given number of segments,
+// see the package level ParallelScan. Segment, TotalSegments and Token of the request are ignored.
+func (t *Table[T]) ParallelScan(ctx context.Context, req ScanRequest, segments int, fn func(ctx context.Context, items []T) error) error {
+	return parallelScan(ctx, t.client, t.config.Name, req, segments, func(ctx context.Context, _ int, page []map[string]types.AttributeValue) error {
+		var items []T
+		if err := attributevalue.UnmarshalListOfMaps(page, &items); err != nil {
+			return err
+		}
+		return fn(ctx, items)
+	})
+}
+
+func parallelScan(ctx context.Context, client ScanAPI, table string, req ScanRequest, segments int, fn PageFunc) error {
+	if segments <= 0 {
+		segments = 1
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	wg.Add(segments)
+	for segment := 0; segment < segments; segment++ {
+		segmentReq := req
+		segmentReq.Token = ""
+		segmentReq.Segment, segmentReq.TotalSegments = int32(segment), int32(segments)
+		go func(segment int) {
+			defer wg.Done()
+			fetch := scanFetcher(client, table, segmentReq)
+			var startKey map[string]types.AttributeValue
+			for {
+				items, lastKey, err := fetch(ctx, startKey)
+				if err == nil && len(items) > 0 {
+					err = fn(ctx, segment, items)
+				}
+				if err != nil {
+					cancel(fmt.Errorf("segment %d: %w", segment, err))
+					return
+				}
+				if len(lastKey) == 0 {
+					return
+				}
+				startKey = lastKey
+			}
+		}(segment)
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// jsonAttributeValue is the DynamoDB JSON representation of an attribute value,
+// as used by the DynamoDB export to S3.
+type jsonAttributeValue struct {
+	S    *string                        `json:"S,omitempty"`
+	N    *string                        `json:"N,omitempty"`
+	B    []byte                         `json:"B,omitempty"`
+	BOOL *bool                          `json:"BOOL,omitempty"`
+	NULL *bool                          `json:"NULL,omitempty"`
+	SS   []string                       `json:"SS,omitempty"`
+	NS   []string                       `json:"NS,omitempty"`
+	BS   [][]byte                       `json:"BS,omitempty"`
+	L    *[]jsonAttributeValue          `json:"L,omitempty"`
+	M    *map[string]jsonAttributeValue `json:"M,omitempty"`
+}
+
+type jsonItem struct {
+	Item map[string]jsonAttributeValue `json:"Item"`
+}
+
+func toJSONAttributeValue(av types.AttributeValue) (jsonAttributeValue, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return jsonAttributeValue{S: &v.Value}, nil
+	case *types.AttributeValueMemberN:
+		return jsonAttributeValue{N: &v.Value}, nil
+	case *types.AttributeValueMemberB:
+		return jsonAttributeValue{B: v.Value}, nil
+	case *types.AttributeValueMemberBOOL:
+		return jsonAttributeValue{BOOL: &v.Value}, nil
+	case *types.AttributeValueMemberNULL:
+		return jsonAttributeValue{NULL: &v.Value}, nil
+	case *types.AttributeValueMemberSS:
+		return jsonAttributeValue{SS: v.Value}, nil
+	case *types.AttributeValueMemberNS:
+		return jsonAttributeValue{NS: v.Value}, nil
+	case *types.AttributeValueMemberBS:
+		return jsonAttributeValue{BS: v.Value}, nil
+	case *types.AttributeValueMemberL:
+		list := make([]jsonAttributeValue, len(v.Value))
+		for i := range v.Value {
+			var err error
+			if list[i], err = toJSONAttributeValue(v.Value[i]); err != nil {
+				return jsonAttributeValue{}, err
+			}
+		}
+		return jsonAttributeValue{L: &list}, nil
+	case *types.AttributeValueMemberM:
+		m, err := toJSONItem(v.Value)
+		if err != nil {
+			return jsonAttributeValue{}, err
+		}
+		return jsonAttributeValue{M: &m}, nil
+	}
+	return jsonAttributeValue{}, fmt.Errorf("dynamodb: unsupported attribute type %T", av)
+}
+
+func toJSONItem(item map[string]types.AttributeValue) (map[string]jsonAttributeValue, error) {
+	m := make(map[string]jsonAttributeValue, len(item))
+	for name, av := range item {
+		v, err := toJSONAttributeValue(av)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		m[name] = v
+	}
+	return m, nil
+}
+
+func (v jsonAttributeValue) attributeValue() (types.AttributeValue, error) {
+	switch {
+	case v.S != nil:
+		return &types.AttributeValueMemberS{Value: *v.S}, nil
+	case v.N != nil:
+		return &types.AttributeValueMemberN{Value: *v.N}, nil
+	case v.B != nil:
+		return &types.AttributeValueMemberB{Value: v.B}, nil
+	case v.BOOL != nil:
+		return &types.AttributeValueMemberBOOL{Value: *v.BOOL}, nil
+	case v.NULL != nil:
+		return &types.AttributeValueMemberNULL{Value: *v.NULL}, nil
+	case v.SS != nil:
+		return &types.AttributeValueMemberSS{Value: v.SS}, nil
+	case v.NS != nil:
+		return &types.AttributeValueMemberNS{Value: v.NS}, nil
+	case v.BS != nil:
+		return &types.AttributeValueMemberBS{Value: v.BS}, nil
+	case v.L != nil:
+		list := make([]types.AttributeValue, len(*v.L))
+		for i := range *v.L {
+			var err error
+			if list[i], err = (*v.L)[i].attributeValue(); err != nil {
+				return nil, err
+			}
+		}
+		return &types.AttributeValueMemberL{Value: list}, nil
+	case v.M != nil:
+		m, err := fromJSONItem(*v.M)
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	}
+	return nil, errors.New("dynamodb: attribute value without type")
+}
+
+func fromJSONItem(m map[string]jsonAttributeValue) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(m))
+	for name, v := range m {
+		av, err := v.attributeValue()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		item[name] = av
+	}
+	return item, nil
+}
+
+// ExportJSONL writes every item of the table to w, one per line, in the DynamoDB JSON format
+// used by the native export to S3 ({"Item":{"id":{"S":"..."}}}). The table is read with a
+// parallel scan of the given number of segments, so the lines are not ordered.
+// It returns the number of exported items.
+func ExportJSONL(ctx context.Context, client ScanAPI, table string, w io.Writer, segments int) (int64, error) {
+	var mu sync.Mutex
+	var count int64
+	err := ParallelScan(ctx, client, table, segments, func(ctx context.Context, _ int, items []map[string]types.AttributeValue) error {
+		var lines []byte
+		for _, item := range items {
+			m, err := toJSONItem(item)
+			if err != nil {
+				return err
+			}
+			line, err := json.Marshal(jsonItem{Item: m})
+			if err != nil {
+				return err
+			}
+			lines = append(append(lines, line...), '\n')
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := w.Write(lines); err != nil {
+			return err
+		}
+		count += int64(len(items))
+		return nil
+	})
+	return count, err
+}
+
+// ImportJSONL reads the items written by ExportJSONL from r and writes them through the batch writer.
+// It returns the number of imported items.
+func ImportJSONL(ctx context.Context, writer *BatchWriter, r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	// Items can be up to 400KB, which takes more once encoded as JSON
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var imported int64
+	batch := make([]types.WriteRequest, 0, maxBatchWriteItems)
+	flush := func() error {
+		if err := writer.Write(ctx, batch); err != nil {
+			return err
+		}
+		imported += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var decoded jsonItem
+		if err := json.Unmarshal(scanner.Bytes(), &decoded); err != nil {
+			return imported, fmt.Errorf("line %d: %w", line, err)
+		}
+		item, err := fromJSONItem(decoded.Item)
+		if err != nil {
+			return imported, fmt.Errorf("line %d: %w", line, err)
+		}
+		batch = append(batch, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		if len(batch) == maxBatchWriteItems {
+			if err = flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, err
+	}
+	return imported, flush()
+}
@@ -0,0 +1,538 @@
+package dynamodbutils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// API is the subset of the DynamoDB client used by Table. It is satisfied by *dynamodb.Client.
+type API interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+var _ API = (*dynamodb.Client)(nil)
+
+var (
+	// ErrNotFound is returned when the requested item does not exist.
+	ErrNotFound = errors.New("dynamodb: item not found")
+	// ErrConditionFailed is returned when a condition, or the optimistic locking check, is not satisfied.
+	ErrConditionFailed = errors.New("dynamodb: condition failed")
+	// ErrInvalidToken is returned when a pagination token cannot be decoded.
+	ErrInvalidToken = errors.New("dynamodb: invalid pagination token")
+)
+
+// TableConfig describes a table accessed through Table.
+type TableConfig struct {
+	Name         string
+	PartitionKey string // Name of the partition key attribute
+	SortKey      string // Name of the sort key attribute, empty for tables without sort key
+
+	// ConsistentReads makes Get use strongly consistent reads.
+	ConsistentReads bool
+
+	// VersionAttribute enables optimistic locking: the numeric attribute is incremented on every
+	// Put and Update, and Put only succeeds if the stored version matches the one of the item.
+	VersionAttribute string
+}
+
+// Key identifies an item by its partition and, optionally, sort key values.
+type Key struct {
+	Partition interface{}
+	Sort      interface{}
+}
+
+// Table is a typed repository over a DynamoDB table, whose items are (un)marshalled
+// from T using the `dynamodbav` struct tags.
+type Table[T any] struct {
+	client API
+	config TableConfig
+}
+
+// NewTable returns a Table over the configured table.
+func NewTable[T any](client API, cfg TableConfig) (*Table[T], error) {
+	if client == nil {
+		return nil, errors.New("dynamodb: client is required")
+	}
+	if cfg.Name == "" || cfg.PartitionKey == "" {
+		return nil, errors.New("dynamodb: table name and partition key are required")
+	}
+	return &Table[T]{client: client, config: cfg}, nil
+}
+
+// Name returns the name of the table.
+func (t *Table[T]) Name() string {
+	return t.config.Name
+}
+
+func (t *Table[T]) key(key Key) (map[string]types.AttributeValue, error) {
+	partition, err := attributevalue.Marshal(key.Partition)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling partition key: %w", err)
+	}
+	av := map[string]types.AttributeValue{t.config.PartitionKey: partition}
+	if t.config.SortKey != "" {
+		sort, err := attributevalue.Marshal(key.Sort)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling sort key: %w", err)
+		}
+		av[t.config.SortKey] = sort
+	}
+	return av, nil
+}
+
+// Get returns the item identified by key, or ErrNotFound.
+func (t *Table[T]) Get(ctx context.Context, key Key) (T, error) {
+	var item T
+	av, err := t.key(key)
+	if err != nil {
+		return item, err
+	}
+	out, err := t.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(t.config.Name),
+		Key:            av,
+		ConsistentRead: aws.Bool(t.config.ConsistentReads),
+	})
+	if err != nil {
+		return item, err
+	}
+	if len(out.Item) == 0 {
+		return item, ErrNotFound
+	}
+	err = attributevalue.UnmarshalMap(out.Item, &item)
+	return item, err
+}
+
+// Put writes the item if all the conditions are satisfied, returning ErrConditionFailed otherwise.
+// With optimistic locking enabled, the version of item is checked against the stored one and
+// incremented in place.
+func (t *Table[T]) Put(ctx context.Context, item *T, conditions ...Condition) error {
+	input, commit, err := t.putInput(item, conditions)
+	if err != nil {
+		return err
+	}
+	if _, err = t.client.PutItem(ctx, input); err != nil {
+		return mapError(err)
+	}
+	return commit()
+}
+
+// putInput builds the PutItem request of item. The returned commit function applies the new
+// version to item once the write succeeded.
+func (t *Table[T]) putInput(item *T, conditions []Condition) (*dynamodb.PutItemInput, func() error, error) {
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	commit := func() error { return nil }
+	if t.config.VersionAttribute != "" {
+		version, err := versionOf(av, t.config.VersionAttribute)
+		if err != nil {
+			return nil, nil, err
+		}
+		if version == 0 {
+			conditions = append(conditions, AttributeNotExists(t.config.PartitionKey))
+		} else {
+			conditions = append(conditions, Equal(t.config.VersionAttribute, version))
+		}
+		av[t.config.VersionAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(version+1, 10)}
+		commit = func() error { return attributevalue.UnmarshalMap(av, item) }
+	}
+
+	b := newExpressionBuilder()
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(t.config.Name),
+		Item:                av,
+		ConditionExpression: b.condition(And(conditions...)),
+	}
+	input.ExpressionAttributeNames, input.ExpressionAttributeValues = b.attributeNames(), b.attributeValues()
+	return input, commit, b.err
+}
+
+// Update applies the update to the item identified by key if all the conditions are satisfied,
+// and returns the updated item. Missing items are created, unless prevented by a condition such as
+// AttributeExists. With optimistic locking enabled the version is incremented; pass
+// Equal(versionAttribute, expected) to check it.
+func (t *Table[T]) Update(ctx context.Context, key Key, update *Update, conditions ...Condition) (T, error) {
+	var item T
+	input, err := t.updateInput(key, update, conditions)
+	if err != nil {
+		return item, err
+	}
+	input.ReturnValues = types.ReturnValueAllNew
+	out, err := t.client.UpdateItem(ctx, input)
+	if err != nil {
+		return item, mapError(err)
+	}
+	err = attributevalue.UnmarshalMap(out.Attributes, &item)
+	return item, err
+}
+
+func (t *Table[T]) updateInput(key Key, update *Update, conditions []Condition) (*dynamodb.UpdateItemInput, error) {
+	if update.IsEmpty() {
+		return nil, errors.New("dynamodb: empty update")
+	}
+	av, err := t.key(key)
+	if err != nil {
+		return nil, err
+	}
+	if t.config.VersionAttribute != "" {
+		versioned := *update
+		versioned.set = append(versioned.set[:len(versioned.set):len(versioned.set)], func(b *expressionBuilder) string {
+			placeholder := b.name(t.config.VersionAttribute)
+			return placeholder + " = if_not_exists(" + placeholder + ", " + b.value(0) + ") + " + b.value(1)
+		})
+		update = &versioned
+	}
+
+	b := newExpressionBuilder()
+	expr := update.build(b)
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(t.config.Name),
+		Key:                 av,
+		UpdateExpression:    &expr,
+		ConditionExpression: b.condition(And(conditions...)),
+	}
+	input.ExpressionAttributeNames, input.ExpressionAttributeValues = b.attributeNames(), b.attributeValues()
+	return input, b.err
+}
+
+// Delete deletes the item identified by key if all the conditions are satisfied.
+// Deleting a missing item is not an error.
+func (t *Table[T]) Delete(ctx context.Context, key Key, conditions ...Condition) error {
+	input, err := t.deleteInput(key, conditions)
+	if err != nil {
+		return err
+	}
+	_, err = t.client.DeleteItem(ctx, input)
+	return mapError(err)
+}
+
+func (t *Table[T]) deleteInput(key Key, conditions []Condition) (*dynamodb.DeleteItemInput, error) {
+	av, err := t.key(key)
+	if err != nil {
+		return nil, err
+	}
+	b := newExpressionBuilder()
+	input := &dynamodb.DeleteItemInput{
+		TableName:           aws.String(t.config.Name),
+		Key:                 av,
+		ConditionExpression: b.condition(And(conditions...)),
+	}
+	input.ExpressionAttributeNames, input.ExpressionAttributeValues = b.attributeNames(), b.attributeValues()
+	return input, b.err
+}
+
+// QueryRequest describes a query on the table or on one of its secondary indexes.
+type QueryRequest struct {
+	Index          string    // Secondary index to query, empty for the table
+	KeyCondition   Condition // Required, e.g. And(Equal("pk", id), BeginsWith("sk", "ORDER#"))
+	Filter         Condition
+	Projection     []string
+	Limit          int32 // Maximum number of items evaluated per page
+	Descending     bool
+	ConsistentRead bool
+	Token          string // Pagination token returned by a previous query
+}
+
+// ScanRequest describes a scan of the table or of one of its secondary indexes.
+type ScanRequest struct {
+	Index          string
+	Filter         Condition
+	Projection     []string
+	Limit          int32
+	ConsistentRead bool
+	Token          string
+
+	// Segment and TotalSegments split the scan for parallel workers, see ParallelScan.
+	Segment       int32
+	TotalSegments int32
+}
+
+// Page is a page of results.
+type Page[T any] struct {
+	Items []T
+	Token string // Token of the next page, empty on the last page
+}
+
+// pageFetcher fetches the page starting after startKey.
+type pageFetcher func(ctx context.Context, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error)
+
+// Query returns an iterator over the items matching the request.
+func (t *Table[T]) Query(ctx context.Context, req QueryRequest) *Iterator[T] {
+	return newIterator[T](ctx, req.Token, t.queryFetcher(req))
+}
+
+// QueryPage returns a single page of the items matching the request.
+func (t *Table[T]) QueryPage(ctx context.Context, req QueryRequest) (*Page[T], error) {
+	return fetchPage[T](ctx, req.Token, t.queryFetcher(req))
+}
+
+func (t *Table[T]) queryFetcher(req QueryRequest) pageFetcher {
+	return func(ctx context.Context, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+		if req.KeyCondition.IsZero() {
+			return nil, nil, errors.New("dynamodb: query requires a key condition")
+		}
+		b := newExpressionBuilder()
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(t.config.Name),
+			KeyConditionExpression: b.condition(req.KeyCondition),
+			FilterExpression:       b.condition(req.Filter),
+			ProjectionExpression:   b.projection(req.Projection),
+			ScanIndexForward:       aws.Bool(!req.Descending),
+			ConsistentRead:         aws.Bool(req.ConsistentRead),
+			ExclusiveStartKey:      startKey,
+		}
+		input.ExpressionAttributeNames, input.ExpressionAttributeValues = b.attributeNames(), b.attributeValues()
+		if req.Index != "" {
+			input.IndexName = aws.String(req.Index)
+		}
+		if req.Limit > 0 {
+			input.Limit = aws.Int32(req.Limit)
+		}
+		if b.err != nil {
+			return nil, nil, b.err
+		}
+		out, err := t.client.Query(ctx, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		return out.Items, out.LastEvaluatedKey, nil
+	}
+}
+
+// Scan returns an iterator over the items of the table matching the request.
+func (t *Table[T]) Scan(ctx context.Context, req ScanRequest) *Iterator[T] {
+	return newIterator[T](ctx, req.Token, scanFetcher(t.client, t.config.Name, req))
+}
+
+// ScanPage returns a single page of the items of the table matching the request.
+func (t *Table[T]) ScanPage(ctx context.Context, req ScanRequest) (*Page[T], error) {
+	return fetchPage[T](ctx, req.Token, scanFetcher(t.client, t.config.Name, req))
+}
+
+func scanFetcher(client ScanAPI, table string, req ScanRequest) pageFetcher {
+	return func(ctx context.Context, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+		b := newExpressionBuilder()
+		input := &dynamodb.ScanInput{
+			TableName:            aws.String(table),
+			FilterExpression:     b.condition(req.Filter),
+			ProjectionExpression: b.projection(req.Projection),
+			ConsistentRead:       aws.Bool(req.ConsistentRead),
+			ExclusiveStartKey:    startKey,
+		}
+		input.ExpressionAttributeNames, input.ExpressionAttributeValues = b.attributeNames(), b.attributeValues()
+		if req.Index != "" {
+			input.IndexName = aws.String(req.Index)
+		}
+		if req.Limit > 0 {
+			input.Limit = aws.Int32(req.Limit)
+		}
+		if req.TotalSegments > 1 {
+			input.Segment = aws.Int32(req.Segment)
+			input.TotalSegments = aws.Int32(req.TotalSegments)
+		}
+		if b.err != nil {
+			return nil, nil, b.err
+		}
+		out, err := client.Scan(ctx, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		return out.Items, out.LastEvaluatedKey, nil
+	}
+}
+
+func fetchPage[T any](ctx context.Context, token string, fetch pageFetcher) (*Page[T], error) {
+	startKey, err := DecodeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	items, lastKey, err := fetch(ctx, startKey)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page[T]{}
+	if err = attributevalue.UnmarshalListOfMaps(items, &page.Items); err != nil {
+		return nil, err
+	}
+	page.Token, err = EncodeToken(lastKey)
+	return page, err
+}
+
+// Iterator iterates over the items of a query or scan, fetching the pages lazily:
+//
+//	it := table.Query(ctx, req)
+//	for it.Next() {
+//		item := it.Item()
+//	}
+//	if err := it.Err(); err != nil {...}
+type Iterator[T any] struct {
+	ctx      context.Context
+	fetch    pageFetcher
+	page     []map[string]types.AttributeValue
+	pos      int
+	item     T
+	startKey map[string]types.AttributeValue
+	lastKey  map[string]types.AttributeValue
+	fetched  bool
+	err      error
+}
+
+func newIterator[T any](ctx context.Context, token string, fetch pageFetcher) *Iterator[T] {
+	it := &Iterator[T]{ctx: ctx, fetch: fetch}
+	it.startKey, it.err = DecodeToken(token)
+	return it
+}
+
+// Next advances to the next item, reporting whether there is one.
+func (it *Iterator[T]) Next() bool {
+	for it.err == nil {
+		if it.pos < len(it.page) {
+			var item T
+			if it.err = attributevalue.UnmarshalMap(it.page[it.pos], &item); it.err != nil {
+				return false
+			}
+			it.item = item
+			it.pos++
+			return true
+		}
+		if it.fetched && len(it.lastKey) == 0 {
+			return false
+		}
+		startKey := it.startKey
+		if it.fetched {
+			startKey = it.lastKey
+		}
+		it.page, it.lastKey, it.err = it.fetch(it.ctx, startKey)
+		it.pos = 0
+		it.fetched = true
+	}
+	return false
+}
+
+// Item returns the current item.
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Token returns the pagination token resuming the iteration after the last fetched page.
+// It is empty once all the pages have been fetched. Items of the current page that were not
+// consumed yet are not covered by the token.
+func (it *Iterator[T]) Token() string {
+	token, _ := EncodeToken(it.lastKey)
+	return token
+}
+
+// tokenValue is the serialisable form of a key attribute: keys can only be strings, numbers or binaries.
+type tokenValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+	B []byte  `json:"B,omitempty"`
+}
+
+// EncodeToken encodes a LastEvaluatedKey as an opaque pagination token.
+func EncodeToken(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	values := make(map[string]tokenValue, len(key))
+	for name, av := range key {
+		switch v := av.(type) {
+		case *types.AttributeValueMemberS:
+			values[name] = tokenValue{S: aws.String(v.Value)}
+		case *types.AttributeValueMemberN:
+			values[name] = tokenValue{N: aws.String(v.Value)}
+		case *types.AttributeValueMemberB:
+			values[name] = tokenValue{B: v.Value}
+		default:
+			return "", fmt.Errorf("dynamodb: unsupported key attribute type %T", av)
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeToken decodes a pagination token produced by EncodeToken.
+func DecodeToken(token string) (map[string]types.AttributeValue, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var values map[string]tokenValue
+	if err = json.Unmarshal(data, &values); err != nil {
+		return nil, ErrInvalidToken
+	}
+	key := make(map[string]types.AttributeValue, len(values))
+	for name, v := range values {
+		switch {
+		case v.S != nil:
+			key[name] = &types.AttributeValueMemberS{Value: *v.S}
+		case v.N != nil:
+			key[name] = &types.AttributeValueMemberN{Value: *v.N}
+		case v.B != nil:
+			key[name] = &types.AttributeValueMemberB{Value: v.B}
+		default:
+			return nil, ErrInvalidToken
+		}
+	}
+	return key, nil
+}
+
+// versionOf returns the value of the version attribute, 0 when missing.
+func versionOf(item map[string]types.AttributeValue, attribute string) (int64, error) {
+	av, ok := item[attribute]
+	if !ok {
+		return 0, nil
+	}
+	n, ok := av.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("dynamodb: version attribute %q is not a number", attribute)
+	}
+	return strconv.ParseInt(n.Value, 10, 64)
+}
+
+// mapError maps failed conditions to ErrConditionFailed, keeping the original error in the chain.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return fmt.Errorf("%w: %w", ErrConditionFailed, err)
+	}
+	var txErr *types.TransactionCanceledException
+	if errors.As(err, &txErr) {
+		for _, reason := range txErr.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return fmt.Errorf("%w: %w", ErrConditionFailed, err)
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+package dynamodbutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests of this file run against DynamoDB Local, e.g.:
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	DYNAMODB_LOCAL_ENDPOINT=http://localhost:8000 go test ./aws/dynamodb/...
+
+type order struct {
+	Customer string `dynamodbav:"customer"`
+	ID       string `dynamodbav:"id"`
+	Status   string `dynamodbav:"status"`
+	Total    int    `dynamodbav:"total"`
+	Version  int64  `dynamodbav:"version,omitempty"`
+}
+
+func newLocalClient(t *testing.T) *dynamodb.Client {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT not set, skipping tests that require DynamoDB Local")
+	}
+	return dynamodb.New(dynamodb.Options{
+		BaseEndpoint: aws.String(endpoint),
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("local", "local", ""),
+	})
+}
+
+// newOrdersTable creates a fresh table keyed by customer/id with a "by-status" index
+func newOrdersTable(t *testing.T, client *dynamodb.Client) *Table[order] {
+	ctx := context.Background()
+	name := fmt.Sprintf("orders-%d", time.Now().UnixNano())
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(name),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("customer"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("status"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("customer"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String("by-status"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("status"), KeyType: types.KeyTypeHash},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	require.NoError(t, err)
+	require.NoError(t, waitForTable(ctx, client, name))
+	t.Cleanup(func() {
+		_, _ = client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(name)})
+	})
+
+	table, err := NewTable[order](client, TableConfig{
+		Name:             name,
+		PartitionKey:     "customer",
+		SortKey:          "id",
+		ConsistentReads:  true,
+		VersionAttribute: "version",
+	})
+	require.NoError(t, err)
+	return table
+}
+
+func TestTableCRUD(t *testing.T) {
+	table := newOrdersTable(t, newLocalClient(t))
+	ctx := context.Background()
+	key := Key{Partition: "alice", Sort: "o1"}
+
+	_, err := table.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	item := order{Customer: "alice", ID: "o1", Status: "new", Total: 10}
+	require.NoError(t, table.Put(ctx, &item))
+	assert.EqualValues(t, 1, item.Version)
+
+	got, err := table.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, item, got)
+
+	updated, err := table.Update(ctx, key, NewUpdate().Set("status", "paid").Add("total", 5), Equal("version", 1))
+	require.NoError(t, err)
+	assert.Equal(t, "paid", updated.Status)
+	assert.Equal(t, 15, updated.Total)
+	assert.EqualValues(t, 2, updated.Version)
+
+	_, err = table.Update(ctx, key, NewUpdate().Set("status", "refunded"), Equal("version", 1))
+	assert.ErrorIs(t, err, ErrConditionFailed)
+
+	require.NoError(t, table.Delete(ctx, key, Equal("status", "paid")))
+	_, err = table.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTableOptimisticLocking(t *testing.T) {
+	table := newOrdersTable(t, newLocalClient(t))
+	ctx := context.Background()
+
+	item := order{Customer: "bob", ID: "o1", Status: "new"}
+	require.NoError(t, table.Put(ctx, &item))
+
+	// A second writer creating the same item must fail
+	duplicate := order{Customer: "bob", ID: "o1", Status: "new"}
+	assert.ErrorIs(t, table.Put(ctx, &duplicate), ErrConditionFailed)
+
+	stale := item
+	item.Status = "paid"
+	require.NoError(t, table.Put(ctx, &item))
+	assert.EqualValues(t, 2, item.Version)
+
+	stale.Status = "cancelled"
+	assert.ErrorIs(t, table.Put(ctx, &stale), ErrConditionFailed)
+	assert.EqualValues(t, 1, stale.Version)
+}
+
+func TestTableQueryPagination(t *testing.T) {
+	table := newOrdersTable(t, newLocalClient(t))
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		status := "new"
+		if i%2 == 0 {
+			status = "paid"
+		}
+		item := order{Customer: "carol", ID: fmt.Sprintf("o%d", i), Status: status, Total: i}
+		require.NoError(t, table.Put(ctx, &item))
+	}
+
+	req := QueryRequest{KeyCondition: Equal("customer", "carol"), Limit: 3, Descending: true}
+	var ids []string
+	it := table.Query(ctx, req)
+	for it.Next() {
+		ids = append(ids, it.Item().ID)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []string{"o6", "o5", "o4", "o3", "o2", "o1", "o0"}, ids)
+
+	page, err := table.QueryPage(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 3)
+	require.NotEmpty(t, page.Token)
+	req.Token = page.Token
+	page, err = table.QueryPage(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "o3", page.Items[0].ID)
+
+	var paid int
+	it = table.Query(ctx, QueryRequest{Index: "by-status", KeyCondition: Equal("status", "paid")})
+	for it.Next() {
+		paid++
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, 4, paid)
+
+	var filtered int
+	scan := table.Scan(ctx, ScanRequest{Filter: GreaterThanEqual("total", 5)})
+	for scan.Next() {
+		filtered++
+	}
+	require.NoError(t, scan.Err())
+	assert.Equal(t, 2, filtered)
+}
+
+func TestTransaction(t *testing.T) {
+	table := newOrdersTable(t, newLocalClient(t))
+	ctx := context.Background()
+	client := table.client
+
+	first := order{Customer: "dave", ID: "o1", Status: "new"}
+	second := order{Customer: "dave", ID: "o2", Status: "new"}
+	tx := NewTransaction()
+	table.PutTx(tx, &first)
+	table.PutTx(tx, &second)
+	require.NoError(t, tx.Commit(ctx, client))
+	assert.EqualValues(t, 1, first.Version)
+
+	// The failed condition check aborts the whole transaction
+	tx = NewTransaction()
+	table.UpdateTx(tx, Key{Partition: "dave", Sort: "o1"}, NewUpdate().Set("status", "paid"))
+	table.ConditionCheckTx(tx, Key{Partition: "dave", Sort: "o2"}, Equal("status", "paid"))
+	assert.ErrorIs(t, tx.Commit(ctx, client), ErrConditionFailed)
+
+	got, err := table.Get(ctx, Key{Partition: "dave", Sort: "o1"})
+	require.NoError(t, err)
+	assert.Equal(t, "new", got.Status)
+
+	tx = NewTransaction()
+	table.DeleteTx(tx, Key{Partition: "dave", Sort: "o1"})
+	table.DeleteTx(tx, Key{Partition: "dave", Sort: "o2"})
+	require.NoError(t, tx.Commit(ctx, client))
+	_, err = table.Get(ctx, Key{Partition: "dave", Sort: "o2"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
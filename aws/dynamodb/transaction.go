@@ -0,0 +1,140 @@
+package dynamodbutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxTransactionItems is the maximum number of actions of a TransactWriteItems call.
+const maxTransactionItems = 100
+
+// Transaction collects write actions, possibly on different tables, committed atomically
+// through TransactWriteItems:
+//
+//	tx := NewTransaction()
+//	orders.PutTx(tx, &order)
+//	stock.UpdateTx(tx, Key{Partition: sku}, NewUpdate().Add("quantity", -1), GreaterThan("quantity", 0))
+//	err := tx.Commit(ctx, client)
+type Transaction struct {
+	items   []types.TransactWriteItem
+	commits []func() error
+	err     error
+}
+
+// NewTransaction returns an empty transaction.
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+func (tx *Transaction) add(item types.TransactWriteItem, err error) {
+	if err != nil {
+		if tx.err == nil {
+			tx.err = err
+		}
+		return
+	}
+	tx.items = append(tx.items, item)
+}
+
+// Len returns the number of actions of the transaction.
+func (tx *Transaction) Len() int {
+	return len(tx.items)
+}
+
+// Commit executes the transaction. It returns ErrConditionFailed when any of the conditions is not
+// satisfied, in which case none of the actions is applied.
+func (tx *Transaction) Commit(ctx context.Context, client API) error {
+	if tx.err != nil {
+		return tx.err
+	}
+	if len(tx.items) == 0 {
+		return errors.New("dynamodb: empty transaction")
+	}
+	if len(tx.items) > maxTransactionItems {
+		return fmt.Errorf("dynamodb: transaction has %d actions, maximum is %d", len(tx.items), maxTransactionItems)
+	}
+	if _, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: tx.items}); err != nil {
+		return mapError(err)
+	}
+	for _, commit := range tx.commits {
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutTx adds the put of item to the transaction. With optimistic locking enabled, the version of
+// item is incremented once the transaction is committed.
+func (t *Table[T]) PutTx(tx *Transaction, item *T, conditions ...Condition) {
+	input, commit, err := t.putInput(item, conditions)
+	if err != nil {
+		tx.add(types.TransactWriteItem{}, err)
+		return
+	}
+	tx.add(types.TransactWriteItem{Put: &types.Put{
+		TableName:                 input.TableName,
+		Item:                      input.Item,
+		ConditionExpression:       input.ConditionExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}}, nil)
+	tx.commits = append(tx.commits, commit)
+}
+
+// UpdateTx adds the update of the item identified by key to the transaction.
+func (t *Table[T]) UpdateTx(tx *Transaction, key Key, update *Update, conditions ...Condition) {
+	input, err := t.updateInput(key, update, conditions)
+	if err != nil {
+		tx.add(types.TransactWriteItem{}, err)
+		return
+	}
+	tx.add(types.TransactWriteItem{Update: &types.Update{
+		TableName:                 input.TableName,
+		Key:                       input.Key,
+		UpdateExpression:          input.UpdateExpression,
+		ConditionExpression:       input.ConditionExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}}, nil)
+}
+
+// DeleteTx adds the deletion of the item identified by key to the transaction.
+func (t *Table[T]) DeleteTx(tx *Transaction, key Key, conditions ...Condition) {
+	input, err := t.deleteInput(key, conditions)
+	if err != nil {
+		tx.add(types.TransactWriteItem{}, err)
+		return
+	}
+	tx.add(types.TransactWriteItem{Delete: &types.Delete{
+		TableName:                 input.TableName,
+		Key:                       input.Key,
+		ConditionExpression:       input.ConditionExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}}, nil)
+}
+
+// ConditionCheckTx makes the transaction fail unless the item identified by key satisfies the condition.
+func (t *Table[T]) ConditionCheckTx(tx *Transaction, key Key, condition Condition) {
+	if condition.IsZero() {
+		tx.add(types.TransactWriteItem{}, errors.New("dynamodb: condition check requires a condition"))
+		return
+	}
+	input, err := t.deleteInput(key, []Condition{condition})
+	if err != nil {
+		tx.add(types.TransactWriteItem{}, err)
+		return
+	}
+	tx.add(types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+		TableName:                 input.TableName,
+		Key:                       input.Key,
+		ConditionExpression:       input.ConditionExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}}, nil)
+}
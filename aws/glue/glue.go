@@ -48,47 +48,85 @@ func StartWorkflow(workflowName string, params map[string]string) error {
 	return err
 }
 
-func UpdateJob(jobname string) error {
-	_job, err := glueClient.GetJob(context.Background(), &glue.GetJobInput{JobName: aws.String(jobname)})
+// JobUpdateOptions overrides the settings of a job in UpdateJob. Zero values keep the current settings.
+type JobUpdateOptions struct {
+	GlueVersion      string
+	WorkerType       types.WorkerType
+	NumberOfWorkers  int32
+	Timeout          int32  // Minutes
+	MaxRetries       *int32 // Pointer as 0 disables retries
+	ExecutionClass   types.ExecutionClass
+	DefaultArguments map[string]string // Merged into the current default arguments
+}
+
+// UpdateJob updates the job definition with the given options, keeping the other settings.
+func UpdateJob(jobname string, opts JobUpdateOptions) error {
+	job, err := glueClient.GetJob(context.Background(), &glue.GetJobInput{JobName: aws.String(jobname)})
 	if err != nil {
 		return err
 	}
-	job := _job.Job
-	if _, err = glueClient.UpdateJob(context.Background(), &glue.UpdateJobInput{
-		JobName: job.Name,
-		JobUpdate: &types.JobUpdate{
-			CodeGenConfigurationNodes: job.CodeGenConfigurationNodes,
-			Command:                   job.Command,
-			Connections:               job.Connections,
-			DefaultArguments:          job.DefaultArguments,
-			Description:               job.Description,
-			ExecutionClass:            job.ExecutionClass,
-			ExecutionProperty:         job.ExecutionProperty,
-			GlueVersion:               aws.String("4.0"),
-			LogUri:                    job.LogUri,
-			MaxRetries:                job.MaxRetries,
-			NonOverridableArguments:   job.NonOverridableArguments,
-			NotificationProperty:      job.NotificationProperty,
-			NumberOfWorkers:           aws.Int32(2),
-			Role:                      job.Role,
-			SecurityConfiguration:     job.SecurityConfiguration,
-
-			//SourceControlDetails: &types.SourceControlDetails{
-			//	AuthStrategy: job.SourceControlDetails.AuthStrategy,
-			//	AuthToken:    job.SourceControlDetails.AuthToken,
-			//	Branch:       aws.String("prod"),
-			//	Folder:       job.SourceControlDetails.Folder,
-			//	Owner:        job.SourceControlDetails.Owner,
-			//	Provider:     job.SourceControlDetails.Provider,
-			//	Repository:   job.SourceControlDetails.Repository,
-			//},
-			Timeout:    job.Timeout,
-			WorkerType: types.WorkerTypeG1x,
-		},
-	}); err != nil {
-		return err
+	_, err = glueClient.UpdateJob(context.Background(), &glue.UpdateJobInput{
+		JobName:   job.Job.Name,
+		JobUpdate: NewJobUpdate(job.Job, opts),
+	})
+	return err
+}
+
+// NewJobUpdate returns the update of the job definition applying the options.
+func NewJobUpdate(job *types.Job, opts JobUpdateOptions) *types.JobUpdate {
+	update := &types.JobUpdate{
+		CodeGenConfigurationNodes: job.CodeGenConfigurationNodes,
+		Command:                   job.Command,
+		Connections:               job.Connections,
+		DefaultArguments:          job.DefaultArguments,
+		Description:               job.Description,
+		ExecutionClass:            job.ExecutionClass,
+		ExecutionProperty:         job.ExecutionProperty,
+		GlueVersion:               job.GlueVersion,
+		LogUri:                    job.LogUri,
+		MaxRetries:                job.MaxRetries,
+		NonOverridableArguments:   job.NonOverridableArguments,
+		NotificationProperty:      job.NotificationProperty,
+		NumberOfWorkers:           job.NumberOfWorkers,
+		Role:                      job.Role,
+		SecurityConfiguration:     job.SecurityConfiguration,
+		Timeout:                   job.Timeout,
+		WorkerType:                job.WorkerType,
+	}
+	if opts.GlueVersion != "" {
+		update.GlueVersion = aws.String(opts.GlueVersion)
+	}
+	if opts.WorkerType != "" {
+		update.WorkerType = opts.WorkerType
+	}
+	if opts.NumberOfWorkers > 0 {
+		update.NumberOfWorkers = aws.Int32(opts.NumberOfWorkers)
+	}
+	if opts.Timeout > 0 {
+		update.Timeout = aws.Int32(opts.Timeout)
+	}
+	if opts.MaxRetries != nil {
+		update.MaxRetries = *opts.MaxRetries
+	}
+	if opts.ExecutionClass != "" {
+		update.ExecutionClass = opts.ExecutionClass
+	}
+	if len(opts.DefaultArguments) > 0 {
+		args := make(map[string]string, len(job.DefaultArguments)+len(opts.DefaultArguments))
+		for k, v := range job.DefaultArguments {
+			args[k] = v
+		}
+		for k, v := range opts.DefaultArguments {
+			args[k] = v
+		}
+		update.DefaultArguments = args
 	}
-	return nil
+	// Jobs sized with workers reject a max capacity, Python shell jobs only have a max capacity
+	if update.WorkerType == "" {
+		update.NumberOfWorkers = nil
+		update.MaxCapacity = job.MaxCapacity
+	}
+	return update
 }
 
 func PushRepo(jobname string) error {
@@ -0,0 +1,32 @@
+package glueutils
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJobUpdate(t *testing.T) {
+	job := &types.Job{
+		Name:             aws.String("load-orders"),
+		GlueVersion:      aws.String("3.0"),
+		WorkerType:       types.WorkerTypeG1x,
+		NumberOfWorkers:  aws.Int32(10),
+		MaxRetries:       2,
+		DefaultArguments: map[string]string{"--day": "today", "--env": "qa"},
+	}
+	update := NewJobUpdate(job, JobUpdateOptions{GlueVersion: "4.0", MaxRetries: aws.Int32(0), DefaultArguments: map[string]string{"--env": "prod"}})
+	assert.Equal(t, "4.0", aws.ToString(update.GlueVersion))
+	assert.EqualValues(t, 10, aws.ToInt32(update.NumberOfWorkers))
+	assert.Equal(t, types.WorkerTypeG1x, update.WorkerType)
+	assert.EqualValues(t, 0, update.MaxRetries)
+	assert.Equal(t, map[string]string{"--day": "today", "--env": "prod"}, update.DefaultArguments)
+	assert.Equal(t, "qa", job.DefaultArguments["--env"])
+
+	shell := &types.Job{Name: aws.String("cleanup"), MaxCapacity: aws.Float64(0.0625)}
+	update = NewJobUpdate(shell, JobUpdateOptions{NumberOfWorkers: 2})
+	assert.Nil(t, update.NumberOfWorkers)
+	assert.Equal(t, 0.0625, aws.ToFloat64(update.MaxCapacity))
+}
@@ -0,0 +1,34 @@
+// Package retry holds the backoff shared by the AWS helpers retrying throttled or failed calls.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff returns an exponential delay with full jitter for the given attempt (starting at 1).
+func Backoff(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	if maxDelay <= minDelay {
+		return minDelay
+	}
+	delay := maxDelay
+	if attempt < 32 {
+		if d := minDelay << (attempt - 1); d > 0 && d < maxDelay {
+			delay = d
+		}
+	}
+	return minDelay + time.Duration(rand.Int63n(int64(delay-minDelay)+1))
+}
+
+// Sleep waits for d or until ctx is done, reporting whether the full delay elapsed.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,27 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 40; attempt++ {
+		d := Backoff(attempt, 10*time.Millisecond, time.Second)
+		assert.GreaterOrEqual(t, d, 10*time.Millisecond)
+		assert.LessOrEqual(t, d, time.Second)
+	}
+	assert.LessOrEqual(t, Backoff(1, 10*time.Millisecond, time.Second), 10*time.Millisecond)
+	assert.Equal(t, time.Second, Backoff(3, time.Second, time.Second))
+}
+
+func TestSleep(t *testing.T) {
+	assert.True(t, Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, Sleep(ctx, time.Hour))
+}
@@ -1,11 +1,13 @@
 package lambdautils
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/seidu626/go-buildingblocks/archive"
 	awsutils "github.com/seidu626/go-buildingblocks/aws"
 	stringutils "github.com/seidu626/go-buildingblocks/string"
 	"log"
@@ -174,30 +176,42 @@ func DeployLambdaFromS3(functionName, bucket, key string) error {
 	return nil
 }
 
+// DeployLambdaFromZIP publishes the zip file at zipPath as the code of the function.
 func DeployLambdaFromZIP(functionName, zipPath string) error {
-	function, err := lambdaClient.GetFunction(context.Background(), &lambda.GetFunctionInput{
-		FunctionName: aws.String(functionName),
-	})
+	file, err := os.ReadFile(zipPath)
 	if err != nil {
 		return err
 	}
+	return updateFunctionCode(functionName, file)
+}
+
+// DeployLambdaFromDir zips the content of dir, keeping the permissions of the executables
+// and the symlinks, and publishes it as the code of the function. The zip is built in
+// memory: direct uploads are limited to 50 MB, use DeployLambdaFromS3 beyond.
+func DeployLambdaFromDir(functionName, dir string) error {
+	var buf bytes.Buffer
+	if _, err := archive.CreateDir(context.Background(), &buf, dir, archive.CreateOptions{Format: archive.Zip}); err != nil {
+		return err
+	}
+	return updateFunctionCode(functionName, buf.Bytes())
+}
 
-	file, err := os.ReadFile(zipPath)
+func updateFunctionCode(functionName string, zipFile []byte) error {
+	function, err := lambdaClient.GetFunction(context.Background(), &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
 	if err != nil {
 		return err
 	}
-	if _, err = lambdaClient.UpdateFunctionCode(context.Background(), &lambda.UpdateFunctionCodeInput{
+	_, err = lambdaClient.UpdateFunctionCode(context.Background(), &lambda.UpdateFunctionCodeInput{
 		FunctionName:  aws.String(functionName),
 		Architectures: function.Configuration.Architectures,
 		DryRun:        false,
 		Publish:       true,
 		RevisionId:    function.Configuration.RevisionId,
-		ZipFile:       file,
-	}); err != nil {
-		return err
-	}
-	//log.Println(helper.MarshalIndent(code))
-	return nil
+		ZipFile:       zipFile,
+	})
+	return err
 }
 
 func ListTags(lambdaARN string) (*lambda.ListTagsOutput, error) {
@@ -0,0 +1,135 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+// CodePipelineAPI is the subset of the CodePipeline client used by Pipeline. It is satisfied by *codepipeline.Client.
+type CodePipelineAPI interface {
+	StartPipelineExecution(ctx context.Context, params *codepipeline.StartPipelineExecutionInput, optFns ...func(*codepipeline.Options)) (*codepipeline.StartPipelineExecutionOutput, error)
+	GetPipelineExecution(ctx context.Context, params *codepipeline.GetPipelineExecutionInput, optFns ...func(*codepipeline.Options)) (*codepipeline.GetPipelineExecutionOutput, error)
+	StopPipelineExecution(ctx context.Context, params *codepipeline.StopPipelineExecutionInput, optFns ...func(*codepipeline.Options)) (*codepipeline.StopPipelineExecutionOutput, error)
+	ListActionExecutions(ctx context.Context, params *codepipeline.ListActionExecutionsInput, optFns ...func(*codepipeline.Options)) (*codepipeline.ListActionExecutionsOutput, error)
+}
+
+var _ CodePipelineAPI = (*codepipeline.Client)(nil)
+
+// Pipeline is an execution of a CodePipeline pipeline.
+type Pipeline struct {
+	runID
+	client    CodePipelineAPI
+	name      string
+	variables map[string]string
+}
+
+// NewPipeline creates an execution of the named pipeline with the given pipeline variables.
+func NewPipeline(client CodePipelineAPI, name string, variables map[string]string) *Pipeline {
+	return &Pipeline{client: client, name: name, variables: variables}
+}
+
+// Attach makes the run follow an existing execution instead of starting a new one.
+func (p *Pipeline) Attach(executionID string) *Pipeline {
+	p.set(executionID)
+	return p
+}
+
+func (p *Pipeline) Kind() string { return "pipeline" }
+func (p *Pipeline) Name() string { return p.name }
+
+func (p *Pipeline) Start(ctx context.Context) error {
+	return p.start(func() (string, error) {
+		input := &codepipeline.StartPipelineExecutionInput{Name: aws.String(p.name)}
+		for name, value := range p.variables {
+			input.Variables = append(input.Variables, types.PipelineVariable{Name: aws.String(name), Value: aws.String(value)})
+		}
+		out, err := p.client.StartPipelineExecution(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		return aws.ToString(out.PipelineExecutionId), nil
+	})
+}
+
+// Status reports the error details of the failed actions when the execution failed.
+func (p *Pipeline) Status(ctx context.Context) (Status, error) {
+	id := p.ID()
+	if id == "" {
+		return Status{}, ErrNotStarted
+	}
+	out, err := p.client.GetPipelineExecution(ctx, &codepipeline.GetPipelineExecutionInput{PipelineName: aws.String(p.name), PipelineExecutionId: aws.String(id)})
+	if err != nil {
+		return Status{}, err
+	}
+	execution := out.PipelineExecution
+	status := Status{Native: string(execution.Status), Time: time.Now()}
+	switch execution.Status {
+	case types.PipelineExecutionStatusInProgress:
+		status.State = StateRunning
+	case types.PipelineExecutionStatusStopping:
+		status.State = StateStopping
+	case types.PipelineExecutionStatusSucceeded:
+		status.State = StateSucceeded
+	case types.PipelineExecutionStatusStopped, types.PipelineExecutionStatusCancelled, types.PipelineExecutionStatusSuperseded:
+		status.State = StateStopped
+		status.Message = aws.ToString(execution.StatusSummary)
+	case types.PipelineExecutionStatusFailed:
+		status.State = StateFailed
+		if status.Message, err = p.failedActions(ctx, id); err != nil {
+			return Status{}, err
+		}
+		if status.Message == "" {
+			status.Message = aws.ToString(execution.StatusSummary)
+		}
+	default:
+		status.State = StatePending
+	}
+	return status, nil
+}
+
+// failedActions describes the actions of the execution that failed.
+func (p *Pipeline) failedActions(ctx context.Context, id string) (string, error) {
+	var failures []string
+	paginator := codepipeline.NewListActionExecutionsPaginator(p.client, &codepipeline.ListActionExecutionsInput{
+		PipelineName: aws.String(p.name),
+		Filter:       &types.ActionExecutionFilter{PipelineExecutionId: aws.String(id)},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("listing actions of pipeline %s execution %s: %w", p.name, id, err)
+		}
+		for _, action := range page.ActionExecutionDetails {
+			if action.Status != types.ActionExecutionStatusFailed {
+				continue
+			}
+			failure := aws.ToString(action.StageName) + "/" + aws.ToString(action.ActionName)
+			if action.Output != nil && action.Output.ExecutionResult != nil && action.Output.ExecutionResult.ErrorDetails != nil {
+				details := action.Output.ExecutionResult.ErrorDetails
+				failure += fmt.Sprintf(": %s: %s", aws.ToString(details.Code), aws.ToString(details.Message))
+			}
+			failures = append(failures, failure)
+		}
+	}
+	return strings.Join(failures, "; "), nil
+}
+
+func (p *Pipeline) Stop(ctx context.Context) error {
+	id := p.ID()
+	if id == "" {
+		return ErrNotStarted
+	}
+	_, err := p.client.StopPipelineExecution(ctx, &codepipeline.StopPipelineExecutionInput{
+		PipelineName:        aws.String(p.name),
+		PipelineExecutionId: aws.String(id),
+		Abandon:             true,
+		Reason:              aws.String("stopped by orchestration"),
+	})
+	return err
+}
@@ -0,0 +1,336 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+)
+
+// GlueAPI is the subset of the Glue client used by the Glue runs. It is satisfied by *glue.Client.
+type GlueAPI interface {
+	StartJobRun(ctx context.Context, params *glue.StartJobRunInput, optFns ...func(*glue.Options)) (*glue.StartJobRunOutput, error)
+	GetJobRun(ctx context.Context, params *glue.GetJobRunInput, optFns ...func(*glue.Options)) (*glue.GetJobRunOutput, error)
+	BatchStopJobRun(ctx context.Context, params *glue.BatchStopJobRunInput, optFns ...func(*glue.Options)) (*glue.BatchStopJobRunOutput, error)
+	StartWorkflowRun(ctx context.Context, params *glue.StartWorkflowRunInput, optFns ...func(*glue.Options)) (*glue.StartWorkflowRunOutput, error)
+	GetWorkflowRun(ctx context.Context, params *glue.GetWorkflowRunInput, optFns ...func(*glue.Options)) (*glue.GetWorkflowRunOutput, error)
+	StopWorkflowRun(ctx context.Context, params *glue.StopWorkflowRunInput, optFns ...func(*glue.Options)) (*glue.StopWorkflowRunOutput, error)
+	StartCrawler(ctx context.Context, params *glue.StartCrawlerInput, optFns ...func(*glue.Options)) (*glue.StartCrawlerOutput, error)
+	GetCrawler(ctx context.Context, params *glue.GetCrawlerInput, optFns ...func(*glue.Options)) (*glue.GetCrawlerOutput, error)
+	StopCrawler(ctx context.Context, params *glue.StopCrawlerInput, optFns ...func(*glue.Options)) (*glue.StopCrawlerOutput, error)
+}
+
+var _ GlueAPI = (*glue.Client)(nil)
+
+// runID holds the identifier of a run, set once by Start.
+type runID struct {
+	mu sync.Mutex
+	id string
+}
+
+func (r *runID) ID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id
+}
+
+func (r *runID) set(id string) {
+	r.mu.Lock()
+	r.id = id
+	r.mu.Unlock()
+}
+
+// start calls fn unless the run already started, storing the returned identifier.
+func (r *runID) start(fn func() (string, error)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.id != "" {
+		return ErrAlreadyStarted
+	}
+	id, err := fn()
+	if err != nil {
+		return err
+	}
+	r.id = id
+	return nil
+}
+
+// GlueJobConfig holds the parameters of a Glue job run. Zero values keep the job definition.
+type GlueJobConfig struct {
+	Arguments       map[string]string
+	WorkerType      types.WorkerType
+	NumberOfWorkers int32
+	Timeout         time.Duration // Rounded up to the minute
+}
+
+// GlueJob is a run of a Glue job.
+type GlueJob struct {
+	runID
+	client GlueAPI
+	name   string
+	config GlueJobConfig
+}
+
+// NewGlueJob creates a run of the named job. Use Attach to follow a run started elsewhere.
+func NewGlueJob(client GlueAPI, name string, cfg GlueJobConfig) *GlueJob {
+	return &GlueJob{client: client, name: name, config: cfg}
+}
+
+// Attach makes the run follow an existing job run instead of starting a new one.
+func (j *GlueJob) Attach(runID string) *GlueJob {
+	j.set(runID)
+	return j
+}
+
+func (j *GlueJob) Kind() string { return "glue job" }
+func (j *GlueJob) Name() string { return j.name }
+
+func (j *GlueJob) Start(ctx context.Context) error {
+	return j.start(func() (string, error) {
+		input := &glue.StartJobRunInput{JobName: aws.String(j.name), Arguments: j.config.Arguments, WorkerType: j.config.WorkerType}
+		if j.config.NumberOfWorkers > 0 {
+			input.NumberOfWorkers = aws.Int32(j.config.NumberOfWorkers)
+		}
+		if j.config.Timeout > 0 {
+			input.Timeout = aws.Int32(int32((j.config.Timeout + time.Minute - 1) / time.Minute))
+		}
+		out, err := j.client.StartJobRun(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		return aws.ToString(out.JobRunId), nil
+	})
+}
+
+func (j *GlueJob) Status(ctx context.Context) (Status, error) {
+	id := j.ID()
+	if id == "" {
+		return Status{}, ErrNotStarted
+	}
+	out, err := j.client.GetJobRun(ctx, &glue.GetJobRunInput{JobName: aws.String(j.name), RunId: aws.String(id)})
+	if err != nil {
+		return Status{}, err
+	}
+	run := out.JobRun
+	status := Status{Native: string(run.JobRunState), State: glueJobState(run.JobRunState), Time: time.Now()}
+	if status.State.Terminal() && status.State != StateSucceeded {
+		status.Message = aws.ToString(run.ErrorMessage)
+	}
+	return status, nil
+}
+
+func (j *GlueJob) Stop(ctx context.Context) error {
+	id := j.ID()
+	if id == "" {
+		return ErrNotStarted
+	}
+	out, err := j.client.BatchStopJobRun(ctx, &glue.BatchStopJobRunInput{JobName: aws.String(j.name), JobRunIds: []string{id}})
+	if err != nil {
+		return err
+	}
+	for _, e := range out.Errors {
+		if e.ErrorDetail != nil {
+			return fmt.Errorf("stopping glue job %s run %s: %s: %s", j.name, id, aws.ToString(e.ErrorDetail.ErrorCode), aws.ToString(e.ErrorDetail.ErrorMessage))
+		}
+	}
+	return nil
+}
+
+func glueJobState(state types.JobRunState) State {
+	switch state {
+	case types.JobRunStateRunning:
+		return StateRunning
+	case types.JobRunStateStopping:
+		return StateStopping
+	case types.JobRunStateSucceeded:
+		return StateSucceeded
+	case types.JobRunStateFailed, types.JobRunStateError, types.JobRunStateExpired:
+		return StateFailed
+	case types.JobRunStateStopped:
+		return StateStopped
+	case types.JobRunStateTimeout:
+		return StateTimedOut
+	}
+	return StatePending
+}
+
+// GlueWorkflow is a run of a Glue workflow.
+type GlueWorkflow struct {
+	runID
+	client     GlueAPI
+	name       string
+	properties map[string]string
+}
+
+// NewGlueWorkflow creates a run of the named workflow. The properties override the default run
+// properties of the workflow for this run only.
+func NewGlueWorkflow(client GlueAPI, name string, properties map[string]string) *GlueWorkflow {
+	return &GlueWorkflow{client: client, name: name, properties: properties}
+}
+
+// Attach makes the run follow an existing workflow run instead of starting a new one.
+func (w *GlueWorkflow) Attach(runID string) *GlueWorkflow {
+	w.set(runID)
+	return w
+}
+
+func (w *GlueWorkflow) Kind() string { return "glue workflow" }
+func (w *GlueWorkflow) Name() string { return w.name }
+
+func (w *GlueWorkflow) Start(ctx context.Context) error {
+	return w.start(func() (string, error) {
+		out, err := w.client.StartWorkflowRun(ctx, &glue.StartWorkflowRunInput{Name: aws.String(w.name), RunProperties: w.properties})
+		if err != nil {
+			return "", err
+		}
+		return aws.ToString(out.RunId), nil
+	})
+}
+
+// Status reports a completed workflow as failed when any of its actions failed, errored or timed out.
+func (w *GlueWorkflow) Status(ctx context.Context) (Status, error) {
+	id := w.ID()
+	if id == "" {
+		return Status{}, ErrNotStarted
+	}
+	out, err := w.client.GetWorkflowRun(ctx, &glue.GetWorkflowRunInput{Name: aws.String(w.name), RunId: aws.String(id)})
+	if err != nil {
+		return Status{}, err
+	}
+	run := out.Run
+	status := Status{Native: string(run.Status), Time: time.Now()}
+	switch run.Status {
+	case types.WorkflowRunStatusRunning:
+		status.State = StateRunning
+	case types.WorkflowRunStatusStopping:
+		status.State = StateStopping
+	case types.WorkflowRunStatusStopped:
+		status.State = StateStopped
+	case types.WorkflowRunStatusError:
+		status.State = StateFailed
+		status.Message = aws.ToString(run.ErrorMessage)
+	case types.WorkflowRunStatusCompleted:
+		status.State = StateSucceeded
+		if stats := run.Statistics; stats != nil && stats.FailedActions+stats.ErroredActions+stats.TimeoutActions > 0 {
+			status.State = StateFailed
+			status.Message = fmt.Sprintf("%d of %d actions failed, %d errored, %d timed out",
+				stats.FailedActions, stats.TotalActions, stats.ErroredActions, stats.TimeoutActions)
+		}
+	default:
+		status.State = StatePending
+	}
+	return status, nil
+}
+
+func (w *GlueWorkflow) Stop(ctx context.Context) error {
+	id := w.ID()
+	if id == "" {
+		return ErrNotStarted
+	}
+	_, err := w.client.StopWorkflowRun(ctx, &glue.StopWorkflowRunInput{Name: aws.String(w.name), RunId: aws.String(id)})
+	return err
+}
+
+// GlueCrawler is a crawl of a Glue crawler. Crawls have no identifier, the ID of the run is the
+// crawler name and the result is taken from the last crawl of the crawler.
+type GlueCrawler struct {
+	client   GlueAPI
+	name     string
+	mu       sync.Mutex
+	started  bool
+	previous time.Time // Start time of the last crawl before Start
+	running  bool      // Whether the crawler was seen running since Start
+}
+
+// NewGlueCrawler creates a crawl of the named crawler.
+func NewGlueCrawler(client GlueAPI, name string) *GlueCrawler {
+	return &GlueCrawler{client: client, name: name}
+}
+
+func (c *GlueCrawler) Kind() string { return "glue crawler" }
+func (c *GlueCrawler) Name() string { return c.name }
+
+func (c *GlueCrawler) ID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started {
+		return ""
+	}
+	return c.name
+}
+
+func (c *GlueCrawler) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return ErrAlreadyStarted
+	}
+	out, err := c.client.GetCrawler(ctx, &glue.GetCrawlerInput{Name: aws.String(c.name)})
+	if err != nil {
+		return err
+	}
+	if last := out.Crawler.LastCrawl; last != nil {
+		c.previous = aws.ToTime(last.StartTime)
+	}
+	if _, err = c.client.StartCrawler(ctx, &glue.StartCrawlerInput{Name: aws.String(c.name)}); err != nil {
+		return err
+	}
+	c.started = true
+	return nil
+}
+
+// Status reports the crawl as pending until the crawler is seen running, or the last crawl of
+// the crawler is more recent than the one preceding Start.
+func (c *GlueCrawler) Status(ctx context.Context) (Status, error) {
+	if c.ID() == "" {
+		return Status{}, ErrNotStarted
+	}
+	out, err := c.client.GetCrawler(ctx, &glue.GetCrawlerInput{Name: aws.String(c.name)})
+	if err != nil {
+		return Status{}, err
+	}
+	crawler := out.Crawler
+	status := Status{Native: string(crawler.State), Time: time.Now()}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch crawler.State {
+	case types.CrawlerStateRunning:
+		c.running = true
+		status.State = StateRunning
+		return status, nil
+	case types.CrawlerStateStopping:
+		c.running = true
+		status.State = StateStopping
+		return status, nil
+	}
+
+	last := crawler.LastCrawl
+	if last == nil || (!c.running && !aws.ToTime(last.StartTime).After(c.previous)) {
+		status.State = StatePending
+		return status, nil
+	}
+	status.Native = string(last.Status)
+	switch last.Status {
+	case types.LastCrawlStatusSucceeded:
+		status.State = StateSucceeded
+	case types.LastCrawlStatusCancelled:
+		status.State = StateStopped
+	default:
+		status.State = StateFailed
+		status.Message = aws.ToString(last.ErrorMessage)
+	}
+	return status, nil
+}
+
+func (c *GlueCrawler) Stop(ctx context.Context) error {
+	if c.ID() == "" {
+		return ErrNotStarted
+	}
+	_, err := c.client.StopCrawler(ctx, &glue.StopCrawlerInput{Name: aws.String(c.name)})
+	return err
+}
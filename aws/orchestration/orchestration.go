@@ -0,0 +1,228 @@
+// Package orchestration starts AWS Glue jobs, workflows and crawlers, Step Functions executions
+// and CodePipeline executions behind a common Run interface, and waits for them to reach a
+// terminal state.
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/seidu626/go-buildingblocks/aws/internal/retry"
+	"go.uber.org/zap"
+)
+
+// State is the state of a run, normalized across services.
+type State string
+
+const (
+	StatePending   State = "PENDING"
+	StateRunning   State = "RUNNING"
+	StateStopping  State = "STOPPING"
+	StateSucceeded State = "SUCCEEDED"
+	StateFailed    State = "FAILED"
+	StateStopped   State = "STOPPED"
+	StateTimedOut  State = "TIMED_OUT"
+)
+
+// Terminal reports whether the run cannot change state anymore.
+func (s State) Terminal() bool {
+	switch s {
+	case StateSucceeded, StateFailed, StateStopped, StateTimedOut:
+		return true
+	}
+	return false
+}
+
+// Status is a snapshot of a run.
+type Status struct {
+	State   State
+	Native  string    // State as reported by the service, e.g. "TIMEOUT" for Glue or "Superseded" for CodePipeline
+	Message string    // Error details when the run did not succeed
+	Output  string    // Output of the run, when the service returns one (Step Functions)
+	Time    time.Time // When the status was observed
+}
+
+// Run is a job, workflow, crawl or execution that can be started once and then polled.
+type Run interface {
+	// Kind describes the type of run, e.g. "glue job"
+	Kind() string
+	// Name is the name of the job, workflow, state machine or pipeline
+	Name() string
+	// ID identifies the run once started
+	ID() string
+	Start(ctx context.Context) error
+	Status(ctx context.Context) (Status, error)
+	Stop(ctx context.Context) error
+}
+
+var (
+	ErrNotStarted     = errors.New("orchestration: run not started")
+	ErrAlreadyStarted = errors.New("orchestration: run already started")
+	ErrWaitTimeout    = errors.New("orchestration: timed out waiting for run")
+)
+
+// RunError is returned by Wait when the run reaches a terminal state other than StateSucceeded.
+type RunError struct {
+	Kind   string
+	Name   string
+	ID     string
+	Status Status
+}
+
+func (e *RunError) Error() string {
+	msg := fmt.Sprintf("orchestration: %s %s run %s ended in state %s", e.Kind, e.Name, e.ID, e.Status.Native)
+	if e.Status.Message != "" {
+		msg += ": " + e.Status.Message
+	}
+	return msg
+}
+
+// WaitOptions configures Wait and Watch.
+type WaitOptions struct {
+	Timeout      time.Duration // Maximum time to wait, 0 to wait until ctx is done
+	PollInterval time.Duration // Delay between status checks (default 15s)
+
+	// StopOnTimeout stops the run when Timeout elapses or ctx is done.
+	StopOnTimeout bool
+
+	// OnTransition is called every time the state of the run changes, including the first observed state.
+	OnTransition func(Status)
+
+	// MaxErrors is the number of consecutive throttling or transient status errors tolerated,
+	// retried with backoff (default 5). Other errors end the wait.
+	MaxErrors int
+
+	Logger *zap.Logger
+}
+
+func (o *WaitOptions) setDefaults() {
+	if o.PollInterval <= 0 {
+		o.PollInterval = 15 * time.Second
+	}
+	if o.MaxErrors <= 0 {
+		o.MaxErrors = 5
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+}
+
+// Wait polls the run until it reaches a terminal state and returns its last status. The error
+// is a *RunError if the run did not succeed, and wraps ErrWaitTimeout if Timeout elapsed first.
+func Wait(ctx context.Context, run Run, opts WaitOptions) (Status, error) {
+	opts.setDefaults()
+	if run.ID() == "" {
+		return Status{}, ErrNotStarted
+	}
+	waitCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	log := opts.Logger.With(zap.String("kind", run.Kind()), zap.String("name", run.Name()), zap.String("run_id", run.ID()))
+	var last Status
+	failures := 0
+	for {
+		delay := opts.PollInterval
+		status, err := run.Status(waitCtx)
+		if err != nil {
+			if waitCtx.Err() == nil {
+				if !retryable(err) || failures >= opts.MaxErrors {
+					return last, fmt.Errorf("getting status of %s %s: %w", run.Kind(), run.Name(), err)
+				}
+				failures++
+				delay = retry.Backoff(failures, opts.PollInterval, maxErrorBackoff*opts.PollInterval)
+				log.Warn("getting run status", zap.Error(err), zap.Duration("retry_in", delay))
+			}
+		} else {
+			failures = 0
+			if status.State != last.State {
+				log.Info("run state changed", zap.String("from", string(last.State)), zap.String("to", string(status.State)), zap.String("native", status.Native))
+				if opts.OnTransition != nil {
+					opts.OnTransition(status)
+				}
+			}
+			last = status
+			if status.State.Terminal() {
+				if status.State != StateSucceeded {
+					return status, &RunError{Kind: run.Kind(), Name: run.Name(), ID: run.ID(), Status: status}
+				}
+				return status, nil
+			}
+		}
+
+		if retry.Sleep(waitCtx, delay) {
+			continue
+		}
+
+		if opts.StopOnTimeout {
+			// waitCtx is done, give the stop call its own deadline
+			stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+			if err := run.Stop(stopCtx); err != nil {
+				log.Warn("stopping run", zap.Error(err))
+			}
+			cancel()
+		}
+		if ctx.Err() != nil {
+			return last, ctx.Err()
+		}
+		return last, fmt.Errorf("%w: %s %s run %s still %s after %s", ErrWaitTimeout, run.Kind(), run.Name(), run.ID(), last.State, opts.Timeout)
+	}
+}
+
+// maxErrorBackoff bounds the delay between the retries of the status errors, in poll intervals
+const maxErrorBackoff = 8
+
+// retryable reports whether a status error is a throttling or a transient error of the service
+func retryable(err error) bool {
+	return awsretry.IsErrorThrottles(awsretry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary ||
+		awsretry.IsErrorRetryables(awsretry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// Event is a state transition streamed by Watch. The last event of the stream has Done set,
+// with Err holding the result of Wait.
+type Event struct {
+	Status Status
+	Done   bool
+	Err    error
+}
+
+// Watch waits for the run in the background, streaming every state transition. The channel is
+// closed after the final event. opts.OnTransition, if set, is still called. Once ctx is done,
+// the events not received are dropped, so the caller can stop reading by cancelling ctx.
+func Watch(ctx context.Context, run Run, opts WaitOptions) <-chan Event {
+	events := make(chan Event, 8)
+	send := func(event Event) {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+	onTransition := opts.OnTransition
+	opts.OnTransition = func(status Status) {
+		if onTransition != nil {
+			onTransition(status)
+		}
+		send(Event{Status: status})
+	}
+	go func() {
+		defer close(events)
+		status, err := Wait(ctx, run, opts)
+		send(Event{Status: status, Done: true, Err: err})
+	}()
+	return events
+}
+
+// StartAndWait starts the run and waits for it to complete, see Wait.
+func StartAndWait(ctx context.Context, run Run, opts WaitOptions) (Status, error) {
+	if err := run.Start(ctx); err != nil {
+		return Status{}, fmt.Errorf("starting %s %s: %w", run.Kind(), run.Name(), err)
+	}
+	return Wait(ctx, run, opts)
+}
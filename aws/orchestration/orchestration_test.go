@@ -0,0 +1,317 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	cptypes "github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	gluetypes "github.com/aws/aws-sdk-go-v2/service/glue/types"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	sfntypes "github.com/aws/aws-sdk-go-v2/service/sfn/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastPoll = WaitOptions{PollInterval: time.Millisecond}
+
+// fakeGlue replays a sequence of states for every kind of run, repeating the last one
+type fakeGlue struct {
+	GlueAPI
+	mu           sync.Mutex
+	jobStates    []gluetypes.JobRunState
+	jobErrs      []error // Returned by GetJobRun before the states
+	workflowRuns []gluetypes.WorkflowRun
+	crawlers     []gluetypes.Crawler
+	started      *glue.StartJobRunInput
+	properties   map[string]string
+	stopped      int
+}
+
+func next[T any](states *[]T) T {
+	state := (*states)[0]
+	if len(*states) > 1 {
+		*states = (*states)[1:]
+	}
+	return state
+}
+
+func (f *fakeGlue) StartJobRun(_ context.Context, params *glue.StartJobRunInput, _ ...func(*glue.Options)) (*glue.StartJobRunOutput, error) {
+	f.started = params
+	return &glue.StartJobRunOutput{JobRunId: aws.String("jr_1")}, nil
+}
+
+func (f *fakeGlue) GetJobRun(_ context.Context, params *glue.GetJobRunInput, _ ...func(*glue.Options)) (*glue.GetJobRunOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.jobErrs) > 0 {
+		err := f.jobErrs[0]
+		f.jobErrs = f.jobErrs[1:]
+		return nil, err
+	}
+	state := next(&f.jobStates)
+	run := &gluetypes.JobRun{Id: params.RunId, JobRunState: state}
+	if state == gluetypes.JobRunStateFailed {
+		run.ErrorMessage = aws.String("AnalysisException: path does not exist")
+	}
+	return &glue.GetJobRunOutput{JobRun: run}, nil
+}
+
+func (f *fakeGlue) BatchStopJobRun(_ context.Context, _ *glue.BatchStopJobRunInput, _ ...func(*glue.Options)) (*glue.BatchStopJobRunOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped++
+	return &glue.BatchStopJobRunOutput{}, nil
+}
+
+func (f *fakeGlue) StartWorkflowRun(_ context.Context, params *glue.StartWorkflowRunInput, _ ...func(*glue.Options)) (*glue.StartWorkflowRunOutput, error) {
+	f.properties = params.RunProperties
+	return &glue.StartWorkflowRunOutput{RunId: aws.String("wr_1")}, nil
+}
+
+func (f *fakeGlue) GetWorkflowRun(_ context.Context, _ *glue.GetWorkflowRunInput, _ ...func(*glue.Options)) (*glue.GetWorkflowRunOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	run := next(&f.workflowRuns)
+	return &glue.GetWorkflowRunOutput{Run: &run}, nil
+}
+
+func (f *fakeGlue) StartCrawler(_ context.Context, _ *glue.StartCrawlerInput, _ ...func(*glue.Options)) (*glue.StartCrawlerOutput, error) {
+	return &glue.StartCrawlerOutput{}, nil
+}
+
+func (f *fakeGlue) GetCrawler(_ context.Context, _ *glue.GetCrawlerInput, _ ...func(*glue.Options)) (*glue.GetCrawlerOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	crawler := next(&f.crawlers)
+	return &glue.GetCrawlerOutput{Crawler: &crawler}, nil
+}
+
+func TestGlueJobSucceeds(t *testing.T) {
+	client := &fakeGlue{jobStates: []gluetypes.JobRunState{
+		gluetypes.JobRunStateStarting, gluetypes.JobRunStateRunning, gluetypes.JobRunStateRunning, gluetypes.JobRunStateSucceeded,
+	}}
+	job := NewGlueJob(client, "load-orders", GlueJobConfig{Arguments: map[string]string{"--day": "2024-01-01"}, NumberOfWorkers: 4, Timeout: 90 * time.Second})
+
+	var transitions []State
+	opts := fastPoll
+	opts.OnTransition = func(s Status) { transitions = append(transitions, s.State) }
+	status, err := StartAndWait(context.Background(), job, opts)
+	require.NoError(t, err)
+	assert.Equal(t, StateSucceeded, status.State)
+	assert.Equal(t, []State{StatePending, StateRunning, StateSucceeded}, transitions)
+	assert.Equal(t, "jr_1", job.ID())
+	assert.EqualValues(t, 4, aws.ToInt32(client.started.NumberOfWorkers))
+	assert.EqualValues(t, 2, aws.ToInt32(client.started.Timeout))
+	assert.Equal(t, "2024-01-01", client.started.Arguments["--day"])
+
+	assert.ErrorIs(t, job.Start(context.Background()), ErrAlreadyStarted)
+}
+
+func TestGlueJobFailure(t *testing.T) {
+	client := &fakeGlue{jobStates: []gluetypes.JobRunState{gluetypes.JobRunStateRunning, gluetypes.JobRunStateFailed}}
+	job := NewGlueJob(client, "load-orders", GlueJobConfig{}).Attach("jr_7")
+
+	status, err := Wait(context.Background(), job, fastPoll)
+	var runErr *RunError
+	require.ErrorAs(t, err, &runErr)
+	assert.Equal(t, StateFailed, status.State)
+	assert.Equal(t, "jr_7", runErr.ID)
+	assert.Contains(t, err.Error(), "path does not exist")
+}
+
+func TestWaitTimeoutStopsRun(t *testing.T) {
+	client := &fakeGlue{jobStates: []gluetypes.JobRunState{gluetypes.JobRunStateRunning}}
+	job := NewGlueJob(client, "load-orders", GlueJobConfig{}).Attach("jr_1")
+
+	opts := fastPoll
+	opts.Timeout = 20 * time.Millisecond
+	opts.StopOnTimeout = true
+	status, err := Wait(context.Background(), job, opts)
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+	assert.Equal(t, StateRunning, status.State)
+	assert.Equal(t, 1, client.stopped)
+
+	_, err = Wait(context.Background(), NewGlueJob(client, "load-orders", GlueJobConfig{}), fastPoll)
+	assert.ErrorIs(t, err, ErrNotStarted)
+}
+
+func TestWaitRetriesThrottling(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	client := &fakeGlue{
+		jobErrs:   []error{throttled, throttled},
+		jobStates: []gluetypes.JobRunState{gluetypes.JobRunStateRunning, gluetypes.JobRunStateSucceeded},
+	}
+	job := NewGlueJob(client, "load-orders", GlueJobConfig{}).Attach("jr_1")
+	status, err := Wait(context.Background(), job, fastPoll)
+	require.NoError(t, err)
+	assert.Equal(t, StateSucceeded, status.State)
+
+	client.jobErrs = []error{throttled, throttled, throttled}
+	opts := fastPoll
+	opts.MaxErrors = 2
+	_, err = Wait(context.Background(), job, opts)
+	assert.ErrorIs(t, err, throttled)
+
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException"}
+	client.jobErrs = []error{denied}
+	_, err = Wait(context.Background(), job, fastPoll)
+	assert.ErrorIs(t, err, denied)
+	assert.Empty(t, client.jobErrs)
+}
+
+func TestWatchStopsWhenCancelled(t *testing.T) {
+	// The state changes at every poll, more events than the channel buffers
+	var runs []gluetypes.WorkflowRun
+	for i := 0; i < 100; i++ {
+		runs = append(runs, gluetypes.WorkflowRun{Status: gluetypes.WorkflowRunStatusRunning}, gluetypes.WorkflowRun{Status: gluetypes.WorkflowRunStatusStopping})
+	}
+	client := &fakeGlue{workflowRuns: runs}
+	workflow := NewGlueWorkflow(client, "nightly", nil).Attach("wr_1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := Watch(ctx, workflow, fastPoll)
+	<-events
+	assert.Eventually(t, func() bool { return len(events) == cap(events) }, 5*time.Second, time.Millisecond)
+
+	// The channel is full and not read anymore, Watch must drop the events and close it
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	for i := len(events); i > 0; i-- {
+		<-events
+	}
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not close its channel")
+	}
+}
+
+func TestWatchStreamsTransitions(t *testing.T) {
+	client := &fakeGlue{workflowRuns: []gluetypes.WorkflowRun{
+		{Status: gluetypes.WorkflowRunStatusRunning},
+		{Status: gluetypes.WorkflowRunStatusCompleted, Statistics: &gluetypes.WorkflowRunStatistics{TotalActions: 3, SucceededActions: 2, FailedActions: 1}},
+	}}
+	workflow := NewGlueWorkflow(client, "nightly", map[string]string{"day": "2024-01-01"})
+	require.NoError(t, workflow.Start(context.Background()))
+	assert.Equal(t, "2024-01-01", client.properties["day"])
+
+	var events []Event
+	for event := range Watch(context.Background(), workflow, fastPoll) {
+		events = append(events, event)
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, StateRunning, events[0].Status.State)
+	assert.Equal(t, StateFailed, events[1].Status.State)
+	assert.True(t, events[2].Done)
+	assert.ErrorContains(t, events[2].Err, "1 of 3 actions failed")
+}
+
+func TestGlueCrawlerIgnoresPreviousCrawl(t *testing.T) {
+	previous := time.Now().Add(-time.Hour)
+	current := time.Now()
+	client := &fakeGlue{crawlers: []gluetypes.Crawler{
+		// Read by Start, then by the first status before the crawler starts
+		{State: gluetypes.CrawlerStateReady, LastCrawl: &gluetypes.LastCrawlInfo{StartTime: &previous, Status: gluetypes.LastCrawlStatusFailed}},
+		{State: gluetypes.CrawlerStateReady, LastCrawl: &gluetypes.LastCrawlInfo{StartTime: &previous, Status: gluetypes.LastCrawlStatusFailed}},
+		{State: gluetypes.CrawlerStateRunning},
+		{State: gluetypes.CrawlerStateReady, LastCrawl: &gluetypes.LastCrawlInfo{StartTime: &current, Status: gluetypes.LastCrawlStatusSucceeded}},
+	}}
+	crawler := NewGlueCrawler(client, "raw-orders")
+	assert.Empty(t, crawler.ID())
+
+	var transitions []State
+	opts := fastPoll
+	opts.OnTransition = func(s Status) { transitions = append(transitions, s.State) }
+	status, err := StartAndWait(context.Background(), crawler, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "SUCCEEDED", status.Native)
+	assert.Equal(t, []State{StatePending, StateRunning, StateSucceeded}, transitions)
+}
+
+type fakeSFN struct {
+	SFNAPI
+	outputs []*sfn.DescribeExecutionOutput
+	stopped bool
+}
+
+func (f *fakeSFN) StartExecution(_ context.Context, params *sfn.StartExecutionInput, _ ...func(*sfn.Options)) (*sfn.StartExecutionOutput, error) {
+	return &sfn.StartExecutionOutput{ExecutionArn: aws.String(aws.ToString(params.StateMachineArn) + ":" + aws.ToString(params.Name))}, nil
+}
+
+func (f *fakeSFN) DescribeExecution(_ context.Context, _ *sfn.DescribeExecutionInput, _ ...func(*sfn.Options)) (*sfn.DescribeExecutionOutput, error) {
+	return next(&f.outputs), nil
+}
+
+func (f *fakeSFN) StopExecution(_ context.Context, _ *sfn.StopExecutionInput, _ ...func(*sfn.Options)) (*sfn.StopExecutionOutput, error) {
+	f.stopped = true
+	return &sfn.StopExecutionOutput{}, nil
+}
+
+func TestStepFunction(t *testing.T) {
+	client := &fakeSFN{outputs: []*sfn.DescribeExecutionOutput{
+		{Status: sfntypes.ExecutionStatusRunning},
+		{Status: sfntypes.ExecutionStatusSucceeded, Output: aws.String(`{"rows":10}`)},
+	}}
+	execution := NewStepFunction(client, "arn:sm", "run-1", `{}`)
+	status, err := StartAndWait(context.Background(), execution, fastPoll)
+	require.NoError(t, err)
+	assert.Equal(t, "arn:sm:run-1", execution.ID())
+	assert.Equal(t, `{"rows":10}`, status.Output)
+
+	client.outputs = []*sfn.DescribeExecutionOutput{{Status: sfntypes.ExecutionStatusFailed, Error: aws.String("States.TaskFailed"), Cause: aws.String("lambda timed out")}}
+	_, err = Wait(context.Background(), NewStepFunction(client, "arn:sm", "", "").Attach("arn:exec"), fastPoll)
+	assert.ErrorContains(t, err, "States.TaskFailed: lambda timed out")
+}
+
+type fakePipeline struct {
+	CodePipelineAPI
+	statuses []cptypes.PipelineExecutionStatus
+	actions  []cptypes.ActionExecutionDetail
+	started  *codepipeline.StartPipelineExecutionInput
+}
+
+func (f *fakePipeline) StartPipelineExecution(_ context.Context, params *codepipeline.StartPipelineExecutionInput, _ ...func(*codepipeline.Options)) (*codepipeline.StartPipelineExecutionOutput, error) {
+	f.started = params
+	return &codepipeline.StartPipelineExecutionOutput{PipelineExecutionId: aws.String("exec-1")}, nil
+}
+
+func (f *fakePipeline) GetPipelineExecution(_ context.Context, params *codepipeline.GetPipelineExecutionInput, _ ...func(*codepipeline.Options)) (*codepipeline.GetPipelineExecutionOutput, error) {
+	return &codepipeline.GetPipelineExecutionOutput{PipelineExecution: &cptypes.PipelineExecution{
+		PipelineExecutionId: params.PipelineExecutionId,
+		Status:              next(&f.statuses),
+	}}, nil
+}
+
+func (f *fakePipeline) ListActionExecutions(_ context.Context, params *codepipeline.ListActionExecutionsInput, _ ...func(*codepipeline.Options)) (*codepipeline.ListActionExecutionsOutput, error) {
+	if params.NextToken == nil {
+		return &codepipeline.ListActionExecutionsOutput{ActionExecutionDetails: f.actions[:1], NextToken: aws.String("page-2")}, nil
+	}
+	return &codepipeline.ListActionExecutionsOutput{ActionExecutionDetails: f.actions[1:]}, nil
+}
+
+func TestPipelineFailureDetails(t *testing.T) {
+	client := &fakePipeline{
+		statuses: []cptypes.PipelineExecutionStatus{cptypes.PipelineExecutionStatusInProgress, cptypes.PipelineExecutionStatusFailed},
+		actions: []cptypes.ActionExecutionDetail{
+			{StageName: aws.String("Source"), ActionName: aws.String("Checkout"), Status: cptypes.ActionExecutionStatusSucceeded},
+			{StageName: aws.String("Deploy"), ActionName: aws.String("Migrate"), Status: cptypes.ActionExecutionStatusFailed, Output: &cptypes.ActionExecutionOutput{
+				ExecutionResult: &cptypes.ActionExecutionResult{ErrorDetails: &cptypes.ErrorDetails{Code: aws.String("JobFailed"), Message: aws.String("exit status 1")}},
+			}},
+		},
+	}
+	pipeline := NewPipeline(client, "deploy", map[string]string{"env": "qa"})
+	status, err := StartAndWait(context.Background(), pipeline, fastPoll)
+	var runErr *RunError
+	require.True(t, errors.As(err, &runErr))
+	assert.Equal(t, StateFailed, status.State)
+	assert.Equal(t, "Deploy/Migrate: JobFailed: exit status 1", status.Message)
+	assert.Equal(t, "env", aws.ToString(client.started.Variables[0].Name))
+}
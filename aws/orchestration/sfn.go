@@ -0,0 +1,107 @@
+package orchestration
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/aws/aws-sdk-go-v2/service/sfn/types"
+)
+
+// SFNAPI is the subset of the Step Functions client used by StepFunction. It is satisfied by *sfn.Client.
+type SFNAPI interface {
+	StartExecution(ctx context.Context, params *sfn.StartExecutionInput, optFns ...func(*sfn.Options)) (*sfn.StartExecutionOutput, error)
+	DescribeExecution(ctx context.Context, params *sfn.DescribeExecutionInput, optFns ...func(*sfn.Options)) (*sfn.DescribeExecutionOutput, error)
+	StopExecution(ctx context.Context, params *sfn.StopExecutionInput, optFns ...func(*sfn.Options)) (*sfn.StopExecutionOutput, error)
+}
+
+var _ SFNAPI = (*sfn.Client)(nil)
+
+// StepFunction is an execution of a Step Functions state machine. Its ID is the execution ARN.
+type StepFunction struct {
+	runID
+	client          SFNAPI
+	stateMachineArn string
+	executionName   string
+	input           string
+}
+
+// NewStepFunction creates an execution of the state machine with the given JSON input.
+// The execution name is optional; when set, starting twice with the same input is idempotent.
+func NewStepFunction(client SFNAPI, stateMachineArn, executionName, input string) *StepFunction {
+	return &StepFunction{client: client, stateMachineArn: stateMachineArn, executionName: executionName, input: input}
+}
+
+// Attach makes the run follow an existing execution instead of starting a new one.
+func (s *StepFunction) Attach(executionArn string) *StepFunction {
+	s.set(executionArn)
+	return s
+}
+
+func (s *StepFunction) Kind() string { return "step function" }
+func (s *StepFunction) Name() string { return s.stateMachineArn }
+
+func (s *StepFunction) Start(ctx context.Context) error {
+	return s.start(func() (string, error) {
+		input := &sfn.StartExecutionInput{StateMachineArn: aws.String(s.stateMachineArn), Input: aws.String(s.input)}
+		if s.executionName != "" {
+			input.Name = aws.String(s.executionName)
+		}
+		out, err := s.client.StartExecution(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		return aws.ToString(out.ExecutionArn), nil
+	})
+}
+
+// Status returns the output of the execution once it succeeded, and its error and cause when it failed.
+func (s *StepFunction) Status(ctx context.Context) (Status, error) {
+	id := s.ID()
+	if id == "" {
+		return Status{}, ErrNotStarted
+	}
+	out, err := s.client.DescribeExecution(ctx, &sfn.DescribeExecutionInput{ExecutionArn: aws.String(id)})
+	if err != nil {
+		return Status{}, err
+	}
+	status := Status{Native: string(out.Status), Time: time.Now()}
+	switch out.Status {
+	case types.ExecutionStatusRunning:
+		status.State = StateRunning
+	case types.ExecutionStatusSucceeded:
+		status.State = StateSucceeded
+		status.Output = aws.ToString(out.Output)
+	case types.ExecutionStatusFailed:
+		status.State = StateFailed
+	case types.ExecutionStatusTimedOut:
+		status.State = StateTimedOut
+	case types.ExecutionStatusAborted:
+		status.State = StateStopped
+	case types.ExecutionStatusPendingRedrive:
+		// A failed execution waiting to be redriven is not going anywhere on its own
+		status.State = StateFailed
+	default:
+		status.State = StatePending
+	}
+	if status.State.Terminal() && status.State != StateSucceeded {
+		status.Message = aws.ToString(out.Error)
+		if cause := aws.ToString(out.Cause); cause != "" {
+			if status.Message != "" {
+				status.Message += ": "
+			}
+			status.Message += cause
+		}
+	}
+	return status, nil
+}
+
+func (s *StepFunction) Stop(ctx context.Context) error {
+	id := s.ID()
+	if id == "" {
+		return ErrNotStarted
+	}
+	_, err := s.client.StopExecution(ctx, &sfn.StopExecutionInput{ExecutionArn: aws.String(id), Cause: aws.String("stopped by orchestration")})
+	return err
+}
@@ -0,0 +1,675 @@
+package redshiftutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	S3utils "github.com/seidu626/go-buildingblocks/aws/S3"
+	"github.com/seidu626/go-buildingblocks/sql/schema"
+	"go.uber.org/zap"
+)
+
+// S3API is the subset of the S3 client used to stage the files of a load. It is satisfied by *s3.Client.
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+var _ S3API = (*s3.Client)(nil)
+
+var (
+	ErrTableNotFound  = errors.New("redshift: table does not exist")
+	ErrSchemaMismatch = errors.New("redshift: source does not match the table")
+	ErrEmptySource    = errors.New("redshift: source has no header")
+)
+
+// Format is the format of a load source.
+type Format int
+
+const (
+	FormatCSV Format = iota
+	FormatParquet
+)
+
+// Source is the data loaded by Load. CSV sources must start with a header row. Parquet
+// sources are staged as a single file and read into memory, so the table must already exist.
+type Source struct {
+	Reader    io.Reader
+	Format    Format
+	Separator rune // CSV field separator (default ',')
+}
+
+// LoadMode selects how the staged data is written to the table.
+type LoadMode int
+
+const (
+	// LoadAppend copies the data straight into the table.
+	LoadAppend LoadMode = iota
+	// LoadUpsert copies the data into a staging table and merges it into the table on the KeyColumns.
+	LoadUpsert
+)
+
+// LoadOptions configures Load.
+type LoadOptions struct {
+	Bucket  string // Bucket where the files are staged
+	Prefix  string // Key prefix of the staged files
+	IAMRole string // ARN of the role used by COPY to read the bucket
+	Region  string // Region of the bucket, when different from the cluster's
+
+	Mode       LoadMode
+	KeyColumns []string // Columns matching the rows in LoadUpsert mode
+
+	CreateTable bool // Create the table from the schema inferred on the sample when it does not exist
+	SampleRows  int  // Rows used to infer the schema (default 1000)
+	PartSize    int  // Uncompressed bytes per staged CSV file (default 64MiB)
+	MaxErrors   int  // Rows COPY may reject before failing
+
+	KeepStagedFiles bool
+
+	S3     S3API // Defaults to S3utils.S3Client
+	Logger *zap.Logger
+}
+
+func (o *LoadOptions) setDefaults() {
+	if o.SampleRows <= 0 {
+		o.SampleRows = 1000
+	}
+	if o.PartSize <= 0 {
+		o.PartSize = 64 << 20
+	}
+	if o.S3 == nil {
+		o.S3 = S3utils.S3Client
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+}
+
+// LoadResult describes a completed load.
+type LoadResult struct {
+	Table    string
+	Columns  []string // Columns of the table loaded from the source, in source order
+	Created  bool     // Whether the table was created from the inferred schema
+	Manifest string   // S3 URL of the manifest
+	Files    []string // S3 URLs of the staged files
+	Rows     int64    // Rows staged, CSV only
+	Loaded   int64    // Rows loaded by COPY
+}
+
+// LoadError is a row rejected by COPY, as recorded in stl_load_errors.
+type LoadError struct {
+	File     string
+	Line     int64
+	Column   string
+	Type     string
+	Position int
+	RawLine  string
+	RawValue string
+	Code     int
+	Reason   string
+}
+
+// CopyError is returned by Load when COPY fails. Errors holds the rejected rows, if any.
+type CopyError struct {
+	QueryID int64
+	Errors  []LoadError
+	Err     error
+}
+
+func (e *CopyError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("redshift: copy failed: %v", e.Err)
+	}
+	first := e.Errors[0]
+	return fmt.Sprintf("redshift: copy failed with %d load errors, first at %s line %d column %s: %s (code %d)",
+		len(e.Errors), first.File, first.Line, first.Column, first.Reason, first.Code)
+}
+
+func (e *CopyError) Unwrap() error { return e.Err }
+
+// Load loads the source into the table through S3: the data is staged as gzip compressed
+// files with a manifest, then copied with the IAM role. The columns of a CSV source are
+// matched by name against the table, which is created from the inferred schema when missing
+// and opts.CreateTable is set. If COPY fails the error is a *CopyError with the rejected rows.
+func Load(ctx context.Context, db *sql.DB, source Source, table string, opts LoadOptions) (*LoadResult, error) {
+	opts.setDefaults()
+	if opts.Bucket == "" || opts.IAMRole == "" {
+		return nil, errors.New("redshift: staging bucket and IAM role are required")
+	}
+	if opts.Mode == LoadUpsert && len(opts.KeyColumns) == 0 {
+		return nil, errors.New("redshift: upsert requires key columns")
+	}
+
+	// COPY and stl_load_errors must share the session to match pg_last_copy_id
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	loader := &loader{conn: conn, opts: opts, table: table, log: opts.Logger.With(zap.String("table", table))}
+	loader.stage = fmt.Sprintf("s3://%s/%s", opts.Bucket, path.Join(opts.Prefix, ColumnName(table), fmt.Sprintf("%d-%04d", time.Now().UnixNano(), rand.Intn(10000))))
+	result := &LoadResult{Table: table}
+	defer func() {
+		if !opts.KeepStagedFiles {
+			loader.cleanup(ctx, result)
+		}
+	}()
+
+	switch source.Format {
+	case FormatCSV:
+		err = loader.stageCSV(ctx, source, result)
+	case FormatParquet:
+		err = loader.stageParquet(ctx, source, result)
+	default:
+		err = fmt.Errorf("redshift: unsupported format %d", source.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Manifest, err = loader.writeManifest(ctx, result.Files, source.Format); err != nil {
+		return nil, err
+	}
+
+	if opts.Mode == LoadUpsert {
+		err = loader.upsert(ctx, source.Format, result)
+	} else {
+		result.Loaded, err = loader.copy(ctx, conn, table, source.Format, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	loader.log.Info("load completed", zap.Int64("rows", result.Loaded), zap.Int("files", len(result.Files)))
+	return result, nil
+}
+
+type loader struct {
+	conn  *sql.Conn
+	opts  LoadOptions
+	table string
+	stage string           // S3 URL prefix of the staged files
+	sizes map[string]int64 // Size of the staged files by URL
+	keys  []string         // Columns of LoadOptions.KeyColumns in LoadUpsert mode
+	log   *zap.Logger
+}
+
+// execer is implemented by *sql.Conn and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (l *loader) stageCSV(ctx context.Context, source Source, result *LoadResult) error {
+	reader := csv.NewReader(source.Reader)
+	if source.Separator != 0 {
+		reader.Comma = source.Separator
+	}
+	reader.LazyQuotes = true
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return ErrEmptySource
+	}
+	if err != nil {
+		return err
+	}
+
+	var sample [][]string
+	for len(sample) < l.opts.SampleRows {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading row %d: %w", len(sample)+2, err)
+		}
+		sample = append(sample, record)
+	}
+	if result.Columns, result.Created, err = l.prepareTable(ctx, headers, sample); err != nil {
+		return err
+	}
+	if l.opts.Mode == LoadUpsert {
+		if l.keys, err = keyColumns(l.opts.KeyColumns, headers, result.Columns); err != nil {
+			return err
+		}
+	}
+
+	part := newPart()
+	flush := func() error {
+		if part.rows == 0 {
+			return nil
+		}
+		key := fmt.Sprintf("%s/part-%05d.csv.gz", l.stage, len(result.Files))
+		if err := l.put(ctx, key, part.bytes()); err != nil {
+			return err
+		}
+		result.Files = append(result.Files, key)
+		part = newPart()
+		return nil
+	}
+	write := func(record []string) error {
+		if err := part.write(record); err != nil {
+			return err
+		}
+		result.Rows++
+		if part.size >= l.opts.PartSize {
+			return flush()
+		}
+		return nil
+	}
+
+	for _, record := range sample {
+		if err = write(record); err != nil {
+			return err
+		}
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading row %d: %w", result.Rows+2, err)
+		}
+		if err = write(record); err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// part is a staged CSV file being written
+type part struct {
+	buf  bytes.Buffer
+	gz   *gzip.Writer
+	csv  *csv.Writer
+	size int // Uncompressed bytes
+	rows int
+}
+
+func newPart() *part {
+	p := &part{}
+	p.gz = gzip.NewWriter(&p.buf)
+	p.csv = csv.NewWriter(countingWriter{w: p.gz, n: &p.size})
+	return p
+}
+
+func (p *part) write(record []string) error {
+	p.rows++
+	if err := p.csv.Write(record); err != nil {
+		return err
+	}
+	p.csv.Flush()
+	return p.csv.Error()
+}
+
+func (p *part) bytes() []byte {
+	_ = p.gz.Close()
+	return p.buf.Bytes()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *int
+}
+
+func (c countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	*c.n += n
+	return n, err
+}
+
+func (l *loader) stageParquet(ctx context.Context, source Source, result *LoadResult) error {
+	columns, err := l.tableColumns(ctx, l.table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, l.table)
+	}
+	if l.opts.Mode == LoadUpsert {
+		// The columns of a Parquet source are those of the table
+		lower := make([]string, len(l.opts.KeyColumns))
+		for i, key := range l.opts.KeyColumns {
+			lower[i] = strings.ToLower(key)
+		}
+		if l.keys, err = keyColumns(lower, columns, columns); err != nil {
+			return err
+		}
+	}
+	data, err := io.ReadAll(source.Reader)
+	if err != nil {
+		return err
+	}
+	key := l.stage + "/part-00000.parquet"
+	if err = l.put(ctx, key, data); err != nil {
+		return err
+	}
+	result.Files = append(result.Files, key)
+	return nil
+}
+
+// prepareTable returns the table columns matching the headers, creating the table if needed.
+// The headers match the columns named with schema.SanitizeNames, or with ColumnName in the
+// tables created by CreateTableByType.
+func (l *loader) prepareTable(ctx context.Context, headers []string, sample [][]string) ([]string, bool, error) {
+	columns := schema.SanitizeNames(headers)
+	existing, err := l.tableColumns(ctx, l.table)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(existing) == 0 {
+		if !l.opts.CreateTable {
+			return nil, false, fmt.Errorf("%w: %s", ErrTableNotFound, l.table)
+		}
+		ddl, err := schema.Redshift.CreateTable(l.table, schema.InferRecords(headers, sample, schema.Options{}), schema.TableOptions{IfNotExists: true})
+		if err != nil {
+			return nil, false, err
+		}
+		if _, err = l.conn.ExecContext(ctx, ddl); err != nil {
+			return nil, false, fmt.Errorf("creating table %s: %w", l.table, err)
+		}
+		l.log.Info("table created", zap.String("ddl", ddl))
+		return columns, true, nil
+	}
+
+	known := make(map[string]bool, len(existing))
+	for _, column := range existing {
+		known[column] = true
+	}
+	var missing []string
+	for i, column := range columns {
+		if known[column] {
+			continue
+		}
+		// Named by CreateTableByType, unquoted so lower cased by Redshift
+		if legacy := strings.ToLower(ColumnName(headers[i])); known[legacy] {
+			columns[i] = legacy
+			continue
+		}
+		missing = append(missing, headers[i])
+	}
+	if len(missing) > 0 {
+		return nil, false, fmt.Errorf("%w: %s has no columns for %s", ErrSchemaMismatch, l.table, strings.Join(missing, ", "))
+	}
+	return columns, false, nil
+}
+
+// tableColumns returns the lower case columns of the table in ordinal order, none if it does not exist.
+func (l *loader) tableColumns(ctx context.Context, table string) ([]string, error) {
+	schema, name := "public", table
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		schema, name = table[:i], table[i+1:]
+	}
+	rows, err := l.conn.QueryContext(ctx, `select column_name from information_schema.columns
+where table_schema = $1 and table_name = $2 order by ordinal_position`, strings.ToLower(schema), strings.ToLower(name))
+	if err != nil {
+		return nil, fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, strings.ToLower(column))
+	}
+	return columns, rows.Err()
+}
+
+type manifest struct {
+	Entries []manifestEntry `json:"entries"`
+}
+
+type manifestEntry struct {
+	URL       string        `json:"url"`
+	Mandatory bool          `json:"mandatory"`
+	Meta      *manifestMeta `json:"meta,omitempty"`
+}
+
+type manifestMeta struct {
+	ContentLength int64 `json:"content_length"`
+}
+
+func (l *loader) writeManifest(ctx context.Context, files []string, format Format) (string, error) {
+	m := manifest{Entries: make([]manifestEntry, 0, len(files))}
+	for _, file := range files {
+		entry := manifestEntry{URL: file, Mandatory: true}
+		if format == FormatParquet {
+			// Columnar formats require the length of the files in the manifest
+			entry.Meta = &manifestMeta{ContentLength: l.sizes[file]}
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	key := l.stage + "/manifest.json"
+	return key, l.put(ctx, key, data)
+}
+
+func (l *loader) put(ctx context.Context, url string, data []byte) error {
+	bucket, key := S3utils.ParseS3Path(url)
+	if _, err := l.opts.S3.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), Body: bytes.NewReader(data)}); err != nil {
+		return fmt.Errorf("staging %s: %w", url, err)
+	}
+	if l.sizes == nil {
+		l.sizes = make(map[string]int64)
+	}
+	l.sizes[url] = int64(len(data))
+	return nil
+}
+
+func (l *loader) cleanup(ctx context.Context, result *LoadResult) {
+	var objects []types.ObjectIdentifier
+	for url := range l.sizes {
+		_, key := S3utils.ParseS3Path(url)
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+	if len(objects) == 0 {
+		return
+	}
+	// Staged files are removed even when the load was cancelled
+	ctx = context.WithoutCancel(ctx)
+	for start := 0; start < len(objects); start += 1000 {
+		end := min(start+1000, len(objects))
+		if _, err := l.opts.S3.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(l.opts.Bucket),
+			Delete: &types.Delete{Objects: objects[start:end], Quiet: aws.Bool(true)},
+		}); err != nil {
+			l.log.Warn("deleting staged files", zap.String("manifest", result.Manifest), zap.Error(err))
+		}
+	}
+}
+
+// copyStatement builds the COPY of the manifest into the table.
+func (l *loader) copyStatement(table string, columns []string, manifest string, format Format) string {
+	var sb strings.Builder
+	sb.WriteString("COPY " + quoteTable(table))
+	if len(columns) > 0 {
+		sb.WriteString(" (" + quoteIdentifiers(columns) + ")")
+	}
+	sb.WriteString(" FROM " + quoteLiteral(manifest) + " IAM_ROLE " + quoteLiteral(l.opts.IAMRole) + " MANIFEST")
+	if format == FormatParquet {
+		sb.WriteString(" FORMAT AS PARQUET")
+	} else {
+		sb.WriteString(" CSV GZIP DELIMITER ',' EMPTYASNULL TIMEFORMAT 'auto' DATEFORMAT 'auto'")
+		if l.opts.MaxErrors > 0 {
+			sb.WriteString(fmt.Sprintf(" MAXERROR %d", l.opts.MaxErrors))
+		}
+	}
+	if l.opts.Region != "" {
+		sb.WriteString(" REGION " + quoteLiteral(l.opts.Region))
+	}
+	return sb.String()
+}
+
+// copy runs COPY into the table and returns the loaded rows. Rejected rows are read from
+// stl_load_errors on the loader connection, so a transaction must be rolled back before.
+func (l *loader) copy(ctx context.Context, e execer, table string, format Format, result *LoadResult) (int64, error) {
+	if _, err := e.ExecContext(ctx, l.copyStatement(table, result.Columns, result.Manifest, format)); err != nil {
+		return 0, l.copyError(ctx, e, err)
+	}
+	var loaded int64
+	if err := e.QueryRowContext(ctx, "select pg_last_copy_count()").Scan(&loaded); err != nil {
+		return 0, err
+	}
+	return loaded, nil
+}
+
+func (l *loader) copyError(ctx context.Context, e execer, err error) error {
+	if tx, ok := e.(*sql.Tx); ok {
+		_ = tx.Rollback()
+	}
+	copyErr := &CopyError{Err: err}
+	if scanErr := l.conn.QueryRowContext(ctx, "select pg_last_copy_id()").Scan(&copyErr.QueryID); scanErr != nil || copyErr.QueryID <= 0 {
+		return copyErr
+	}
+	loadErrors, queryErr := LoadErrors(ctx, l.conn, copyErr.QueryID)
+	if queryErr != nil {
+		l.log.Warn("reading stl_load_errors", zap.Int64("query", copyErr.QueryID), zap.Error(queryErr))
+	}
+	copyErr.Errors = loadErrors
+	return copyErr
+}
+
+// upsert copies the data into a temporary table shaped like the table, then merges it on the key columns.
+func (l *loader) upsert(ctx context.Context, format Format, result *LoadResult) error {
+	columns := result.Columns
+	if format == FormatParquet {
+		var err error
+		if columns, err = l.tableColumns(ctx, l.table); err != nil {
+			return err
+		}
+	}
+	tx, err := l.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	staging := fmt.Sprintf("stage_%s_%d", strings.ToLower(ColumnName(strings.ReplaceAll(l.table, ".", "_"))), time.Now().UnixNano())
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s)", quoteIdentifier(staging), quoteTable(l.table))); err != nil {
+		return fmt.Errorf("creating staging table: %w", err)
+	}
+	if result.Loaded, err = l.copy(ctx, tx, staging, format, result); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, mergeStatement(l.table, staging, columns, l.keys)); err != nil {
+		return fmt.Errorf("merging into %s: %w", l.table, err)
+	}
+	if _, err = tx.ExecContext(ctx, "DROP TABLE "+quoteIdentifier(staging)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// keyColumns returns the table columns of the keys, which are names of the source, e.g. the
+// headers of a CSV, names[i] being loaded into columns[i]
+func keyColumns(keys, names, columns []string) ([]string, error) {
+	byName := make(map[string]string, len(names))
+	for i, name := range names {
+		if _, ok := byName[name]; !ok {
+			byName[name] = columns[i]
+		}
+	}
+	keyColumns := make([]string, len(keys))
+	for i, key := range keys {
+		column, ok := byName[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: key column %s is not in the source", ErrSchemaMismatch, key)
+		}
+		keyColumns[i] = column
+	}
+	return keyColumns, nil
+}
+
+// mergeStatement builds the MERGE of the staging table into the table, matching rows on the keys.
+func mergeStatement(table, staging string, columns, keys []string) string {
+	target, source := quoteTable(table), quoteIdentifier(staging)
+	on := make([]string, len(keys))
+	for i, key := range keys {
+		on[i] = fmt.Sprintf("%s.%s = %s.%s", target, quoteIdentifier(key), source, quoteIdentifier(key))
+	}
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	var set, values []string
+	for _, column := range columns {
+		values = append(values, source+"."+quoteIdentifier(column))
+		if !isKey[column] {
+			set = append(set, fmt.Sprintf("%s = %s.%s", quoteIdentifier(column), source, quoteIdentifier(column)))
+		}
+	}
+	if len(set) == 0 {
+		// Every column is a key, matched rows are left as they are
+		set = append(set, fmt.Sprintf("%s = %s.%s", quoteIdentifier(keys[0]), source, quoteIdentifier(keys[0])))
+	}
+	return fmt.Sprintf("MERGE INTO %s USING %s ON %s WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+		target, source, strings.Join(on, " AND "), strings.Join(set, ", "), quoteIdentifiers(columns), strings.Join(values, ", "))
+}
+
+// LoadErrors returns the rows rejected by the COPY with the given query id, from stl_load_errors.
+func LoadErrors(ctx context.Context, conn interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}, queryID int64) ([]LoadError, error) {
+	rows, err := conn.QueryContext(ctx, `select filename, line_number, colname, type, position, raw_line, raw_field_value, err_code, err_reason
+from stl_load_errors where query = $1 order by line_number`, queryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var loadErrors []LoadError
+	for rows.Next() {
+		var e LoadError
+		if err = rows.Scan(&e.File, &e.Line, &e.Column, &e.Type, &e.Position, &e.RawLine, &e.RawValue, &e.Code, &e.Reason); err != nil {
+			return nil, err
+		}
+		// char columns of the system tables are blank padded
+		e.File, e.Column, e.Type = strings.TrimSpace(e.File), strings.TrimSpace(e.Column), strings.TrimSpace(e.Type)
+		e.RawLine, e.RawValue, e.Reason = strings.TrimSpace(e.RawLine), strings.TrimSpace(e.RawValue), strings.TrimSpace(e.Reason)
+		loadErrors = append(loadErrors, e)
+	}
+	return loadErrors, rows.Err()
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(strings.ToLower(name), `"`, `""`) + `"`
+}
+
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i := range names {
+		quoted[i] = quoteIdentifier(names[i])
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quoteTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i := range parts {
+		parts[i] = quoteIdentifier(parts[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
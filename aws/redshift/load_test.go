@@ -0,0 +1,248 @@
+package redshiftutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedshift is a database/sql driver recording the statements and answering the
+// queries of the loader
+type fakeRedshift struct {
+	mu         sync.Mutex
+	statements []string
+	columns    map[string][]string // Columns by schema.table
+	failCopy   bool
+	loadErrors [][]driver.Value
+}
+
+func (f *fakeRedshift) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeRedshift) Driver() driver.Driver                        { return nil }
+
+func (f *fakeRedshift) record(query string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, query)
+}
+
+type fakeConn struct{ db *fakeRedshift }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { c.db.record("BEGIN"); return c, nil }
+func (c *fakeConn) Commit() error                       { c.db.record("COMMIT"); return nil }
+func (c *fakeConn) Rollback() error                     { c.db.record("ROLLBACK"); return nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	if c.db.failCopy && strings.HasPrefix(query, "COPY") {
+		return nil, errors.New("pq: Load into table failed. Check 'stl_load_errors' system table for details.")
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+	switch {
+	case strings.Contains(query, "information_schema.columns"):
+		rows := &fakeRows{columns: []string{"column_name"}}
+		for _, column := range c.db.columns[args[0].Value.(string)+"."+args[1].Value.(string)] {
+			rows.values = append(rows.values, []driver.Value{column})
+		}
+		return rows, nil
+	case strings.Contains(query, "pg_last_copy_count"):
+		return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{int64(3)}}}, nil
+	case strings.Contains(query, "pg_last_copy_id"):
+		return &fakeRows{columns: []string{"id"}, values: [][]driver.Value{{int64(7)}}}, nil
+	case strings.Contains(query, "stl_load_errors"):
+		return &fakeRows{columns: make([]string, 9), values: c.db.loadErrors}, nil
+	}
+	return nil, errors.New("unexpected query " + query)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	deleted []string
+}
+
+func (f *fakeS3) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects["s3://"+aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, object := range params.Delete.Objects {
+		f.deleted = append(f.deleted, aws.ToString(object.Key))
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func newFakes(columns map[string][]string) (*fakeRedshift, *sql.DB, *fakeS3) {
+	fake := &fakeRedshift{columns: columns}
+	return fake, sql.OpenDB(fake), &fakeS3{objects: map[string][]byte{}}
+}
+
+const ordersCSV = `id;amount;customer name
+1;10.5;alice
+2;3.25;bob
+3;7;carol
+`
+
+func gunzip(t *testing.T, data []byte) string {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestLoadCreatesTableAndCopies(t *testing.T) {
+	fake, db, store := newFakes(nil)
+	opts := LoadOptions{Bucket: "staging", Prefix: "loads", IAMRole: "arn:aws:iam::1:role/copy", CreateTable: true, PartSize: 20, S3: store, KeepStagedFiles: true}
+
+	result, err := Load(context.Background(), db, Source{Reader: strings.NewReader(ordersCSV), Separator: ';'}, "orders", opts)
+	require.NoError(t, err)
+	assert.True(t, result.Created)
+	assert.Equal(t, []string{"id", "amount", "customer_name"}, result.Columns)
+	assert.EqualValues(t, 3, result.Rows)
+	assert.EqualValues(t, 3, result.Loaded)
+	require.Len(t, result.Files, 2)
+	assert.Equal(t, "1,10.5,alice\n2,3.25,bob\n", gunzip(t, store.objects[result.Files[0]]))
+	assert.Equal(t, "3,7,carol\n", gunzip(t, store.objects[result.Files[1]]))
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(store.objects[result.Manifest], &m))
+	require.Len(t, m.Entries, 2)
+	assert.Equal(t, result.Files[0], m.Entries[0].URL)
+	assert.True(t, strings.HasPrefix(result.Manifest, "s3://staging/loads/orders/"))
+
+	assert.Contains(t, fake.statements, "CREATE TABLE IF NOT EXISTS orders (\n\tid INTEGER,\n\tamount DECIMAL(4,2),\n\tcustomer_name VARCHAR(256));")
+	assert.Contains(t, fake.statements, `COPY "orders" ("id", "amount", "customer_name") FROM '`+result.Manifest+
+		`' IAM_ROLE 'arn:aws:iam::1:role/copy' MANIFEST CSV GZIP DELIMITER ',' EMPTYASNULL TIMEFORMAT 'auto' DATEFORMAT 'auto'`)
+	assert.Empty(t, store.deleted)
+}
+
+func TestLoadValidatesExistingTable(t *testing.T) {
+	_, db, store := newFakes(map[string][]string{"sales.orders": {"id", "amount"}})
+	opts := LoadOptions{Bucket: "staging", IAMRole: "role", S3: store}
+
+	_, err := Load(context.Background(), db, Source{Reader: strings.NewReader(ordersCSV), Separator: ';'}, "sales.orders", opts)
+	assert.ErrorIs(t, err, ErrSchemaMismatch)
+	assert.ErrorContains(t, err, "customer name")
+
+	_, err = Load(context.Background(), db, Source{Reader: strings.NewReader(ordersCSV), Separator: ';'}, "missing", opts)
+	assert.ErrorIs(t, err, ErrTableNotFound)
+	assert.Empty(t, store.objects)
+}
+
+func TestLoadLegacyTable(t *testing.T) {
+	headers := []string{"Order.ID", "Amount (USD)"}
+	ddl := CreateTableByType("orders", headers, map[string]string{"Order.ID": "int", "Amount (USD)": "float"})
+	assert.True(t, strings.HasPrefix(ddl, "CREATE TABLE IF NOT EXISTS orders (\n\tOrder_ID "), ddl)
+	assert.Contains(t, ddl, "\tAmount__USD_ ")
+
+	// As read from information_schema
+	fake, db, store := newFakes(map[string][]string{"public.orders": {"order_id", "amount__usd_"}})
+	source := "Order.ID,Amount (USD)\n1,10.5\n"
+	result, err := Load(context.Background(), db, Source{Reader: strings.NewReader(source)}, "orders", LoadOptions{Bucket: "staging", IAMRole: "role", S3: store})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order_id", "amount__usd_"}, result.Columns)
+	assert.Contains(t, strings.Join(fake.statements, "\n"), `COPY "orders" ("order_id", "amount__usd_")`)
+}
+
+func TestLoadReportsCopyErrors(t *testing.T) {
+	fake, db, store := newFakes(map[string][]string{"public.orders": {"id", "amount", "customer_name"}})
+	fake.failCopy = true
+	fake.loadErrors = [][]driver.Value{
+		{"s3://staging/orders/part-00000.csv.gz   ", int64(2), "amount    ", "numeric   ", int64(3), "2,abc,bob", "abc   ", int64(1207), "Invalid digit, Value 'a', Pos 0   "},
+	}
+
+	_, err := Load(context.Background(), db, Source{Reader: strings.NewReader(ordersCSV), Separator: ';'}, "orders", LoadOptions{Bucket: "staging", IAMRole: "role", S3: store})
+	var copyErr *CopyError
+	require.ErrorAs(t, err, &copyErr)
+	assert.EqualValues(t, 7, copyErr.QueryID)
+	require.Len(t, copyErr.Errors, 1)
+	assert.Equal(t, LoadError{
+		File: "s3://staging/orders/part-00000.csv.gz", Line: 2, Column: "amount", Type: "numeric", Position: 3,
+		RawLine: "2,abc,bob", RawValue: "abc", Code: 1207, Reason: "Invalid digit, Value 'a', Pos 0",
+	}, copyErr.Errors[0])
+	assert.Contains(t, err.Error(), "line 2 column amount")
+	// The staged files and the manifest are removed
+	assert.Len(t, store.deleted, 2)
+}
+
+func TestLoadUpsert(t *testing.T) {
+	fake, db, store := newFakes(map[string][]string{"public.orders": {"id", "amount", "customer_name"}})
+	opts := LoadOptions{Bucket: "staging", IAMRole: "role", S3: store, Mode: LoadUpsert, KeyColumns: []string{"id"}}
+
+	result, err := Load(context.Background(), db, Source{Reader: strings.NewReader(ordersCSV), Separator: ';'}, "orders", opts)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, result.Loaded)
+
+	var statements []string
+	for _, statement := range fake.statements {
+		statements = append(statements, strings.SplitN(statement, " ", 3)[0])
+	}
+	assert.Equal(t, []string{"select", "BEGIN", "CREATE", "COPY", "select", "MERGE", "DROP", "COMMIT"}, statements)
+
+	_, err = Load(context.Background(), db, Source{Reader: strings.NewReader(ordersCSV)}, "orders", LoadOptions{Bucket: "b", IAMRole: "r", Mode: LoadUpsert})
+	assert.Error(t, err)
+
+	// Keys are headers, matched like the columns
+	fake, db, store = newFakes(map[string][]string{"public.users": {"id", "id_2", "c_3"}})
+	opts = LoadOptions{Bucket: "staging", IAMRole: "role", S3: store, Mode: LoadUpsert, KeyColumns: []string{"ID", "%"}}
+	_, err = Load(context.Background(), db, Source{Reader: strings.NewReader("id,ID,%\n1,2,3\n")}, "users", opts)
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(fake.statements, "\n"), `ON "users"."id_2" = "`)
+	assert.Contains(t, strings.Join(fake.statements, "\n"), `AND "users"."c_3" = "`)
+
+	opts.KeyColumns = []string{"missing"}
+	_, err = Load(context.Background(), db, Source{Reader: strings.NewReader("id,ID,%\n1,2,3\n")}, "users", opts)
+	assert.ErrorIs(t, err, ErrSchemaMismatch)
+}
+
+func TestMergeStatement(t *testing.T) {
+	assert.Equal(t,
+		`MERGE INTO "sales"."orders" USING "stage" ON "sales"."orders"."id" = "stage"."id" `+
+			`WHEN MATCHED THEN UPDATE SET "amount" = "stage"."amount" `+
+			`WHEN NOT MATCHED THEN INSERT ("id", "amount") VALUES ("stage"."id", "stage"."amount")`,
+		mergeStatement("sales.orders", "stage", []string{"id", "amount"}, []string{"id"}))
+}
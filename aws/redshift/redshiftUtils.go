@@ -97,32 +97,74 @@ func (c *Conf) Load(confFile string) error {
 	return nil
 }
 
+// PoolOptions configures the connection pool of the database handle. Zero values keep the database/sql defaults.
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// MakeRedshfitConnection opens a connection to the cluster with the default pool settings.
 func MakeRedshfitConnection(conf Conf) (*sql.DB, error) {
-	var err error
-	var db *sql.DB = nil
+	return MakeRedshiftConnectionContext(context.Background(), conf, PoolOptions{})
+}
+
+// MakeRedshiftConnectionContext opens a connection pool to the cluster and pings it within ctx.
+func MakeRedshiftConnectionContext(ctx context.Context, conf Conf, pool PoolOptions) (*sql.DB, error) {
 	if err := conf.Validate(); err != nil {
-		return db, err
+		return nil, err
 	}
-	url := fmt.Sprintf("sslmode=require user=%v password=%v host=%v port=%v dbname=%v",
-		conf.Username, conf.Password, conf.Host, conf.Port, conf.DBName)
-	if db, err = sql.Open("postgres", url); err != nil {
+	url := fmt.Sprintf("sslmode=require user=%s password=%s host=%s port=%s dbname=%s",
+		dsnValue(conf.Username), dsnValue(conf.Password), dsnValue(conf.Host), dsnValue(conf.Port.String()), dsnValue(conf.DBName))
+	db, err := sql.Open("postgres", url)
+	if err != nil {
 		return nil, fmt.Errorf("redshift connect error : (%s)", err.Error())
 	}
-	return db, db.Ping()
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// dsnValue quotes a value of a key/value connection string, so passwords can contain spaces and quotes
+func dsnValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(v) + "'"
 }
 
+// ColumnName returns the column name used in the tables created by CreateTableByType for a CSV header.
+func ColumnName(header string) string {
+	return columnReplacer.Replace(header)
+}
+
+var columnReplacer = strings.NewReplacer(".", "_", ",", "_", " ", "_", "(", "_", ")", "_", "/", "_")
+
 // CreateTableByType is delegated to create the `CREATE TABLE` query for the given table
 // tableName: Name of the table
 // headers: List of headers necessary to preserve orders
 // tableType: Map of headers:type for the given table
-
+// Its columns are named with ColumnName, e.g. "Amount (USD)" is Amount__USD_, while Load
+// creates the tables with schema.SanitizeNames, amount_usd. Load still matches the columns
+// of the tables created by CreateTableByType, so these tables need no migration.
 func CreateTableByType(tableName string, headers []string, tableType map[string]string) string {
 	var sb strings.Builder
 	translator := sqlutils.GetRedshiftTranslator()
 	sb.WriteString("CREATE TABLE IF NOT EXISTS " + tableName + " (\n")
-	replacer := strings.NewReplacer(".", "_", ",", "_", " ", "_", "(", "_", ")", "_", "/", "_")
 	for _, header := range headers {
-		fixHeader := replacer.Replace(header)
+		fixHeader := ColumnName(header)
 		//for k, v := range tableType {
 		sb.WriteString("\t" + fixHeader + " " + translator[tableType[header]] + ",\n")
 	}
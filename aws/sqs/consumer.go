@@ -0,0 +1,534 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/seidu626/go-buildingblocks/aws/internal/retry"
+	"go.uber.org/zap"
+)
+
+// API is the subset of the SQS client used by Consumer and Producer.
+// It is satisfied by *sqs.Client.
+type API interface {
+	GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	ChangeMessageVisibilityBatch(ctx context.Context, params *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+}
+
+var _ API = (*sqs.Client)(nil)
+
+// maxBatchSize is the maximum number of entries accepted by the SQS batch APIs.
+const maxBatchSize = 10
+
+const (
+	// maxWaitTime is the longest wait time of a long poll accepted by SQS.
+	maxWaitTime = 20 * time.Second
+	// shortPollInterval is the pause between the empty receives of a short-polling consumer.
+	shortPollInterval = time.Second
+)
+
+var (
+	// ErrConsumerStarted is returned by Start when the consumer is already running.
+	ErrConsumerStarted = errors.New("sqs: consumer already started")
+	// ErrNoQueue is returned when neither a queue URL nor a queue name is configured.
+	ErrNoQueue = errors.New("sqs: queue url or queue name is required")
+)
+
+// Handler processes a single message. Returning nil acknowledges (deletes) the message,
+// returning an error schedules it for redelivery or moves it to the dead-letter queue.
+type Handler func(ctx context.Context, msg sqsTypes.Message) error
+
+// ConsumerConfig holds the configuration of a Consumer.
+type ConsumerConfig struct {
+	QueueURL  string // Queue URL; resolved from QueueName when empty
+	QueueName string // Queue name, resolved once at start-up
+
+	Concurrency       int            // Number of concurrent handlers (default 1)
+	MaxMessages       int32          // Messages per receive call, 1-10 (default 10)
+	WaitTime          *time.Duration // Long-poll wait time, up to 20s, aws.Duration(0) short polls (default 20s)
+	VisibilityTimeout time.Duration  // Visibility timeout requested on receive (default 30s)
+	HeartbeatInterval time.Duration  // Interval of visibility extensions for slow handlers (default VisibilityTimeout/2)
+
+	// Failure handling
+	MinBackoff         time.Duration // Redelivery delay after the first failure (default 1s)
+	MaxBackoff         time.Duration // Upper bound of the redelivery delay (default 15m)
+	MaxReceiveCount    int           // Receive count after which a failed message is redriven (0 disables)
+	DeadLetterQueueURL string        // Queue receiving redriven messages; required when MaxReceiveCount > 0
+
+	// Delete batching
+	DeleteFlushInterval time.Duration // Max time a receipt handle waits before being deleted (default 1s)
+
+	// FIFO enables message-group ordering. It is set automatically for queues ending in ".fifo".
+	FIFO bool
+
+	Logger *zap.Logger
+}
+
+func (c *ConsumerConfig) setDefaults() {
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.MaxMessages <= 0 || c.MaxMessages > maxBatchSize {
+		c.MaxMessages = maxBatchSize
+	}
+	if c.WaitTime == nil || *c.WaitTime < 0 || *c.WaitTime > maxWaitTime {
+		waitTime := maxWaitTime
+		c.WaitTime = &waitTime
+	}
+	if c.VisibilityTimeout <= 0 {
+		c.VisibilityTimeout = 30 * time.Second
+	}
+	if c.HeartbeatInterval <= 0 {
+		c.HeartbeatInterval = c.VisibilityTimeout / 2
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 15 * time.Minute
+	}
+	// SQS caps the visibility timeout to 12 hours
+	if c.MaxBackoff > 12*time.Hour {
+		c.MaxBackoff = 12 * time.Hour
+	}
+	if c.DeleteFlushInterval <= 0 {
+		c.DeleteFlushInterval = time.Second
+	}
+	if strings.HasSuffix(c.QueueURL, ".fifo") || strings.HasSuffix(c.QueueName, ".fifo") {
+		c.FIFO = true
+	}
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+}
+
+// Consumer long-polls a queue and dispatches messages to a pool of handlers.
+//
+// Messages are deleted in batches once handled, kept invisible while their handler is still
+// running, and re-scheduled with an exponential backoff on failure. For FIFO queues the messages
+// sharing a MessageGroupId are handled sequentially, in the order they were received.
+//
+// Shutdown matches graceful.Operation, so a consumer can be drained through graceful.GracefulShutdown:
+//
+//	graceful.GracefulShutdown(ctx, logger, timeout, map[string]graceful.Operation{"sqs": consumer.Shutdown})
+type Consumer struct {
+	client  API
+	config  ConsumerConfig
+	handler Handler
+	logger  *zap.Logger
+
+	mu      sync.Mutex
+	started bool
+	stop    context.CancelFunc
+	done    chan struct{}
+
+	deletes chan string
+}
+
+// NewConsumer creates a Consumer for the configured queue.
+func NewConsumer(client API, cfg ConsumerConfig, handler Handler) (*Consumer, error) {
+	if client == nil {
+		return nil, errors.New("sqs: client is required")
+	}
+	if handler == nil {
+		return nil, errors.New("sqs: handler is required")
+	}
+	if cfg.QueueURL == "" && cfg.QueueName == "" {
+		return nil, ErrNoQueue
+	}
+	if cfg.MaxReceiveCount > 0 && cfg.DeadLetterQueueURL == "" {
+		return nil, errors.New("sqs: dead-letter queue url is required when MaxReceiveCount is set")
+	}
+	cfg.setDefaults()
+	return &Consumer{
+		client:  client,
+		config:  cfg,
+		handler: handler,
+		logger:  cfg.Logger.With(zap.String("queue", cfg.QueueURL+cfg.QueueName)),
+	}, nil
+}
+
+// Start polls the queue until ctx is cancelled or Shutdown is called. In-flight messages are
+// always drained before Start returns.
+func (c *Consumer) Start(ctx context.Context) error {
+	c.mu.Lock()
+	if c.started {
+		c.mu.Unlock()
+		return ErrConsumerStarted
+	}
+	c.started = true
+	pollCtx, cancel := context.WithCancel(ctx)
+	c.stop = cancel
+	c.done = make(chan struct{})
+	c.mu.Unlock()
+	defer close(c.done)
+	defer cancel()
+
+	if c.config.QueueURL == "" {
+		out, err := c.client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(c.config.QueueName)})
+		if err != nil {
+			return fmt.Errorf("sqs: resolving queue url: %w", err)
+		}
+		c.config.QueueURL = aws.ToString(out.QueueUrl)
+		if strings.HasSuffix(c.config.QueueURL, ".fifo") {
+			c.config.FIFO = true
+		}
+	}
+
+	// Handlers and deletes use a context detached from the poller, so that cancelling the
+	// consumer stops receiving without aborting the messages already in flight.
+	workCtx := context.WithoutCancel(ctx)
+
+	c.deletes = make(chan string, maxBatchSize*c.config.Concurrency)
+	deleterDone := make(chan struct{})
+	go func() {
+		defer close(deleterDone)
+		c.runDeleter(workCtx)
+	}()
+
+	jobs := make(chan []sqsTypes.Message)
+	var workers sync.WaitGroup
+	workers.Add(c.config.Concurrency)
+	for i := 0; i < c.config.Concurrency; i++ {
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				c.process(workCtx, job)
+			}
+		}()
+	}
+
+	err := c.poll(pollCtx, jobs)
+	close(jobs)
+	workers.Wait()
+	close(c.deletes)
+	<-deleterDone
+	return err
+}
+
+// Shutdown stops polling and waits for in-flight messages to be handled and deleted, or for ctx to expire.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.started {
+		c.mu.Unlock()
+		return nil
+	}
+	stop, done := c.stop, c.done
+	c.mu.Unlock()
+
+	stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Consumer) poll(ctx context.Context, jobs chan<- []sqsTypes.Message) error {
+	failures := 0
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		out, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(c.config.QueueURL),
+			MaxNumberOfMessages: c.config.MaxMessages,
+			WaitTimeSeconds:     int32(*c.config.WaitTime / time.Second),
+			VisibilityTimeout:   int32(c.config.VisibilityTimeout / time.Second),
+			MessageSystemAttributeNames: []sqsTypes.MessageSystemAttributeName{
+				sqsTypes.MessageSystemAttributeNameApproximateReceiveCount,
+				sqsTypes.MessageSystemAttributeNameMessageGroupId,
+			},
+			MessageAttributeNames: []string{"All"},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures++
+			delay := retry.Backoff(failures, c.config.MinBackoff, 30*time.Second)
+			c.logger.Warn("Receive failed", zap.Error(err), zap.Duration("retry_in", delay))
+			if !retry.Sleep(ctx, delay) {
+				return nil
+			}
+			continue
+		}
+		failures = 0
+		if len(out.Messages) == 0 && *c.config.WaitTime < time.Second {
+			// Short polling returns at once, the empty queue is polled again after a pause
+			if !retry.Sleep(ctx, shortPollInterval) {
+				return nil
+			}
+			continue
+		}
+
+		for _, job := range c.group(out.Messages) {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				// Messages that were not dispatched are released immediately
+				c.release(context.WithoutCancel(ctx), job)
+			}
+		}
+	}
+}
+
+// group splits the received messages into units of work. On standard queues every message is
+// handled independently; on FIFO queues the messages of the same group are kept together, in order.
+func (c *Consumer) group(messages []sqsTypes.Message) [][]sqsTypes.Message {
+	if !c.config.FIFO {
+		jobs := make([][]sqsTypes.Message, len(messages))
+		for i := range messages {
+			jobs[i] = messages[i : i+1]
+		}
+		return jobs
+	}
+	var jobs [][]sqsTypes.Message
+	index := make(map[string]int)
+	for _, msg := range messages {
+		groupID := msg.Attributes[string(sqsTypes.MessageSystemAttributeNameMessageGroupId)]
+		i, ok := index[groupID]
+		if !ok {
+			i = len(jobs)
+			index[groupID] = i
+			jobs = append(jobs, nil)
+		}
+		jobs[i] = append(jobs[i], msg)
+	}
+	return jobs
+}
+
+// process runs the handler over the messages of a job, keeping the pending ones invisible until handled.
+func (c *Consumer) process(ctx context.Context, job []sqsTypes.Message) {
+	var mu sync.Mutex
+	pending := make(map[string]struct{}, len(job))
+	for _, msg := range job {
+		pending[aws.ToString(msg.ReceiptHandle)] = struct{}{}
+	}
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go c.heartbeat(heartbeatCtx, &mu, pending)
+
+	for i, msg := range job {
+		err := c.handle(ctx, msg)
+
+		mu.Lock()
+		delete(pending, aws.ToString(msg.ReceiptHandle))
+		mu.Unlock()
+
+		if err == nil {
+			c.deletes <- aws.ToString(msg.ReceiptHandle)
+			continue
+		}
+		redelivered := c.fail(ctx, msg, err)
+		if redelivered && c.config.FIFO && i+1 < len(job) {
+			// Later messages of the group must not overtake the failed one
+			stopHeartbeat()
+			c.retryLater(ctx, job[i+1:], c.redeliveryDelay(msg))
+			return
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, msg sqsTypes.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sqs: handler panic: %v", r)
+		}
+	}()
+	return c.handler(ctx, msg)
+}
+
+// heartbeat extends the visibility timeout of the pending messages until ctx is cancelled.
+func (c *Consumer) heartbeat(ctx context.Context, mu *sync.Mutex, pending map[string]struct{}) {
+	ticker := time.NewTicker(c.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		mu.Lock()
+		handles := make([]string, 0, len(pending))
+		for handle := range pending {
+			handles = append(handles, handle)
+		}
+		mu.Unlock()
+		if err := c.changeVisibility(ctx, handles, c.config.VisibilityTimeout); err != nil && ctx.Err() == nil {
+			c.logger.Warn("Visibility extension failed", zap.Error(err))
+		}
+	}
+}
+
+// fail redrives the message to the dead-letter queue once it exceeded MaxReceiveCount,
+// otherwise it delays its redelivery with an exponential backoff. It reports whether the
+// message will be redelivered.
+func (c *Consumer) fail(ctx context.Context, msg sqsTypes.Message, cause error) bool {
+	receiveCount := receiveCount(msg)
+	logger := c.logger.With(zap.String("message_id", aws.ToString(msg.MessageId)), zap.Int("receive_count", receiveCount))
+
+	if c.config.MaxReceiveCount > 0 && receiveCount >= c.config.MaxReceiveCount {
+		if err := c.redrive(ctx, msg); err != nil {
+			logger.Error("Redrive to dead-letter queue failed", zap.Error(err), zap.NamedError("cause", cause))
+			c.retryLater(ctx, []sqsTypes.Message{msg}, c.redeliveryDelay(msg))
+			return true
+		}
+		logger.Warn("Message moved to dead-letter queue", zap.Error(cause))
+		c.deletes <- aws.ToString(msg.ReceiptHandle)
+		return false
+	}
+	logger.Warn("Message handling failed", zap.Error(cause))
+	c.retryLater(ctx, []sqsTypes.Message{msg}, c.redeliveryDelay(msg))
+	return true
+}
+
+func (c *Consumer) redrive(ctx context.Context, msg sqsTypes.Message) error {
+	entry := sqsTypes.SendMessageBatchRequestEntry{
+		Id:                aws.String("0"),
+		MessageBody:       msg.Body,
+		MessageAttributes: msg.MessageAttributes,
+	}
+	if strings.HasSuffix(c.config.DeadLetterQueueURL, ".fifo") {
+		groupID := msg.Attributes[string(sqsTypes.MessageSystemAttributeNameMessageGroupId)]
+		if groupID == "" {
+			groupID = "dlq"
+		}
+		entry.MessageGroupId = aws.String(groupID)
+		entry.MessageDeduplicationId = msg.MessageId
+	}
+	out, err := c.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(c.config.DeadLetterQueueURL),
+		Entries:  []sqsTypes.SendMessageBatchRequestEntry{entry},
+	})
+	if err != nil {
+		return err
+	}
+	if len(out.Failed) > 0 {
+		return fmt.Errorf("sqs: %s: %s", aws.ToString(out.Failed[0].Code), aws.ToString(out.Failed[0].Message))
+	}
+	return nil
+}
+
+func (c *Consumer) redeliveryDelay(msg sqsTypes.Message) time.Duration {
+	return retry.Backoff(receiveCount(msg), c.config.MinBackoff, c.config.MaxBackoff)
+}
+
+// retryLater makes the messages visible again after delay.
+func (c *Consumer) retryLater(ctx context.Context, messages []sqsTypes.Message, delay time.Duration) {
+	handles := make([]string, len(messages))
+	for i := range messages {
+		handles[i] = aws.ToString(messages[i].ReceiptHandle)
+	}
+	if err := c.changeVisibility(ctx, handles, delay); err != nil {
+		c.logger.Warn("Scheduling redelivery failed", zap.Error(err))
+	}
+}
+
+// release makes the messages visible again immediately.
+func (c *Consumer) release(ctx context.Context, messages []sqsTypes.Message) {
+	c.retryLater(ctx, messages, 0)
+}
+
+func (c *Consumer) changeVisibility(ctx context.Context, handles []string, timeout time.Duration) error {
+	var errs []error
+	for start := 0; start < len(handles); start += maxBatchSize {
+		end := min(start+maxBatchSize, len(handles))
+		entries := make([]sqsTypes.ChangeMessageVisibilityBatchRequestEntry, 0, end-start)
+		for i, handle := range handles[start:end] {
+			entries = append(entries, sqsTypes.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     aws.String(handle),
+				VisibilityTimeout: int32(timeout / time.Second),
+			})
+		}
+		out, err := c.client.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: aws.String(c.config.QueueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, failed := range out.Failed {
+			errs = append(errs, fmt.Errorf("sqs: %s: %s", aws.ToString(failed.Code), aws.ToString(failed.Message)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runDeleter deletes acknowledged messages in batches of up to 10, flushing at least every DeleteFlushInterval.
+func (c *Consumer) runDeleter(ctx context.Context) {
+	ticker := time.NewTicker(c.config.DeleteFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]string, 0, maxBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := c.deleteBatch(ctx, batch); err != nil {
+			c.logger.Error("Batch delete failed", zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case handle, ok := <-c.deletes:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, handle)
+			if len(batch) == maxBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (c *Consumer) deleteBatch(ctx context.Context, handles []string) error {
+	entries := make([]sqsTypes.DeleteMessageBatchRequestEntry, len(handles))
+	for i, handle := range handles {
+		entries[i] = sqsTypes.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: aws.String(handle),
+		}
+	}
+	out, err := c.client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(c.config.QueueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, failed := range out.Failed {
+		errs = append(errs, fmt.Errorf("sqs: delete %s: %s: %s", aws.ToString(failed.Id), aws.ToString(failed.Code), aws.ToString(failed.Message)))
+	}
+	return errors.Join(errs...)
+}
+
+// receiveCount returns the ApproximateReceiveCount of the message, defaulting to 1.
+func receiveCount(msg sqsTypes.Message) int {
+	count, err := strconv.Atoi(msg.Attributes[string(sqsTypes.MessageSystemAttributeNameApproximateReceiveCount)])
+	if err != nil || count < 1 {
+		return 1
+	}
+	return count
+}
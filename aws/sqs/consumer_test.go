@@ -0,0 +1,467 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMessage is a message stored by fakeSQS
+type fakeMessage struct {
+	id           string
+	body         string
+	groupID      string
+	receiveCount int
+	visibleAt    time.Time
+	handle       string
+}
+
+// fakeSQS is an in-memory SQS speaking the AWS JSON 1.0 protocol used by the SDK
+type fakeSQS struct {
+	mu      sync.Mutex
+	server  *httptest.Server
+	queues  map[string][]*fakeMessage
+	nextID  int
+	calls   map[string]int
+	batches map[string][]int // Entries per batch call, by operation
+	// failSends lists message bodies failing once with a server error on SendMessageBatch
+	failSends map[string]bool
+	// rejectSends lists message bodies always failing with a sender fault on SendMessageBatch
+	rejectSends map[string]bool
+}
+
+func newFakeSQS(t *testing.T, queues ...string) *fakeSQS {
+	f := &fakeSQS{
+		queues:      make(map[string][]*fakeMessage),
+		calls:       make(map[string]int),
+		batches:     make(map[string][]int),
+		failSends:   make(map[string]bool),
+		rejectSends: make(map[string]bool),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	for _, queue := range queues {
+		f.queues[f.url(queue)] = nil
+	}
+	return f
+}
+
+func (f *fakeSQS) url(queue string) string {
+	return f.server.URL + "/000000000000/" + queue
+}
+
+func (f *fakeSQS) client() *sqs.Client {
+	return sqs.New(sqs.Options{
+		BaseEndpoint:                     aws.String(f.server.URL),
+		Region:                           "us-east-1",
+		Credentials:                      credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		DisableMessageChecksumValidation: true,
+	})
+}
+
+// len returns the number of messages still stored in the queue
+func (f *fakeSQS) len(queue string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queues[f.url(queue)])
+}
+
+func (f *fakeSQS) hasQueue(url string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.queues[url]
+	return ok
+}
+
+func (f *fakeSQS) callCount(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[operation]
+}
+
+type fakeEntry struct {
+	Id                string
+	MessageBody       string
+	MessageGroupId    string
+	ReceiptHandle     string
+	VisibilityTimeout int
+}
+
+type fakeRequest struct {
+	QueueName           string
+	QueueUrl            string
+	MaxNumberOfMessages int
+	WaitTimeSeconds     int
+	VisibilityTimeout   int
+	Entries             []fakeEntry
+}
+
+func (f *fakeSQS) serve(w http.ResponseWriter, r *http.Request) {
+	operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AmazonSQS.")
+	var req fakeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if operation == "SendMessageBatch" && !f.hasQueue(req.QueueUrl) {
+		f.mu.Lock()
+		f.calls[operation]++
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"__type":  "com.amazonaws.sqs#QueueDoesNotExist",
+			"message": "The specified queue does not exist.",
+		})
+		return
+	}
+
+	var resp interface{}
+	switch operation {
+	case "GetQueueUrl":
+		resp = map[string]string{"QueueUrl": f.url(req.QueueName)}
+	case "ReceiveMessage":
+		resp = f.receive(r.Context(), req)
+	case "SendMessageBatch":
+		resp = f.sendBatch(req)
+	case "DeleteMessageBatch":
+		resp = f.deleteBatch(req)
+	case "ChangeMessageVisibilityBatch":
+		resp = f.changeVisibilityBatch(req)
+	default:
+		http.Error(w, "unsupported operation "+operation, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.calls[operation]++
+	if len(req.Entries) > 0 {
+		f.batches[operation] = append(f.batches[operation], len(req.Entries))
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (f *fakeSQS) receive(ctx context.Context, req fakeRequest) map[string]interface{} {
+	deadline := time.Now().Add(time.Duration(req.WaitTimeSeconds) * time.Second)
+	for {
+		f.mu.Lock()
+		var messages []map[string]interface{}
+		now := time.Now()
+		for _, msg := range f.queues[req.QueueUrl] {
+			if len(messages) == req.MaxNumberOfMessages {
+				break
+			}
+			if msg.visibleAt.After(now) {
+				continue
+			}
+			f.nextID++
+			msg.receiveCount++
+			msg.handle = fmt.Sprintf("%s-%d", msg.id, f.nextID)
+			msg.visibleAt = now.Add(time.Duration(req.VisibilityTimeout) * time.Second)
+			attributes := map[string]string{"ApproximateReceiveCount": strconv.Itoa(msg.receiveCount)}
+			if msg.groupID != "" {
+				attributes["MessageGroupId"] = msg.groupID
+			}
+			messages = append(messages, map[string]interface{}{
+				"MessageId":     msg.id,
+				"ReceiptHandle": msg.handle,
+				"Body":          msg.body,
+				"Attributes":    attributes,
+			})
+		}
+		f.mu.Unlock()
+
+		if len(messages) > 0 || time.Now().After(deadline) {
+			return map[string]interface{}{"Messages": messages}
+		}
+		select {
+		case <-ctx.Done():
+			return map[string]interface{}{}
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (f *fakeSQS) sendBatch(req fakeRequest) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var successful, failed []map[string]interface{}
+	for _, entry := range req.Entries {
+		if f.rejectSends[entry.MessageBody] {
+			failed = append(failed, map[string]interface{}{"Id": entry.Id, "Code": "InvalidParameterValue", "SenderFault": true})
+			continue
+		}
+		if f.failSends[entry.MessageBody] {
+			delete(f.failSends, entry.MessageBody)
+			failed = append(failed, map[string]interface{}{"Id": entry.Id, "Code": "InternalError", "SenderFault": false})
+			continue
+		}
+		f.nextID++
+		id := fmt.Sprintf("msg-%d", f.nextID)
+		f.queues[req.QueueUrl] = append(f.queues[req.QueueUrl], &fakeMessage{id: id, body: entry.MessageBody, groupID: entry.MessageGroupId})
+		successful = append(successful, map[string]interface{}{"Id": entry.Id, "MessageId": id, "MD5OfMessageBody": ""})
+	}
+	return map[string]interface{}{"Successful": successful, "Failed": failed}
+}
+
+func (f *fakeSQS) find(queueURL, handle string) int {
+	for i, msg := range f.queues[queueURL] {
+		if msg.handle == handle {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeSQS) deleteBatch(req fakeRequest) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var successful []map[string]string
+	for _, entry := range req.Entries {
+		if i := f.find(req.QueueUrl, entry.ReceiptHandle); i >= 0 {
+			f.queues[req.QueueUrl] = append(f.queues[req.QueueUrl][:i], f.queues[req.QueueUrl][i+1:]...)
+		}
+		successful = append(successful, map[string]string{"Id": entry.Id})
+	}
+	return map[string]interface{}{"Successful": successful}
+}
+
+func (f *fakeSQS) changeVisibilityBatch(req fakeRequest) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var successful []map[string]string
+	for _, entry := range req.Entries {
+		if i := f.find(req.QueueUrl, entry.ReceiptHandle); i >= 0 {
+			f.queues[req.QueueUrl][i].visibleAt = time.Now().Add(time.Duration(entry.VisibilityTimeout) * time.Second)
+		}
+		successful = append(successful, map[string]string{"Id": entry.Id})
+	}
+	return map[string]interface{}{"Successful": successful}
+}
+
+// runConsumer starts the consumer and returns a function shutting it down
+func runConsumer(t *testing.T, consumer *Consumer) func() {
+	errc := make(chan error, 1)
+	go func() { errc <- consumer.Start(context.Background()) }()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, consumer.Shutdown(ctx))
+		require.NoError(t, <-errc)
+	}
+}
+
+func sendBodies(t *testing.T, f *fakeSQS, queue string, groupID string, bodies ...string) {
+	producer, err := NewProducer(f.client(), ProducerConfig{QueueURL: f.url(queue)})
+	require.NoError(t, err)
+	messages := make([]Message, len(bodies))
+	for i, body := range bodies {
+		messages[i] = Message{Body: body, GroupID: groupID}
+	}
+	_, err = producer.Send(context.Background(), messages...)
+	require.NoError(t, err)
+}
+
+func TestConsumerDeletesHandledMessages(t *testing.T) {
+	f := newFakeSQS(t, "orders")
+	var bodies []string
+	for i := 0; i < 25; i++ {
+		bodies = append(bodies, fmt.Sprintf("order-%d", i))
+	}
+	sendBodies(t, f, "orders", "", bodies...)
+	assert.Equal(t, []int{10, 10, 5}, f.batches["SendMessageBatch"])
+
+	var mu sync.Mutex
+	handled := make(map[string]int)
+	consumer, err := NewConsumer(f.client(), ConsumerConfig{
+		QueueName:           "orders",
+		Concurrency:         4,
+		WaitTime:            aws.Duration(time.Second),
+		DeleteFlushInterval: 50 * time.Millisecond,
+	}, func(ctx context.Context, msg sqsTypes.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[aws.ToString(msg.Body)]++
+		return nil
+	})
+	require.NoError(t, err)
+	stop := runConsumer(t, consumer)
+
+	assert.Eventually(t, func() bool { return f.len("orders") == 0 }, 5*time.Second, 20*time.Millisecond)
+	stop()
+
+	assert.Len(t, handled, 25)
+	for body, count := range handled {
+		assert.Equal(t, 1, count, body)
+	}
+	assert.Equal(t, 1, f.callCount("GetQueueUrl"))
+	for _, size := range f.batches["DeleteMessageBatch"] {
+		assert.LessOrEqual(t, size, maxBatchSize)
+	}
+}
+
+func TestConsumerRedrivesToDeadLetterQueue(t *testing.T) {
+	f := newFakeSQS(t, "jobs", "jobs-dlq")
+	sendBodies(t, f, "jobs", "", "poison")
+
+	var mu sync.Mutex
+	attempts := 0
+	consumer, err := NewConsumer(f.client(), ConsumerConfig{
+		QueueURL:            f.url("jobs"),
+		WaitTime:            aws.Duration(time.Second),
+		MinBackoff:          time.Millisecond,
+		MaxBackoff:          time.Millisecond,
+		MaxReceiveCount:     3,
+		DeadLetterQueueURL:  f.url("jobs-dlq"),
+		DeleteFlushInterval: 20 * time.Millisecond,
+	}, func(ctx context.Context, msg sqsTypes.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		return fmt.Errorf("cannot process %s", aws.ToString(msg.Body))
+	})
+	require.NoError(t, err)
+	stop := runConsumer(t, consumer)
+
+	assert.Eventually(t, func() bool { return f.len("jobs-dlq") == 1 && f.len("jobs") == 0 }, 5*time.Second, 20*time.Millisecond)
+	stop()
+	assert.Equal(t, 3, attempts)
+}
+
+func TestConsumerExtendsVisibilityOfSlowHandlers(t *testing.T) {
+	f := newFakeSQS(t, "reports")
+	sendBodies(t, f, "reports", "", "slow")
+
+	var mu sync.Mutex
+	attempts := 0
+	consumer, err := NewConsumer(f.client(), ConsumerConfig{
+		QueueURL:          f.url("reports"),
+		Concurrency:       2,
+		WaitTime:          aws.Duration(time.Second),
+		VisibilityTimeout: time.Second,
+		HeartbeatInterval: 200 * time.Millisecond,
+	}, func(ctx context.Context, msg sqsTypes.Message) error {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		time.Sleep(2500 * time.Millisecond)
+		return nil
+	})
+	require.NoError(t, err)
+	stop := runConsumer(t, consumer)
+
+	assert.Eventually(t, func() bool { return f.len("reports") == 0 }, 6*time.Second, 50*time.Millisecond)
+	stop()
+	assert.Equal(t, 1, attempts)
+	assert.Positive(t, f.callCount("ChangeMessageVisibilityBatch"))
+}
+
+func TestConsumerPreservesFIFOGroupOrder(t *testing.T) {
+	f := newFakeSQS(t, "events.fifo")
+	sendBodies(t, f, "events.fifo", "a", "a0", "a1", "a2", "a3", "a4")
+	sendBodies(t, f, "events.fifo", "b", "b0", "b1", "b2", "b3", "b4")
+
+	var mu sync.Mutex
+	order := make(map[string][]string)
+	consumer, err := NewConsumer(f.client(), ConsumerConfig{
+		QueueURL:            f.url("events.fifo"),
+		Concurrency:         4,
+		WaitTime:            aws.Duration(time.Second),
+		DeleteFlushInterval: 20 * time.Millisecond,
+	}, func(ctx context.Context, msg sqsTypes.Message) error {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		group := msg.Attributes["MessageGroupId"]
+		order[group] = append(order[group], aws.ToString(msg.Body))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, consumer.config.FIFO)
+	stop := runConsumer(t, consumer)
+
+	assert.Eventually(t, func() bool { return f.len("events.fifo") == 0 }, 5*time.Second, 20*time.Millisecond)
+	stop()
+	assert.Equal(t, []string{"a0", "a1", "a2", "a3", "a4"}, order["a"])
+	assert.Equal(t, []string{"b0", "b1", "b2", "b3", "b4"}, order["b"])
+}
+
+func TestConsumerConfigWaitTime(t *testing.T) {
+	for _, tc := range []struct {
+		waitTime *time.Duration
+		want     time.Duration
+	}{
+		{nil, 20 * time.Second},
+		{aws.Duration(0), 0},
+		{aws.Duration(5 * time.Second), 5 * time.Second},
+		{aws.Duration(time.Minute), 20 * time.Second},
+	} {
+		cfg := ConsumerConfig{WaitTime: tc.waitTime}
+		cfg.setDefaults()
+		assert.Equal(t, tc.want, *cfg.WaitTime)
+	}
+}
+
+func TestProducerRetriesPartialFailures(t *testing.T) {
+	f := newFakeSQS(t, "notifications")
+	f.failSends["n3"] = true
+	f.failSends["n12"] = true
+	f.rejectSends["n7"] = true
+
+	producer, err := NewProducer(f.client(), ProducerConfig{QueueURL: f.url("notifications"), MinBackoff: time.Millisecond})
+	require.NoError(t, err)
+	var messages []Message
+	for i := 0; i < 15; i++ {
+		messages = append(messages, Message{Body: fmt.Sprintf("n%d", i)})
+	}
+
+	result, err := producer.Send(context.Background(), messages...)
+	assert.ErrorIs(t, err, ErrPartialFailure)
+	assert.Len(t, result.Successful, 14)
+	require.Len(t, result.Failed, 1)
+	assert.Equal(t, 7, result.Failed[0].Index)
+	assert.True(t, result.Failed[0].SenderFault)
+	assert.Equal(t, 14, f.len("notifications"))
+	assert.Equal(t, []int{10, 5, 2}, f.batches["SendMessageBatch"])
+}
+
+func TestProducerDoesNotRetryClientFaults(t *testing.T) {
+	f := newFakeSQS(t, "notifications")
+	producer, err := NewProducer(f.client(), ProducerConfig{QueueURL: f.url("missing"), MinBackoff: time.Millisecond})
+	require.NoError(t, err)
+
+	result, err := producer.Send(context.Background(), Message{Body: "a"}, Message{Body: "b"})
+	assert.ErrorIs(t, err, ErrPartialFailure)
+	require.Len(t, result.Failed, 2)
+	for i, failed := range result.Failed {
+		assert.Equal(t, i, failed.Index)
+		assert.Equal(t, "QueueDoesNotExist", failed.Code)
+		assert.True(t, failed.SenderFault)
+	}
+	assert.Equal(t, 1, f.callCount("SendMessageBatch"))
+}
+
+func TestSplitBatchesRespectsPayloadLimit(t *testing.T) {
+	large := strings.Repeat("x", 100*1024)
+	messages := []Message{{Body: large}, {Body: large}, {Body: large}, {Body: "small"}}
+	batches := splitBatches(messages, []int{0, 1, 2, 3})
+	assert.Equal(t, [][]int{{0, 1}, {2, 3}}, batches)
+}
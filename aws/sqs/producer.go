@@ -0,0 +1,223 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/seidu626/go-buildingblocks/aws/internal/retry"
+)
+
+// maxBatchBytes is the maximum total payload of a SendMessageBatch call.
+const maxBatchBytes = 256 * 1024
+
+// ErrPartialFailure is returned by Producer.Send when some messages could not be sent.
+var ErrPartialFailure = errors.New("sqs: some messages were not sent")
+
+// Message is a message to be sent by a Producer.
+type Message struct {
+	Body            string
+	GroupID         string        // Required by FIFO queues
+	DeduplicationID string        // Optional on FIFO queues with content-based deduplication
+	Delay           time.Duration // Per-message delay, not supported by FIFO queues
+	Attributes      map[string]sqsTypes.MessageAttributeValue
+}
+
+// FailedMessage describes a message that could not be sent.
+type FailedMessage struct {
+	Index       int // Position of the message in the Send call
+	Message     Message
+	Code        string
+	Reason      string
+	SenderFault bool
+}
+
+// SendResult reports the outcome of a Producer.Send call.
+type SendResult struct {
+	Successful []sqsTypes.SendMessageBatchResultEntry
+	Failed     []FailedMessage
+}
+
+// ProducerConfig holds the configuration of a Producer.
+type ProducerConfig struct {
+	QueueURL    string
+	MaxAttempts int           // Attempts per message, including the first one (default 3)
+	MinBackoff  time.Duration // Delay before the first retry (default 100ms)
+	MaxBackoff  time.Duration // Upper bound of the retry delay (default 5s)
+}
+
+// Producer sends messages to a queue, splitting them into valid batches and retrying
+// the entries that failed for reasons not attributable to the sender.
+type Producer struct {
+	client API
+	config ProducerConfig
+}
+
+// NewProducer creates a Producer for the configured queue.
+func NewProducer(client API, cfg ProducerConfig) (*Producer, error) {
+	if client == nil {
+		return nil, errors.New("sqs: client is required")
+	}
+	if cfg.QueueURL == "" {
+		return nil, ErrNoQueue
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Second
+	}
+	return &Producer{client: client, config: cfg}, nil
+}
+
+// Send sends the messages in batches of up to 10 entries and 256 KiB. Entries and batch calls
+// that fail with a server-side or throttling error are retried with backoff; when some messages are still not sent, the
+// returned error wraps ErrPartialFailure and the result lists them.
+func (p *Producer) Send(ctx context.Context, messages ...Message) (*SendResult, error) {
+	result := &SendResult{}
+	pending := make([]int, len(messages))
+	for i := range messages {
+		pending[i] = i
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		var retried []int
+		var lastErr error
+		for _, batch := range splitBatches(messages, pending) {
+			out, err := p.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+				QueueUrl: aws.String(p.config.QueueURL),
+				Entries:  batchEntries(messages, batch),
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				if retryable(err) {
+					lastErr = err
+					retried = append(retried, batch...)
+					continue
+				}
+				failed := callFailure(err)
+				for _, index := range batch {
+					failed.Index, failed.Message = index, messages[index]
+					result.Failed = append(result.Failed, failed)
+				}
+				continue
+			}
+			result.Successful = append(result.Successful, out.Successful...)
+			for _, failed := range out.Failed {
+				index, _ := strconv.Atoi(aws.ToString(failed.Id))
+				if !failed.SenderFault && attempt < p.config.MaxAttempts {
+					retried = append(retried, index)
+					continue
+				}
+				result.Failed = append(result.Failed, FailedMessage{
+					Index:       index,
+					Message:     messages[index],
+					Code:        aws.ToString(failed.Code),
+					Reason:      aws.ToString(failed.Message),
+					SenderFault: failed.SenderFault,
+				})
+			}
+		}
+
+		if len(retried) > 0 && attempt >= p.config.MaxAttempts {
+			for _, index := range retried {
+				failed := FailedMessage{Index: index, Message: messages[index], Code: "RetriesExhausted"}
+				if lastErr != nil {
+					failed.Reason = lastErr.Error()
+				}
+				result.Failed = append(result.Failed, failed)
+			}
+			break
+		}
+		pending = retried
+		if len(pending) > 0 && !retry.Sleep(ctx, retry.Backoff(attempt, p.config.MinBackoff, p.config.MaxBackoff)) {
+			return result, ctx.Err()
+		}
+	}
+
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("%w: %d of %d failed", ErrPartialFailure, len(result.Failed), len(messages))
+	}
+	return result, nil
+}
+
+// retryable reports whether a failed SendMessageBatch call is worth retrying: throttling,
+// connection and server errors are, client faults such as an unknown queue are not.
+func retryable(err error) bool {
+	return awsretry.IsErrorThrottles(awsretry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary ||
+		awsretry.IsErrorRetryables(awsretry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// callFailure describes the messages of a batch whose call failed with a non-retryable error.
+func callFailure(err error) FailedMessage {
+	failed := FailedMessage{Reason: err.Error()}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		failed.Code = apiErr.ErrorCode()
+		failed.SenderFault = apiErr.ErrorFault() != smithy.FaultServer
+	}
+	return failed
+}
+
+// splitBatches groups the indexes of the messages to send in batches respecting the SQS limits.
+func splitBatches(messages []Message, indexes []int) [][]int {
+	var batches [][]int
+	var current []int
+	size := 0
+	for _, index := range indexes {
+		msgSize := messageSize(messages[index])
+		if len(current) == maxBatchSize || (len(current) > 0 && size+msgSize > maxBatchBytes) {
+			batches = append(batches, current)
+			current, size = nil, 0
+		}
+		current = append(current, index)
+		size += msgSize
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// messageSize returns the size of the message as accounted by SQS: body plus attribute names, types and values.
+func messageSize(msg Message) int {
+	size := len(msg.Body)
+	for name, attr := range msg.Attributes {
+		size += len(name) + len(aws.ToString(attr.DataType)) + len(aws.ToString(attr.StringValue)) + len(attr.BinaryValue)
+	}
+	return size
+}
+
+func batchEntries(messages []Message, indexes []int) []sqsTypes.SendMessageBatchRequestEntry {
+	entries := make([]sqsTypes.SendMessageBatchRequestEntry, len(indexes))
+	for i, index := range indexes {
+		msg := messages[index]
+		entry := sqsTypes.SendMessageBatchRequestEntry{
+			// The entry id carries the index of the message, so that failures can be mapped back
+			Id:                aws.String(strconv.Itoa(index)),
+			MessageBody:       aws.String(msg.Body),
+			MessageAttributes: msg.Attributes,
+			DelaySeconds:      int32(msg.Delay / time.Second),
+		}
+		if msg.GroupID != "" {
+			entry.MessageGroupId = aws.String(msg.GroupID)
+		}
+		if msg.DeduplicationID != "" {
+			entry.MessageDeduplicationId = aws.String(msg.DeduplicationID)
+		}
+		entries[i] = entry
+	}
+	return entries
+}
@@ -5,15 +5,18 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
-	guuid "github.com/google/uuid"
 	awsutils "github.com/seidu626/go-buildingblocks/aws"
 	"html"
+	"strconv"
 	"sync"
 )
 
 var sqsClient *sqs.Client = nil
 var once sync.Once
 
+// queueURLs caches the queue URLs resolved by name
+var queueURLs sync.Map
+
 func init() {
 	once.Do(func() {
 		cfg, err := awsutils.New()
@@ -24,15 +27,17 @@ func init() {
 	})
 }
 
+// GetMessage long-polls the queue for up to 20 seconds and returns at most 10 messages.
+// Use a Consumer for continuous processing.
 func GetMessage(queueName string) ([]sqsTypes.Message, error) {
-	url, err := sqsClient.GetQueueUrl(context.Background(), &sqs.GetQueueUrlInput{
-		QueueName: aws.String(queueName),
-	})
+	url, err := GetQueueURL(queueName)
 	if err != nil {
 		return nil, err
 	}
 	messages, err := sqsClient.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{
-		QueueUrl: url.QueueUrl,
+		QueueUrl:            aws.String(url),
+		MaxNumberOfMessages: maxBatchSize,
+		WaitTimeSeconds:     20,
 	})
 	if err != nil {
 		return nil, err
@@ -45,26 +50,29 @@ func GetMessage(queueName string) ([]sqsTypes.Message, error) {
 }
 
 func DeleteMessage(queueName, receiptHandle string) error {
-	url, err := sqsClient.GetQueueUrl(context.Background(), &sqs.GetQueueUrlInput{
-		QueueName: aws.String(queueName),
-	})
+	url, err := GetQueueURL(queueName)
 	if err != nil {
 		return err
 	}
 	_, err = sqsClient.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{
-		QueueUrl:      url.QueueUrl,
+		QueueUrl:      aws.String(url),
 		ReceiptHandle: aws.String(receiptHandle),
 	})
 	return err
 }
 
+// GetQueueURL resolves the URL of the queue, caching it for the following calls.
 func GetQueueURL(queueName string) (string, error) {
+	if url, ok := queueURLs.Load(queueName); ok {
+		return url.(string), nil
+	}
 	url, err := sqsClient.GetQueueUrl(context.Background(), &sqs.GetQueueUrlInput{
 		QueueName: aws.String(queueName),
 	})
 	if err != nil {
 		return "", err
 	}
+	queueURLs.Store(queueName, *url.QueueUrl)
 	return *url.QueueUrl, nil
 }
 
@@ -75,19 +83,28 @@ func WriteMessage(queueURL, message string) (*sqs.SendMessageOutput, error) {
 	})
 }
 
+// WriteMessages sends the messages in batches of up to 10, retrying the entries that failed server side.
+// The returned output aggregates the successful and failed entries of all the batches; the entry ids
+// are the indexes of the messages.
 func WriteMessages(queueURL string, messages []string) (*sqs.SendMessageBatchOutput, error) {
-	var msgs []sqsTypes.SendMessageBatchRequestEntry
+	producer, err := NewProducer(sqsClient, ProducerConfig{QueueURL: queueURL})
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]Message, len(messages))
+	for i, message := range messages {
+		msgs[i] = Message{Body: message}
+	}
 
-	for _, message := range messages {
-		guid := guuid.New().String()
-		msgs = append(msgs, sqsTypes.SendMessageBatchRequestEntry{
-			Id:          &guid,
-			MessageBody: &message,
+	result, err := producer.Send(context.Background(), msgs...)
+	output := &sqs.SendMessageBatchOutput{Successful: result.Successful}
+	for _, failed := range result.Failed {
+		output.Failed = append(output.Failed, sqsTypes.BatchResultErrorEntry{
+			Id:          aws.String(strconv.Itoa(failed.Index)),
+			Code:        aws.String(failed.Code),
+			Message:     aws.String(failed.Reason),
+			SenderFault: failed.SenderFault,
 		})
 	}
-
-	return sqsClient.SendMessageBatch(context.Background(), &sqs.SendMessageBatchInput{
-		Entries:  msgs,
-		QueueUrl: &queueURL,
-	})
+	return output, err
 }
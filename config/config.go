@@ -144,10 +144,72 @@ type Config struct {
 			TLSEnableHostname bool     `mapstructure:"TLS_ENABLE_HOSTNAME"`
 		} `mapstructure:"REDIS"`
 	} `mapstructure:"CACHE"`
+	GRPC struct {
+		Port           int           `mapstructure:"PORT"`
+		MaxRecvMsgSize int           `mapstructure:"MAX_RECV_MSG_SIZE"`
+		DefaultTimeout time.Duration `mapstructure:"DEFAULT_TIMEOUT"` // Deadline of the calls without one
+		MaxTimeout     time.Duration `mapstructure:"MAX_TIMEOUT"`
+		TLSEnabled     bool          `mapstructure:"TLS_ENABLED"`
+		TLSCertPath    string        `mapstructure:"TLS_CERT_PATH"`
+		TLSKeyPath     string        `mapstructure:"TLS_KEY_PATH"`
+		TLSCaPath      string        `mapstructure:"TLS_CA_PATH"` // Requires client certificates
+		Clients        map[string]struct {
+			Target        string        `mapstructure:"TARGET"`
+			Timeout       time.Duration `mapstructure:"TIMEOUT"`
+			TLSEnabled    bool          `mapstructure:"TLS_ENABLED"`
+			TLSCaPath     string        `mapstructure:"TLS_CA_PATH"`
+			TLSCertPath   string        `mapstructure:"TLS_CERT_PATH"`
+			TLSKeyPath    string        `mapstructure:"TLS_KEY_PATH"`
+			TLSServerName string        `mapstructure:"TLS_SERVER_NAME"`
+		} `mapstructure:"CLIENTS"`
+	} `mapstructure:"GRPC"`
 	Logging struct {
 		Level  string `mapstructure:"LEVEL"`
 		Format string `mapstructure:"FORMAT"`
+		File   struct {
+			Path       string        `mapstructure:"PATH"`
+			MaxSizeMB  int           `mapstructure:"MAX_SIZE_MB"`
+			MaxAge     time.Duration `mapstructure:"MAX_AGE"`
+			MaxBackups int           `mapstructure:"MAX_BACKUPS"`
+			Compress   bool          `mapstructure:"COMPRESS"`
+		} `mapstructure:"FILE"`
+		Sampling struct {
+			Initial    int `mapstructure:"INITIAL"`
+			Thereafter int `mapstructure:"THEREAFTER"`
+		} `mapstructure:"SAMPLING"`
+		RedactKeys []string `mapstructure:"REDACT_KEYS"`
 	} `mapstructure:"LOGGING"`
+	Telemetry struct {
+		Enabled        bool              `mapstructure:"ENABLED"`
+		Exporter       string            `mapstructure:"EXPORTER"` // otlp (default), stdout or memory
+		Endpoint       string            `mapstructure:"ENDPOINT"` // OTLP gRPC endpoint, e.g. otel-collector:4317
+		Insecure       bool              `mapstructure:"INSECURE"`
+		Headers        map[string]string `mapstructure:"HEADERS"`
+		SampleRatio    float64           `mapstructure:"SAMPLE_RATIO"`
+		MetricInterval time.Duration     `mapstructure:"METRIC_INTERVAL"`
+		Instrument     struct {          // Layers instrumented, none by default
+			Database   bool `mapstructure:"DATABASE"` // Postgres and CockroachDB
+			Cassandra  bool `mapstructure:"CASSANDRA"`
+			Redis      bool `mapstructure:"REDIS"`
+			HTTPClient bool `mapstructure:"HTTP_CLIENT"`
+			HTTPServer bool `mapstructure:"HTTP_SERVER"`
+			AWS        bool `mapstructure:"AWS"`
+		} `mapstructure:"INSTRUMENT"`
+	} `mapstructure:"TELEMETRY"`
+	Temporal struct {
+		HostPort      string `mapstructure:"HOST_PORT"`
+		Namespace     string `mapstructure:"NAMESPACE"`
+		APIKey        string `mapstructure:"API_KEY"`
+		TLSEnabled    bool   `mapstructure:"TLS_ENABLED"`
+		TLSCaPath     string `mapstructure:"TLS_CA_PATH"`
+		TLSCertPath   string `mapstructure:"TLS_CERT_PATH"` // mTLS, e.g. Temporal Cloud
+		TLSKeyPath    string `mapstructure:"TLS_KEY_PATH"`
+		TLSServerName string `mapstructure:"TLS_SERVER_NAME"`
+		Encryption    struct {
+			KeyID string            `mapstructure:"KEY_ID"` // Key encrypting the payloads
+			Keys  map[string]string `mapstructure:"KEYS"`   // Base64 keys by ID, the retired ones kept to decrypt
+		} `mapstructure:"ENCRYPTION"`
+	} `mapstructure:"TEMPORAL"`
 	// DynamicConfigs holds configurations registered by external services
 	DynamicConfigs map[string]interface{}
 }
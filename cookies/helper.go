@@ -1,16 +1,13 @@
 package cookies
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/hmac"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/seidu626/go-buildingblocks/crypt"
 	"github.com/valyala/fasthttp"
-	"io"
 	"strings"
 )
 
@@ -22,17 +19,30 @@ var (
 )
 
 func Write(ctx *fasthttp.RequestCtx, cookie fasthttp.Cookie) error {
+	return write(ctx, &cookie)
+}
+
+// write encodes the value of cookie in place and sets it in the response
+func write(ctx *fasthttp.RequestCtx, cookie *fasthttp.Cookie) error {
 	cookie.SetValue(base64.URLEncoding.EncodeToString([]byte(cookie.Value())))
 
 	if len(cookie.String()) > 4096 {
 		return ErrValueTooLong
 	}
 
-	ctx.Response.Header.SetCookie(&cookie)
+	ctx.Response.Header.SetCookie(cookie)
 
 	return nil
 }
 
+// withValue returns a copy of cookie holding value, leaving cookie untouched
+func withValue(cookie *fasthttp.Cookie, value []byte) *fasthttp.Cookie {
+	out := &fasthttp.Cookie{}
+	out.CopyTo(cookie)
+	out.SetValueBytes(value)
+	return out
+}
+
 func Read(ctx *fasthttp.RequestCtx, name string) (string, error) {
 	cookieValue := ctx.Request.Header.Cookie(name)
 	if cookieValue == nil {
@@ -83,70 +93,77 @@ func ReadSigned(ctx *fasthttp.RequestCtx, name string, secretKey []byte) (string
 	return value, nil
 }
 
+// WriteEncrypted writes the cookie encrypted with AES-GCM, secretKey being an AES key of 16, 24 or 32 bytes.
 func WriteEncrypted(ctx *fasthttp.RequestCtx, cookie fasthttp.Cookie, secretKey []byte) error {
-	block, err := aes.NewCipher(secretKey)
+	aead, err := crypt.NewAEAD(crypt.AESGCM, secretKey)
 	if err != nil {
 		return err
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		return err
-	}
+	plaintext := fmt.Sprintf("%s:%s", cookie.Key(), cookie.Value())
 
-	nonce := make([]byte, aesGCM.NonceSize())
-	_, err = io.ReadFull(rand.Reader, nonce)
+	encryptedValue, err := crypt.Seal(aead, []byte(plaintext), nil)
 	if err != nil {
 		return err
 	}
 
-	plaintext := fmt.Sprintf("%s:%s", cookie.Key(), cookie.Value())
-
-	encryptedValue := aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)
-
 	cookie.SetValue(string(encryptedValue))
 
-	return Write(ctx, cookie)
+	return write(ctx, &cookie)
 }
 
+// ReadEncrypted reads a cookie of WriteEncrypted.
 func ReadEncrypted(ctx *fasthttp.RequestCtx, name string, secretKey []byte) (string, error) {
 	encryptedValue, err := Read(ctx, name)
 	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(secretKey)
+	aead, err := crypt.NewAEAD(crypt.AESGCM, secretKey)
 	if err != nil {
 		return "", err
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
+	plaintext, err := crypt.Open(aead, []byte(encryptedValue), nil)
 	if err != nil {
-		return "", err
+		return "", ErrInvalidValue
 	}
 
-	nonceSize := aesGCM.NonceSize()
+	expectedName, value, ok := strings.Cut(string(plaintext), ":")
+	if !ok {
+		return "", ErrInvalidValue
+	}
 
-	if len(encryptedValue) < nonceSize {
+	if expectedName != name {
 		return "", ErrInvalidValue
 	}
 
-	nonce := encryptedValue[:nonceSize]
-	ciphertext := encryptedValue[nonceSize:]
+	return value, nil
+}
 
-	plaintext, err := aesGCM.Open(nil, []byte(nonce), []byte(ciphertext), nil)
+// WriteSealed writes the cookie encrypted with c, bound to its name. Unlike WriteEncrypted, the
+// keys of c can be rotated without invalidating the cookies already issued. The cookie itself is
+// not modified.
+func WriteSealed(ctx *fasthttp.RequestCtx, cookie *fasthttp.Cookie, c *crypt.Cipher) error {
+	encryptedValue, err := c.Encrypt(cookie.Value(), cookie.Key())
 	if err != nil {
-		return "", ErrInvalidValue
+		return err
 	}
 
-	expectedName, value, ok := strings.Cut(string(plaintext), ":")
-	if !ok {
-		return "", ErrInvalidValue
+	return write(ctx, withValue(cookie, encryptedValue))
+}
+
+// ReadSealed reads a cookie of WriteSealed.
+func ReadSealed(ctx *fasthttp.RequestCtx, name string, c *crypt.Cipher) (string, error) {
+	encryptedValue, err := Read(ctx, name)
+	if err != nil {
+		return "", err
 	}
 
-	if expectedName != name {
+	value, err := c.Decrypt([]byte(encryptedValue), []byte(name))
+	if err != nil {
 		return "", ErrInvalidValue
 	}
 
-	return value, nil
+	return string(value), nil
 }
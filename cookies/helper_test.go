@@ -0,0 +1,62 @@
+package cookies
+
+import (
+	"testing"
+
+	"github.com/seidu626/go-buildingblocks/crypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// roundTrip sends the cookie written in the response back in the request
+func roundTrip(t *testing.T, ctx *fasthttp.RequestCtx, name string) {
+	t.Helper()
+	var cookie fasthttp.Cookie
+	cookie.SetKey(name)
+	require.True(t, ctx.Response.Header.Cookie(&cookie))
+	ctx.Request.Header.SetCookieBytesKV(cookie.Key(), cookie.Value())
+}
+
+func TestSealed(t *testing.T) {
+	oldKey, err := crypt.NewKey("old", crypt.AESGCM)
+	require.NoError(t, err)
+	old, err := crypt.NewCipher(oldKey)
+	require.NoError(t, err)
+
+	ctx := &fasthttp.RequestCtx{}
+	var cookie fasthttp.Cookie
+	cookie.SetKey("session")
+	cookie.SetValue("user=42")
+	require.NoError(t, WriteSealed(ctx, &cookie, old))
+	roundTrip(t, ctx, "session")
+
+	newKey, err := crypt.NewKey("new", crypt.XChaCha20Poly1305)
+	require.NoError(t, err)
+	rotated, err := crypt.NewCipher(newKey, oldKey)
+	require.NoError(t, err)
+	value, err := ReadSealed(ctx, "session", rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "user=42", value)
+
+	// Bound to the name of the cookie
+	ctx.Request.Header.SetCookie("other", string(ctx.Request.Header.Cookie("session")))
+	_, err = ReadSealed(ctx, "other", rotated)
+	assert.ErrorIs(t, err, ErrInvalidValue)
+}
+
+func TestEncrypted(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	ctx := &fasthttp.RequestCtx{}
+	var cookie fasthttp.Cookie
+	cookie.SetKey("prefs")
+	cookie.SetValue("dark")
+	require.NoError(t, WriteEncrypted(ctx, cookie, key))
+	roundTrip(t, ctx, "prefs")
+
+	value, err := ReadEncrypted(ctx, "prefs", key)
+	require.NoError(t, err)
+	assert.Equal(t, "dark", value)
+	_, err = ReadEncrypted(ctx, "prefs", []byte("fedcba9876543210"))
+	assert.ErrorIs(t, err, ErrInvalidValue)
+}
@@ -0,0 +1,241 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithm is an authenticated encryption algorithm.
+type Algorithm byte
+
+const (
+	// AESGCM is AES in GCM mode with 96-bit random nonces, with keys of 16, 24 or 32 bytes.
+	// Keys should not encrypt more than 2^32 messages.
+	AESGCM Algorithm = 1
+	// XChaCha20Poly1305 has 192-bit nonces, safe to generate randomly without usage limit, and
+	// 32-byte keys.
+	XChaCha20Poly1305 Algorithm = 2
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case AESGCM:
+		return "AES-GCM"
+	case XChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	}
+	return fmt.Sprintf("Algorithm(%d)", byte(a))
+}
+
+// KeySize is the size of the generated and derived keys.
+const KeySize = 32
+
+const (
+	version     byte = 1
+	kindMessage byte = 1
+	kindStream  byte = 2
+)
+
+var (
+	ErrInvalidKey = errors.New("crypt: invalid key")
+	// ErrDecrypt is returned when a ciphertext, its header or its associated data was modified,
+	// or was encrypted with another key
+	ErrDecrypt = errors.New("crypt: message authentication failed")
+	// ErrMalformed is returned for the ciphertexts that were not produced by this package
+	ErrMalformed          = errors.New("crypt: malformed ciphertext")
+	ErrUnsupportedVersion = errors.New("crypt: unsupported version")
+	ErrUnknownKey         = errors.New("crypt: unknown key id")
+)
+
+// NewAEAD returns the cipher.AEAD of the algorithm with the key.
+func NewAEAD(alg Algorithm, key []byte) (cipher.AEAD, error) {
+	switch alg {
+	case AESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+		}
+		return cipher.NewGCM(block)
+	case XChaCha20Poly1305:
+		aead, err := chacha20poly1305.NewX(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+		}
+		return aead, nil
+	}
+	return nil, fmt.Errorf("%w: unknown algorithm %d", ErrInvalidKey, alg)
+}
+
+// Seal encrypts plaintext with a random nonce, returned as the prefix of the ciphertext.
+func Seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	out := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, out); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out, plaintext, aad), nil
+}
+
+// Open decrypts the ciphertext of Seal.
+func Open(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrMalformed
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// Key is a key of a Cipher.
+type Key struct {
+	// ID identifies the key in the ciphertexts, to find it when decrypting (at most 255 bytes)
+	ID        string
+	Algorithm Algorithm
+	Material  []byte
+}
+
+// NewKey generates a random key.
+func NewKey(id string, alg Algorithm) (Key, error) {
+	material := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, material); err != nil {
+		return Key{}, err
+	}
+	return Key{ID: id, Algorithm: alg, Material: material}, nil
+}
+
+// Cipher encrypts with its primary key, and decrypts with the key whose ID is in the header of
+// the ciphertext, so keys can be rotated while the older ciphertexts remain readable.
+// A Cipher is safe for concurrent use.
+//
+// Ciphertexts start with a header of the version, the kind (message or stream), the algorithm
+// and the key ID. It is authenticated along with the associated data, which binds a ciphertext
+// to its context (e.g. a record ID or a cookie name) without being stored.
+type Cipher struct {
+	primary string
+	keys    map[string]*cipherKey
+}
+
+type cipherKey struct {
+	Key
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher encrypting with primary, and decrypting with primary or the
+// older keys.
+func NewCipher(primary Key, older ...Key) (*Cipher, error) {
+	c := &Cipher{primary: primary.ID, keys: make(map[string]*cipherKey, 1+len(older))}
+	for _, key := range append([]Key{primary}, older...) {
+		if len(key.ID) > 255 {
+			return nil, fmt.Errorf("%w: id longer than 255 bytes", ErrInvalidKey)
+		}
+		if _, ok := c.keys[key.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate id %q", ErrInvalidKey, key.ID)
+		}
+		aead, err := NewAEAD(key.Algorithm, key.Material)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, err)
+		}
+		c.keys[key.ID] = &cipherKey{Key: key, aead: aead}
+	}
+	return c, nil
+}
+
+// header returns the header of a ciphertext of the key
+func header(kind byte, key *cipherKey) []byte {
+	h := make([]byte, 0, 4+len(key.ID))
+	h = append(h, version, kind, byte(key.Algorithm), byte(len(key.ID)))
+	return append(h, key.ID...)
+}
+
+// parseHeader returns the key of a ciphertext and the length of its header
+func (c *Cipher) parseHeader(data []byte, kind byte) (*cipherKey, int, error) {
+	if len(data) < 4 {
+		return nil, 0, ErrMalformed
+	}
+	if data[0] != version {
+		return nil, 0, fmt.Errorf("%w: %d", ErrUnsupportedVersion, data[0])
+	}
+	if data[1] != kind {
+		return nil, 0, fmt.Errorf("%w: unexpected kind %d", ErrMalformed, data[1])
+	}
+	n := 4 + int(data[3])
+	if len(data) < n {
+		return nil, 0, ErrMalformed
+	}
+	key, ok := c.keys[string(data[4:n])]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %q", ErrUnknownKey, data[4:n])
+	}
+	if byte(key.Algorithm) != data[2] {
+		return nil, 0, fmt.Errorf("%w: algorithm %d for key %q", ErrMalformed, data[2], key.ID)
+	}
+	return key, n, nil
+}
+
+func withHeader(h, aad []byte) []byte {
+	return append(h[:len(h):len(h)], aad...)
+}
+
+// Encrypt encrypts plaintext with the primary key, authenticating aad, which must be given
+// again to Decrypt.
+func (c *Cipher) Encrypt(plaintext, aad []byte) ([]byte, error) {
+	key := c.keys[c.primary]
+	h := header(kindMessage, key)
+	sealed, err := Seal(key.aead, plaintext, withHeader(h, aad))
+	if err != nil {
+		return nil, err
+	}
+	return append(h, sealed...), nil
+}
+
+// Decrypt decrypts a ciphertext of Encrypt.
+func (c *Cipher) Decrypt(ciphertext, aad []byte) ([]byte, error) {
+	key, n, err := c.parseHeader(ciphertext, kindMessage)
+	if err != nil {
+		return nil, err
+	}
+	return Open(key.aead, ciphertext[n:], withHeader(ciphertext[:n], aad))
+}
+
+// EncryptString encrypts plaintext into unpadded URL-safe base64, for URLs, cookies or text columns.
+func (c *Cipher) EncryptString(plaintext string, aad []byte) (string, error) {
+	ciphertext, err := c.Encrypt([]byte(plaintext), aad)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptString decrypts a ciphertext of EncryptString.
+func (c *Cipher) DecryptString(ciphertext string, aad []byte) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	plaintext, err := c.Decrypt(data, aad)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// KeyID returns the ID of the key of a ciphertext of Encrypt or EncryptWriter, e.g. to
+// re-encrypt the ciphertexts of the retired keys.
+func KeyID(ciphertext []byte) (string, error) {
+	if len(ciphertext) < 4 || len(ciphertext) < 4+int(ciphertext[3]) {
+		return "", ErrMalformed
+	}
+	if ciphertext[0] != version {
+		return "", fmt.Errorf("%w: %d", ErrUnsupportedVersion, ciphertext[0])
+	}
+	return string(ciphertext[4 : 4+int(ciphertext[3])]), nil
+}
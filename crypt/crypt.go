@@ -5,6 +5,10 @@ import (
 	"encoding/hex"
 )
 
+// EncryptAES encrypts the first 16 bytes of plaintext with a raw AES block, without nonce nor
+// authentication, and panics on invalid keys.
+//
+// Deprecated: insecure, use Cipher.Encrypt or Seal.
 func EncryptAES(key []byte, plaintext string) string {
 	c, err := aes.NewCipher(key)
 	if err != nil {
@@ -16,6 +20,9 @@ func EncryptAES(key []byte, plaintext string) string {
 	return hex.EncodeToString(out)
 }
 
+// DecryptAES decrypts the output of EncryptAES, ignoring invalid hex.
+//
+// Deprecated: use Cipher.Decrypt or Open.
 func DecryptAES(key []byte, ct string) string {
 	ciphertext, _ := hex.DecodeString(ct)
 	c, err := aes.NewCipher(key)
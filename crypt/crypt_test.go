@@ -0,0 +1,193 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCipher(t *testing.T, alg Algorithm, id string, older ...Key) (*Cipher, Key) {
+	t.Helper()
+	key, err := NewKey(id, alg)
+	require.NoError(t, err)
+	c, err := NewCipher(key, older...)
+	require.NoError(t, err)
+	return c, key
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	for _, alg := range []Algorithm{AESGCM, XChaCha20Poly1305} {
+		t.Run(alg.String(), func(t *testing.T) {
+			c, _ := newCipher(t, alg, "k1")
+			ciphertext, err := c.Encrypt([]byte("msisdn=233200000001"), []byte("subscriber:42"))
+			require.NoError(t, err)
+
+			plaintext, err := c.Decrypt(ciphertext, []byte("subscriber:42"))
+			require.NoError(t, err)
+			assert.Equal(t, "msisdn=233200000001", string(plaintext))
+
+			_, err = c.Decrypt(ciphertext, []byte("subscriber:43"))
+			assert.ErrorIs(t, err, ErrDecrypt, "other associated data")
+
+			for i := range ciphertext {
+				tampered := bytes.Clone(ciphertext)
+				tampered[i] ^= 1
+				_, err = c.Decrypt(tampered, []byte("subscriber:42"))
+				assert.Error(t, err, "byte %d", i)
+			}
+
+			other, _ := newCipher(t, alg, "k1")
+			_, err = other.Decrypt(ciphertext, []byte("subscriber:42"))
+			assert.ErrorIs(t, err, ErrDecrypt)
+
+			again, err := c.Encrypt([]byte("msisdn=233200000001"), []byte("subscriber:42"))
+			require.NoError(t, err)
+			assert.NotEqual(t, ciphertext, again, "random nonces")
+		})
+	}
+}
+
+func TestCipherRotation(t *testing.T) {
+	old, oldKey := newCipher(t, AESGCM, "2024")
+	ciphertext, err := old.EncryptString("secret", nil)
+	require.NoError(t, err)
+
+	rotated, _ := newCipher(t, XChaCha20Poly1305, "2025", oldKey)
+	plaintext, err := rotated.DecryptString(ciphertext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+
+	fresh, err := rotated.Encrypt([]byte("secret"), nil)
+	require.NoError(t, err)
+	id, err := KeyID(fresh)
+	require.NoError(t, err)
+	assert.Equal(t, "2025", id)
+
+	_, err = old.Decrypt(fresh, nil)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = NewCipher(oldKey, oldKey)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = NewCipher(Key{ID: "short", Algorithm: XChaCha20Poly1305, Material: make([]byte, 16)})
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestMalformed(t *testing.T) {
+	c, _ := newCipher(t, AESGCM, "k")
+	for _, data := range [][]byte{nil, {1}, {2, 1, 1, 1, 'k'}, {1, 1, 1, 5, 'k'}, {1, 1, 1, 1, 'k', 0}} {
+		_, err := c.Decrypt(data, nil)
+		assert.Error(t, err, "%v", data)
+	}
+	_, err := c.Decrypt([]byte{9, 1, 1, 1, 'k'}, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+	_, err = c.DecryptString("not base64!", nil)
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestStream(t *testing.T) {
+	for _, alg := range []Algorithm{AESGCM, XChaCha20Poly1305} {
+		for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 17} {
+			plaintext := make([]byte, size)
+			_, _ = rand.Read(plaintext)
+			c, _ := newCipher(t, alg, "k")
+
+			var buf bytes.Buffer
+			w, err := c.EncryptWriter(&buf, []byte("file.csv"))
+			require.NoError(t, err)
+			// Odd write sizes, across the chunks
+			for rest := plaintext; len(rest) > 0; {
+				n := min(len(rest), 1000)
+				_, err = w.Write(rest[:n])
+				require.NoError(t, err)
+				rest = rest[n:]
+			}
+			require.NoError(t, w.Close())
+			encrypted := buf.Bytes()
+
+			r, err := c.DecryptReader(iotest.HalfReader(bytes.NewReader(encrypted)), []byte("file.csv"))
+			require.NoError(t, err)
+			decrypted, err := io.ReadAll(r)
+			require.NoError(t, err, "%s %d", alg, size)
+			assert.True(t, bytes.Equal(plaintext, decrypted), "%s %d", alg, size)
+
+			// Truncated at a chunk boundary or in the middle of a chunk
+			for _, cut := range []int{len(encrypted) - 1, len(encrypted) - 16 - 1, len(encrypted) - ChunkSize - 16} {
+				if cut < 0 {
+					continue
+				}
+				r, err = c.DecryptReader(bytes.NewReader(encrypted[:cut]), []byte("file.csv"))
+				if err != nil {
+					continue
+				}
+				_, err = io.ReadAll(r)
+				assert.Error(t, err, "%s %d cut at %d", alg, size, cut)
+			}
+
+			r, err = c.DecryptReader(bytes.NewReader(encrypted), []byte("other.csv"))
+			require.NoError(t, err)
+			_, err = io.ReadAll(r)
+			assert.ErrorIs(t, err, ErrDecrypt)
+		}
+	}
+
+	c, _ := newCipher(t, AESGCM, "k")
+	message, err := c.Encrypt([]byte("x"), nil)
+	require.NoError(t, err)
+	_, err = c.DecryptReader(bytes.NewReader(message), nil)
+	assert.ErrorIs(t, err, ErrMalformed, "a message is not a stream")
+}
+
+func TestKeyDerivation(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	params := Argon2Params{Time: 1, Memory: 8 * 1024, Threads: 1}
+	k1, err := PasswordKey("pw", XChaCha20Poly1305, "correct horse", salt, params)
+	require.NoError(t, err)
+	k2, err := PasswordKey("pw", XChaCha20Poly1305, "correct horse", salt, params)
+	require.NoError(t, err)
+	assert.Equal(t, k1, k2)
+	assert.Len(t, k1.Material, KeySize)
+	k3, err := PasswordKey("pw", XChaCha20Poly1305, "wrong horse", salt, params)
+	require.NoError(t, err)
+	assert.NotEqual(t, k1.Material, k3.Material)
+	_, err = PasswordKey("pw", AESGCM, "x", []byte("short"), params)
+	assert.Error(t, err)
+
+	master := []byte("0123456789abcdef0123456789abcdef")
+	a, err := DeriveKey("a", AESGCM, master, nil, "cookies")
+	require.NoError(t, err)
+	b, err := DeriveKey("b", AESGCM, master, nil, "files")
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Material, b.Material)
+
+	c, err := NewCipher(a)
+	require.NoError(t, err)
+	ciphertext, err := c.Encrypt([]byte("x"), nil)
+	require.NoError(t, err)
+	a2, err := DeriveKey("a", AESGCM, master, nil, "cookies")
+	require.NoError(t, err)
+	c2, err := NewCipher(a2)
+	require.NoError(t, err)
+	plaintext, err := c2.Decrypt(ciphertext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(plaintext))
+}
+
+func TestSealOpen(t *testing.T) {
+	aead, err := NewAEAD(AESGCM, make([]byte, 16))
+	require.NoError(t, err)
+	sealed, err := Seal(aead, []byte("value"), []byte("name"))
+	require.NoError(t, err)
+	opened, err := Open(aead, sealed, []byte("name"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(opened))
+	_, err = Open(aead, sealed[:10], []byte("name"))
+	assert.ErrorIs(t, err, ErrMalformed)
+	_, err = NewAEAD(AESGCM, make([]byte, 7))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,72 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// SaltSize is the size of the salts of NewSalt.
+const SaltSize = 16
+
+// NewSalt returns a random salt, stored along with the data protected by a derived key.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// HKDF derives a key of size bytes from a secret with high entropy, e.g. a master key, with
+// HKDF-SHA256. Distinct infos give independent keys from the same secret.
+func HKDF(secret, salt, info []byte, size int) ([]byte, error) {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// DeriveKey derives a key of a Cipher from a master secret with HKDF.
+func DeriveKey(id string, alg Algorithm, secret, salt []byte, info string) (Key, error) {
+	material, err := HKDF(secret, salt, []byte(info), KeySize)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{ID: id, Algorithm: alg, Material: material}, nil
+}
+
+// Argon2Params holds the cost parameters of Argon2id.
+type Argon2Params struct {
+	Time    uint32 // Number of passes (default 3)
+	Memory  uint32 // Memory in KiB (default 64 MiB)
+	Threads uint8  // Parallelism (default 4)
+}
+
+func (p *Argon2Params) setDefaults() {
+	if p.Time == 0 {
+		p.Time = 3
+	}
+	if p.Memory == 0 {
+		p.Memory = 64 * 1024
+	}
+	if p.Threads == 0 {
+		p.Threads = 4
+	}
+}
+
+// PasswordKey derives a key of a Cipher from a password with Argon2id, slow by design against
+// brute force. The salt, from NewSalt, and the parameters must be kept to derive it again.
+func PasswordKey(id string, alg Algorithm, password string, salt []byte, params Argon2Params) (Key, error) {
+	if len(salt) < 8 {
+		return Key{}, errors.New("crypt: salt shorter than 8 bytes")
+	}
+	params.setDefaults()
+	material := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, KeySize)
+	return Key{ID: id, Algorithm: alg, Material: material}, nil
+}
@@ -0,0 +1,232 @@
+package crypt
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ChunkSize is the size of the plaintext of the chunks of a stream.
+const ChunkSize = 64 * 1024
+
+const streamSaltSize = 32
+
+// EncryptWriter returns a writer encrypting to w with the primary key, for contents of any
+// size. The content is split in chunks authenticated separately, in order, the last one being
+// marked, so reordered, removed or truncated chunks are detected by DecryptReader. Each stream
+// uses its own key, derived from the primary key with a random salt.
+//
+// Close must be called to write the last chunk, it does not close w.
+func (c *Cipher) EncryptWriter(w io.Writer, aad []byte) (io.WriteCloser, error) {
+	key := c.keys[c.primary]
+	h := header(kindStream, key)
+	h = h[:len(h):len(h)]
+	// Salt of the key of the stream, prefix of the nonces and chunk size
+	params := make([]byte, streamSaltSize+key.aead.NonceSize()-5+4)
+	if _, err := io.ReadFull(rand.Reader, params[:len(params)-4]); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(params[len(params)-4:], ChunkSize)
+	aead, err := streamAEAD(key, h, params[:streamSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(append(h, params...)); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		aad:    aad,
+		prefix: params[streamSaltSize : len(params)-4],
+		buf:    make([]byte, 0, ChunkSize),
+		out:    make([]byte, 0, ChunkSize+aead.Overhead()),
+		nonce:  make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+// streamAEAD returns the AEAD of the key of a stream
+func streamAEAD(key *cipherKey, h, salt []byte) (cipher.AEAD, error) {
+	subkey, err := HKDF(key.Material, salt, h, KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return NewAEAD(key.Algorithm, subkey)
+}
+
+// chunkNonce returns the nonce of a chunk: prefix, counter and last chunk flag
+func chunkNonce(nonce, prefix []byte, counter uint32, last bool) ([]byte, error) {
+	if counter == ^uint32(0) {
+		return nil, errors.New("crypt: stream too long")
+	}
+	n := copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[n:], counter)
+	nonce[n+4] = 0
+	if last {
+		nonce[n+4] = 1
+	}
+	return nonce, nil
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	nonce   []byte
+	buf     []byte // Plaintext of the pending chunk
+	out     []byte
+	counter uint32
+	err     error
+	closed  bool
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("crypt: write to closed stream")
+	}
+	written := 0
+	for len(p) > 0 && e.err == nil {
+		// A full chunk is written once more data comes, for the last chunk to be marked on Close
+		if len(e.buf) == ChunkSize {
+			e.err = e.flush(false)
+			continue
+		}
+		n := copy(e.buf[len(e.buf):ChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, e.err
+}
+
+func (e *encryptWriter) flush(last bool) error {
+	nonce, err := chunkNonce(e.nonce, e.prefix, e.counter, last)
+	if err != nil {
+		return err
+	}
+	e.out = e.aead.Seal(e.out[:0], nonce, e.buf, e.aad)
+	if _, err = e.w.Write(e.out); err != nil {
+		return err
+	}
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return e.err
+	}
+	e.closed = true
+	if e.err == nil {
+		e.err = e.flush(true)
+	}
+	return e.err
+}
+
+// DecryptReader returns a reader decrypting a stream of EncryptWriter from r. Read returns
+// ErrDecrypt when the stream was modified or truncated, the data returned before must then be
+// discarded.
+func (c *Cipher) DecryptReader(r io.Reader, aad []byte) (io.Reader, error) {
+	fixed := make([]byte, 4)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	h := append(fixed, make([]byte, fixed[3])...)
+	if _, err := io.ReadFull(r, h[4:]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	key, _, err := c.parseHeader(h, kindStream)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := key.aead.NonceSize()
+	params := make([]byte, streamSaltSize+nonceSize-5+4)
+	if _, err = io.ReadFull(r, params); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	chunkSize := int(binary.BigEndian.Uint32(params[len(params)-4:]))
+	if chunkSize == 0 || chunkSize > 16<<20 {
+		return nil, fmt.Errorf("%w: chunk size %d", ErrMalformed, chunkSize)
+	}
+	aead, err := streamAEAD(key, h, params[:streamSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		aad:    aad,
+		prefix: params[streamSaltSize : len(params)-4],
+		nonce:  make([]byte, nonceSize),
+		in:     make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	nonce   []byte
+	in      []byte
+	plain   []byte // Decrypted data not read yet
+	counter uint32
+	done    bool
+	err     error
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.r, d.in)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		// A full chunk is the last one when nothing follows
+		if _, err = d.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if n < d.aead.Overhead() {
+		// Truncated: the stream ends with a marked chunk, even empty
+		return ErrDecrypt
+	}
+	nonce, err := chunkNonce(d.nonce, d.prefix, d.counter, last)
+	if err != nil {
+		return err
+	}
+	plain, err := d.aead.Open(d.in[:0], nonce, d.in[:n], d.aad)
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.counter++
+	d.plain = plain
+	d.done = last
+	return nil
+}
@@ -0,0 +1,293 @@
+package csvutils
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Base struct {
+	ID int64 `csv:"id,required"`
+}
+
+type subscriber struct {
+	Base
+	MSISDN   string         `csv:"msisdn"`
+	Balance  big.Rat        `csv:"balance,scale=2"`
+	Joined   time.Time      `csv:"joined,layout=2006-01-02"`
+	LastSeen *time.Time     `csv:"last_seen"`
+	Score    *float64       `csv:"score"`
+	Region   sql.NullString `csv:"region"`
+	Status   status         `csv:"status"`
+	internal string
+	Ignored  string `csv:"-"`
+}
+
+type status int
+
+func decodeAll[T any](t *testing.T, dec *Decoder[T]) ([]T, error) {
+	t.Helper()
+	var items []T
+	for {
+		var item T
+		err := dec.Decode(&item)
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+}
+
+var statusConverter = map[reflect.Type]Converter{
+	reflect.TypeOf(status(0)): {
+		Decode: func(value string) (any, error) {
+			switch value {
+			case "active":
+				return status(1), nil
+			case "churned":
+				return status(2), nil
+			}
+			return nil, errors.New("unknown status")
+		},
+		Encode: func(value any) (string, error) {
+			return map[status]string{0: "", 1: "active", 2: "churned"}[value.(status)], nil
+		},
+	},
+}
+
+func TestDecoder(t *testing.T) {
+	input := "\ufeffID, Phone ,balance,joined,last_seen,score,region,status,extra\n" +
+		"1,233200000001,10.50,2024-01-02,2024-03-01T10:00:00Z,0.5,north,active,x\n" +
+		"2,233200000002,3,2024-02-03,,,,churned,y\n"
+	dec, err := NewDecoder[subscriber](strings.NewReader(input), DecoderConfig{
+		Schema:     &Schema{Aliases: map[string][]string{"msisdn": {"phone"}}},
+		Converters: statusConverter,
+	})
+	require.NoError(t, err)
+	items, err := decodeAll(t, dec)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	first := items[0]
+	assert.Equal(t, int64(1), first.ID)
+	assert.Equal(t, "233200000001", first.MSISDN)
+	assert.Equal(t, "21/2", first.Balance.String())
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), first.Joined)
+	require.NotNil(t, first.LastSeen)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), first.LastSeen.UTC())
+	require.NotNil(t, first.Score)
+	assert.Equal(t, 0.5, *first.Score)
+	assert.Equal(t, sql.NullString{String: "north", Valid: true}, first.Region)
+	assert.Equal(t, status(1), first.Status)
+
+	second := items[1]
+	assert.Nil(t, second.LastSeen)
+	assert.Nil(t, second.Score)
+	assert.False(t, second.Region.Valid)
+	assert.Equal(t, status(2), second.Status)
+}
+
+func TestDecoderErrorPolicies(t *testing.T) {
+	input := "id,msisdn,balance,joined\n" +
+		"1,a,1.00,2024-01-01\n" +
+		",b,1.234,nope\n" +
+		"3,c\n" +
+		"4,d,2,2024-01-04\n"
+
+	t.Run("fail", func(t *testing.T) {
+		dec, err := NewDecoder[subscriber](strings.NewReader(input), DecoderConfig{})
+		require.NoError(t, err)
+		items, err := decodeAll(t, dec)
+		require.Len(t, items, 1)
+		var rowErr *RowError
+		require.ErrorAs(t, err, &rowErr)
+		assert.Equal(t, 2, rowErr.Row)
+		assert.Equal(t, 3, rowErr.Line)
+		require.Len(t, rowErr.Fields, 3)
+		assert.Equal(t, "id", rowErr.Fields[0].Column)
+		assert.ErrorIs(t, err, ErrRequired)
+		assert.ErrorIs(t, err, ErrScale)
+		assert.Equal(t, []string{"", "b", "1.234", "nope"}, rowErr.Record)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		dec, err := NewDecoder[subscriber](strings.NewReader(input), DecoderConfig{OnError: Skip})
+		require.NoError(t, err)
+		items, err := decodeAll(t, dec)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, int64(4), items[1].ID)
+		assert.Equal(t, 2, dec.Skipped())
+		assert.Empty(t, dec.Errors())
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		dec, err := NewDecoder[subscriber](strings.NewReader(input), DecoderConfig{OnError: Collect})
+		require.NoError(t, err)
+		items, err := decodeAll(t, dec)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		require.Len(t, dec.Errors(), 2)
+		assert.ErrorIs(t, dec.Errors()[1], ErrFieldCount)
+	})
+
+	t.Run("max errors", func(t *testing.T) {
+		dec, err := NewDecoder[subscriber](strings.NewReader(input), DecoderConfig{OnError: Collect, MaxErrors: 1})
+		require.NoError(t, err)
+		_, err = decodeAll(t, dec)
+		assert.ErrorIs(t, err, ErrTooManyErrors)
+	})
+}
+
+func TestDecoderSchema(t *testing.T) {
+	_, err := decodeHeader("msisdn\n", nil)
+	assert.ErrorIs(t, err, ErrMissingColumn)
+
+	_, err = decodeHeader("id,msisdn\n", &Schema{Required: []string{"balance", "country"}})
+	assert.ErrorIs(t, err, ErrMissingColumn)
+	assert.Contains(t, err.Error(), "balance, country")
+
+	_, err = decodeHeader("id,msisdn,other\n", &Schema{Strict: true})
+	assert.ErrorIs(t, err, ErrUnknownColumn)
+
+	header, err := decodeHeader("ID,MSISDN\n", &Schema{Strict: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ID", "MSISDN"}, header)
+}
+
+func decodeHeader(input string, schema *Schema) ([]string, error) {
+	dec, err := NewDecoder[subscriber](strings.NewReader(input), DecoderConfig{Schema: schema})
+	if err != nil {
+		return nil, err
+	}
+	return dec.Header()
+}
+
+func TestDecoderNoHeader(t *testing.T) {
+	type row struct {
+		Name string
+		Age  int
+	}
+	dec, err := NewDecoder[row](strings.NewReader("ann;31\nbob;42\n"), DecoderConfig{Separator: ';', NoHeader: true})
+	require.NoError(t, err)
+	items, err := decodeAll(t, dec)
+	require.NoError(t, err)
+	assert.Equal(t, []row{{"ann", 31}, {"bob", 42}}, items)
+}
+
+func TestDecoderNullTime(t *testing.T) {
+	type event struct {
+		ID      int          `csv:"id"`
+		Expires sql.NullTime `csv:"expires,layout=2006-01-02"`
+	}
+	dec, err := NewDecoder[event](strings.NewReader("id,expires\n1,2024-05-06\n2,\n"), DecoderConfig{})
+	require.NoError(t, err)
+	items, err := decodeAll(t, dec)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, sql.NullTime{Time: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), Valid: true}, items[0].Expires)
+	assert.False(t, items[1].Expires.Valid)
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder[event](&buf, EncoderConfig{})
+	require.NoError(t, err)
+	for _, item := range items {
+		require.NoError(t, enc.Encode(item))
+	}
+	require.NoError(t, enc.Flush())
+	assert.Equal(t, "id,expires\n1,2024-05-06\n2,\n", buf.String())
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	score := 1.25
+	seen := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	items := []subscriber{
+		{Base: Base{ID: 1}, MSISDN: "a,b", LastSeen: &seen, Score: &score, Region: sql.NullString{String: "north", Valid: true}, Status: 1},
+		{Base: Base{ID: 2}, MSISDN: "c", Joined: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Status: 2},
+	}
+	items[0].Balance.SetString("10.5")
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder[subscriber](&buf, EncoderConfig{Converters: statusConverter})
+	require.NoError(t, err)
+	for _, item := range items {
+		require.NoError(t, enc.Encode(item))
+	}
+	require.NoError(t, enc.Flush())
+	assert.Equal(t, "id,msisdn,balance,joined,last_seen,score,region,status\n"+
+		"1,\"a,b\",10.50,0001-01-01,2024-03-01T10:00:00Z,1.25,north,active\n"+
+		"2,c,0.00,2024-01-02,,,,churned\n", buf.String())
+
+	dec, err := NewDecoder[subscriber](&buf, DecoderConfig{Converters: statusConverter})
+	require.NoError(t, err)
+	decoded, err := decodeAll(t, dec)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	assert.Equal(t, items[0].MSISDN, decoded[0].MSISDN)
+	assert.Equal(t, 0, items[0].Balance.Cmp(&decoded[0].Balance))
+	assert.Equal(t, items[1].Joined, decoded[1].Joined)
+	assert.Nil(t, decoded[1].Score)
+}
+
+func TestEncoderFloatPrecision(t *testing.T) {
+	type reading struct {
+		Value float64 `csv:"value,precision=4"`
+		Ratio float32 `csv:"ratio,precision=2"`
+		Raw   float64 `csv:"raw"`
+	}
+	var buf bytes.Buffer
+	enc, err := NewEncoder[reading](&buf, EncoderConfig{})
+	require.NoError(t, err)
+	require.NoError(t, enc.Encode(reading{Value: 3.14159, Ratio: 0.456, Raw: 3.14159}))
+	require.NoError(t, enc.Encode(reading{Value: 123456, Ratio: 2, Raw: 0.1}))
+	require.NoError(t, enc.Flush())
+	assert.Equal(t, "value,ratio,raw\n3.142,0.46,3.14159\n123500,2,0.1\n", buf.String())
+}
+
+func TestTypeFieldsErrors(t *testing.T) {
+	type duplicate struct {
+		A string `csv:"x"`
+		B string `csv:"x"`
+	}
+	_, err := NewDecoder[duplicate](strings.NewReader(""), DecoderConfig{})
+	assert.Error(t, err)
+
+	type badOption struct {
+		A string `csv:"a,nope"`
+	}
+	_, err = NewEncoder[badOption](io.Discard, EncoderConfig{})
+	assert.Error(t, err)
+
+	type badPrecision struct {
+		A big.Rat `csv:"a,precision=0"`
+	}
+	_, err = NewEncoder[badPrecision](io.Discard, EncoderConfig{})
+	assert.Error(t, err)
+
+	_, err = NewDecoder[string](strings.NewReader(""), DecoderConfig{})
+	assert.Error(t, err)
+}
+
+func TestGetCol(t *testing.T) {
+	data := [][]string{{"a", "b"}, {"c"}}
+	assert.Nil(t, GetCol(data, 2))
+	assert.Equal(t, []string{"b", ""}, GetCol(data, 1))
+}
+
+func TestWriteCSV(t *testing.T) {
+	raw, err := WriteCSV(nil, [][]string{{"a", "b"}}, ';')
+	require.NoError(t, err)
+	assert.Equal(t, "a;b", string(raw))
+}
@@ -5,13 +5,13 @@ import (
 	"encoding/csv"
 	"errors"
 	processingutils "github.com/seidu626/go-buildingblocks/files/processing"
-	"strconv"
-	"time"
+	"github.com/seidu626/go-buildingblocks/sql/schema"
 )
 
 // ReadCSV is delegated to read into a CSV the content of the bytes in input
 // []string -> Headers of the CSV
 // [][]string -> Content of the CSV
+// The whole content is loaded in memory, use a Decoder to stream large inputs.
 func ReadCSV(data []byte, separator rune, hasHeaders bool) ([]string, [][]string, error) {
 	buf, err := processingutils.ToUTF8(data)
 	if err != nil {
@@ -22,7 +22,6 @@ func ReadCSV(data []byte, separator rune, hasHeaders bool) ([]string, [][]string
 	csvReader.Comma = separator
 	csvReader.LazyQuotes = true
 	csvReader.TrimLeadingSpace = true
-	csvReader.ReuseRecord = true
 	csvData, err := csvReader.ReadAll()
 	if err != nil {
 		return nil, nil, err
@@ -40,127 +39,63 @@ func ReadCSV(data []byte, separator rune, hasHeaders bool) ([]string, [][]string
 	}
 	return headers, csvData, nil
 }
+
+// WriteCSV is delegated to write the headers, if any, and the records as CSV
 func WriteCSV(headers []string, records [][]string, separator rune) ([]byte, error) {
 	var buff bytes.Buffer
 	writer := csv.NewWriter(&buff)
-	defer writer.Flush()
 	writer.Comma = separator
-	if err := writer.Write(headers); err != nil {
-		return nil, err
+	if len(headers) > 0 {
+		if err := writer.Write(headers); err != nil {
+			return nil, err
+		}
 	}
+	// WriteAll flushes the writer
 	if err := writer.WriteAll(records); err != nil {
 		return nil, err
 	}
 	return bytes.TrimSuffix(buff.Bytes(), []byte("\n")), nil
 }
 
-// GetCol is delegated to filter a given column from the csvData
+// GetCol is delegated to filter a given column from the csvData, rows too short are returned empty
 func GetCol(csvData [][]string, index int) []string {
-	if len(csvData) == 0 || len(csvData[0]) < index {
+	if len(csvData) == 0 || index < 0 || len(csvData[0]) <= index {
 		return nil
 	}
 	var ret = make([]string, len(csvData))
 	for i := range csvData {
-		ret[i] = csvData[i][index]
+		if index < len(csvData[i]) {
+			ret[i] = csvData[i][index]
+		}
 	}
 	return ret
 }
 
-// GetCSVDataType is delegated to retrieve the data type for every field of the CSV
+// GetCSVDataType is delegated to retrieve the data type for every field of the CSV: int, float,
+// bool, time or string. Types are inferred with schema.InferRecords on all the rows, so a
+// column only widens (int to float to string) and never depends on the order of the rows.
 // Return: headers, csv data, data type, error
 func GetCSVDataType(raw []byte, separator rune) ([]string, [][]string, map[string]string, error) {
 	headers, data, err := ReadCSV(raw, separator, true)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	columns := schema.InferRecords(headers, data, schema.Options{SampleRows: -1})
 	// key = headers ; value = type
-	var dataType = make(map[string]string)
-	// Iterating headers
-	for i, header := range headers {
-		// Track if a given type is already tested and returned an error
-		// e[<type>] = True -> Error, skip check for the give <type>
-		// e[<type>] = False -> Not checked, continue trying to parse the field
-		var e = make(map[string]bool)
-		for _, row := range data {
-			// INT was not checked for this header
-			if !e["int"] {
-				if _, err = strconv.ParseInt(row[i], 10, 64); err == nil {
-					dataType[header] = "int"
-					continue
-				} else {
-					// Error, INT can be used as type for this headers
-					//log.Println(fmt.Sprintf("Line %d break the int rule [%s] for header %s", lineN+2, row[i], header))
-					e["int"] = true
-				}
-			}
-			if !e["float"] {
-				if _, err = strconv.ParseFloat(row[i], 64); err == nil {
-					dataType[header] = "float"
-					continue
-				} else {
-					//log.Println(fmt.Sprintf("Line %d break the float rule [%s] for header %s", lineN+2, row[i], header))
-					e["float"] = true
-				}
-			}
-			if !e["bool"] {
-				if _, err = strconv.ParseBool(row[i]); err == nil {
-					dataType[header] = "bool"
-					continue
-				} else {
-					////log.Println(fmt.Sprintf("Line %d break the bool rule [%s] for header %s", lineN+2, row[i], header))
-					e["bool"] = true
-				}
-			}
-			if row[i] == "" {
-				dataType[header] = "string"
-				//log.Println("Found empty value in line", lineN+2, "of column", header, "| Setting text type")
-				continue
-			}
-			if row[i][0] == '0' {
-				// A number that start with 0 is a valid number for golang, but from a data warehouse POV, it has to be saved as is, so it's better to use a string.
-				// Example: 00100 will be saved as 100, that is not correct
-				dataType[header] = "string"
-				break
-			}
-			if !e["time"] {
-				for _, layout := range []string{
-					"2006-01-02T15:04:05Z",
-					"2006-01-02",
-					"2006-01-02 15:04:05",
-					"2006-01-02T15:04:05.999999Z07:00",
-					"2006-01-02 15:04:05.999999Z07",
-					time.ANSIC,
-					time.UnixDate,
-					time.RubyDate,
-					time.RFC822,
-					time.RFC822Z,
-					time.RFC850,
-					time.RFC1123,
-					time.RFC1123Z,
-					time.RFC3339,
-					time.RFC3339Nano,
-					time.Kitchen,
-					time.Stamp,
-					time.StampMilli,
-					time.StampMicro,
-					time.StampNano} {
-
-					if _, err := time.Parse(layout, row[i]); err == nil {
-						dataType[header] = "time"
-						e["time"] = false
-						break
-					}
-					//log.Println(fmt.Sprintf("Line %d break the time rule [%s] for header %s", lineN+2, row[i], header))
-					e["time"] = true
-				}
-				if !e["time"] {
-					continue
-				}
-			}
-			// fallback data type
-			dataType[header] = "string"
+	var dataType = make(map[string]string, len(columns))
+	for _, column := range columns {
+		switch column.Type {
+		case schema.Boolean:
+			dataType[column.Header] = "bool"
+		case schema.Integer, schema.BigInt:
+			dataType[column.Header] = "int"
+		case schema.Decimal, schema.Float:
+			dataType[column.Header] = "float"
+		case schema.Date, schema.Timestamp, schema.TimestampTZ:
+			dataType[column.Header] = "time"
+		default:
+			dataType[column.Header] = "string"
 		}
-
 	}
 	return headers, data, dataType, nil
 }
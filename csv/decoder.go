@@ -0,0 +1,359 @@
+package csvutils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrorPolicy is what the Decoder does with a row that cannot be decoded.
+type ErrorPolicy int
+
+const (
+	// Fail returns the RowError from Decode; the next call continues with the following row.
+	Fail ErrorPolicy = iota
+	// Skip drops the row silently, Skipped counts them.
+	Skip
+	// Collect drops the row and keeps its error, see Errors.
+	Collect
+)
+
+// ErrTooManyErrors is returned by Decode when Collect gathered more than MaxErrors errors
+var ErrTooManyErrors = errors.New("csv: too many errors")
+
+// Schema maps the header of a file to the columns of the struct fields.
+type Schema struct {
+	// Aliases are the other headers accepted for a column, e.g. "msisdn": {"phone", "mobile"}.
+	// Headers are matched case insensitively, ignoring the surrounding spaces.
+	Aliases map[string][]string
+	// Required are the columns the header must contain, in addition to the fields tagged required
+	Required []string
+	// Strict rejects the headers not mapped to a field
+	Strict bool
+}
+
+// DecoderConfig holds the configuration of a Decoder.
+type DecoderConfig struct {
+	Separator rune // Defaults to ','
+	Comment   rune
+	// NoHeader maps the columns by position, in the order of the struct fields
+	NoHeader bool
+	Schema   *Schema
+	OnError  ErrorPolicy
+	// MaxErrors stops the decoding once Collect gathered more errors, 0 means unlimited
+	MaxErrors int
+	// NullValues are decoded as nil pointers and invalid sql.Null* values (default: empty string)
+	NullValues []string
+	// TrimSpace trims the spaces around the values
+	TrimSpace bool
+	// TimeLayouts are tried for the time.Time fields without layout option (default: DefaultTimeLayouts)
+	TimeLayouts []string
+	Location    *time.Location // Location of the times without zone (default: UTC)
+	Converters  map[reflect.Type]Converter
+}
+
+func (c *DecoderConfig) setDefaults() {
+	if c.Separator == 0 {
+		c.Separator = ','
+	}
+	if c.NullValues == nil {
+		c.NullValues = []string{""}
+	}
+	if c.TimeLayouts == nil {
+		c.TimeLayouts = DefaultTimeLayouts
+	}
+	if c.Location == nil {
+		c.Location = time.UTC
+	}
+}
+
+// FieldError is the error of a value of a row.
+type FieldError struct {
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("column %q: value %q: %v", e.Column, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// RowError is the error of a row that cannot be decoded: either Err is set for a malformed
+// row, or Fields lists the values that could not be converted.
+type RowError struct {
+	Row    int // Data row, starting at 1 after the header
+	Line   int // Line of the row in the input
+	Record []string
+	Fields []*FieldError
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "csv: row %d (line %d)", e.Row, e.Line)
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "; %v", f)
+	}
+	return b.String()
+}
+
+func (e *RowError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Fields)+1)
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	for _, f := range e.Fields {
+		errs = append(errs, f)
+	}
+	return errs
+}
+
+// column is a field mapped to its position in the records
+type column struct {
+	field *field
+	index int
+}
+
+// Decoder reads the rows of a CSV into structs, streaming the input:
+//
+//	dec, err := csvutils.NewDecoder[Subscriber](r, csvutils.DecoderConfig{OnError: csvutils.Collect})
+//	for {
+//		var s Subscriber
+//		if err = dec.Decode(&s); err == io.EOF {
+//			break
+//		} else if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//	bad := dec.Errors()
+//
+// The input must be UTF-8, a leading BOM is ignored.
+type Decoder[T any] struct {
+	config  DecoderConfig
+	reader  *csv.Reader
+	codec   codec
+	fields  []field
+	nulls   map[string]bool
+	header  []string
+	columns []column
+	started bool
+	err     error // Error of the header, returned by every call
+	row     int
+	skipped int
+	errs    []*RowError
+}
+
+// NewDecoder creates a Decoder for the struct T.
+func NewDecoder[T any](r io.Reader, cfg DecoderConfig) (*Decoder[T], error) {
+	cfg.setDefaults()
+	fields, err := typeFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(r)
+	reader.Comma = cfg.Separator
+	reader.Comment = cfg.Comment
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	nulls := make(map[string]bool, len(cfg.NullValues))
+	for _, null := range cfg.NullValues {
+		nulls[null] = true
+	}
+	return &Decoder[T]{
+		config: cfg,
+		reader: reader,
+		codec:  codec{converters: cfg.Converters, timeLayouts: cfg.TimeLayouts, location: cfg.Location},
+		fields: fields,
+		nulls:  nulls,
+	}, nil
+}
+
+// Header reads the header, if not yet read, and returns it. It is nil with NoHeader.
+func (d *Decoder[T]) Header() ([]string, error) {
+	d.start()
+	return d.header, d.err
+}
+
+// Decode decodes the next row into v, returning io.EOF at the end of the input. With the
+// Fail policy, a bad row returns a *RowError and v is left unchanged.
+func (d *Decoder[T]) Decode(v *T) error {
+	if d.start(); d.err != nil {
+		return d.err
+	}
+	for {
+		record, err := d.reader.Read()
+		if err == io.EOF {
+			return io.EOF
+		}
+		d.row++
+		var rowErr *RowError
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return err
+			}
+			rowErr = &RowError{Row: d.row, Line: parseErr.StartLine, Err: parseErr.Err}
+		} else {
+			rowErr = d.decodeRecord(record, v)
+			if rowErr == nil {
+				return nil
+			}
+			rowErr.Line, _ = d.reader.FieldPos(0)
+		}
+		if rowErr.Record == nil && record != nil {
+			rowErr.Record = append([]string(nil), record...)
+		}
+
+		switch d.config.OnError {
+		case Skip:
+			d.skipped++
+		case Collect:
+			d.errs = append(d.errs, rowErr)
+			if d.config.MaxErrors > 0 && len(d.errs) > d.config.MaxErrors {
+				return fmt.Errorf("%w: %d rows", ErrTooManyErrors, len(d.errs))
+			}
+		default:
+			return rowErr
+		}
+	}
+}
+
+// Errors returns the errors gathered by the Collect policy.
+func (d *Decoder[T]) Errors() []*RowError {
+	return d.errs
+}
+
+// Skipped returns the number of rows dropped by the Skip and Collect policies.
+func (d *Decoder[T]) Skipped() int {
+	return d.skipped + len(d.errs)
+}
+
+func (d *Decoder[T]) decodeRecord(record []string, v *T) *RowError {
+	var item T
+	rv := reflect.ValueOf(&item).Elem()
+	rowErr := &RowError{Row: d.row}
+	for _, col := range d.columns {
+		if col.index >= len(record) {
+			rowErr.Err = fmt.Errorf("%w: %d instead of at least %d", ErrFieldCount, len(record), col.index+1)
+			return rowErr
+		}
+		value := record[col.index]
+		if d.config.TrimSpace {
+			value = strings.TrimSpace(value)
+		}
+		null := d.nulls[value]
+		if null && col.field.required {
+			rowErr.Fields = append(rowErr.Fields, &FieldError{Column: col.field.name, Value: value, Err: ErrRequired})
+			continue
+		}
+		if err := d.codec.decode(value, null, rv.FieldByIndex(col.field.index), col.field); err != nil {
+			rowErr.Fields = append(rowErr.Fields, &FieldError{Column: col.field.name, Value: value, Err: err})
+		}
+	}
+	if len(rowErr.Fields) > 0 {
+		return rowErr
+	}
+	*v = item
+	return nil
+}
+
+// start reads the header and maps the columns on the first call
+func (d *Decoder[T]) start() {
+	if d.started {
+		return
+	}
+	d.started = true
+	if d.config.NoHeader {
+		for i := range d.fields {
+			d.columns = append(d.columns, column{field: &d.fields[i], index: i})
+		}
+		return
+	}
+	header, err := d.reader.Read()
+	if err == io.EOF {
+		d.err = io.EOF
+		return
+	}
+	if err != nil {
+		d.err = fmt.Errorf("csv: reading header: %w", err)
+		return
+	}
+	d.header = append([]string(nil), header...)
+	if len(d.header) > 0 {
+		d.header[0] = strings.TrimPrefix(d.header[0], "\ufeff")
+	}
+	d.columns, d.err = mapColumns(d.header, d.fields, d.config.Schema)
+}
+
+func normalizeHeader(h string) string {
+	return strings.ToLower(strings.TrimSpace(h))
+}
+
+// mapColumns finds the position of the fields in the header
+func mapColumns(header []string, fields []field, schema *Schema) ([]column, error) {
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		if _, ok := positions[normalizeHeader(h)]; !ok {
+			positions[normalizeHeader(h)] = i
+		}
+	}
+	if schema == nil {
+		schema = &Schema{}
+	}
+	required := make(map[string]bool, len(schema.Required))
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	var columns []column
+	used := make(map[int]bool, len(fields))
+	var missing []string
+	for i := range fields {
+		f := &fields[i]
+		found := false
+		for _, name := range append([]string{f.name}, schema.Aliases[f.name]...) {
+			if pos, ok := positions[normalizeHeader(name)]; ok {
+				columns = append(columns, column{field: f, index: pos})
+				used[pos], found = true, true
+				break
+			}
+		}
+		if !found && (f.required || required[f.name]) {
+			missing = append(missing, f.name)
+		}
+		delete(required, f.name)
+	}
+	for name := range required {
+		if _, ok := positions[normalizeHeader(name)]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: %s", ErrMissingColumn, strings.Join(missing, ", "))
+	}
+	if schema.Strict {
+		var unknown []string
+		for i, h := range header {
+			if !used[i] {
+				unknown = append(unknown, h)
+			}
+		}
+		if len(unknown) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, strings.Join(unknown, ", "))
+		}
+	}
+	return columns, nil
+}
@@ -0,0 +1,100 @@
+package csvutils
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"time"
+)
+
+// EncoderConfig holds the configuration of an Encoder.
+type EncoderConfig struct {
+	Separator rune // Defaults to ','
+	UseCRLF   bool
+	// NoHeader omits the header row built from the tags
+	NoHeader bool
+	// NullValue is written for nil pointers and invalid sql.Null* values
+	NullValue string
+	// TimeLayout formats the time.Time fields without layout option (default: time.RFC3339Nano)
+	TimeLayout string
+	Converters map[reflect.Type]Converter
+}
+
+func (c *EncoderConfig) setDefaults() {
+	if c.Separator == 0 {
+		c.Separator = ','
+	}
+	if c.TimeLayout == "" {
+		c.TimeLayout = time.RFC3339Nano
+	}
+}
+
+// Encoder writes structs as CSV rows, with a header from their tags. Rows are buffered: Flush
+// must be called once done.
+type Encoder[T any] struct {
+	config  EncoderConfig
+	writer  *csv.Writer
+	codec   codec
+	fields  []field
+	started bool
+	record  []string
+}
+
+// NewEncoder creates an Encoder for the struct T.
+func NewEncoder[T any](w io.Writer, cfg EncoderConfig) (*Encoder[T], error) {
+	cfg.setDefaults()
+	fields, err := typeFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = cfg.Separator
+	writer.UseCRLF = cfg.UseCRLF
+	return &Encoder[T]{
+		config: cfg,
+		writer: writer,
+		codec:  codec{converters: cfg.Converters},
+		fields: fields,
+		record: make([]string, len(fields)),
+	}, nil
+}
+
+// Header returns the columns written by the encoder.
+func (e *Encoder[T]) Header() []string {
+	header := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		header[i] = f.name
+	}
+	return header
+}
+
+// Encode writes v, preceded by the header on the first call.
+func (e *Encoder[T]) Encode(v T) error {
+	if !e.started {
+		e.started = true
+		if !e.config.NoHeader {
+			if err := e.writer.Write(e.Header()); err != nil {
+				return err
+			}
+		}
+	}
+	rv := reflect.ValueOf(&v).Elem()
+	for i := range e.fields {
+		f := &e.fields[i]
+		s, null, err := e.codec.encode(rv.FieldByIndex(f.index), f, e.config.TimeLayout)
+		if err != nil {
+			return &FieldError{Column: f.name, Err: err}
+		}
+		if null {
+			s = e.config.NullValue
+		}
+		e.record[i] = s
+	}
+	return e.writer.Write(e.record)
+}
+
+// Flush writes the buffered rows to the underlying writer.
+func (e *Encoder[T]) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
@@ -0,0 +1,367 @@
+package csvutils
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRequired is reported for an empty value of a required column
+	ErrRequired = errors.New("csv: value is required")
+	// ErrMissingColumn is returned when the header lacks a required column
+	ErrMissingColumn = errors.New("csv: missing column")
+	// ErrUnknownColumn is returned by a strict schema for a header without field
+	ErrUnknownColumn = errors.New("csv: unknown column")
+	// ErrFieldCount is reported for a row with fewer values than the mapped columns
+	ErrFieldCount = errors.New("csv: wrong number of fields")
+	// ErrScale is reported for a decimal with more digits than the scale of its field
+	ErrScale = errors.New("csv: decimal exceeds scale")
+)
+
+// DefaultTimeLayouts are the layouts tried for the time.Time fields without layout option
+var DefaultTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Converter converts the fields of a type the codec does not handle natively. Either function
+// may be nil when the type is only decoded or only encoded.
+type Converter struct {
+	Decode func(value string) (any, error)
+	Encode func(value any) (string, error)
+}
+
+// field is a struct field mapped to a column, with the options of its tag:
+//
+//	Amount *big.Rat   `csv:"amount,scale=2,precision=12"`
+//	Created time.Time `csv:"created_at,required,layout=2006-01-02"`
+type field struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	required  bool
+	layout    string
+	scale     int // -1 when not set
+	precision int // Total digits of the decimal columns and significant digits of the floats, 0 when not set
+}
+
+var fieldCache sync.Map // reflect.Type -> []field
+
+// typeFields returns the mapped fields of a struct type. Untagged exported fields use their
+// name, fields tagged "-" are ignored and embedded structs are flattened.
+func typeFields(t reflect.Type) ([]field, error) {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv: %s is not a struct", t)
+	}
+	var fields []field
+	if err := appendFields(&fields, t, nil); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if seen[f.name] {
+			return nil, fmt.Errorf("csv: duplicate column %q in %s", f.name, t)
+		}
+		seen[f.name] = true
+	}
+	fieldCache.Store(t, fields)
+	return fields, nil
+}
+
+func appendFields(fields *[]field, t reflect.Type, index []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("csv")
+		if tag == "-" {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			if err := appendFields(fields, sf.Type, idx); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		f := field{name: sf.Name, index: idx, typ: sf.Type, scale: -1}
+		parts := strings.Split(tag, ",")
+		if parts[0] != "" {
+			f.name = parts[0]
+		}
+		for _, option := range parts[1:] {
+			key, value, _ := strings.Cut(option, "=")
+			switch key {
+			case "required":
+				f.required = true
+			case "layout":
+				f.layout = value
+			case "scale":
+				scale, err := strconv.Atoi(value)
+				if err != nil || scale < 0 {
+					return fmt.Errorf("csv: invalid scale %q of field %s", value, sf.Name)
+				}
+				f.scale = scale
+			case "precision":
+				precision, err := strconv.Atoi(value)
+				if err != nil || precision <= 0 {
+					return fmt.Errorf("csv: invalid precision %q of field %s", value, sf.Name)
+				}
+				f.precision = precision
+			default:
+				return fmt.Errorf("csv: unknown option %q of field %s", option, sf.Name)
+			}
+		}
+		*fields = append(*fields, f)
+	}
+	return nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durType      = reflect.TypeOf(time.Duration(0))
+	ratType      = reflect.TypeOf(big.Rat{})
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+	scannerType  = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType   = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	textUnmType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// codec holds the options shared by the decoder and the encoder
+type codec struct {
+	converters  map[reflect.Type]Converter
+	timeLayouts []string
+	location    *time.Location
+}
+
+// decode sets v from s. Null values leave pointers nil, sql.Null* types invalid and the other
+// types to their zero value.
+func (c *codec) decode(s string, null bool, v reflect.Value, f *field) error {
+	if conv, ok := c.converters[v.Type()]; ok && conv.Decode != nil {
+		if null {
+			v.SetZero()
+			return nil
+		}
+		res, err := conv.Decode(s)
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(res)
+		if !rv.IsValid() {
+			v.SetZero()
+			return nil
+		}
+		if !rv.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("converter returned %s instead of %s", rv.Type(), v.Type())
+		}
+		v.Set(rv)
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if null {
+			v.SetZero()
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := c.decode(s, false, elem.Elem(), f); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Type() == nullTimeType {
+		// sql.NullTime only scans time.Time values
+		if null {
+			v.SetZero()
+			return nil
+		}
+		t, err := c.parseTime(s, f.layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(scannerType) {
+		var src any
+		if !null {
+			src = s
+		}
+		return v.Addr().Interface().(sql.Scanner).Scan(src)
+	}
+	if null {
+		v.SetZero()
+		return nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		t, err := c.parseTime(s, f.layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case ratType:
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return fmt.Errorf("invalid decimal %q", s)
+		}
+		if f.scale >= 0 {
+			if digits, exact := r.FloatPrec(); !exact || digits > f.scale {
+				return ErrScale
+			}
+		}
+		v.Set(reflect.ValueOf(r).Elem())
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (c *codec) parseTime(s, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.ParseInLocation(layout, s, c.location)
+	}
+	for _, layout = range c.timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, c.location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// encode formats v, null reports the nil pointers and the invalid sql.Null* values
+func (c *codec) encode(v reflect.Value, f *field, layout string) (s string, null bool, err error) {
+	if conv, ok := c.converters[v.Type()]; ok && conv.Encode != nil {
+		s, err = conv.Encode(v.Interface())
+		return s, false, err
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", true, nil
+		}
+		return c.encode(v.Elem(), f, layout)
+	}
+	if f.layout != "" {
+		layout = f.layout
+	}
+
+	switch v.Type() {
+	case timeType:
+		return v.Interface().(time.Time).Format(layout), false, nil
+	case durType:
+		return time.Duration(v.Int()).String(), false, nil
+	case ratType:
+		r := v.Addr().Interface().(*big.Rat)
+		if f.scale >= 0 {
+			return r.FloatString(f.scale), false, nil
+		}
+		digits, exact := r.FloatPrec()
+		if !exact {
+			digits = 16
+		}
+		return r.FloatString(digits), false, nil
+	}
+	if valuer, ok := implements(v, valuerType).(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil || value == nil {
+			return "", value == nil, err
+		}
+		return c.encode(reflect.ValueOf(value), f, layout)
+	}
+	if marshaler, ok := implements(v, textMarType).(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), false, err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), false, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), false, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), false, nil
+	case reflect.Float32, reflect.Float64:
+		x, bits := v.Float(), v.Type().Bits()
+		if f.precision > 0 {
+			x, _ = strconv.ParseFloat(strconv.FormatFloat(x, 'g', f.precision, bits), bits)
+		}
+		return strconv.FormatFloat(x, 'f', -1, bits), false, nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), false, nil
+		}
+	}
+	return "", false, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// implements returns v or its address when either implements the interface, nil otherwise
+func implements(v reflect.Value, iface reflect.Type) any {
+	if v.Type().Implements(iface) {
+		return v.Interface()
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(iface) {
+		return v.Addr().Interface()
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package csvutils
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/sql/schema"
+)
+
+// Mapping converts the structs T to records of strings and back, with the tags and converters
+// of the Decoder and the Encoder. The formats package relies on it to read and write the same
+// structs as Parquet or Avro.
+type Mapping[T any] struct {
+	codec      codec
+	fields     []field
+	timeLayout string
+	columns    []schema.Column
+}
+
+// MappingConfig holds the configuration of a Mapping.
+type MappingConfig struct {
+	// TimeLayout formats the time.Time fields without layout option (default: time.RFC3339Nano)
+	TimeLayout string
+	Location   *time.Location // Location of the times without zone (default: UTC)
+	Converters map[reflect.Type]Converter
+}
+
+// DefaultDecimalScale is the scale of the big.Rat fields without scale option in Columns
+const DefaultDecimalScale = 9
+
+var nullTypes = map[reflect.Type]schema.Type{
+	reflect.TypeOf(sql.NullString{}):  schema.String,
+	reflect.TypeOf(sql.NullBool{}):    schema.Boolean,
+	reflect.TypeOf(sql.NullByte{}):    schema.Integer,
+	reflect.TypeOf(sql.NullInt16{}):   schema.Integer,
+	reflect.TypeOf(sql.NullInt32{}):   schema.Integer,
+	reflect.TypeOf(sql.NullInt64{}):   schema.BigInt,
+	reflect.TypeOf(sql.NullFloat64{}): schema.Float,
+	reflect.TypeOf(sql.NullTime{}):    schema.TimestampTZ,
+}
+
+// NewMapping creates the Mapping of the struct T.
+func NewMapping[T any](cfg MappingConfig) (*Mapping[T], error) {
+	if cfg.TimeLayout == "" {
+		cfg.TimeLayout = time.RFC3339Nano
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	fields, err := typeFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	m := &Mapping[T]{
+		codec:      codec{converters: cfg.Converters, timeLayouts: []string{cfg.TimeLayout}, location: cfg.Location},
+		fields:     fields,
+		timeLayout: cfg.TimeLayout,
+	}
+	m.columns = make([]schema.Column, len(fields))
+	for i := range fields {
+		m.columns[i] = m.column(&fields[i])
+	}
+	return m, nil
+}
+
+// Columns returns the schema of the fields, in the order of the records.
+func (m *Mapping[T]) Columns() []schema.Column {
+	return append([]schema.Column(nil), m.columns...)
+}
+
+// column derives the schema of a field from its Go type
+func (m *Mapping[T]) column(f *field) schema.Column {
+	c := schema.Column{Header: f.name, Name: f.name, Type: schema.String, Nullable: !f.required}
+	t := f.typ
+	if _, ok := m.codec.converters[t]; ok {
+		return c
+	}
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+	if _, ok := m.codec.converters[t]; ok {
+		return c
+	}
+	c.Nullable = nullable && !f.required
+	if typ, ok := nullTypes[t]; ok {
+		c.Type, c.Nullable = typ, !f.required
+		if typ == schema.TimestampTZ {
+			c.Layout = m.timeLayout
+		}
+		return c
+	}
+
+	switch t {
+	case timeType:
+		c.Type, c.Layout = schema.TimestampTZ, m.timeLayout
+		if f.layout != "" {
+			c.Layout = f.layout
+			if isDateLayout(f.layout) {
+				c.Type = schema.Date
+			}
+		}
+		return c
+	case durType:
+		return c
+	case ratType:
+		c.Type, c.Scale, c.Precision = schema.Decimal, DefaultDecimalScale, schema.MaxPrecision
+		if f.scale >= 0 {
+			c.Scale = f.scale
+		}
+		if f.precision > 0 {
+			c.Precision = f.precision
+		}
+		return c
+	}
+	if reflect.PointerTo(t).Implements(textUnmType) || t.Implements(textMarType) {
+		return c
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		c.Type = schema.Boolean
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		c.Type = schema.Integer
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		c.Type = schema.BigInt
+	case reflect.Uint, reflect.Uint64:
+		c.Type, c.Precision = schema.Decimal, 20
+	case reflect.Float32, reflect.Float64:
+		c.Type = schema.Float
+	}
+	return c
+}
+
+// Encode formats the fields of v into values, nulls reports the nil pointers and the invalid
+// sql.Null* values. Both slices must have the length of Columns.
+func (m *Mapping[T]) Encode(v T, values []string, nulls []bool) error {
+	rv := reflect.ValueOf(&v).Elem()
+	for i := range m.fields {
+		f := &m.fields[i]
+		s, null, err := m.codec.encode(rv.FieldByIndex(f.index), f, m.timeLayout)
+		if err != nil {
+			return &FieldError{Column: f.name, Err: err}
+		}
+		values[i], nulls[i] = s, null
+	}
+	return nil
+}
+
+// Decode sets the fields of v from the values, in the order of Columns. The failures of the
+// fields are reported in a *RowError, leaving v unchanged.
+func (m *Mapping[T]) Decode(values []string, nulls []bool, v *T) error {
+	if len(values) != len(m.fields) || len(nulls) != len(m.fields) {
+		return fmt.Errorf("%w: %d instead of %d", ErrFieldCount, len(values), len(m.fields))
+	}
+	var item T
+	rv := reflect.ValueOf(&item).Elem()
+	rowErr := &RowError{}
+	for i := range m.fields {
+		f := &m.fields[i]
+		if nulls[i] && f.required {
+			rowErr.Fields = append(rowErr.Fields, &FieldError{Column: f.name, Err: ErrRequired})
+			continue
+		}
+		if err := m.codec.decode(values[i], nulls[i], rv.FieldByIndex(f.index), f); err != nil {
+			rowErr.Fields = append(rowErr.Fields, &FieldError{Column: f.name, Value: values[i], Err: err})
+		}
+	}
+	if len(rowErr.Fields) > 0 {
+		return rowErr
+	}
+	*v = item
+	return nil
+}
+
+// isDateLayout reports whether a layout has no time of day
+func isDateLayout(layout string) bool {
+	ref := time.Date(2001, 2, 3, 16, 17, 18, 0, time.UTC)
+	t, err := time.Parse(layout, ref.Format(layout))
+	return err == nil && t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
+}
@@ -30,6 +30,12 @@ type Config struct {
 	ProtoVersion   int
 	SSL            bool
 	SslOpts        *gocql.SslOptions // Updated to use SslOptions
+	// Logger, optional, receives the logs of the driver, see logging.NewGocqlLogger
+	Logger gocql.StdLogger
+	// QueryObserver and BatchObserver, optional, observe the queries, see
+	// telemetry.Provider.InstrumentCassandra
+	QueryObserver gocql.QueryObserver
+	BatchObserver gocql.BatchObserver
 }
 
 // NewCassandraStore initializes and returns a new CassandraStore.
@@ -44,6 +50,11 @@ func NewCassandraStore(logger *zap.Logger, config *Config) (*CxStore, error) {
 	cluster.NumConns = config.PoolSize
 	cluster.RetryPolicy = config.RetryPolicy
 	cluster.ProtoVersion = config.ProtoVersion
+	if config.Logger != nil {
+		cluster.Logger = config.Logger
+	}
+	cluster.QueryObserver = config.QueryObserver
+	cluster.BatchObserver = config.BatchObserver
 
 	if config.Username != "" && config.Password != "" {
 		cluster.Authenticator = gocql.PasswordAuthenticator{
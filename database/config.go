@@ -1,6 +1,10 @@
 package database
 
-import "time"
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
 
 // Config holds the configuration parameters for CockroachDB connection.
 type Config struct {
@@ -15,6 +19,9 @@ type Config struct {
 	MaxIdleConns    int
 	MaxOpenConns    int
 	ConnMaxLifetime time.Duration
+	// Tracer, optional, traces the queries in addition to the trace logger, see
+	// telemetry.Provider.InstrumentDatabase
+	Tracer pgx.QueryTracer
 }
 
 type PoolSize struct {
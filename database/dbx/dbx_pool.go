@@ -11,6 +11,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/tracelog"
@@ -34,10 +36,10 @@ func NewDBXPool(ctx context.Context, logger *zap.Logger, traceLogger tracelog.Lo
 		return nil, err
 	}
 
-	poolConfig.ConnConfig.Tracer = &tracelog.TraceLog{
+	poolConfig.ConnConfig.Tracer = withTracer(&tracelog.TraceLog{
 		Logger:   traceLogger,
 		LogLevel: logLevel,
-	}
+	}, config.Tracer)
 
 	// pgxpool default max number of connections is the number of CPUs on your machine returned by runtime.NumCPU().
 	// This number is very conservative, and you might be able to improve performance for highly concurrent applications
@@ -60,6 +62,14 @@ func NewDBXPool(ctx context.Context, logger *zap.Logger, traceLogger tracelog.Lo
 	return pool, nil
 }
 
+// withTracer combines the trace logger with the tracer of the configuration, if any.
+func withTracer(traceLog *tracelog.TraceLog, tracer pgx.QueryTracer) pgx.QueryTracer {
+	if tracer == nil {
+		return traceLog
+	}
+	return multitracer.New(traceLog, tracer)
+}
+
 // buildDSN constructs the Data Source Name based on the config.
 func buildDSN(config *database.Config) string {
 	hosts := strings.Join(config.Hosts, ",")
@@ -7,6 +7,8 @@ import (
 	"os"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/tracelog"
@@ -23,6 +25,12 @@ import (
 // PGDATABASE, PGHOST, PGPORT, PGUSER, PGPASSWORD, PGCONNECT_TIMEOUT, etc.
 // Reference: https://www.postgresql.org/docs/current/libpq-envars.html
 func NewPGXPool(ctx context.Context, connString string, logger tracelog.Logger, logLevel tracelog.LogLevel) (*pgxpool.Pool, error) {
+	return NewPGXPoolWithTracer(ctx, connString, logger, logLevel, nil)
+}
+
+// NewPGXPoolWithTracer is NewPGXPool with a tracer of the queries in addition to the logger, e.g.
+// the one of telemetry.Provider.PgxTracer. A nil tracer is ignored.
+func NewPGXPoolWithTracer(ctx context.Context, connString string, logger tracelog.Logger, logLevel tracelog.LogLevel, tracer pgx.QueryTracer) (*pgxpool.Pool, error) {
 	conf, err := pgxpool.ParseConfig(connString) // Using environment variables instead of a connection string.
 	if err != nil {
 		return nil, err
@@ -32,6 +40,9 @@ func NewPGXPool(ctx context.Context, connString string, logger tracelog.Logger,
 		Logger:   logger,
 		LogLevel: logLevel,
 	}
+	if tracer != nil {
+		conf.ConnConfig.Tracer = multitracer.New(conf.ConnConfig.Tracer, tracer)
+	}
 
 	conf.MaxConns = 20                      // Set the maximum number of connections
 	conf.MinConns = 5                       // Set the minimum number of idle connections
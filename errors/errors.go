@@ -1,6 +1,13 @@
+// Package errors is the error model of the services: an Error has an identifier, the HTTP
+// status of the responses it fails, a detail for the client and, optionally, the invalid
+// fields of a request, a cause and the stack where it was created. The identifiers can be
+// registered with their message and gRPC code, see Register, and the errors are converted to
+// and from gRPC statuses and written as HTTP responses.
 package errors
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"google.golang.org/grpc/status"
@@ -17,11 +24,93 @@ type Error struct {
 	Source string `json:"source"`
 	Detail string `json:"detail"`
 	Status string `json:"status"`
+	// Fields are the invalid fields of a request, see Validation
+	Fields []FieldError `json:"fields,omitempty"`
+	// Meta is additional information for the client
+	Meta map[string]string `json:"meta,omitempty"`
+
+	cause error
+	stack stack
 }
 
+// Error returns the identifier, the detail and the cause of the error.
 func (e *Error) Error() string {
-	b, _ := json.Marshal(e)
-	return string(b)
+	var b strings.Builder
+	if e.Id != "" {
+		b.WriteString(e.Id)
+		b.WriteString(": ")
+	}
+	if e.Detail != "" {
+		b.WriteString(e.Detail)
+	} else {
+		b.WriteString(e.Status)
+	}
+	if e.cause != nil {
+		b.WriteString(": ")
+		b.WriteString(e.cause.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether the error has the identifier of target, an *Error or a *Definition, and
+// its code when target has one, so that errors.Is matches the errors of a Definition or a
+// sentinel *Error.
+func (e *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case *Definition:
+		return e.Id == t.Id
+	case *Error:
+		return t.Id != "" && e.Id == t.Id && (t.Code == 0 || e.Code == t.Code)
+	}
+	return false
+}
+
+// Is reports whether any error in the chain of err matches target, see the standard errors.Is.
+func Is(err, target error) bool { return stderrors.Is(err, target) }
+
+// As finds the first error in the chain of err matching target, see the standard errors.As.
+func As(err error, target interface{}) bool { return stderrors.As(err, target) }
+
+// Unwrap returns the error wrapped by err, see the standard errors.Unwrap.
+func Unwrap(err error) error { return stderrors.Unwrap(err) }
+
+// Join returns an error wrapping errs, see the standard errors.Join.
+func Join(errs ...error) error { return stderrors.Join(errs...) }
+
+// WithCause returns a copy of the error caused by err, capturing the stack.
+func (e *Error) WithCause(err error) *Error {
+	c := e.clone()
+	c.cause = err
+	c.stack = callers(2)
+	return c
+}
+
+// WithSource returns a copy of the error with a source.
+func (e *Error) WithSource(source string) *Error {
+	c := e.clone()
+	c.Source = source
+	return c
+}
+
+// WithMeta returns a copy of the error with metadata.
+func (e *Error) WithMeta(key, value string) *Error {
+	c := e.clone()
+	c.Meta = make(map[string]string, len(e.Meta)+1)
+	for k, v := range e.Meta {
+		c.Meta[k] = v
+	}
+	c.Meta[key] = value
+	return c
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	return &c
 }
 
 // New generates a custom error.
@@ -35,19 +124,52 @@ func New(id, source, detail string, code int32) error {
 	}
 }
 
-// FromError try to convert go error to *Error
+// Wrap returns an error caused by err with the identifier id, its code and message being
+// those of the registered Definition, otherwise 500 and the detail fmt.Sprint(a...) or
+// "internal error": the message of err is kept in the cause, not sent to the clients. It
+// returns nil for a nil err.
+func Wrap(err error, id string, a ...interface{}) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if d, ok := Lookup(id); ok {
+		e = d.newError(a...)
+	} else {
+		detail := fmt.Sprint(a...)
+		if detail == "" {
+			detail = "internal error"
+		}
+		e = &Error{Id: id, Code: http.StatusInternalServerError, Detail: detail, Status: http.StatusText(http.StatusInternalServerError)}
+	}
+	e.cause = err
+	e.stack = callers(2)
+	return e
+}
+
+// FromError try to convert go error to *Error: an *Error in the chain of err, the error of a
+// gRPC status, otherwise an error of the detail of err.
 func FromError(err error) *Error {
 	if err == nil {
 		return nil
 	}
-	if verr, ok := err.(*Error); ok && verr != nil {
+	var verr *Error
+	if stderrors.As(err, &verr) && verr != nil {
 		return verr
 	}
 	if serr, ok := status.FromError(err); ok {
-		return Parse(serr.Message())
+		return FromStatus(serr)
+	}
+	switch {
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return &Error{Code: http.StatusGatewayTimeout, Detail: err.Error(), Status: http.StatusText(http.StatusGatewayTimeout), cause: err}
+	case stderrors.Is(err, context.Canceled):
+		return &Error{Code: StatusClientClosedRequest, Detail: err.Error(), Status: "Client Closed Request", cause: err}
 	}
 
-	return Parse(err.Error())
+	e := Parse(err.Error())
+	e.cause = err
+	return e
 }
 
 // Parse tries to parse a JSON string into an error. If that
@@ -203,6 +325,9 @@ func Equal(err1 error, err2 error) bool {
 
 // IsNetworkError tries to detect if error is a network error.
 func IsNetworkError(err error) bool {
+	if stderrors.Is(err, context.DeadlineExceeded) || stderrors.Is(err, context.Canceled) {
+		return true
+	}
 	s := err.Error()
 	parsed := FromError(err)
 	return strings.Contains(s, "context deadline exceeded") ||
@@ -219,13 +344,15 @@ func IsContextCanceled(err error) bool {
 	if err == nil {
 		return false
 	}
-	return strings.Contains(err.Error(), "context canceled")
+	return stderrors.Is(err, context.Canceled) || strings.Contains(err.Error(), "context canceled")
 }
 
 // ErrorCode for app
 type ErrorCode int
 
 // ErrorDetail for app
+//
+// Deprecated: See Definition.
 type ErrorDetail struct {
 	ID     string
 	Detail string
@@ -249,28 +376,26 @@ const (
 	PSE
 )
 
-var appErrors = map[ErrorCode]ErrorDetail{
-	EC1: {"EC1", "not good", 500},
-	EC2: {"EC2", "not valid", 500},
-	EC3: {"EC3", "not valid", 500},
-	EC4: {"EC4", "not valid", 500},
-	SME: {"SME", "unable to send email: %v", 500},
-	DBE: {"DBE", "database error: %v", 500},
-	PSE: {"PSE", "broker publish error: %v", 500},
+// appErrors are not registered, so that the services can register these identifiers
+var appErrors = map[ErrorCode]*Definition{
+	EC1: {Id: "EC1", Message: "not good", HTTPStatus: 500},
+	EC2: {Id: "EC2", Message: "not valid", HTTPStatus: 500},
+	EC3: {Id: "EC3", Message: "not valid", HTTPStatus: 500},
+	EC4: {Id: "EC4", Message: "not valid", HTTPStatus: 500},
+	SME: {Id: "SME", Message: "unable to send email: %v", HTTPStatus: 500},
+	DBE: {Id: "DBE", Message: "database error: %v", HTTPStatus: 500},
+	PSE: {Id: "PSE", Message: "broker publish error: %v", HTTPStatus: 500},
 }
 
-// TODO: Should I use https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
-// https://github.com/avinassh/grpc-errors/blob/master/go/server.go
-// http://avi.im/grpc-errors/
-
 // AppError - App specific Error
+//
+// Deprecated: Register a Definition and use its New or Wrap methods.
 func AppError(errorCode ErrorCode, a ...interface{}) error {
-	return &Error{
-		Id:     appErrors[errorCode].ID,
-		Code:   appErrors[errorCode].Code,
-		Detail: fmt.Sprintf(appErrors[errorCode].Detail, a...),
-		Status: http.StatusText(500),
+	d, ok := appErrors[errorCode]
+	if !ok {
+		return &Error{Status: http.StatusText(500)}
 	}
+	return d.newError(a...)
 }
 
 // ValidationError - Unprocessable Entity
@@ -285,6 +410,8 @@ func ValidationError(id, source, format string, a ...interface{}) error {
 }
 
 // SrvError defines the string type relating to all the global errors
+//
+// Deprecated: Its methods return messages, not errors: register Definitions instead.
 type SrvError string
 
 const (
@@ -0,0 +1,131 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errSubscriptionExists = Register(Definition{Id: "test.subscription_exists", Message: "msisdn %s already subscribed", HTTPStatus: http.StatusConflict})
+
+func TestWrapping(t *testing.T) {
+	cause := io.ErrUnexpectedEOF
+	err := fmt.Errorf("subscribing: %w", errSubscriptionExists.Wrap(cause, "233200000000"))
+
+	assert.True(t, Is(err, errSubscriptionExists))
+	assert.True(t, Is(err, io.ErrUnexpectedEOF), "cause in the chain")
+	assert.True(t, Is(err, &Error{Id: "test.subscription_exists", Code: 409}))
+	assert.False(t, Is(err, &Error{Id: "test.subscription_exists", Code: 500}))
+	assert.False(t, Is(NotFound("x", "y"), errSubscriptionExists))
+	assert.Equal(t, "subscribing: test.subscription_exists: msisdn 233200000000 already subscribed: unexpected EOF", err.Error())
+
+	var e *Error
+	require.True(t, As(err, &e))
+	assert.EqualValues(t, 409, e.Code)
+	assert.Equal(t, "Conflict", e.Status)
+	assert.Equal(t, codes.AlreadyExists, e.GRPCCode())
+	require.NotEmpty(t, e.StackTrace())
+	assert.Contains(t, e.StackTrace()[0].Function, "TestWrapping")
+	assert.Contains(t, fmt.Sprintf("%+v", e), "errors_test.go")
+	assert.Same(t, e, FromError(err))
+
+	assert.Nil(t, Wrap(nil, "x"))
+	w := Wrap(cause, "test.unregistered")
+	assert.EqualValues(t, 500, w.Code)
+	assert.Equal(t, "test.unregistered: internal error: unexpected EOF", w.Error())
+	assert.Contains(t, w.StackTrace()[0].Function, "TestWrapping")
+
+	assert.Panics(t, func() { Register(Definition{Id: "test.subscription_exists"}) })
+	_, ok := Lookup("DBE")
+	assert.False(t, ok, "legacy codes left to the services")
+	assert.NotPanics(t, func() { Register(Definition{Id: "DBE"}) })
+	assert.Equal(t, "DBE: database error: timeout", AppError(DBE, "timeout").Error())
+	assert.Equal(t, codes.Internal, FromError(AppError(DBE, "timeout")).GRPCCode())
+	assert.Equal(t, &Error{Status: "Internal Server Error"}, AppError(ErrorCode(100)))
+}
+
+func TestLegacy(t *testing.T) {
+	legacy := `{"id":"users.get","code":404,"detail":"user 42 not found","status":"Not Found"}`
+	e := FromError(fmt.Errorf("%s", legacy))
+	assert.Equal(t, "users.get", e.Id)
+	assert.EqualValues(t, 404, e.Code)
+
+	e = FromError(status.Error(codes.NotFound, legacy))
+	assert.Equal(t, "users.get", e.Id)
+
+	e = FromError(context.DeadlineExceeded)
+	assert.EqualValues(t, http.StatusGatewayTimeout, e.Code)
+	assert.True(t, IsNetworkError(fmt.Errorf("calling: %w", context.Canceled)))
+	assert.True(t, IsContextCanceled(fmt.Errorf("calling: %w", context.Canceled)))
+}
+
+func TestStatus(t *testing.T) {
+	v := NewValidation("users.create")
+	v.Check(true, "name", "must not be empty")
+	assert.True(t, v.Valid())
+	assert.NoError(t, v.Err())
+	v.Check(false, "msisdn", "must have 12 digits, not %d", 9)
+	v.Add("email", "invalid")
+	err := v.Err()
+	e := FromError(err)
+	assert.EqualValues(t, 422, e.Code)
+	assert.Equal(t, "users.create: invalid msisdn, email", err.Error())
+	e = e.WithSource("api").WithMeta("request_id", "r-1")
+
+	st, ok := status.FromError(e)
+	require.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "invalid msisdn, email", st.Message())
+
+	got := FromError(st.Err())
+	assert.Equal(t, "users.create", got.Id)
+	assert.EqualValues(t, 422, got.Code)
+	assert.Equal(t, "api", got.Source)
+	assert.Equal(t, map[string]string{"request_id": "r-1"}, got.Meta)
+	assert.Equal(t, []FieldError{{"msisdn", "must have 12 digits, not 9"}, {"email", "invalid"}}, got.Fields)
+
+	got = FromError(status.Error(codes.Unavailable, "down"))
+	assert.EqualValues(t, 503, got.Code)
+	assert.Equal(t, "down", got.Detail)
+
+	assert.Equal(t, codes.Internal, ToStatus(io.EOF).Code())
+	assert.Equal(t, "Internal Server Error", ToStatus(io.EOF).Message())
+	assert.Equal(t, codes.DeadlineExceeded, ToStatus(fmt.Errorf("x: %w", context.DeadlineExceeded)).Code())
+	assert.Equal(t, codes.NotFound, ToStatus(NotFound("users.get", "no")).Code())
+	assert.Nil(t, ToStatus(nil))
+}
+
+func TestWriters(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteHTTP(rec, fmt.Errorf("handler: %w", NotFound("users.get", "user %d not found", 42)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"users.get","code":404,"source":"","detail":"user 42 not found","status":"Not Found"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	WriteHTTP(rec, fmt.Errorf("dial tcp 10.0.0.1:5432: connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "10.0.0.1")
+
+	rec = httptest.NewRecorder()
+	WriteHTTP(rec, Wrap(fmt.Errorf(`pq: relation "users" does not exist`), "test.load_user"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"id":"test.load_user","code":500,"source":"","detail":"internal error","status":"Internal Server Error"}`, rec.Body.String())
+
+	var ctx fasthttp.RequestCtx
+	WriteFastHTTP(&ctx, NewValidation("users.create").Add("name", "required").Err())
+	assert.Equal(t, http.StatusUnprocessableEntity, ctx.Response.StatusCode())
+	var e Error
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &e))
+	assert.Equal(t, []FieldError{{"name", "required"}}, e.Fields)
+}
@@ -0,0 +1,124 @@
+package errors
+
+import (
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorInfoDomain is the domain of the ErrorInfo details of the statuses
+const errorInfoDomain = "go-buildingblocks"
+
+// GRPCCode returns the gRPC code of the error: the code of its Definition, otherwise the code
+// of its HTTP status.
+func (e *Error) GRPCCode() codes.Code {
+	if d, ok := Lookup(e.Id); ok && d.HTTPStatus == int(e.Code) {
+		return d.GRPCCode
+	}
+	if e.Code == 0 {
+		return codes.Unknown
+	}
+	return GRPCCodeFromHTTP(int(e.Code))
+}
+
+// GRPCStatus returns the gRPC status of the error, its detail being the message, with an
+// ErrorInfo of its identifier, source, HTTP status and metadata and, for the invalid fields,
+// a BadRequest. It is used by status.FromError and status.Convert, so that the handlers of a
+// gRPC server can return an *Error.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.GRPCCode(), e.Detail)
+	info := &errdetails.ErrorInfo{Reason: e.Id, Domain: errorInfoDomain, Metadata: map[string]string{}}
+	for k, v := range e.Meta {
+		info.Metadata[k] = v
+	}
+	if e.Source != "" {
+		info.Metadata["source"] = e.Source
+	}
+	if e.Code != 0 {
+		info.Metadata["http_status"] = strconv.Itoa(int(e.Code))
+	}
+	details := []protoadapt.MessageV1{info}
+	if len(e.Fields) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, f := range e.Fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+		}
+		details = append(details, br)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+// FromStatus returns the error of a gRPC status, reading the details set by GRPCStatus. The
+// statuses of the servers sending the JSON encoding of an Error as message are decoded.
+func FromStatus(st *status.Status) *Error {
+	e := &Error{Detail: st.Message()}
+	var info *errdetails.ErrorInfo
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if d.Domain == errorInfoDomain {
+				info = d
+			}
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				e.Fields = append(e.Fields, FieldError{Field: v.Field, Message: v.Description})
+			}
+		}
+	}
+	if info == nil {
+		if parsed := Parse(st.Message()); parsed.Id != "" || parsed.Code != 0 {
+			// Message of a legacy server
+			return parsed
+		}
+		e.Code = int32(HTTPStatusFromGRPC(st.Code()))
+		e.Status = http.StatusText(int(e.Code))
+		return e
+	}
+
+	e.Id = info.Reason
+	for k, v := range info.Metadata {
+		switch k {
+		case "source":
+			e.Source = v
+		case "http_status":
+			code, _ := strconv.Atoi(v)
+			e.Code = int32(code)
+		default:
+			if e.Meta == nil {
+				e.Meta = map[string]string{}
+			}
+			e.Meta[k] = v
+		}
+	}
+	if e.Code == 0 {
+		e.Code = int32(HTTPStatusFromGRPC(st.Code()))
+	}
+	e.Status = http.StatusText(int(e.Code))
+	return e
+}
+
+// ToStatus returns the gRPC status of err: the status of its *Error, of a gRPC error or of
+// a context error, otherwise an Internal status hiding the message of err.
+func ToStatus(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if As(err, &e) {
+		return e.GRPCStatus()
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	if st := status.FromContextError(err); st.Code() != codes.Unknown {
+		return st
+	}
+	return status.New(codes.Internal, http.StatusText(http.StatusInternalServerError))
+}
@@ -0,0 +1,51 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Response returns the error sent to the client for err, with its status: the *Error in the
+// chain of err, that of a gRPC status or of a context error, otherwise an internal error not
+// revealing the message of err.
+func Response(err error) (*Error, int) {
+	var e *Error
+	if !As(err, &e) {
+		e = FromError(err)
+		if e.Id == "" && e.Code == 0 {
+			// An unexpected error, its message is not for the client
+			e = &Error{Id: "internal", Detail: "internal error", Code: http.StatusInternalServerError}
+		}
+	}
+	code := int(e.Code)
+	if code < 400 || code > 599 {
+		code = http.StatusInternalServerError
+	}
+	if e.Status == "" {
+		e = e.clone()
+		e.Status = http.StatusText(code)
+	}
+	return e, code
+}
+
+// WriteHTTP writes err as a JSON response, see Response.
+func WriteHTTP(w http.ResponseWriter, err error) {
+	e, code := Response(err)
+	body, _ := json.Marshal(e)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+// WriteFastHTTP writes err as a JSON response of a fasthttp handler, see Response.
+func WriteFastHTTP(ctx *fasthttp.RequestCtx, err error) {
+	e, code := Response(err)
+	body, _ := json.Marshal(e)
+	ctx.Response.Header.Set("X-Content-Type-Options", "nosniff")
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(code)
+	ctx.SetBody(body)
+}
@@ -0,0 +1,175 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+)
+
+// StatusClientClosedRequest is the status of the requests canceled by the client.
+const StatusClientClosedRequest = 499
+
+// Definition is a registered error: its identifier, the format of its detail, its HTTP status
+// and gRPC code. It is matched by errors.Is with the errors it creates:
+//
+//	var ErrSubscriptionExists = errors.Register(errors.Definition{
+//		Id: "subscriptions.exists", Message: "msisdn %s already subscribed", HTTPStatus: http.StatusConflict,
+//	})
+//	...
+//	return ErrSubscriptionExists.New(msisdn)
+//	...
+//	if errors.Is(err, ErrSubscriptionExists) {
+type Definition struct {
+	Id string
+	// Message is the format of the detail of the errors
+	Message    string
+	HTTPStatus int
+	// GRPCCode default the code of HTTPStatus, see GRPCCodeFromHTTP
+	GRPCCode codes.Code
+}
+
+// Error returns the identifier, so that a Definition can be the target of errors.Is.
+func (d *Definition) Error() string {
+	return d.Id
+}
+
+// New returns an error of the definition, its detail formatted with a, capturing the stack.
+func (d *Definition) New(a ...interface{}) *Error {
+	e := d.newError(a...)
+	e.stack = callers(2)
+	return e
+}
+
+// Wrap returns an error of the definition caused by err, nil for a nil err.
+func (d *Definition) Wrap(err error, a ...interface{}) *Error {
+	if err == nil {
+		return nil
+	}
+	e := d.newError(a...)
+	e.cause = err
+	e.stack = callers(2)
+	return e
+}
+
+func (d *Definition) newError(a ...interface{}) *Error {
+	detail := d.Message
+	if len(a) > 0 {
+		detail = fmt.Sprintf(d.Message, a...)
+	}
+	return &Error{Id: d.Id, Code: int32(d.HTTPStatus), Detail: detail, Status: http.StatusText(d.HTTPStatus)}
+}
+
+var registry = struct {
+	sync.RWMutex
+	definitions map[string]*Definition
+}{definitions: map[string]*Definition{}}
+
+// Register adds a definition to the catalogue, usually in a package variable. It panics if
+// its identifier is already registered.
+func Register(d Definition) *Definition {
+	if d.Id == "" {
+		panic("errors: definition without identifier")
+	}
+	if d.HTTPStatus == 0 {
+		d.HTTPStatus = http.StatusInternalServerError
+	}
+	if d.GRPCCode == codes.OK {
+		d.GRPCCode = GRPCCodeFromHTTP(d.HTTPStatus)
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.definitions[d.Id]; ok {
+		panic(fmt.Sprintf("errors: %s already registered", d.Id))
+	}
+	registry.definitions[d.Id] = &d
+	return &d
+}
+
+// Lookup returns the definition of an identifier.
+func Lookup(id string) (*Definition, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	d, ok := registry.definitions[id]
+	return d, ok
+}
+
+// Definitions returns the registered definitions, e.g. to document the errors of an API.
+func Definitions() []*Definition {
+	registry.RLock()
+	defer registry.RUnlock()
+	defs := make([]*Definition, 0, len(registry.definitions))
+	for _, d := range registry.definitions {
+		defs = append(defs, d)
+	}
+	return defs
+}
+
+// GRPCCodeFromHTTP returns the gRPC code of an HTTP status.
+func GRPCCodeFromHTTP(status int) codes.Code {
+	switch status {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case StatusClientClosedRequest:
+		return codes.Canceled
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	switch {
+	case status >= 500:
+		return codes.Internal
+	case status >= 400:
+		return codes.FailedPrecondition
+	}
+	return codes.Unknown
+}
+
+// HTTPStatusFromGRPC returns the HTTP status of a gRPC code.
+func HTTPStatusFromGRPC(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return StatusClientClosedRequest
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,53 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// stack is the program counters of the callers where an error was created
+type stack []uintptr
+
+// callers returns the stack of the caller skip frames above
+func callers(skip int) stack {
+	var pcs [32]uintptr
+	n := runtime.Callers(skip+1, pcs[:])
+	return pcs[:n]
+}
+
+// StackTrace returns the frames where the error was created or wrapped, nil when it was
+// created by a constructor not capturing it, e.g. NotFound.
+func (e *Error) StackTrace() []runtime.Frame {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	var st []runtime.Frame
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		f, more := frames.Next()
+		st = append(st, f)
+		if !more {
+			return st
+		}
+	}
+}
+
+// Format implements fmt.Formatter: %+v prints the error with its stack and the chain of its
+// causes.
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		_, _ = io.WriteString(s, e.Error())
+		for _, f := range e.StackTrace() {
+			_, _ = fmt.Fprintf(s, "\n\t%s\n\t\t%s:%d", f.Function, f.File, f.Line)
+		}
+		if e.cause != nil {
+			_, _ = fmt.Fprintf(s, "\ncaused by: %+v", e.cause)
+		}
+	case verb == 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	default:
+		_, _ = io.WriteString(s, e.Error())
+	}
+}
@@ -0,0 +1,70 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FieldError is an invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validation aggregates the invalid fields of a request into a 422 error:
+//
+//	v := errors.NewValidation("users.create")
+//	v.Check(req.Name != "", "name", "must not be empty")
+//	v.Check(len(req.Msisdn) == 12, "msisdn", "must have 12 digits, not %d", len(req.Msisdn))
+//	if err := v.Err(); err != nil {
+//		return err
+//	}
+type Validation struct {
+	id     string
+	fields []FieldError
+}
+
+// NewValidation returns a Validation of the errors of identifier id.
+func NewValidation(id string) *Validation {
+	return &Validation{id: id}
+}
+
+// Add adds an invalid field.
+func (v *Validation) Add(field, format string, a ...interface{}) *Validation {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, a...)})
+	return v
+}
+
+// Check adds an invalid field when ok is false, returning ok.
+func (v *Validation) Check(ok bool, field, format string, a ...interface{}) bool {
+	if !ok {
+		v.Add(field, format, a...)
+	}
+	return ok
+}
+
+// Valid reports whether no field is invalid.
+func (v *Validation) Valid() bool {
+	return len(v.fields) == 0
+}
+
+// Err returns nil when no field is invalid, otherwise an *Error of status 422 with the
+// invalid fields.
+func (v *Validation) Err() error {
+	if v.Valid() {
+		return nil
+	}
+	names := make([]string, len(v.fields))
+	for i, f := range v.fields {
+		names[i] = f.Field
+	}
+	return &Error{
+		Id:     v.id,
+		Code:   http.StatusUnprocessableEntity,
+		Detail: "invalid " + strings.Join(names, ", "),
+		Status: http.StatusText(http.StatusUnprocessableEntity),
+		Fields: append([]FieldError(nil), v.fields...),
+		stack:  callers(2),
+	}
+}
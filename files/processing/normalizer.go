@@ -0,0 +1,305 @@
+package processingutils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+	"golang.org/x/text/transform"
+)
+
+// NormalizerOptions holds the options of a Normalizer.
+type NormalizerOptions struct {
+	// Encoding forces the charset of the source, e.g. "windows-1252" or "utf-16le" (default: detected)
+	Encoding string
+	// Fallback is the charset of the sources that are neither UTF-8 nor UTF-16 (default "windows-1252")
+	Fallback string
+	// SniffSize is the size of the prefix read to detect the charset and the line terminator (default 64 KiB)
+	SniffSize int
+	// LineTerminator replaces the line terminators (default LF)
+	LineTerminator LineTerminatorType
+	// KeepLineTerminators disables the normalization of the line terminators
+	KeepLineTerminators bool
+	// StripControl removes the control characters, except tabs and line terminators
+	StripControl bool
+	// DropInvalid removes the invalid UTF-8 sequences instead of replacing them with U+FFFD
+	DropInvalid bool
+}
+
+func (o *NormalizerOptions) setDefaults() {
+	if o.Fallback == "" {
+		o.Fallback = "windows-1252"
+	}
+	if o.SniffSize <= 0 {
+		o.SniffSize = 64 * 1024
+	}
+	if o.LineTerminator == "" {
+		o.LineTerminator = LF
+	}
+}
+
+// Report describes what a Normalizer detected and changed. The counters are complete once the
+// Normalizer has been read until io.EOF.
+type Report struct {
+	Encoding       string             // Charset of the source, detected or forced
+	BOM            bool               // A byte order mark was removed
+	LineTerminator LineTerminatorType // Main line terminator of the prefix, ND when none
+	Lines          int64              // Line terminators written
+	ControlChars   int64              // Control characters removed
+	InvalidBytes   int64              // Invalid UTF-8 bytes replaced or removed
+}
+
+// Normalizer is an io.Reader transcoding its source to UTF-8 on the fly, with normalized line
+// terminators and optionally without control characters. Only a bounded prefix is buffered
+// for the detection, so it can clean files of any size before they are parsed:
+//
+//	n, err := processingutils.NewNormalizer(file, processingutils.NormalizerOptions{StripControl: true})
+//	...
+//	dec := csvutils.NewDecoder[Row](n, csvutils.DecoderConfig{})
+type Normalizer struct {
+	r       io.Reader
+	cleaner *cleaner
+	report  Report
+}
+
+// NewNormalizer reads the prefix of r to detect its charset, byte order mark and line terminator.
+func NewNormalizer(r io.Reader, opts NormalizerOptions) (*Normalizer, error) {
+	opts.setDefaults()
+	br := bufio.NewReaderSize(r, opts.SniffSize)
+	prefix, err := br.Peek(opts.SniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	atEOF := err == io.EOF
+
+	name, bomLen := detectEncoding(prefix, atEOF, opts.Fallback)
+	if opts.Encoding != "" {
+		forced := strings.ToLower(opts.Encoding)
+		if forced != name {
+			bomLen = 0
+		}
+		name = forced
+	}
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = br.Discard(bomLen); err != nil {
+		return nil, err
+	}
+
+	n := &Normalizer{report: Report{Encoding: name, BOM: bomLen > 0}}
+	// The terminator is detected on the decoded prefix, for the UTF-16 and UTF-32 sources
+	decoded := prefix[bomLen:]
+	if enc != nil {
+		decoded, _, _ = transform.Bytes(enc.NewDecoder(), decoded)
+	}
+	n.report.LineTerminator, _ = DetectLineTerminator(bytes.NewReader(decoded))
+
+	n.cleaner = &cleaner{
+		normalize:   !opts.KeepLineTerminators,
+		newline:     []byte(opts.LineTerminator),
+		lfcr:        n.report.LineTerminator == LFCR,
+		rs:          n.report.LineTerminator == RS,
+		strip:       opts.StripControl,
+		dropInvalid: opts.DropInvalid,
+	}
+	var t transform.Transformer = n.cleaner
+	if enc != nil {
+		t = transform.Chain(enc.NewDecoder(), n.cleaner)
+	}
+	n.r = transform.NewReader(br, t)
+	return n, nil
+}
+
+func (n *Normalizer) Read(p []byte) (int, error) {
+	return n.r.Read(p)
+}
+
+// Report returns what was detected and the changes made so far.
+func (n *Normalizer) Report() Report {
+	report := n.report
+	report.Lines = n.cleaner.lines
+	report.ControlChars = n.cleaner.controls
+	report.InvalidBytes = n.cleaner.invalid
+	return report
+}
+
+// detectEncoding returns the charset of a prefix and the length of its byte order mark
+func detectEncoding(prefix []byte, atEOF bool, fallback string) (string, int) {
+	switch {
+	case bytes.HasPrefix(prefix, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8", 3
+	case bytes.HasPrefix(prefix, []byte{0xff, 0xfe, 0x00, 0x00}):
+		return "utf-32le", 4
+	case bytes.HasPrefix(prefix, []byte{0x00, 0x00, 0xfe, 0xff}):
+		return "utf-32be", 4
+	case bytes.HasPrefix(prefix, []byte{0xff, 0xfe}):
+		return "utf-16le", 2
+	case bytes.HasPrefix(prefix, []byte{0xfe, 0xff}):
+		return "utf-16be", 2
+	}
+
+	// Text in UTF-16 without byte order mark: mostly ASCII characters, with a zero byte each
+	if len(prefix) >= 4 {
+		var even, odd int
+		for i, b := range prefix {
+			if b == 0 {
+				if i%2 == 0 {
+					even++
+				} else {
+					odd++
+				}
+			}
+		}
+		half := len(prefix) / 2
+		switch {
+		case odd > half*3/10 && even <= half/20:
+			return "utf-16le", 0
+		case even > half*3/10 && odd <= half/20:
+			return "utf-16be", 0
+		}
+	}
+
+	valid := prefix
+	if !atEOF {
+		// The prefix may end in the middle of a character
+		for i := 1; i < utf8.UTFMax && i <= len(valid); i++ {
+			if utf8.RuneStart(valid[len(valid)-i]) {
+				if !utf8.FullRune(valid[len(valid)-i:]) {
+					valid = valid[:len(valid)-i]
+				}
+				break
+			}
+		}
+	}
+	if utf8.Valid(valid) {
+		return "utf-8", 0
+	}
+	return strings.ToLower(fallback), 0
+}
+
+// lookupEncoding returns the encoding of a charset, nil for UTF-8
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	switch name {
+	case "utf-8", "utf8":
+		return nil, nil
+	case "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+	case "utf-32le":
+		return utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM), nil
+	case "utf-32be":
+		return utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("processingutils: unknown encoding %q: %w", name, err)
+	}
+	return enc, nil
+}
+
+// cleaner is the transformer of the UTF-8 text: line terminators, control characters and
+// invalid sequences
+type cleaner struct {
+	normalize   bool
+	newline     []byte
+	lfcr        bool // "\n\r" is a single terminator
+	rs          bool // The record separator is a terminator
+	strip       bool
+	dropInvalid bool
+
+	lines    int64
+	controls int64
+	invalid  int64
+}
+
+func (c *cleaner) Reset() {}
+
+func (c *cleaner) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		b := src[nSrc]
+		switch {
+		case c.normalize && (b == '\r' || b == '\n' || (c.rs && b == 0x1e)):
+			size := 1
+			if b != 0x1e {
+				if nSrc+1 == len(src) && !atEOF {
+					// The terminator may continue in the next read
+					return nDst, nSrc, transform.ErrShortSrc
+				}
+				if nSrc+1 < len(src) {
+					next := src[nSrc+1]
+					if (b == '\r' && next == '\n') || (c.lfcr && b == '\n' && next == '\r') {
+						size = 2
+					}
+				}
+			}
+			if nDst+len(c.newline) > len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			nDst += copy(dst[nDst:], c.newline)
+			nSrc += size
+			c.lines++
+
+		case b < utf8.RuneSelf:
+			if c.strip && isControl(rune(b)) {
+				nSrc++
+				c.controls++
+				continue
+			}
+			if nDst >= len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = b
+			nDst++
+			nSrc++
+
+		default:
+			if !atEOF && !utf8.FullRune(src[nSrc:]) {
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+			r, size := utf8.DecodeRune(src[nSrc:])
+			if r == utf8.RuneError && size == 1 {
+				c.invalid++
+				if c.dropInvalid {
+					nSrc++
+					continue
+				}
+				if nDst+3 > len(dst) {
+					return nDst, nSrc, transform.ErrShortDst
+				}
+				nDst += utf8.EncodeRune(dst[nDst:], utf8.RuneError)
+				nSrc++
+				continue
+			}
+			if c.strip && isControl(r) {
+				nSrc += size
+				c.controls++
+				continue
+			}
+			if nDst+size > len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			nDst += copy(dst[nDst:], src[nSrc:nSrc+size])
+			nSrc += size
+		}
+	}
+	return nDst, nSrc, nil
+}
+
+// isControl reports the C0 and C1 control characters and DEL, except tab and the line feeds
+func isControl(r rune) bool {
+	switch r {
+	case '\t', '\n', '\r':
+		return false
+	}
+	return r < 0x20 || r == 0x7f || (r >= 0x80 && r <= 0x9f)
+}
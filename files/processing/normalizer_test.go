@@ -0,0 +1,133 @@
+package processingutils
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func normalize(t *testing.T, r io.Reader, opts NormalizerOptions) (string, Report) {
+	t.Helper()
+	n, err := NewNormalizer(r, opts)
+	require.NoError(t, err)
+	out, err := io.ReadAll(n)
+	require.NoError(t, err)
+	return string(out), n.Report()
+}
+
+func TestDetectLineTerminatorSplitReads(t *testing.T) {
+	terminator, err := DetectLineTerminator(iotest.OneByteReader(strings.NewReader("a\r\nb\r\nc\r\nd\n")))
+	require.NoError(t, err)
+	assert.Equal(t, CRLF, terminator)
+
+	// A short read must not count the bytes of the previous one
+	terminator, err = DetectLineTerminator(iotest.HalfReader(strings.NewReader("1\n2\n3\n" + strings.Repeat("x", 10))))
+	require.NoError(t, err)
+	assert.Equal(t, LF, terminator)
+
+	terminator, err = DetectLineTerminator(strings.NewReader("no terminator"))
+	require.NoError(t, err)
+	assert.Equal(t, ND, terminator)
+}
+
+func TestNormalizerUTF8(t *testing.T) {
+	out, report := normalize(t, strings.NewReader("\ufeffid,name\r\n1,José\r\n2,Zoë\r\n"), NormalizerOptions{})
+	assert.Equal(t, "id,name\n1,José\n2,Zoë\n", out)
+	assert.Equal(t, Report{Encoding: "utf-8", BOM: true, LineTerminator: CRLF, Lines: 3}, report)
+}
+
+func TestNormalizerUTF16(t *testing.T) {
+	for _, enc := range []struct {
+		name     string
+		encoding string
+		bom      bool
+	}{
+		{"le with bom", "utf-16le", true},
+		{"be with bom", "utf-16be", true},
+		{"le without bom", "utf-16le", false},
+	} {
+		t.Run(enc.name, func(t *testing.T) {
+			endianness, bom := unicode.LittleEndian, unicode.IgnoreBOM
+			if enc.encoding == "utf-16be" {
+				endianness = unicode.BigEndian
+			}
+			if enc.bom {
+				bom = unicode.UseBOM
+			}
+			encoded, err := unicode.UTF16(endianness, bom).NewEncoder().String("id;name\r\n1;José\r\n")
+			require.NoError(t, err)
+			out, report := normalize(t, strings.NewReader(encoded), NormalizerOptions{})
+			assert.Equal(t, "id;name\n1;José\n", out)
+			assert.Equal(t, enc.encoding, report.Encoding)
+			assert.Equal(t, enc.bom, report.BOM)
+			assert.Equal(t, CRLF, report.LineTerminator)
+		})
+	}
+}
+
+func TestNormalizerFallback(t *testing.T) {
+	out, report := normalize(t, strings.NewReader("caf\xe9\r\n\x80 5\r\n"), NormalizerOptions{})
+	assert.Equal(t, "café\n€ 5\n", out)
+	assert.Equal(t, "windows-1252", report.Encoding)
+
+	out, report = normalize(t, strings.NewReader("caf\xe9\n"), NormalizerOptions{Fallback: "iso-8859-15"})
+	assert.Equal(t, "café\n", out)
+	assert.Equal(t, "iso-8859-15", report.Encoding)
+
+	out, _ = normalize(t, strings.NewReader("\xa4"), NormalizerOptions{Encoding: "ISO-8859-15"})
+	assert.Equal(t, "€", out)
+
+	_, err := NewNormalizer(strings.NewReader("x"), NormalizerOptions{Encoding: "nope"})
+	assert.Error(t, err)
+}
+
+func TestNormalizerLineTerminators(t *testing.T) {
+	// Terminators split between reads are still a single terminator
+	out, report := normalize(t, iotest.OneByteReader(strings.NewReader("a\r\nb\rc\nd")), NormalizerOptions{LineTerminator: CRLF})
+	assert.Equal(t, "a\r\nb\r\nc\r\nd", out)
+	assert.Equal(t, int64(3), report.Lines)
+
+	out, _ = normalize(t, strings.NewReader("a\n\rb\n\rc"), NormalizerOptions{})
+	assert.Equal(t, "a\nb\nc", out)
+
+	out, report = normalize(t, strings.NewReader("a\036b\036c"), NormalizerOptions{})
+	assert.Equal(t, "a\nb\nc", out)
+	assert.Equal(t, RS, report.LineTerminator)
+
+	out, _ = normalize(t, strings.NewReader("a\r\nb\n"), NormalizerOptions{KeepLineTerminators: true})
+	assert.Equal(t, "a\r\nb\n", out)
+}
+
+func TestNormalizerControlAndInvalid(t *testing.T) {
+	out, report := normalize(t, strings.NewReader("a\x00b\x07c\u0085d\te\x7f\n"), NormalizerOptions{StripControl: true})
+	assert.Equal(t, "abcd\te\n", out)
+	assert.Equal(t, int64(4), report.ControlChars)
+
+	// Invalid bytes after the sniffed prefix, detected as UTF-8
+	input := strings.Repeat("é", 8) + "\xff" + "end"
+	out, report = normalize(t, strings.NewReader(input), NormalizerOptions{SniffSize: 16})
+	assert.Equal(t, strings.Repeat("é", 8)+"�end", out)
+	assert.Equal(t, "utf-8", report.Encoding)
+	assert.Equal(t, int64(1), report.InvalidBytes)
+
+	out, _ = normalize(t, strings.NewReader(input), NormalizerOptions{SniffSize: 16, DropInvalid: true})
+	assert.Equal(t, strings.Repeat("é", 8)+"end", out)
+}
+
+func TestNormalizerLargeInput(t *testing.T) {
+	line := "0123456789,abcdefghij,é\r\n"
+	input := strings.Repeat(line, 100000)
+	n, err := NewNormalizer(strings.NewReader(input), NormalizerOptions{})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	_, err = io.Copy(&out, n)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat(strings.TrimSuffix(line, "\r\n")+"\n", 100000), out.String())
+	assert.Equal(t, int64(100000), n.Report().Lines)
+}
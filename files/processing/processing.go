@@ -22,32 +22,42 @@ const (
 	ND   LineTerminatorType = `unable to detect line terminator`
 )
 
+// DetectLineTerminator returns the most frequent line terminator of the content of reader,
+// ND when there is none. The whole reader is consumed.
 func DetectLineTerminator(reader io.Reader) (LineTerminatorType, error) {
-	// Read 1mb of file
-	buff := make([]byte, 1024*1000)
+	// Read by chunks of 1mb, keeping the last byte of the previous chunk for the terminators
+	// split between two reads
+	buff := make([]byte, 1+1024*1000)
 	var counts = make(map[LineTerminatorType]int)
+	carry := 0
 	for {
-		if _, err := reader.Read(buff); err != nil {
-			if err != io.EOF {
-				return ND, err
-			} else {
-				break
-			}
+		n, err := reader.Read(buff[carry:])
+		if n > 0 {
+			chunk := buff[:carry+n]
+			counts[CRLF] += bytes.Count(chunk, []byte("\r\n"))
+			counts[LFCR] += bytes.Count(chunk, []byte("\n\r"))
+			// The carried byte has already been counted alone
+			counts[CR] += bytes.Count(chunk[carry:], []byte("\r"))
+			counts[LF] += bytes.Count(chunk[carry:], []byte("\n"))
+			counts[RS] += bytes.Count(chunk[carry:], []byte("\036"))
+			buff[0] = chunk[len(chunk)-1]
+			carry = 1
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ND, err
 		}
-		counts[CRLF] = bytes.Count(buff, []byte("\r\n"))
-		counts[LFCR] = bytes.Count(buff, []byte("\n\r"))
-		counts[CR] = bytes.Count(buff, []byte("\r"))
-		counts[LF] = bytes.Count(buff, []byte("\n"))
-		counts[RS] = bytes.Count(buff, []byte("\036"))
-
 	}
 
 	counts[CR] -= counts[CRLF] + counts[LFCR]
 	counts[LF] -= counts[CRLF] + counts[LFCR]
 	maxV := 0
 	var maxKey = ND
-	for k, v := range counts {
-		if v > maxV {
+	// Fixed order, for the ties to be deterministic
+	for _, k := range []LineTerminatorType{CRLF, LFCR, LF, CR, RS} {
+		if v := counts[k]; v > maxV {
 			maxV = v
 			maxKey = k
 		}
@@ -117,6 +127,8 @@ func DecodeNonUTF8Bytes(data []byte) []byte {
 	return data
 }
 
+// ToUTF8 converts data to UTF-8 with LF line terminators, trimmed. It works on the whole
+// content in memory: NewNormalizer streams the conversion of large files.
 func ToUTF8(data []byte) ([]byte, error) {
 	// Clean file if possible ...
 	if terminator, err := DetectLineTerminator(bytes.NewReader(data)); err == nil {
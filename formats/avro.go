@@ -0,0 +1,326 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+	csvutils "github.com/seidu626/go-buildingblocks/csv"
+	"github.com/seidu626/go-buildingblocks/sql/schema"
+)
+
+// AvroOptions holds the options of the Avro writers.
+type AvroOptions struct {
+	Name      string // Name of the record schema (default "record")
+	Namespace string
+	// Compression of the blocks: Snappy, Zstd, Gzip (deflate) or Uncompressed (default Snappy)
+	Compression Compression
+	// BlockRows is the number of records of a block (default 100)
+	BlockRows int
+}
+
+func (o *AvroOptions) setDefaults() {
+	if o.Name == "" {
+		o.Name = "record"
+	}
+	if o.Compression == "" {
+		o.Compression = Snappy
+	}
+	if o.BlockRows <= 0 {
+		o.BlockRows = 100
+	}
+}
+
+func (o AvroOptions) codec() (ocf.CodecName, error) {
+	switch o.Compression {
+	case Snappy:
+		return ocf.Snappy, nil
+	case Zstd:
+		return ocf.ZStandard, nil
+	case Gzip:
+		return ocf.Deflate, nil
+	case Uncompressed:
+		return ocf.Null, nil
+	}
+	return "", fmt.Errorf("formats: unknown compression %q", o.Compression)
+}
+
+// AvroSchema returns the Avro schema of the columns. Nullable columns are unions with null,
+// timestamps are in microseconds.
+func AvroSchema(name, namespace string, columns []schema.Column) (avro.Schema, error) {
+	fields := make([]map[string]any, len(columns))
+	for i, c := range columns {
+		var typ any
+		switch c.Type {
+		case schema.Boolean:
+			typ = "boolean"
+		case schema.Integer:
+			typ = "int"
+		case schema.BigInt:
+			typ = "long"
+		case schema.Decimal:
+			typ = map[string]any{"type": "bytes", "logicalType": "decimal", "precision": c.Precision, "scale": c.Scale}
+		case schema.Float:
+			typ = "double"
+		case schema.Date:
+			typ = map[string]any{"type": "int", "logicalType": "date"}
+		case schema.Timestamp:
+			typ = map[string]any{"type": "long", "logicalType": "local-timestamp-micros"}
+		case schema.TimestampTZ:
+			typ = map[string]any{"type": "long", "logicalType": "timestamp-micros"}
+		default:
+			typ = "string"
+		}
+		field := map[string]any{"name": c.Name, "type": typ}
+		if c.Nullable || c.Type == schema.Null {
+			field["type"] = []any{"null", typ}
+			field["default"] = nil
+		}
+		fields[i] = field
+	}
+	record := map[string]any{"type": "record", "name": name, "fields": fields}
+	if namespace != "" {
+		record["namespace"] = namespace
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return avro.Parse(string(raw))
+}
+
+// AvroRecordWriter writes records to an Avro object container file.
+type AvroRecordWriter struct {
+	encoder *ocf.Encoder
+	columns []schema.Column
+	record  map[string]any
+	closed  bool
+}
+
+var _ RecordWriter = (*AvroRecordWriter)(nil)
+
+// NewAvroRecordWriter creates a writer of the columns to w. The header is written right away,
+// the records by blocks.
+func NewAvroRecordWriter(w io.Writer, columns []schema.Column, opts AvroOptions) (*AvroRecordWriter, error) {
+	opts.setDefaults()
+	codec, err := opts.codec()
+	if err != nil {
+		return nil, err
+	}
+	avroSchema, err := AvroSchema(opts.Name, opts.Namespace, columns)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := json.Marshal(columns)
+	if err != nil {
+		return nil, err
+	}
+	encoder, err := ocf.NewEncoderWithSchema(avroSchema, w,
+		ocf.WithCodec(codec),
+		ocf.WithBlockLength(opts.BlockRows),
+		ocf.WithMetadata(map[string][]byte{schemaMetadataKey: meta}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &AvroRecordWriter{encoder: encoder, columns: columns, record: make(map[string]any, len(columns))}, nil
+}
+
+func (a *AvroRecordWriter) Write(record []any) error {
+	if a.closed {
+		return ErrClosed
+	}
+	for i, c := range a.columns {
+		v := record[i]
+		if v == nil && !(c.Nullable || c.Type == schema.Null) {
+			return &csvutils.FieldError{Column: c.Name, Err: csvutils.ErrRequired}
+		}
+		switch t := v.(type) {
+		case int32:
+			// The int of Avro maps to Go int
+			v = int(t)
+		case time.Time:
+			if c.Type == schema.Date {
+				y, m, d := t.Date()
+				v = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+			}
+		}
+		a.record[c.Name] = v
+	}
+	return a.encoder.Encode(a.record)
+}
+
+// Close writes the pending block.
+func (a *AvroRecordWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	return a.encoder.Close()
+}
+
+// AvroRecordReader reads the records of an Avro object container file, streaming its blocks.
+type AvroRecordReader struct {
+	decoder *ocf.Decoder
+	columns []schema.Column
+	record  map[string]any
+}
+
+var _ RecordReader = (*AvroRecordReader)(nil)
+
+// NewAvroRecordReader reads the header of an Avro file. Only records of primitive and logical
+// types, or unions of null and such a type, are supported.
+func NewAvroRecordReader(r io.Reader) (*AvroRecordReader, error) {
+	decoder, err := ocf.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	var columns []schema.Column
+	if meta, ok := decoder.Metadata()[schemaMetadataKey]; ok {
+		if err = json.Unmarshal(meta, &columns); err != nil {
+			columns = nil
+		}
+	}
+	if columns == nil {
+		if columns, err = avroColumns(decoder.Schema()); err != nil {
+			return nil, err
+		}
+	}
+	return &AvroRecordReader{decoder: decoder, columns: columns}, nil
+}
+
+// avroColumns derives the columns of a record schema
+func avroColumns(s avro.Schema) ([]schema.Column, error) {
+	record, ok := s.(*avro.RecordSchema)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a record", ErrUnsupportedType, s.Type())
+	}
+	columns := make([]schema.Column, 0, len(record.Fields()))
+	for _, field := range record.Fields() {
+		c := schema.Column{Header: field.Name(), Name: field.Name()}
+		typ := field.Type()
+		if union, ok := typ.(*avro.UnionSchema); ok {
+			if !union.Nullable() || len(union.Types()) != 2 {
+				return nil, fmt.Errorf("%w: union column %q", ErrUnsupportedType, field.Name())
+			}
+			c.Nullable = true
+			for _, t := range union.Types() {
+				if t.Type() != avro.Null {
+					typ = t
+				}
+			}
+		}
+		primitive, ok := typ.(*avro.PrimitiveSchema)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s column %q", ErrUnsupportedType, typ.Type(), field.Name())
+		}
+		switch logical := primitive.Logical(); {
+		case logical == nil:
+			switch primitive.Type() {
+			case avro.Boolean:
+				c.Type = schema.Boolean
+			case avro.Int:
+				c.Type = schema.Integer
+			case avro.Long:
+				c.Type = schema.BigInt
+			case avro.Float, avro.Double:
+				c.Type = schema.Float
+			case avro.String:
+				c.Type = schema.String
+			default:
+				return nil, fmt.Errorf("%w: %s column %q", ErrUnsupportedType, primitive.Type(), field.Name())
+			}
+		case logical.Type() == avro.Decimal:
+			decimal := logical.(*avro.DecimalLogicalSchema)
+			c.Type, c.Precision, c.Scale = schema.Decimal, decimal.Precision(), decimal.Scale()
+		case logical.Type() == avro.Date:
+			c.Type = schema.Date
+		case logical.Type() == avro.TimestampMillis || logical.Type() == avro.TimestampMicros:
+			c.Type = schema.TimestampTZ
+		case logical.Type() == avro.LocalTimestampMillis || logical.Type() == avro.LocalTimestampMicros:
+			c.Type = schema.Timestamp
+		case logical.Type() == avro.UUID:
+			c.Type = schema.String
+		default:
+			return nil, fmt.Errorf("%w: %s column %q", ErrUnsupportedType, logical.Type(), field.Name())
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// Columns returns the columns of the file.
+func (a *AvroRecordReader) Columns() []schema.Column {
+	return append([]schema.Column(nil), a.columns...)
+}
+
+func (a *AvroRecordReader) Read(record []any) error {
+	if !a.decoder.HasNext() {
+		if err := a.decoder.Error(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	clear(a.record)
+	if err := a.decoder.Decode(&a.record); err != nil {
+		return err
+	}
+	for i, c := range a.columns {
+		record[i] = avroValue(a.record[c.Name])
+	}
+	return nil
+}
+
+// avroValue converts a decoded value to the typed values of the package
+func avroValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		// Union decoded with its branch name
+		for _, value := range v {
+			return avroValue(value)
+		}
+		return nil
+	case int:
+		return int32(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	case big.Rat:
+		return &v
+	case time.Time:
+		return v.UTC()
+	}
+	return v
+}
+
+// NewAvroWriter creates a writer of the structs T, with a field per csv tag.
+func NewAvroWriter[T any](w io.Writer, opts AvroOptions) (*Writer[T], error) {
+	mapping, err := csvutils.NewMapping[T](csvutils.MappingConfig{})
+	if err != nil {
+		return nil, err
+	}
+	columns := mapping.Columns()
+	rw, err := NewAvroRecordWriter(w, columns, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(mapping, columns, rw), nil
+}
+
+// NewAvroReader creates a reader of the structs T from an Avro file.
+func NewAvroReader[T any](r io.Reader) (*Reader[T], error) {
+	mapping, err := csvutils.NewMapping[T](csvutils.MappingConfig{})
+	if err != nil {
+		return nil, err
+	}
+	rr, err := NewAvroRecordReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(mapping, rr), nil
+}
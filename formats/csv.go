@@ -0,0 +1,189 @@
+package formats
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	csvutils "github.com/seidu626/go-buildingblocks/csv"
+	"github.com/seidu626/go-buildingblocks/sql/schema"
+)
+
+// CSVOptions holds the options of a CSVRecordReader.
+type CSVOptions struct {
+	Separator rune // default ','
+	// Inference options: the first Inference.SampleRows rows are buffered to infer the columns
+	Inference schema.Options
+	// Columns overrides the inference, in the order of the CSV columns
+	Columns []schema.Column
+	// NullValues are the values read as null (default: Inference.NullValues, or the empty string)
+	NullValues []string
+}
+
+func (o *CSVOptions) setDefaults() {
+	if o.Separator == 0 {
+		o.Separator = ','
+	}
+	o.Inference.Separator = o.Separator
+	if o.Inference.SampleRows == 0 {
+		o.Inference.SampleRows = 1000
+	}
+	if o.NullValues == nil {
+		o.NullValues = o.Inference.NullValues
+	}
+	if o.NullValues == nil {
+		o.NullValues = []string{""}
+	}
+	o.Inference.NullValues = o.NullValues
+}
+
+// CSVRecordReader reads the records of a CSV with a header, typed with the inferred columns.
+type CSVRecordReader struct {
+	reader  *csv.Reader
+	columns []schema.Column
+	nulls   map[string]bool
+	sample  [][]string // Rows buffered by the inference, read first
+	line    int
+}
+
+var _ RecordReader = (*CSVRecordReader)(nil)
+
+// NewCSVRecordReader reads the header of the CSV and infers its columns from a sample of rows.
+// When the sample does not cover the whole input, all the inferred columns are nullable, and a
+// value beyond the sample that does not fit its column fails the Read.
+func NewCSVRecordReader(r io.Reader, opts CSVOptions) (*CSVRecordReader, error) {
+	opts.setDefaults()
+	reader := csv.NewReader(r)
+	reader.Comma = opts.Separator
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("formats: empty CSV")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("formats: reading header: %w", err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	c := &CSVRecordReader{reader: reader, nulls: make(map[string]bool, len(opts.NullValues)), line: 1}
+	for _, null := range opts.NullValues {
+		c.nulls[null] = true
+	}
+	if opts.Columns != nil {
+		if len(opts.Columns) != len(header) {
+			return nil, fmt.Errorf("formats: %d columns for %d CSV columns", len(opts.Columns), len(header))
+		}
+		c.columns = append([]schema.Column(nil), opts.Columns...)
+		return c, nil
+	}
+
+	in := schema.NewInferrer(header, opts.Inference)
+	complete := false
+	for opts.Inference.SampleRows < 0 || in.Rows() < opts.Inference.SampleRows {
+		record, err := reader.Read()
+		if err == io.EOF {
+			complete = true
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("formats: reading row %d: %w", in.Rows()+1, err)
+		}
+		in.Observe(record)
+		c.sample = append(c.sample, record)
+	}
+	c.columns = in.Columns()
+	for i := range c.columns {
+		if c.columns[i].Type == schema.Null {
+			c.columns[i].Type = schema.String
+		}
+		if !complete {
+			c.columns[i].Nullable = true
+		}
+	}
+	return c, nil
+}
+
+// Columns returns the columns of the CSV.
+func (c *CSVRecordReader) Columns() []schema.Column {
+	return append([]schema.Column(nil), c.columns...)
+}
+
+func (c *CSVRecordReader) Read(record []any) error {
+	var values []string
+	if len(c.sample) > 0 {
+		values, c.sample = c.sample[0], c.sample[1:]
+	} else {
+		var err error
+		if values, err = c.reader.Read(); err != nil {
+			return err
+		}
+	}
+	c.line++
+	rowErr := &csvutils.RowError{Row: c.line - 1, Line: c.line, Record: values}
+	for i, col := range c.columns {
+		if i >= len(values) || c.nulls[values[i]] {
+			if !col.Nullable {
+				rowErr.Fields = append(rowErr.Fields, &csvutils.FieldError{Column: col.Name, Err: csvutils.ErrRequired})
+			}
+			record[i] = nil
+			continue
+		}
+		value, err := ParseValue(col, values[i])
+		if err != nil {
+			rowErr.Fields = append(rowErr.Fields, &csvutils.FieldError{Column: col.Name, Value: values[i], Err: err})
+			continue
+		}
+		record[i] = value
+	}
+	if len(rowErr.Fields) > 0 {
+		return rowErr
+	}
+	return nil
+}
+
+// ConvertOptions holds the options of the conversions of a CSV.
+type ConvertOptions struct {
+	CSV     CSVOptions
+	Parquet ParquetOptions
+	Avro    AvroOptions
+}
+
+// CSVToParquet converts a CSV with a header to Parquet, with the inferred or given columns,
+// returning the number of rows. Only the inference sample is held in memory besides the
+// pending row group, w can be an S3 upload pipe.
+func CSVToParquet(r io.Reader, w io.Writer, opts ConvertOptions) (int64, error) {
+	rr, err := NewCSVRecordReader(r, opts.CSV)
+	if err != nil {
+		return 0, err
+	}
+	rw, err := NewParquetRecordWriter(w, rr.Columns(), opts.Parquet)
+	if err != nil {
+		return 0, err
+	}
+	return convert(rw, rr)
+}
+
+// CSVToAvro converts a CSV with a header to an Avro object container file, like CSVToParquet.
+func CSVToAvro(r io.Reader, w io.Writer, opts ConvertOptions) (int64, error) {
+	rr, err := NewCSVRecordReader(r, opts.CSV)
+	if err != nil {
+		return 0, err
+	}
+	rw, err := NewAvroRecordWriter(w, rr.Columns(), opts.Avro)
+	if err != nil {
+		return 0, err
+	}
+	return convert(rw, rr)
+}
+
+func convert(w RecordWriter, r RecordReader) (int64, error) {
+	n, err := Copy(w, r)
+	if err != nil {
+		_ = w.Close()
+		return n, err
+	}
+	return n, w.Close()
+}
@@ -0,0 +1,245 @@
+// Package formats reads and writes tabular data as Parquet and Avro, sharing the schema of the
+// csvutils struct tags (csv:"name") and of the CSV inference of the schema package:
+//
+//	w, err := formats.NewParquetWriter[Subscriber](s3Pipe, formats.ParquetOptions{})
+//	...
+//	rows, err := formats.CSVToParquet(csvFile, parquetFile, formats.ConvertOptions{})
+//
+// Records are exchanged as typed values, depending on the type of their column:
+//
+//	schema.Boolean                 bool
+//	schema.Integer                 int32
+//	schema.BigInt                  int64
+//	schema.Decimal                 *big.Rat
+//	schema.Float                   float64
+//	schema.Date, Timestamp[TZ]     time.Time
+//	schema.String, schema.Null     string
+//
+// and nil for null values.
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	csvutils "github.com/seidu626/go-buildingblocks/csv"
+	"github.com/seidu626/go-buildingblocks/sql/schema"
+)
+
+var (
+	// ErrUnsupportedType is returned for the columns of a file that cannot be mapped to a schema.Type
+	ErrUnsupportedType = errors.New("formats: unsupported column type")
+	// ErrClosed is returned when writing to a closed writer
+	ErrClosed = errors.New("formats: writer closed")
+)
+
+// RecordWriter writes records of typed values, in the order of its columns.
+type RecordWriter interface {
+	Write(record []any) error
+	// Close flushes the pending records and writes the footer, it does not close the underlying writer
+	Close() error
+}
+
+// RecordReader reads records of typed values.
+type RecordReader interface {
+	Columns() []schema.Column
+	// Read fills record, which must have the length of Columns, returning io.EOF at the end
+	Read(record []any) error
+}
+
+// Copy writes the records of r to w until io.EOF, returning the number of records.
+func Copy(w RecordWriter, r RecordReader) (int64, error) {
+	record := make([]any, len(r.Columns()))
+	var n int64
+	for {
+		if err := r.Read(record); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		if err := w.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// Writer writes structs T with a RecordWriter, mapped with their csv tags.
+type Writer[T any] struct {
+	mapping *csvutils.Mapping[T]
+	columns []schema.Column
+	w       RecordWriter
+	values  []string
+	nulls   []bool
+	record  []any
+}
+
+func newWriter[T any](mapping *csvutils.Mapping[T], columns []schema.Column, w RecordWriter) *Writer[T] {
+	return &Writer[T]{
+		mapping: mapping,
+		columns: columns,
+		w:       w,
+		values:  make([]string, len(columns)),
+		nulls:   make([]bool, len(columns)),
+		record:  make([]any, len(columns)),
+	}
+}
+
+// Write writes v.
+func (w *Writer[T]) Write(v T) error {
+	if err := w.mapping.Encode(v, w.values, w.nulls); err != nil {
+		return err
+	}
+	for i, c := range w.columns {
+		if w.nulls[i] {
+			w.record[i] = nil
+			continue
+		}
+		value, err := ParseValue(c, w.values[i])
+		if err != nil {
+			return &csvutils.FieldError{Column: c.Name, Value: w.values[i], Err: err}
+		}
+		w.record[i] = value
+	}
+	return w.w.Write(w.record)
+}
+
+// Close flushes the pending records, it does not close the underlying writer.
+func (w *Writer[T]) Close() error {
+	return w.w.Close()
+}
+
+// Reader reads structs T from a RecordReader. The columns of the file are matched by name to
+// the csv tags, the fields without column are null: zero value, or an error if required.
+type Reader[T any] struct {
+	mapping *csvutils.Mapping[T]
+	columns []schema.Column
+	r       RecordReader
+	index   []int // Position of the mapping columns in the records, -1 when missing
+	record  []any
+	values  []string
+	nulls   []bool
+	row     int
+}
+
+func newReader[T any](mapping *csvutils.Mapping[T], r RecordReader) *Reader[T] {
+	columns := mapping.Columns()
+	positions := make(map[string]int)
+	for i, c := range r.Columns() {
+		positions[c.Name] = i
+	}
+	index := make([]int, len(columns))
+	for i, c := range columns {
+		if pos, ok := positions[c.Name]; ok {
+			index[i] = pos
+		} else {
+			index[i] = -1
+		}
+	}
+	return &Reader[T]{
+		mapping: mapping,
+		columns: columns,
+		r:       r,
+		index:   index,
+		record:  make([]any, len(r.Columns())),
+		values:  make([]string, len(columns)),
+		nulls:   make([]bool, len(columns)),
+	}
+}
+
+// Read reads the next record into v, returning io.EOF at the end.
+func (r *Reader[T]) Read(v *T) error {
+	if err := r.r.Read(r.record); err != nil {
+		return err
+	}
+	r.row++
+	for i, c := range r.columns {
+		if r.index[i] < 0 || r.record[r.index[i]] == nil {
+			r.values[i], r.nulls[i] = "", true
+			continue
+		}
+		s, err := FormatValue(c, r.record[r.index[i]])
+		if err != nil {
+			return &csvutils.RowError{Row: r.row, Fields: []*csvutils.FieldError{{Column: c.Name, Err: err}}}
+		}
+		r.values[i], r.nulls[i] = s, false
+	}
+	if err := r.mapping.Decode(r.values, r.nulls, v); err != nil {
+		var rowErr *csvutils.RowError
+		if errors.As(err, &rowErr) {
+			rowErr.Row = r.row
+		}
+		return err
+	}
+	return nil
+}
+
+// ParseValue converts the string of a column to its typed value.
+func ParseValue(c schema.Column, s string) (any, error) {
+	switch c.Type {
+	case schema.Boolean:
+		return strconv.ParseBool(s)
+	case schema.Integer:
+		n, err := strconv.ParseInt(s, 10, 32)
+		return int32(n), err
+	case schema.BigInt:
+		return strconv.ParseInt(s, 10, 64)
+	case schema.Decimal:
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid decimal %q", s)
+		}
+		return r, nil
+	case schema.Float:
+		return strconv.ParseFloat(s, 64)
+	case schema.Date, schema.Timestamp, schema.TimestampTZ:
+		return parseTime(c, s)
+	}
+	return s, nil
+}
+
+// FormatValue converts a typed value to the string of its column.
+func FormatValue(c schema.Column, v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case *big.Rat:
+		return v.FloatString(c.Scale), nil
+	case time.Time:
+		layout := c.Layout
+		if layout == "" {
+			layout = time.RFC3339Nano
+			if c.Type == schema.Date {
+				layout = time.DateOnly
+			}
+		}
+		return v.Format(layout), nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+}
+
+func parseTime(c schema.Column, s string) (time.Time, error) {
+	if c.Layout != "" {
+		return time.Parse(c.Layout, s)
+	}
+	for _, layouts := range [][]string{{time.RFC3339Nano}, schema.DateLayouts, schema.TimestampLayouts, schema.TimestampTZLayouts} {
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
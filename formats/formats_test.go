@@ -0,0 +1,219 @@
+package formats
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/sql/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type subscriber struct {
+	ID      int64     `csv:"id,required"`
+	MSISDN  string    `csv:"msisdn"`
+	Active  bool      `csv:"active"`
+	Count   int32     `csv:"count"`
+	Balance *big.Rat  `csv:"balance,scale=2,precision=10"`
+	Total   *big.Rat  `csv:"total,scale=4"`
+	Ratio   float64   `csv:"ratio"`
+	Born    time.Time `csv:"born,layout=2006-01-02"`
+	Seen    time.Time `csv:"seen"`
+	Note    *string   `csv:"note"`
+}
+
+func subscribers() []subscriber {
+	note := "vip"
+	return []subscriber{
+		{ID: 1, MSISDN: "233200000001", Active: true, Count: 3, Balance: big.NewRat(1050, 100), Total: big.NewRat(-12345678, 10000),
+			Ratio: 0.5, Born: time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC), Seen: time.Date(2024, 1, 2, 10, 0, 0, 123000000, time.UTC), Note: &note},
+		{ID: 2, MSISDN: "233200000002", Balance: new(big.Rat), Total: big.NewRat(1, 4),
+			Born: time.Date(2001, 12, 31, 0, 0, 0, 0, time.UTC), Seen: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+}
+
+func assertSubscribers(t *testing.T, want, got []subscriber) {
+	t.Helper()
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.Equal(t, want[i].ID, got[i].ID)
+		assert.Equal(t, want[i].MSISDN, got[i].MSISDN)
+		assert.Equal(t, want[i].Active, got[i].Active)
+		assert.Equal(t, want[i].Count, got[i].Count)
+		assert.Equal(t, 0, want[i].Balance.Cmp(got[i].Balance), "balance %s", got[i].Balance)
+		assert.Equal(t, 0, want[i].Total.Cmp(got[i].Total), "total %s", got[i].Total)
+		assert.Equal(t, want[i].Ratio, got[i].Ratio)
+		assert.True(t, want[i].Born.Equal(got[i].Born), "born %s", got[i].Born)
+		assert.True(t, want[i].Seen.Equal(got[i].Seen), "seen %s", got[i].Seen)
+		assert.Equal(t, want[i].Note, got[i].Note)
+	}
+}
+
+func readAll[T any](t *testing.T, r *Reader[T]) []T {
+	t.Helper()
+	var items []T
+	for {
+		var v T
+		err := r.Read(&v)
+		if err == io.EOF {
+			return items
+		}
+		require.NoError(t, err)
+		items = append(items, v)
+	}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	for _, compression := range []Compression{Snappy, Gzip, Zstd, Uncompressed} {
+		t.Run(string(compression), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewParquetWriter[subscriber](&buf, ParquetOptions{Compression: compression, RowGroupRows: 1})
+			require.NoError(t, err)
+			for _, s := range subscribers() {
+				require.NoError(t, w.Write(s))
+			}
+			require.NoError(t, w.Close())
+			assert.ErrorIs(t, w.Write(subscribers()[0]), ErrClosed)
+
+			r, err := NewParquetReader[subscriber](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			require.NoError(t, err)
+			assertSubscribers(t, subscribers(), readAll(t, r))
+		})
+	}
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	for _, compression := range []Compression{Snappy, Gzip, Zstd, Uncompressed} {
+		t.Run(string(compression), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewAvroWriter[subscriber](&buf, AvroOptions{Compression: compression, BlockRows: 1})
+			require.NoError(t, err)
+			for _, s := range subscribers() {
+				require.NoError(t, w.Write(s))
+			}
+			require.NoError(t, w.Close())
+
+			r, err := NewAvroReader[subscriber](&buf)
+			require.NoError(t, err)
+			assertSubscribers(t, subscribers(), readAll(t, r))
+		})
+	}
+}
+
+func TestReaderMatchesColumnsByName(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewParquetWriter[subscriber](&buf, ParquetOptions{})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(subscribers()[0]))
+	require.NoError(t, w.Close())
+
+	type partial struct {
+		Note    string `csv:"note"`
+		ID      int    `csv:"id"`
+		Missing string `csv:"missing"`
+	}
+	r, err := NewParquetReader[partial](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []partial{{Note: "vip", ID: 1}}, readAll(t, r))
+
+	type required struct {
+		Missing string `csv:"missing,required"`
+	}
+	rr, err := NewParquetReader[required](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var v required
+	assert.Error(t, rr.Read(&v))
+}
+
+const sampleCSV = "\ufeffID,Amount,Active,Day,At,Name,Empty\n" +
+	"1,10.50,true,2024-01-02,2024-01-02T10:00:00Z,ann,\n" +
+	"2,,false,2024-01-03,2024-01-03T11:30:00Z,,\n" +
+	"3000000000,-1.25,TRUE,2024-01-04,2024-01-04T00:00:00Z,carl,\n"
+
+func TestCSVToParquet(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := CSVToParquet(strings.NewReader(sampleCSV), &buf, ConvertOptions{Parquet: ParquetOptions{Compression: Zstd, RowGroupRows: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	r, err := NewParquetRecordReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, int64(3), r.NumRows())
+
+	columns := r.Columns()
+	types := make(map[string]schema.Type, len(columns))
+	for _, c := range columns {
+		types[c.Name] = c.Type
+	}
+	assert.Equal(t, map[string]schema.Type{"id": schema.BigInt, "amount": schema.Decimal, "active": schema.Boolean,
+		"day": schema.Date, "at": schema.TimestampTZ, "name": schema.String, "empty": schema.String}, types)
+
+	record := make([]any, len(columns))
+	require.NoError(t, r.Read(record))
+	assert.Equal(t, int64(1), record[0])
+	assert.Equal(t, "10.50", record[1].(*big.Rat).FloatString(2))
+	assert.Equal(t, true, record[2])
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), record[3])
+	assert.True(t, time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC).Equal(record[4].(time.Time)))
+	assert.Equal(t, "ann", record[5])
+	assert.Nil(t, record[6])
+
+	require.NoError(t, r.Read(record))
+	assert.Nil(t, record[1], "null decimal")
+	assert.Nil(t, record[5], "null string")
+	require.NoError(t, r.Read(record))
+	assert.Equal(t, int64(3000000000), record[0])
+	assert.Equal(t, io.EOF, r.Read(record))
+}
+
+func TestCSVToAvro(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := CSVToAvro(strings.NewReader(sampleCSV), &buf, ConvertOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	r, err := NewAvroRecordReader(&buf)
+	require.NoError(t, err)
+	record := make([]any, len(r.Columns()))
+	require.NoError(t, r.Read(record))
+	assert.Equal(t, int64(1), record[0])
+	assert.Equal(t, "10.50", record[1].(*big.Rat).FloatString(2))
+	assert.Nil(t, record[6])
+}
+
+func TestCSVSampleBeyondInference(t *testing.T) {
+	input := "id,v\n1,2\n2,3\n3,abc\n"
+	_, err := CSVToParquet(strings.NewReader(input), io.Discard, ConvertOptions{CSV: CSVOptions{Inference: schema.Options{SampleRows: 2}}})
+	require.Error(t, err, "abc does not fit the inferred integer")
+
+	r, err := NewCSVRecordReader(strings.NewReader("id,v\n1,\n"), CSVOptions{Columns: []schema.Column{
+		{Name: "id", Type: schema.Integer}, {Name: "v", Type: schema.String},
+	}})
+	require.NoError(t, err)
+	assert.Error(t, r.Read(make([]any, 2)), "v is not nullable")
+}
+
+func TestDecimalPrecision(t *testing.T) {
+	columns := []schema.Column{{Name: "d", Type: schema.Decimal, Precision: 30, Scale: 6, Nullable: true}}
+	var buf bytes.Buffer
+	w, err := NewParquetRecordWriter(&buf, columns, ParquetOptions{})
+	require.NoError(t, err)
+	large, _ := new(big.Rat).SetString("-123456789012345678901234.123456")
+	require.NoError(t, w.Write([]any{large}))
+	require.NoError(t, w.Write([]any{nil}))
+	assert.Error(t, w.Write([]any{big.NewRat(1, 3)}), "1/3 does not fit scale 6")
+	require.NoError(t, w.Close())
+
+	r, err := NewParquetRecordReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	record := make([]any, 1)
+	require.NoError(t, r.Read(record))
+	assert.Equal(t, 0, large.Cmp(record[0].(*big.Rat)))
+	require.NoError(t, r.Read(record))
+	assert.Nil(t, record[0])
+}
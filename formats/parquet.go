@@ -0,0 +1,523 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	csvutils "github.com/seidu626/go-buildingblocks/csv"
+	"github.com/seidu626/go-buildingblocks/sql/schema"
+)
+
+// schemaMetadataKey is the key of the columns stored in the metadata of the files, to read
+// back the layouts and the headers
+const schemaMetadataKey = "go-buildingblocks.schema"
+
+// Compression is the codec of the pages of a Parquet file or of the blocks of an Avro file.
+type Compression string
+
+const (
+	Snappy       Compression = "snappy"
+	Gzip         Compression = "gzip"
+	Zstd         Compression = "zstd"
+	Uncompressed Compression = "none"
+)
+
+// ParquetOptions holds the options of the Parquet writers.
+type ParquetOptions struct {
+	Compression Compression // Defaults to Snappy
+	// RowGroupRows is the number of rows of a row group (default 128K). Row groups are
+	// buffered in memory before being written.
+	RowGroupRows int64
+	// PageBufferSize is the size of the pages in bytes (default 256KB)
+	PageBufferSize int
+}
+
+func (o *ParquetOptions) setDefaults() {
+	if o.Compression == "" {
+		o.Compression = Snappy
+	}
+	if o.RowGroupRows <= 0 {
+		o.RowGroupRows = 128 * 1024
+	}
+	if o.PageBufferSize <= 0 {
+		o.PageBufferSize = 256 * 1024
+	}
+}
+
+func (o ParquetOptions) codec() (compress.Codec, error) {
+	switch o.Compression {
+	case Snappy:
+		return &parquet.Snappy, nil
+	case Gzip:
+		return &parquet.Gzip, nil
+	case Zstd:
+		return &parquet.Zstd, nil
+	case Uncompressed:
+		return &parquet.Uncompressed, nil
+	}
+	return nil, fmt.Errorf("formats: unknown compression %q", o.Compression)
+}
+
+// ParquetSchema returns the Parquet schema of the columns.
+func ParquetSchema(name string, columns []schema.Column) *parquet.Schema {
+	group := make(parquet.Group, len(columns))
+	for _, c := range columns {
+		var node parquet.Node
+		switch c.Type {
+		case schema.Boolean:
+			node = parquet.Leaf(parquet.BooleanType)
+		case schema.Integer:
+			node = parquet.Int(32)
+		case schema.BigInt:
+			node = parquet.Int(64)
+		case schema.Decimal:
+			if c.Precision <= 18 {
+				node = parquet.Decimal(c.Scale, c.Precision, parquet.Int64Type)
+			} else {
+				node = parquet.Decimal(c.Scale, c.Precision, parquet.FixedLenByteArrayType(16))
+			}
+		case schema.Float:
+			node = parquet.Leaf(parquet.DoubleType)
+		case schema.Date:
+			node = parquet.Date()
+		case schema.Timestamp:
+			node = parquet.TimestampAdjusted(parquet.Microsecond, false)
+		case schema.TimestampTZ:
+			node = parquet.Timestamp(parquet.Microsecond)
+		default:
+			node = parquet.String()
+		}
+		if c.Nullable || c.Type == schema.Null {
+			node = parquet.Optional(node)
+		}
+		group[c.Name] = node
+	}
+	return parquet.NewSchema(name, group)
+}
+
+// parquetColumn converts the values of a column
+type parquetColumn struct {
+	schema.Column
+	index    int // Leaf index in the Parquet schema
+	optional bool
+	fixed    bool          // Decimal stored as FIXED_LEN_BYTE_ARRAY
+	unit     time.Duration // Unit of the timestamps
+}
+
+// ParquetRecordWriter writes records to a Parquet file.
+type ParquetRecordWriter struct {
+	writer  *parquet.Writer
+	columns []parquetColumn
+	rows    []parquet.Row
+	batch   int
+	closed  bool
+}
+
+var _ RecordWriter = (*ParquetRecordWriter)(nil)
+
+// NewParquetRecordWriter creates a writer of the columns to w. Nothing is written until the
+// first row group is complete, Close must be called to write the footer.
+func NewParquetRecordWriter(w io.Writer, columns []schema.Column, opts ParquetOptions) (*ParquetRecordWriter, error) {
+	opts.setDefaults()
+	codec, err := opts.codec()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("formats: no columns")
+	}
+	meta, err := json.Marshal(columns)
+	if err != nil {
+		return nil, err
+	}
+	pqSchema := ParquetSchema("record", columns)
+	writer := parquet.NewWriter(w, pqSchema,
+		parquet.Compression(codec),
+		parquet.MaxRowsPerRowGroup(opts.RowGroupRows),
+		parquet.PageBufferSize(opts.PageBufferSize),
+		parquet.KeyValueMetadata(schemaMetadataKey, string(meta)),
+	)
+	pqColumns, err := parquetColumns(pqSchema, columns)
+	if err != nil {
+		return nil, err
+	}
+	return &ParquetRecordWriter{writer: writer, columns: pqColumns, batch: 1024}, nil
+}
+
+// parquetColumns maps the columns to the leaves of the schema, which are sorted by name
+func parquetColumns(pqSchema *parquet.Schema, columns []schema.Column) ([]parquetColumn, error) {
+	out := make([]parquetColumn, len(columns))
+	for i, c := range columns {
+		leaf, ok := pqSchema.Lookup(c.Name)
+		if !ok {
+			return nil, fmt.Errorf("formats: column %q not found in the parquet schema", c.Name)
+		}
+		out[i] = parquetColumn{Column: c, index: leaf.ColumnIndex, optional: leaf.Node.Optional(), unit: time.Microsecond}
+		if leaf.Node.Repeated() {
+			return nil, fmt.Errorf("%w: repeated column %q", ErrUnsupportedType, c.Name)
+		}
+		typ := leaf.Node.Type()
+		if c.Type == schema.Decimal && typ.Kind() == parquet.FixedLenByteArray {
+			out[i].fixed = true
+		}
+		if logical := typ.LogicalType(); logical != nil && logical.Timestamp != nil {
+			switch unit := logical.Timestamp.Unit; {
+			case unit.Millis != nil:
+				out[i].unit = time.Millisecond
+			case unit.Nanos != nil:
+				out[i].unit = time.Nanosecond
+			}
+		}
+	}
+	return out, nil
+}
+
+// Write buffers a record, rows are written by batches.
+func (p *ParquetRecordWriter) Write(record []any) error {
+	if p.closed {
+		return ErrClosed
+	}
+	row := make(parquet.Row, len(p.columns))
+	for i, c := range p.columns {
+		value, err := c.value(record[i])
+		if err != nil {
+			return &csvutils.FieldError{Column: c.Name, Err: err}
+		}
+		row[c.index] = value
+	}
+	p.rows = append(p.rows, row)
+	if len(p.rows) >= p.batch {
+		return p.flushRows()
+	}
+	return nil
+}
+
+func (p *ParquetRecordWriter) flushRows() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+	_, err := p.writer.WriteRows(p.rows)
+	p.rows = p.rows[:0]
+	return err
+}
+
+// Close writes the pending rows and the footer.
+func (p *ParquetRecordWriter) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if err := p.flushRows(); err != nil {
+		return err
+	}
+	return p.writer.Close()
+}
+
+func (c *parquetColumn) value(v any) (parquet.Value, error) {
+	if v == nil {
+		if !c.optional {
+			return parquet.Value{}, csvutils.ErrRequired
+		}
+		return parquet.NullValue().Level(0, 0, c.index), nil
+	}
+	var value parquet.Value
+	switch c.Type {
+	case schema.Boolean:
+		b, ok := v.(bool)
+		if !ok {
+			return value, typeError(c.Column, v)
+		}
+		value = parquet.BooleanValue(b)
+	case schema.Integer:
+		n, ok := v.(int32)
+		if !ok {
+			return value, typeError(c.Column, v)
+		}
+		value = parquet.Int32Value(n)
+	case schema.BigInt:
+		n, ok := v.(int64)
+		if !ok {
+			return value, typeError(c.Column, v)
+		}
+		value = parquet.Int64Value(n)
+	case schema.Decimal:
+		r, ok := v.(*big.Rat)
+		if !ok {
+			return value, typeError(c.Column, v)
+		}
+		unscaled, err := unscaledDecimal(r, c.Scale, c.Precision)
+		if err != nil {
+			return value, err
+		}
+		if c.fixed {
+			value = parquet.FixedLenByteArrayValue(twosComplement(unscaled, 16))
+		} else {
+			value = parquet.Int64Value(unscaled.Int64())
+		}
+	case schema.Float:
+		f, ok := v.(float64)
+		if !ok {
+			return value, typeError(c.Column, v)
+		}
+		value = parquet.DoubleValue(f)
+	case schema.Date:
+		t, ok := v.(time.Time)
+		if !ok {
+			return value, typeError(c.Column, v)
+		}
+		y, m, d := t.Date()
+		value = parquet.Int32Value(int32(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400))
+	case schema.Timestamp, schema.TimestampTZ:
+		t, ok := v.(time.Time)
+		if !ok {
+			return value, typeError(c.Column, v)
+		}
+		if c.Type == schema.Timestamp {
+			// Wall clock, whatever its location
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		}
+		value = parquet.Int64Value(t.UnixNano() / int64(c.unit))
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return value, typeError(c.Column, v)
+		}
+		value = parquet.ByteArrayValue([]byte(s))
+	}
+	definition := 0
+	if c.optional {
+		definition = 1
+	}
+	return value.Level(0, definition, c.index), nil
+}
+
+func (c *parquetColumn) decode(v parquet.Value) (any, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+	switch c.Type {
+	case schema.Boolean:
+		return v.Boolean(), nil
+	case schema.Integer:
+		return v.Int32(), nil
+	case schema.BigInt:
+		return v.Int64(), nil
+	case schema.Decimal:
+		var unscaled *big.Int
+		switch v.Kind() {
+		case parquet.Int32:
+			unscaled = big.NewInt(int64(v.Int32()))
+		case parquet.Int64:
+			unscaled = big.NewInt(v.Int64())
+		default:
+			unscaled = fromTwosComplement(v.ByteArray())
+		}
+		return new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Scale)), nil)), nil
+	case schema.Float:
+		if v.Kind() == parquet.Float {
+			return float64(v.Float()), nil
+		}
+		return v.Double(), nil
+	case schema.Date:
+		return time.Unix(int64(v.Int32())*86400, 0).UTC(), nil
+	case schema.Timestamp, schema.TimestampTZ:
+		return time.Unix(0, v.Int64()*int64(c.unit)).UTC(), nil
+	}
+	return string(v.ByteArray()), nil
+}
+
+func typeError(c schema.Column, v any) error {
+	return fmt.Errorf("%w: %T for %s column", ErrUnsupportedType, v, c.Type)
+}
+
+// unscaledDecimal returns r * 10^scale, which must be an integer of at most precision digits
+func unscaledDecimal(r *big.Rat, scale, precision int) (*big.Int, error) {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if !scaled.IsInt() {
+		return nil, fmt.Errorf("%w: %s with scale %d", csvutils.ErrScale, r.FloatString(scale+2), scale)
+	}
+	n := scaled.Num()
+	if len(new(big.Int).Abs(n).String()) > precision {
+		return nil, fmt.Errorf("formats: %s exceeds precision %d", r.FloatString(scale), precision)
+	}
+	return n, nil
+}
+
+// twosComplement returns the big-endian two's complement of n on size bytes
+func twosComplement(n *big.Int, size int) []byte {
+	b := make([]byte, size)
+	if n.Sign() >= 0 {
+		return n.FillBytes(b)
+	}
+	// 2^(8*size) + n
+	m := new(big.Int).Lsh(big.NewInt(1), uint(8*size))
+	return m.Add(m, n).FillBytes(b)
+}
+
+func fromTwosComplement(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return n
+}
+
+// ParquetRecordReader reads the records of a Parquet file.
+type ParquetRecordReader struct {
+	reader  *parquet.Reader
+	columns []parquetColumn
+	rows    []parquet.Row
+	pos     int
+	n       int
+}
+
+var _ RecordReader = (*ParquetRecordReader)(nil)
+
+// NewParquetRecordReader opens a Parquet file. Parquet files end with their metadata, so they
+// are read with an io.ReaderAt, e.g. an *os.File or a *bytes.Reader. Only flat schemas are
+// supported.
+func NewParquetRecordReader(r io.ReaderAt, size int64) (*ParquetRecordReader, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := fileColumns(file)
+	if err != nil {
+		return nil, err
+	}
+	pqColumns, err := parquetColumns(file.Schema(), columns)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]parquet.Row, 256)
+	return &ParquetRecordReader{reader: parquet.NewReader(file), columns: pqColumns, rows: rows}, nil
+}
+
+// fileColumns returns the columns stored in the metadata by the writers of this package, or
+// derives them from the Parquet schema
+func fileColumns(file *parquet.File) ([]schema.Column, error) {
+	if meta, ok := file.Lookup(schemaMetadataKey); ok {
+		var columns []schema.Column
+		if err := json.Unmarshal([]byte(meta), &columns); err == nil {
+			return columns, nil
+		}
+	}
+	var columns []schema.Column
+	for _, field := range file.Schema().Fields() {
+		if !field.Leaf() || field.Repeated() {
+			return nil, fmt.Errorf("%w: nested column %q", ErrUnsupportedType, field.Name())
+		}
+		c := schema.Column{Header: field.Name(), Name: field.Name(), Nullable: field.Optional()}
+		typ := field.Type()
+		logical := typ.LogicalType()
+		switch {
+		case logical != nil && logical.Decimal != nil:
+			c.Type, c.Precision, c.Scale = schema.Decimal, int(logical.Decimal.Precision), int(logical.Decimal.Scale)
+		case logical != nil && logical.Date != nil:
+			c.Type = schema.Date
+		case logical != nil && logical.Timestamp != nil:
+			c.Type = schema.Timestamp
+			if logical.Timestamp.IsAdjustedToUTC {
+				c.Type = schema.TimestampTZ
+			}
+		case logical != nil && logical.Integer != nil:
+			c.Type = schema.BigInt
+			if logical.Integer.BitWidth < 32 || (logical.Integer.BitWidth == 32 && logical.Integer.IsSigned) {
+				c.Type = schema.Integer
+			}
+		case logical != nil && (logical.UTF8 != nil || logical.Enum != nil || logical.Json != nil):
+			c.Type = schema.String
+		default:
+			switch typ.Kind() {
+			case parquet.Boolean:
+				c.Type = schema.Boolean
+			case parquet.Int32:
+				c.Type = schema.Integer
+			case parquet.Int64:
+				c.Type = schema.BigInt
+			case parquet.Float, parquet.Double:
+				c.Type = schema.Float
+			case parquet.ByteArray:
+				c.Type = schema.String
+			default:
+				return nil, fmt.Errorf("%w: %s column %q", ErrUnsupportedType, typ, field.Name())
+			}
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// Columns returns the columns of the file.
+func (p *ParquetRecordReader) Columns() []schema.Column {
+	columns := make([]schema.Column, len(p.columns))
+	for i, c := range p.columns {
+		columns[i] = c.Column
+	}
+	return columns
+}
+
+// NumRows returns the number of rows of the file.
+func (p *ParquetRecordReader) NumRows() int64 {
+	return p.reader.NumRows()
+}
+
+func (p *ParquetRecordReader) Read(record []any) error {
+	if p.pos >= p.n {
+		n, err := p.reader.ReadRows(p.rows)
+		if n == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return err
+		}
+		p.pos, p.n = 0, n
+	}
+	row := p.rows[p.pos]
+	p.pos++
+	for i, c := range p.columns {
+		value, err := c.decode(row[c.index])
+		if err != nil {
+			return err
+		}
+		record[i] = value
+	}
+	return nil
+}
+
+// Close releases the reader.
+func (p *ParquetRecordReader) Close() error {
+	return p.reader.Close()
+}
+
+// NewParquetWriter creates a writer of the structs T, with a column per csv tag.
+func NewParquetWriter[T any](w io.Writer, opts ParquetOptions) (*Writer[T], error) {
+	mapping, err := csvutils.NewMapping[T](csvutils.MappingConfig{})
+	if err != nil {
+		return nil, err
+	}
+	columns := mapping.Columns()
+	rw, err := NewParquetRecordWriter(w, columns, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(mapping, columns, rw), nil
+}
+
+// NewParquetReader creates a reader of the structs T from a Parquet file.
+func NewParquetReader[T any](r io.ReaderAt, size int64) (*Reader[T], error) {
+	mapping, err := csvutils.NewMapping[T](csvutils.MappingConfig{})
+	if err != nil {
+		return nil, err
+	}
+	rr, err := NewParquetRecordReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(mapping, rr), nil
+}
@@ -0,0 +1,69 @@
+package grpcx
+
+import (
+	"context"
+	"time"
+
+	apperrors "github.com/seidu626/go-buildingblocks/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewClient creates a client connection. Its interceptors send the request ID of the context,
+// apply the timeout to the calls without deadline and return the failures as *errors.Error,
+// which keep their gRPC code for status.Code.
+func NewClient(cfg ClientConfig) (*grpc.ClientConn, error) {
+	cfg.setDefaults()
+	creds := insecure.NewCredentials()
+	if cfg.TLSConfig != nil {
+		creds = credentials.NewTLS(cfg.TLSConfig)
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(append([]grpc.UnaryClientInterceptor{UnaryClientInterceptor(cfg.Timeout)}, cfg.UnaryInterceptors...)...),
+		grpc.WithChainStreamInterceptor(append([]grpc.StreamClientInterceptor{StreamClientInterceptor()}, cfg.StreamInterceptors...)...),
+	}
+	return grpc.NewClient(cfg.Target, append(opts, cfg.Options...)...)
+}
+
+// outgoing returns the context of a call with the request ID of ctx
+func outgoing(ctx context.Context) context.Context {
+	if id := RequestID(ctx); id != "" {
+		if md, ok := metadata.FromOutgoingContext(ctx); !ok || len(md.Get(RequestIDKey)) == 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+		}
+	}
+	return ctx
+}
+
+// fromStatus returns the *errors.Error of the status of err
+func fromStatus(err error) error {
+	if st, ok := status.FromError(err); ok && err != nil {
+		return apperrors.FromStatus(st)
+	}
+	return err
+}
+
+// UnaryClientInterceptor sends the request ID of the context, bounds the calls without
+// deadline by timeout, zero for none, and returns the failures as *errors.Error.
+func UnaryClientInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return fromStatus(invoker(outgoing(ctx), method, req, reply, cc, opts...))
+	}
+}
+
+// StreamClientInterceptor sends the request ID of the context.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(outgoing(ctx), desc, cc, method, opts...)
+		return cs, fromStatus(err)
+	}
+}
@@ -0,0 +1,168 @@
+// Package grpcx builds gRPC servers and clients: a Server chains the interceptors of request
+// IDs, logging, errors, panic recovery, deadlines and JWT authentication, and serves the
+// health service; a client connection propagates the request IDs and the deadlines and
+// returns the errors of the package errors.
+//
+//	srv := grpcx.NewServer(cfg)
+//	pb.RegisterSubscriptionsServer(srv, handler)
+//	go srv.ListenAndServe()
+//	graceful.GracefulShutdown(ctx, logger, timeout, map[string]graceful.Operation{"grpc": srv.Shutdown})
+package grpcx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/seidu626/go-buildingblocks/config"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// AuthConfig configures the validation of the JWT of the calls.
+type AuthConfig struct {
+	// KeyFunc returns the key of a token, see middleware.RSAKeyFunc and middleware.HMACKeyFunc
+	KeyFunc jwt.Keyfunc
+	// Public are the methods, full names or prefixes ending with "/", called without token.
+	// The health service is always public.
+	Public []string
+}
+
+func (c *AuthConfig) public(fullMethod string) bool {
+	if strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") {
+		return true
+	}
+	for _, p := range c.Public {
+		if fullMethod == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(fullMethod, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerConfig holds the configuration of a Server.
+type ServerConfig struct {
+	// Addr to listen on, default ":50051"
+	Addr      string
+	TLSConfig *tls.Config
+	// MaxRecvMsgSize default 4MiB
+	MaxRecvMsgSize int
+	// DefaultTimeout is the deadline of the calls received without one, none when zero
+	DefaultTimeout time.Duration
+	// MaxTimeout caps the deadlines of the calls, none when zero
+	MaxTimeout time.Duration
+	// Auth, optional, requires a valid JWT on the calls
+	Auth *AuthConfig
+	// UnaryInterceptors and StreamInterceptors are chained after those of the package
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+	// Options are additional options of the server
+	Options []grpc.ServerOption
+	Logger  *zap.Logger
+}
+
+func (c *ServerConfig) setDefaults() {
+	if c.Addr == "" {
+		c.Addr = ":50051"
+	}
+	if c.MaxRecvMsgSize <= 0 {
+		c.MaxRecvMsgSize = 4 << 20
+	}
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+}
+
+// ClientConfig holds the configuration of a client connection.
+type ClientConfig struct {
+	Target string
+	// TLSConfig, plaintext when nil
+	TLSConfig *tls.Config
+	// Timeout is the deadline of the calls made without one, none when zero
+	Timeout time.Duration
+	// UnaryInterceptors and StreamInterceptors are chained after those of the package
+	UnaryInterceptors  []grpc.UnaryClientInterceptor
+	StreamInterceptors []grpc.StreamClientInterceptor
+	// Options are additional options of the connection
+	Options []grpc.DialOption
+	Logger  *zap.Logger
+}
+
+func (c *ClientConfig) setDefaults() {
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+}
+
+// ConvertToServerConfig converts the GRPC section of a config.Config to a ServerConfig.
+func ConvertToServerConfig(appConfig *config.Config) (ServerConfig, error) {
+	conf := appConfig.GRPC
+	cfg := ServerConfig{
+		MaxRecvMsgSize: conf.MaxRecvMsgSize,
+		DefaultTimeout: conf.DefaultTimeout,
+		MaxTimeout:     conf.MaxTimeout,
+	}
+	if conf.Port != 0 {
+		cfg.Addr = fmt.Sprintf(":%d", conf.Port)
+	}
+	if conf.TLSEnabled {
+		cert, err := tls.LoadX509KeyPair(conf.TLSCertPath, conf.TLSKeyPath)
+		if err != nil {
+			return ServerConfig{}, fmt.Errorf("failed to load TLS key pair: %v", err)
+		}
+		cfg.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		if conf.TLSCaPath != "" {
+			pool, err := loadCertPool(conf.TLSCaPath)
+			if err != nil {
+				return ServerConfig{}, err
+			}
+			cfg.TLSConfig.ClientCAs = pool
+			cfg.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return cfg, nil
+}
+
+// ConvertToClientConfig converts the client name of the GRPC section of a config.Config to a
+// ClientConfig.
+func ConvertToClientConfig(appConfig *config.Config, name string) (ClientConfig, error) {
+	conf, ok := appConfig.GRPC.Clients[name]
+	if !ok || conf.Target == "" {
+		return ClientConfig{}, fmt.Errorf("grpc configuration error: no target for client %s", name)
+	}
+	cfg := ClientConfig{Target: conf.Target, Timeout: conf.Timeout}
+	if conf.TLSEnabled {
+		cfg.TLSConfig = &tls.Config{ServerName: conf.TLSServerName, MinVersion: tls.VersionTLS12}
+		if conf.TLSCertPath != "" && conf.TLSKeyPath != "" {
+			cert, err := tls.LoadX509KeyPair(conf.TLSCertPath, conf.TLSKeyPath)
+			if err != nil {
+				return ClientConfig{}, fmt.Errorf("failed to load TLS key pair: %v", err)
+			}
+			cfg.TLSConfig.Certificates = []tls.Certificate{cert}
+		}
+		if conf.TLSCaPath != "" {
+			pool, err := loadCertPool(conf.TLSCaPath)
+			if err != nil {
+				return ClientConfig{}, err
+			}
+			cfg.TLSConfig.RootCAs = pool
+		}
+	}
+	return cfg, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("failed to append CA certificate")
+	}
+	return pool, nil
+}
@@ -0,0 +1,235 @@
+package grpcx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	apperrors "github.com/seidu626/go-buildingblocks/errors"
+	"github.com/seidu626/go-buildingblocks/logging"
+	"github.com/seidu626/go-buildingblocks/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var secret = []byte("test-secret")
+
+var errNotSubscribed = apperrors.Register(apperrors.Definition{Id: "grpcx.not_subscribed", Message: "%s not subscribed", HTTPStatus: http.StatusNotFound})
+
+// echo is the handler of the test service: it echoes its input, or fails depending on it
+func echo(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	switch in.Value {
+	case "panic":
+		panic("boom")
+	case "missing":
+		return nil, errNotSubscribed.New("233200000000")
+	case "db":
+		return nil, errors.New("dial tcp 10.0.0.1:5432: connection refused")
+	case "deadline":
+		d, ok := ctx.Deadline()
+		if !ok {
+			return wrapperspb.String("none"), nil
+		}
+		return wrapperspb.String(time.Until(d).Round(time.Second).String()), nil
+	case "request_id":
+		logging.FromContext(ctx).Info("handling request")
+		return wrapperspb.String(RequestID(ctx)), nil
+	case "subject":
+		return wrapperspb.String(Claims(ctx)["sub"].(string)), nil
+	}
+	return in, nil
+}
+
+var testService = grpc.ServiceDesc{
+	ServiceName: "grpcx.test.Echo",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/grpcx.test.Echo/Echo"}
+			return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+				return echo(ctx, req.(*wrapperspb.StringValue))
+			})
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Repeat",
+		ServerStreams: true,
+		Handler: func(_ any, stream grpc.ServerStream) error {
+			in := new(wrapperspb.StringValue)
+			if err := stream.RecvMsg(in); err != nil {
+				return err
+			}
+			if in.Value == "panic" {
+				panic("boom")
+			}
+			for i := 0; i < 3; i++ {
+				if err := stream.SendMsg(wrapperspb.String(in.Value + ":" + RequestID(stream.Context()))); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}},
+}
+
+// start serves the test service over bufconn and returns a client connection
+func start(t *testing.T, cfg ServerConfig) (*Server, *grpc.ClientConn) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(cfg)
+	srv.RegisterService(&testService, nil)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := NewClient(ClientConfig{
+		Target:  "passthrough:///bufnet",
+		Timeout: 5 * time.Second,
+		Options: []grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		})},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+func call(ctx context.Context, conn *grpc.ClientConn, value string, opts ...grpc.CallOption) (string, error) {
+	out := new(wrapperspb.StringValue)
+	err := conn.Invoke(ctx, "/grpcx.test.Echo/Echo", wrapperspb.String(value), out, opts...)
+	return out.Value, err
+}
+
+func TestServerInterceptors(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	_, conn := start(t, ServerConfig{Logger: zap.New(core), DefaultTimeout: 10 * time.Second, MaxTimeout: time.Minute})
+	ctx := context.Background()
+
+	got, err := call(ctx, conn, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", got)
+
+	// Request IDs received, generated and sent back
+	var header metadata.MD
+	got, err = call(WithRequestID(ctx, "req-1"), conn, "request_id", grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, "req-1", got)
+	assert.Equal(t, []string{"req-1"}, header.Get(RequestIDKey))
+	got, err = call(ctx, conn, "request_id")
+	require.NoError(t, err)
+	assert.Len(t, got, 36)
+
+	// Errors
+	_, err = call(ctx, conn, "missing")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.True(t, apperrors.Is(err, errNotSubscribed), "error of the registry on the client")
+	assert.ErrorContains(t, err, "233200000000 not subscribed")
+
+	_, err = call(ctx, conn, "db")
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NotContains(t, err.Error(), "10.0.0.1")
+
+	_, err = call(ctx, conn, "panic")
+	assert.Equal(t, codes.Internal, status.Code(err))
+	got, err = call(ctx, conn, "hello")
+	require.NoError(t, err, "server still serving")
+
+	// Deadlines: the default of the client, capped by the server
+	got, err = call(ctx, conn, "deadline")
+	require.NoError(t, err)
+	assert.Equal(t, "5s", got)
+	long, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	got, err = call(long, conn, "deadline")
+	require.NoError(t, err)
+	assert.Equal(t, "1m0s", got)
+
+	assert.NotEmpty(t, logs.FilterMessage("grpc handler panicked").All())
+	assert.NotEmpty(t, logs.FilterMessage("grpc handler failed").FilterField(zap.String("grpc.method", "/grpcx.test.Echo/Echo")).All())
+	calls := logs.FilterMessage("grpc call").FilterField(zap.String("request_id", "req-1")).All()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "OK", calls[0].ContextMap()["grpc.code"])
+	assert.Len(t, logs.FilterMessage("handling request").FilterField(zap.String("request_id", "req-1")).All(), 1, "logger of the context")
+}
+
+func TestStreams(t *testing.T) {
+	_, conn := start(t, ServerConfig{})
+	desc := &testService.Streams[0]
+
+	stream, err := conn.NewStream(WithRequestID(context.Background(), "req-2"), desc, "/grpcx.test.Echo/Repeat")
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(wrapperspb.String("x")))
+	require.NoError(t, stream.CloseSend())
+	var got []string
+	for {
+		out := new(wrapperspb.StringValue)
+		if err := stream.RecvMsg(out); err != nil {
+			break
+		}
+		got = append(got, out.Value)
+	}
+	assert.Equal(t, []string{"x:req-2", "x:req-2", "x:req-2"}, got)
+
+	stream, err = conn.NewStream(context.Background(), desc, "/grpcx.test.Echo/Repeat")
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(wrapperspb.String("panic")))
+	require.NoError(t, stream.CloseSend())
+	err = stream.RecvMsg(new(wrapperspb.StringValue))
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestAuth(t *testing.T) {
+	_, conn := start(t, ServerConfig{Auth: &AuthConfig{KeyFunc: middleware.HMACKeyFunc(secret), Public: []string{"/grpcx.test.Public/"}}})
+	ctx := context.Background()
+
+	_, err := call(ctx, conn, "subject")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-42", "exp": time.Now().Add(time.Minute).Unix()}).SignedString(secret)
+	require.NoError(t, err)
+	got, err := call(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token), conn, "subject")
+	require.NoError(t, err)
+	assert.Equal(t, "user-42", got)
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-42", "exp": time.Now().Add(-time.Minute).Unix()}).SignedString(secret)
+	require.NoError(t, err)
+	_, err = call(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+expired), conn, "subject")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// The health service is public
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestShutdown(t *testing.T) {
+	srv, conn := start(t, ServerConfig{})
+	health := healthpb.NewHealthClient(conn)
+	srv.Health().SetServingStatus("grpcx.test.Echo", healthpb.HealthCheckResponse_SERVING)
+	resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "grpcx.test.Echo"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+	_, err = call(context.Background(), conn, "hello")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
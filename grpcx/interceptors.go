@@ -0,0 +1,288 @@
+package grpcx
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	apperrors "github.com/seidu626/go-buildingblocks/errors"
+	"github.com/seidu626/go-buildingblocks/logging"
+	"github.com/seidu626/go-buildingblocks/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key of the request IDs.
+const RequestIDKey = "x-request-id"
+
+type requestIDKey struct{}
+
+type claimsKey struct{}
+
+// WithRequestID returns a context carrying a request ID, sent by the client connections.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of the call handled with ctx, "" if none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Claims returns the claims of the JWT of the call handled with ctx, nil if none.
+func Claims(ctx context.Context) jwt.MapClaims {
+	claims, _ := ctx.Value(claimsKey{}).(jwt.MapClaims)
+	return claims
+}
+
+// serverStream is a grpc.ServerStream with the context of the interceptors
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// wrapStream returns the stream with the context ctx
+func wrapStream(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	if ctx == ss.Context() {
+		return ss
+	}
+	return &serverStream{ServerStream: ss, ctx: ctx}
+}
+
+// requestID returns the context of the call with its request ID, received or generated, and
+// sends the ID back in the headers. The ID is a request-scoped field of the loggers, see
+// logging.WithFields.
+func requestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" || len(id) > 128 {
+		id = uuid.NewString()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+	return logging.WithFields(WithRequestID(ctx, id), zap.String("request_id", id))
+}
+
+// UnaryRequestID reads the request ID of the calls, or generates one.
+func UnaryRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(requestID(ctx), req)
+	}
+}
+
+// StreamRequestID reads the request ID of the streams, or generates one.
+func StreamRequestID() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, wrapStream(ss, requestID(ss.Context())))
+	}
+}
+
+// logCall logs a call at a level depending on its code
+func logCall(logger *zap.Logger, ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := zapcore.InfoLevel
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unimplemented:
+		level = zapcore.ErrorLevel
+	default:
+		level = zapcore.WarnLevel
+	}
+	ce := logger.Check(level, "grpc call")
+	if ce == nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("grpc.method", method),
+		zap.String("grpc.code", code.String()),
+		zap.Duration("duration", time.Since(start)),
+		zap.String("request_id", RequestID(ctx)),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+}
+
+// handlerContext returns the context of a handler, carrying logger with the request-scoped
+// fields, see logging.FromContext
+func handlerContext(logger *zap.Logger, ctx context.Context) context.Context {
+	return logging.WithContext(ctx, logger.With(logging.Fields(ctx)...))
+}
+
+// UnaryLogging logs the calls with their code, duration and request ID, and passes logger to
+// the handlers, see logging.FromContext.
+func UnaryLogging(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(handlerContext(logger, ctx), req)
+		logCall(logger, ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamLogging logs the streams with their code, duration and request ID, and passes logger
+// to the handlers as UnaryLogging.
+func StreamLogging(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, wrapStream(ss, handlerContext(logger, ss.Context())))
+		logCall(logger, ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+// UnaryErrors returns the status of the errors of the handlers, see errors.ToStatus: the
+// *errors.Error keep their code and details, the unexpected errors are hidden from the
+// clients as Internal.
+func UnaryErrors(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		return resp, toStatusError(logger, ctx, info.FullMethod, err)
+	}
+}
+
+// StreamErrors returns the status of the errors of the stream handlers, as UnaryErrors.
+func StreamErrors(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return toStatusError(logger, ss.Context(), info.FullMethod, handler(srv, ss))
+	}
+}
+
+func toStatusError(logger *zap.Logger, ctx context.Context, method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	st := apperrors.ToStatus(err)
+	if st.Code() == codes.Internal {
+		if _, ok := status.FromError(err); !ok {
+			// The message of the status hides the error
+			logger.Error("grpc handler failed", zap.String("grpc.method", method), zap.String("request_id", RequestID(ctx)), zap.Error(err))
+		}
+	}
+	return st.Err()
+}
+
+// recovered returns the error of a panic
+func recovered(logger *zap.Logger, ctx context.Context, method string, r any) error {
+	logger.Error("grpc handler panicked",
+		zap.String("grpc.method", method),
+		zap.String("request_id", RequestID(ctx)),
+		zap.String("panic", fmt.Sprint(r)),
+		zap.ByteString("stack", debug.Stack()))
+	return status.Error(codes.Internal, "Internal Server Error")
+}
+
+// UnaryRecovery turns the panics of the handlers into Internal errors.
+func UnaryRecovery(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(logger, ctx, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecovery turns the panics of the stream handlers into Internal errors.
+func StreamRecovery(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(logger, ss.Context(), info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// deadline applies the default deadline to the calls without one and caps the others
+func deadline(ctx context.Context, defaultTimeout, maxTimeout time.Duration) (context.Context, context.CancelFunc) {
+	d, ok := ctx.Deadline()
+	switch {
+	case !ok && defaultTimeout > 0:
+		return context.WithTimeout(ctx, defaultTimeout)
+	case maxTimeout > 0 && (!ok || time.Until(d) > maxTimeout):
+		return context.WithTimeout(ctx, maxTimeout)
+	}
+	return ctx, func() {}
+}
+
+// UnaryDeadline bounds the calls by the default timeout when they have no deadline, and by
+// the max timeout, zero for none.
+func UnaryDeadline(defaultTimeout, maxTimeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := deadline(ctx, defaultTimeout, maxTimeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamDeadline bounds the streams as UnaryDeadline.
+func StreamDeadline(defaultTimeout, maxTimeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := deadline(ss.Context(), defaultTimeout, maxTimeout)
+		defer cancel()
+		return handler(srv, wrapStream(ss, ctx))
+	}
+}
+
+// authenticate returns the context of a call with the claims of its token
+func authenticate(ctx context.Context, cfg *AuthConfig, method string) (context.Context, error) {
+	if cfg.public(method) {
+		return ctx, nil
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = values[0]
+		}
+	}
+	claims, err := middleware.ParseJWT(token, cfg.KeyFunc)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+// UnaryAuth validates the JWT of the authorization metadata of the calls, see Claims.
+func UnaryAuth(cfg AuthConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, &cfg, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuth validates the JWT of the streams as UnaryAuth.
+func StreamAuth(cfg AuthConfig) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), &cfg, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, wrapStream(ss, ctx))
+	}
+}
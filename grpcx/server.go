@@ -0,0 +1,96 @@
+package grpcx
+
+import (
+	"context"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Server is a gRPC server serving the health service.
+type Server struct {
+	*grpc.Server
+	cfg    ServerConfig
+	health *health.Server
+}
+
+// NewServer creates a Server. Its interceptors handle, in order: the request IDs, the logging,
+// the errors, the panics, the deadlines and the authentication when configured.
+func NewServer(cfg ServerConfig) *Server {
+	cfg.setDefaults()
+	unary := []grpc.UnaryServerInterceptor{
+		UnaryRequestID(),
+		UnaryLogging(cfg.Logger),
+		UnaryErrors(cfg.Logger),
+		UnaryRecovery(cfg.Logger),
+		UnaryDeadline(cfg.DefaultTimeout, cfg.MaxTimeout),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		StreamRequestID(),
+		StreamLogging(cfg.Logger),
+		StreamErrors(cfg.Logger),
+		StreamRecovery(cfg.Logger),
+		StreamDeadline(cfg.DefaultTimeout, cfg.MaxTimeout),
+	}
+	if cfg.Auth != nil {
+		unary = append(unary, UnaryAuth(*cfg.Auth))
+		stream = append(stream, StreamAuth(*cfg.Auth))
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(append(unary, cfg.UnaryInterceptors...)...),
+		grpc.ChainStreamInterceptor(append(stream, cfg.StreamInterceptors...)...),
+		grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize),
+	}
+	if cfg.TLSConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg.TLSConfig)))
+	}
+	s := &Server{Server: grpc.NewServer(append(opts, cfg.Options...)...), cfg: cfg, health: health.NewServer()}
+	healthpb.RegisterHealthServer(s.Server, s.health)
+	return s
+}
+
+// Health returns the health service, e.g. to set the status of a service while a dependency
+// is down.
+func (s *Server) Health() *health.Server {
+	return s.health
+}
+
+// ListenAndServe listens on the address of the configuration and serves.
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Serve serves the connections of lis.
+func (s *Server) Serve(lis net.Listener) error {
+	s.cfg.Logger.Info("grpc server listening", zap.String("addr", lis.Addr().String()))
+	return s.Server.Serve(lis)
+}
+
+// Shutdown reports the services as not serving and stops the server gracefully, waiting for
+// the pending calls until ctx is done, then closing the connections. It is a
+// graceful.Operation.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
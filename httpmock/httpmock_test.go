@@ -0,0 +1,183 @@
+package httpmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeT records the errors of the assertions
+type fakeT struct {
+	mu     sync.Mutex
+	errors []string
+}
+
+func (t *fakeT) Helper()        {}
+func (t *fakeT) Cleanup(func()) {}
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestServerRoutes(t *testing.T) {
+	srv := NewServer(t)
+	charges := srv.On(http.MethodPost, "/v1/charges").
+		MatchHeader("Authorization", "Bearer token").
+		MatchJSON(`{"msisdn": "233200000000", "amount": 100}`).
+		Respond(Status(http.StatusServiceUnavailable), JSON(http.StatusCreated, map[string]string{"id": "ch_1"}).WithHeader("X-Request-Id", "42")).
+		Times(2)
+	srv.On(http.MethodGet, "/v1/charges/*").MatchQuery("expand", "customer").Respond(Text(http.StatusOK, "charge"))
+
+	c := requests.NewClient(requests.Config{BaseURL: srv.URL, Retry: requests.RetryPolicy{InitialBackoff: time.Millisecond}})
+	ctx := context.Background()
+	resp, err := c.Post("/v1/charges").BearerToken("token").IdempotencyKey("1").
+		JSON(map[string]any{"amount": 100, "msisdn": "233200000000"}).Do(ctx)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "retried after the 503")
+	assert.Equal(t, "42", resp.Header.Get("X-Request-Id"))
+	assert.JSONEq(t, `{"id":"ch_1"}`, string(body))
+
+	calls := charges.Calls()
+	require.Len(t, calls, 2)
+	var sent struct{ Amount int }
+	require.NoError(t, calls[1].DecodeJSON(&sent))
+	assert.Equal(t, 100, sent.Amount)
+
+	body, err = requests.ReadBody(c.Get("/v1/charges/ch_1?expand=customer").Do(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, "charge", string(body))
+	assert.True(t, srv.AssertExpectations(t))
+
+	// Already called twice
+	_, err = requests.ReadBody(c.Post("/v1/charges").BearerToken("token").JSON(map[string]any{"amount": 100, "msisdn": "233200000000"}).Do(ctx))
+	assert.ErrorContains(t, err, "already called 2 times")
+	_, err = requests.ReadBody(c.Get("/v1/charges/ch_1").Do(ctx))
+	assert.ErrorContains(t, err, "query expand=customer not matched")
+	assert.Len(t, srv.Requests(), 5)
+
+	ft := &fakeT{}
+	assert.False(t, srv.AssertExpectations(ft))
+	assert.Equal(t, []string{"httpmock: unexpected request POST /v1/charges", "httpmock: unexpected request GET /v1/charges/ch_1"}, ft.errors)
+
+	srv.Reset()
+	srv.On("", "/ping").Once()
+	ft = &fakeT{}
+	assert.False(t, srv.AssertExpectations(ft))
+	assert.Equal(t, []string{"httpmock: * /ping called 0 times, expected 1"}, ft.errors)
+}
+
+func TestServerRouteChangedWhileServing(t *testing.T) {
+	srv := NewServer(t)
+	route := srv.On(http.MethodGet, "/status")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				resp, err := http.Get(srv.URL + "/status")
+				if assert.NoError(t, err) {
+					resp.Body.Close()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		route.Respond(Status(http.StatusAccepted)).Match("any request", func(*Request) bool { return true }).Times(-1)
+	}
+	wg.Wait()
+}
+
+func TestServerFaults(t *testing.T) {
+	srv := NewServer(t)
+	srv.On(http.MethodGet, "/reset").Respond(Error(FaultReset))
+	srv.On(http.MethodGet, "/hang").Respond(Error(FaultHang))
+	srv.On(http.MethodGet, "/truncate").Respond(Response{Status: http.StatusOK, Body: []byte("0123456789"), Fault: FaultTruncate})
+	srv.On(http.MethodGet, "/slow").Respond(Status(http.StatusNoContent).WithDelay(50 * time.Millisecond))
+
+	_, err := http.Get(srv.URL + "/reset")
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/hang", nil)
+	_, err = http.DefaultClient.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	resp, err := http.Get(srv.URL + "/truncate")
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	start := time.Now()
+	resp, err = http.Get(srv.URL + "/slow")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestRecorder(t *testing.T) {
+	srv := NewServer(t)
+	srv.On(http.MethodPost, "/v1/subscriptions").Respond(JSON(http.StatusCreated, `{"id":"sub_1"}`), JSON(http.StatusConflict, `{"error":"exists"}`))
+	srv.On(http.MethodGet, "/v1/logo").Respond(Response{Status: http.StatusOK, Body: []byte{0xff, 0xd8, 0xff}})
+
+	path := filepath.Join(t.TempDir(), "testdata", "partner.json")
+	send := func(r *Recorder) []string {
+		c := requests.NewClient(requests.Config{BaseURL: srv.URL, Transport: r})
+		var got []string
+		for i := 0; i < 2; i++ {
+			resp, err := c.Post("/v1/subscriptions").BearerToken("secret").JSON(map[string]string{"msisdn": "233200000000"}).Do(context.Background())
+			require.NoError(t, err)
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			got = append(got, fmt.Sprintf("%d %s", resp.StatusCode, body))
+		}
+		logo, err := requests.ReadBody(c.Get("/v1/logo").Do(context.Background()))
+		require.NoError(t, err)
+		return append(got, fmt.Sprintf("%x", logo))
+	}
+
+	rec, err := NewRecorder(RecorderConfig{Path: path})
+	require.NoError(t, err)
+	assert.True(t, rec.Recording(), "no cassette yet")
+	want := send(rec)
+	assert.Equal(t, []string{`201 {"id":"sub_1"}`, `409 {"error":"exists"}`, "ffd8ff"}, want)
+	require.NoError(t, rec.Save())
+	cassette, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(cassette), "secret")
+	assert.Contains(t, string(cassette), `"base64": "/9j/"`)
+
+	// Offline
+	srv.Close()
+	rec, err = NewRecorder(RecorderConfig{Path: path})
+	require.NoError(t, err)
+	assert.False(t, rec.Recording())
+	assert.Equal(t, want, send(rec))
+	assert.Empty(t, rec.Unused())
+
+	_, err = rec.Client().Get(srv.URL + "/v1/other")
+	assert.True(t, errors.Is(err, ErrNoInteraction), err)
+
+	_, err = NewRecorder(RecorderConfig{Path: filepath.Join(t.TempDir(), "missing.json"), Mode: ModeReplay})
+	assert.Error(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	_, err = NewRecorder(RecorderConfig{Path: path})
+	assert.ErrorContains(t, err, "decoding cassette")
+}
@@ -0,0 +1,311 @@
+package httpmock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNoInteraction is returned by a replaying Recorder for a request not in its cassette.
+var ErrNoInteraction = errors.New("httpmock: no recorded interaction")
+
+// ModeEnv is the environment variable overriding the Mode of the recorders, "record" or
+// "replay", e.g. to refresh the cassettes against the real APIs.
+const ModeEnv = "HTTPMOCK_MODE"
+
+// Mode is whether a Recorder records or replays.
+type Mode int
+
+const (
+	// ModeAuto replays the cassette when it exists, otherwise records it
+	ModeAuto Mode = iota
+	// ModeRecord sends the requests and records them, replacing the cassette
+	ModeRecord
+	// ModeReplay replays the cassette, failing the requests not recorded
+	ModeReplay
+)
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded response.
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Body is a recorded body, saved as a string when it is text, base64 otherwise.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	*b = decoded
+	return err
+}
+
+// Cassette is the file of the interactions of a Recorder.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// RecorderConfig holds the configuration of a Recorder.
+type RecorderConfig struct {
+	// Path of the cassette, conventionally under testdata
+	Path string
+	Mode Mode
+	// Base sends the requests while recording (default http.DefaultTransport)
+	Base http.RoundTripper
+	// RedactHeaders are replaced by "REDACTED" in the cassette, default Authorization, Cookie,
+	// Set-Cookie, X-API-Key and Proxy-Authorization
+	RedactHeaders []string
+	// BeforeSave, optional, edits an interaction when it is recorded, e.g. to mask an identifier
+	BeforeSave func(*Interaction)
+	// Matcher reports whether a request matches a recorded one, default the same method, URL
+	// and body
+	Matcher func(req *http.Request, body []byte, recorded *RecordedRequest) bool
+}
+
+func (c *RecorderConfig) setDefaults() {
+	if c.Base == nil {
+		c.Base = http.DefaultTransport
+	}
+	if c.RedactHeaders == nil {
+		c.RedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-API-Key", "Proxy-Authorization"}
+	}
+	if c.Matcher == nil {
+		c.Matcher = DefaultMatcher
+	}
+	switch os.Getenv(ModeEnv) {
+	case "record":
+		c.Mode = ModeRecord
+	case "replay":
+		c.Mode = ModeReplay
+	}
+}
+
+// DefaultMatcher matches the requests of same method, URL and body.
+func DefaultMatcher(req *http.Request, body []byte, recorded *RecordedRequest) bool {
+	return req.Method == recorded.Method && req.URL.String() == recorded.URL && bytes.Equal(body, recorded.Body)
+}
+
+// Recorder is an http.RoundTripper recording the interactions with an API into a cassette, or
+// replaying them. A recorded interaction is replayed once, in the order of the recording, so
+// that the same request can have successive responses.
+type Recorder struct {
+	cfg       RecorderConfig
+	recording bool
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder creates a Recorder, loading its cassette when replaying.
+func NewRecorder(cfg RecorderConfig) (*Recorder, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("httpmock: cassette path required")
+	}
+	cfg.setDefaults()
+	r := &Recorder{cfg: cfg, recording: cfg.Mode == ModeRecord}
+
+	data, err := os.ReadFile(cfg.Path)
+	switch {
+	case r.recording:
+	case errors.Is(err, os.ErrNotExist) && cfg.Mode == ModeAuto:
+		r.recording = true
+	case err != nil:
+		return nil, fmt.Errorf("httpmock: reading cassette: %w", err)
+	default:
+		if err = json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("httpmock: decoding cassette %s: %w", cfg.Path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// NewTestRecorder creates a Recorder of the cassette testdata/<name>.json, saved at the end of
+// the test when recording.
+func NewTestRecorder(t interface {
+	TestingT
+	Name() string
+	Fatalf(format string, args ...any)
+}, mode Mode) *Recorder {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	r, err := NewRecorder(RecorderConfig{Path: filepath.Join("testdata", name+".json"), Mode: mode})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	t.Cleanup(func() {
+		if err := r.Save(); err != nil {
+			t.Errorf("%v", err)
+		}
+	})
+	return r
+}
+
+// Recording reports whether the recorder records.
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Client returns an http.Client using the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.recording {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || !r.cfg.Matcher(req, body, &in.Request) {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			StatusCode:    in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL.Redacted())
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := r.cfg.Base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := &Interaction{
+		Request:  RecordedRequest{Method: req.Method, URL: req.URL.String(), Header: r.redact(req.Header), Body: body},
+		Response: RecordedResponse{Status: resp.StatusCode, Header: r.redact(resp.Header), Body: respBody},
+	}
+	if r.cfg.BeforeSave != nil {
+		r.cfg.BeforeSave(in)
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) redact(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range r.cfg.RedactHeaders {
+		if _, ok := h[http.CanonicalHeaderKey(k)]; ok {
+			h.Set(k, "REDACTED")
+		}
+	}
+	return h
+}
+
+// Unused returns the recorded interactions not replayed, e.g. to detect that a test does not
+// send some requests any more.
+func (r *Recorder) Unused() []*Interaction {
+	if r.recording {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []*Interaction
+	for i, in := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+// Save writes the cassette when recording.
+func (r *Recorder) Save() error {
+	if !r.recording {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.cfg.Path), 0o755); err != nil {
+		return fmt.Errorf("httpmock: saving cassette: %w", err)
+	}
+	if err = os.WriteFile(r.cfg.Path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("httpmock: saving cassette: %w", err)
+	}
+	return nil
+}
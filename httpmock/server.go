@@ -0,0 +1,395 @@
+// Package httpmock helps testing the integrations with HTTP APIs: Server is a programmable
+// stub server, Recorder a transport recording the exchanges with a real API into a cassette
+// replayed by the next runs, offline.
+//
+//	srv := httpmock.NewServer(t)
+//	srv.On(http.MethodPost, "/v1/charges").
+//		MatchHeader("Authorization", "Bearer token").
+//		MatchJSON(`{"msisdn":"233200000000","amount":100}`).
+//		Respond(httpmock.Status(http.StatusServiceUnavailable), httpmock.JSON(http.StatusCreated, charge)).
+//		Times(2)
+//	client := requests.NewClient(requests.Config{BaseURL: srv.URL})
+//	...
+//	srv.AssertExpectations(t)
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TestingT is the subset of testing.TB used by the package.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Cleanup(func())
+}
+
+// Request is a request received by a Server.
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// DecodeJSON decodes the JSON body of the request into v.
+func (r *Request) DecodeJSON(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+func (r *Request) String() string {
+	return r.Method + " " + r.URL.RequestURI()
+}
+
+// Fault is a failure of the connection injected instead of a response.
+type Fault int
+
+const (
+	// NoFault sends the response
+	NoFault Fault = iota
+	// FaultReset closes the connection without response
+	FaultReset
+	// FaultHang responds only when the client gives up
+	FaultHang
+	// FaultTruncate sends the headers and half the body, then closes the connection
+	FaultTruncate
+)
+
+// Response is a response of a Route, built with JSON, Text, Status or Error.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Delay  time.Duration // Latency before the response
+	Fault  Fault
+}
+
+// Status returns an empty response of the given status.
+func Status(status int) Response {
+	return Response{Status: status, Header: http.Header{}}
+}
+
+// Text returns a text/plain response.
+func Text(status int, body string) Response {
+	return Response{Status: status, Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, Body: []byte(body)}
+}
+
+// JSON returns a response with the JSON encoding of v, a string or []byte being sent as is.
+func JSON(status int, v any) Response {
+	var body []byte
+	switch v := v.(type) {
+	case string:
+		body = []byte(v)
+	case []byte:
+		body = v
+	default:
+		var err error
+		if body, err = json.Marshal(v); err != nil {
+			panic(fmt.Sprintf("httpmock: encoding JSON response: %v", err))
+		}
+	}
+	return Response{Status: status, Header: http.Header{"Content-Type": {"application/json"}}, Body: body}
+}
+
+// Error returns a response failing the connection.
+func Error(fault Fault) Response {
+	return Response{Fault: fault}
+}
+
+// WithHeader returns the response with a header set.
+func (r Response) WithHeader(key, value string) Response {
+	h := r.Header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set(key, value)
+	r.Header = h
+	return r
+}
+
+// WithDelay returns the response sent after a delay.
+func (r Response) WithDelay(d time.Duration) Response {
+	r.Delay = d
+	return r
+}
+
+// Route responds to the requests it matches. It can be changed while the server is serving
+// requests, e.g. to change its responses.
+type Route struct {
+	srv       *Server
+	method    string
+	pattern   string
+	matchers  []func(*Request) (bool, string)
+	responses []Response
+	times     int // Expected calls, -1 for any
+	calls     []*Request
+}
+
+// On adds a route for the requests of method, "" for any, whose path matches pattern, in the
+// syntax of path.Match. The routes are matched in the order they are added, a route having
+// responded its expected times being skipped. It responds 200 until Respond is called.
+func (s *Server) On(method, pattern string) *Route {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &Route{srv: s, method: method, pattern: pattern, times: -1}
+	s.routes = append(s.routes, r)
+	return r
+}
+
+// Match adds a custom matcher.
+func (r *Route) Match(desc string, match func(*Request) bool) *Route {
+	r.srv.mu.Lock()
+	defer r.srv.mu.Unlock()
+	r.matchers = append(r.matchers, func(req *Request) (bool, string) {
+		return match(req), desc
+	})
+	return r
+}
+
+// MatchHeader matches the requests having a header value.
+func (r *Route) MatchHeader(key, value string) *Route {
+	return r.Match(fmt.Sprintf("header %s: %s", key, value), func(req *Request) bool {
+		for _, v := range req.Header.Values(key) {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// MatchQuery matches the requests having a query parameter value.
+func (r *Route) MatchQuery(key, value string) *Route {
+	return r.Match(fmt.Sprintf("query %s=%s", key, value), func(req *Request) bool {
+		for _, v := range req.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// MatchJSON matches the requests whose JSON body equals v, a string or []byte being JSON,
+// regardless of the formatting and the order of the keys.
+func (r *Route) MatchJSON(v any) *Route {
+	var data []byte
+	switch v := v.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			panic(fmt.Sprintf("httpmock: encoding JSON matcher: %v", err))
+		}
+	}
+	var want any
+	if err := json.Unmarshal(data, &want); err != nil {
+		panic(fmt.Sprintf("httpmock: invalid JSON matcher: %v", err))
+	}
+	return r.Match("JSON body "+string(data), func(req *Request) bool {
+		var got any
+		return json.Unmarshal(req.Body, &got) == nil && reflect.DeepEqual(got, want)
+	})
+}
+
+// MatchBody matches the requests whose body contains s.
+func (r *Route) MatchBody(s string) *Route {
+	return r.Match("body containing "+s, func(req *Request) bool {
+		return bytes.Contains(req.Body, []byte(s))
+	})
+}
+
+// Respond sets the responses to the successive requests, the last one being repeated.
+func (r *Route) Respond(responses ...Response) *Route {
+	r.srv.mu.Lock()
+	defer r.srv.mu.Unlock()
+	r.responses = responses
+	return r
+}
+
+// Times sets the number of requests expected by the route, checked by AssertExpectations.
+// Beyond, the route does not match any more.
+func (r *Route) Times(n int) *Route {
+	r.srv.mu.Lock()
+	defer r.srv.mu.Unlock()
+	r.times = n
+	return r
+}
+
+// Once is Times(1).
+func (r *Route) Once() *Route { return r.Times(1) }
+
+// Calls returns the requests the route responded to.
+func (r *Route) Calls() []*Request {
+	r.srv.mu.Lock()
+	defer r.srv.mu.Unlock()
+	return append([]*Request(nil), r.calls...)
+}
+
+func (r *Route) String() string {
+	method := r.method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + r.pattern
+}
+
+// match returns whether the route matches req, or why not, with srv.mu held
+func (r *Route) match(req *Request) (bool, string) {
+	if r.method != "" && r.method != req.Method {
+		return false, "method " + r.method
+	}
+	if ok, _ := path.Match(r.pattern, req.URL.Path); !ok {
+		return false, "path " + r.pattern
+	}
+	for _, m := range r.matchers {
+		if ok, desc := m(req); !ok {
+			return false, desc
+		}
+	}
+	return true, ""
+}
+
+// Server is a stub HTTP server.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	routes    []*Route
+	requests  []*Request
+	unmatched []*Request
+}
+
+// NewServer starts a Server, closed at the end of the test.
+func NewServer(t TestingT) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the requests received by the server.
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// Reset removes the routes and the requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes, s.requests, s.unmatched = nil, nil, nil
+}
+
+// AssertExpectations reports the requests no route matched and the routes not called the
+// expected times.
+func (s *Server) AssertExpectations(t TestingT) bool {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok := true
+	for _, req := range s.unmatched {
+		t.Errorf("httpmock: unexpected request %s", req)
+		ok = false
+	}
+	for _, r := range s.routes {
+		if r.times >= 0 && len(r.calls) != r.times {
+			t.Errorf("httpmock: %s called %d times, expected %d", r, len(r.calls), r.times)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := &Request{Method: r.Method, URL: r.URL, Header: r.Header.Clone(), Body: body}
+
+	resp, found, reasons := s.route(req)
+	if !found {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusNotImplemented)
+		_, _ = fmt.Fprintf(w, "httpmock: no route for %s\n%s", req, strings.Join(reasons, "\n"))
+		return
+	}
+
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	switch resp.Fault {
+	case FaultReset:
+		if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+			_ = conn.Close()
+		}
+		return
+	case FaultHang:
+		<-r.Context().Done()
+		return
+	}
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if resp.Fault == FaultTruncate {
+		// Announces the whole body
+		w.Header().Set("Content-Length", fmt.Sprint(len(resp.Body)))
+		w.WriteHeader(status)
+		_, _ = w.Write(resp.Body[:len(resp.Body)/2])
+		if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+			_ = conn.Close()
+		}
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(resp.Body)
+}
+
+// route records req and returns the response of the first route matching it, otherwise why
+// the routes do not match
+func (s *Server) route(req *Request) (Response, bool, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	var reasons []string
+	for _, r := range s.routes {
+		if r.times >= 0 && len(r.calls) >= r.times {
+			reasons = append(reasons, fmt.Sprintf("%s: already called %d times", r, r.times))
+			continue
+		}
+		ok, reason := r.match(req)
+		if !ok {
+			reasons = append(reasons, fmt.Sprintf("%s: %s not matched", r, reason))
+			continue
+		}
+		r.calls = append(r.calls, req)
+		if len(r.responses) == 0 {
+			return Status(http.StatusOK), true, nil
+		}
+		return r.responses[min(len(r.calls), len(r.responses))-1], true, nil
+	}
+	s.unmatched = append(s.unmatched, req)
+	return Response{}, false, reasons
+}
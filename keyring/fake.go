@@ -0,0 +1,77 @@
+package keyring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/seidu626/go-buildingblocks/crypt"
+)
+
+// FakeProvider is an in-memory KeyProvider for tests, counting its calls. Its master keys are
+// random, the wrapped keys do not outlive it.
+type FakeProvider struct {
+	mu       sync.Mutex
+	keys     []crypt.Key
+	provider *LocalProvider
+
+	Generated int // Calls of GenerateDataKey
+	Wrapped   int // Calls of WrapKey
+	Unwrapped int // Calls of UnwrapKey
+	// Err, when set, is returned by all the calls
+	Err error
+}
+
+var _ KeyProvider = (*FakeProvider)(nil)
+
+// NewFakeProvider creates a FakeProvider with a master key "fake-1".
+func NewFakeProvider() *FakeProvider {
+	p := &FakeProvider{}
+	p.Rotate()
+	return p
+}
+
+// Rotate makes a new master key current, returning its ID. The former keys still unwrap.
+func (p *FakeProvider) Rotate() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, err := crypt.NewKey(fmt.Sprintf("fake-%d", len(p.keys)+1), crypt.AESGCM)
+	if err != nil {
+		panic(err)
+	}
+	p.keys = append([]crypt.Key{key}, p.keys...)
+	if p.provider, err = NewLocalProvider(p.keys[0], p.keys[1:]...); err != nil {
+		panic(err)
+	}
+	return key.ID
+}
+
+func (p *FakeProvider) GenerateDataKey(ctx context.Context) ([]byte, WrappedKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Generated++
+	if p.Err != nil {
+		return nil, WrappedKey{}, p.Err
+	}
+	return p.provider.GenerateDataKey(ctx)
+}
+
+func (p *FakeProvider) WrapKey(ctx context.Context, plaintext []byte) (WrappedKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Wrapped++
+	if p.Err != nil {
+		return WrappedKey{}, p.Err
+	}
+	return p.provider.WrapKey(ctx, plaintext)
+}
+
+func (p *FakeProvider) UnwrapKey(ctx context.Context, wrapped WrappedKey) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Unwrapped++
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return p.provider.UnwrapKey(ctx, wrapped)
+}
@@ -0,0 +1,438 @@
+// Package keyring implements envelope encryption: payloads are encrypted with data keys, which
+// are stored wrapped (encrypted) by a master key held by a KeyProvider, such as AWS KMS or
+// local keys. Rotating the master key only re-wraps the data keys, the payloads are not
+// encrypted again.
+//
+//	kr, err := keyring.New(keyring.Config{Provider: keyring.NewKMSProvider(kmsClient, "alias/app")})
+//	...
+//	envelope, err := kr.Seal(ctx, []byte(secret), []byte("user:42"))
+//	plaintext, err := kr.Open(ctx, envelope, []byte("user:42"))
+package keyring
+
+import (
+	"container/list"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/crypt"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// DataKeySize is the size of the data keys.
+const DataKeySize = 32
+
+const envelopeVersion byte = 1
+
+var (
+	// ErrMalformed is returned for the envelopes and wrapped keys that were not produced by this package
+	ErrMalformed = errors.New("keyring: malformed envelope")
+	// ErrUnknownMasterKey is returned when the master key of a wrapped key is not held by the provider
+	ErrUnknownMasterKey = errors.New("keyring: unknown master key")
+)
+
+// KeyProvider wraps and unwraps data keys with master keys that never leave it.
+type KeyProvider interface {
+	// GenerateDataKey returns a new random data key of DataKeySize bytes, in plaintext and
+	// wrapped by the current master key
+	GenerateDataKey(ctx context.Context) ([]byte, WrappedKey, error)
+	// WrapKey wraps a data key by the current master key
+	WrapKey(ctx context.Context, plaintext []byte) (WrappedKey, error)
+	// UnwrapKey unwraps a data key wrapped by any of the master keys of the provider
+	UnwrapKey(ctx context.Context, wrapped WrappedKey) ([]byte, error)
+}
+
+// WrappedKey is a data key encrypted by a master key.
+type WrappedKey struct {
+	MasterKeyID string
+	Ciphertext  []byte
+}
+
+// MarshalBinary encodes the wrapped key to be stored along with its payload.
+func (w WrappedKey) MarshalBinary() ([]byte, error) {
+	if len(w.MasterKeyID) > 0xffff || len(w.Ciphertext) > 0xffff {
+		return nil, fmt.Errorf("%w: wrapped key too long", ErrMalformed)
+	}
+	b := make([]byte, 0, 4+len(w.MasterKeyID)+len(w.Ciphertext))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(w.MasterKeyID)))
+	b = append(b, w.MasterKeyID...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(w.Ciphertext)))
+	return append(b, w.Ciphertext...), nil
+}
+
+// UnmarshalBinary decodes a wrapped key of MarshalBinary.
+func (w *WrappedKey) UnmarshalBinary(data []byte) error {
+	n, err := w.decode(data)
+	if err == nil && n != len(data) {
+		err = fmt.Errorf("%w: trailing data", ErrMalformed)
+	}
+	return err
+}
+
+// decode decodes the wrapped key at the start of data, returning its length
+func (w *WrappedKey) decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, ErrMalformed
+	}
+	idLen := int(binary.BigEndian.Uint16(data))
+	if len(data) < 4+idLen {
+		return 0, ErrMalformed
+	}
+	ctLen := int(binary.BigEndian.Uint16(data[2+idLen:]))
+	end := 4 + idLen + ctLen
+	if len(data) < end {
+		return 0, ErrMalformed
+	}
+	w.MasterKeyID = string(data[2 : 2+idLen])
+	w.Ciphertext = append([]byte(nil), data[4+idLen:end]...)
+	return end, nil
+}
+
+// String encodes the wrapped key in base64, e.g. for object metadata.
+func (w WrappedKey) String() string {
+	b, err := w.MarshalBinary()
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseWrappedKey decodes a wrapped key of String.
+func ParseWrappedKey(s string) (WrappedKey, error) {
+	var w WrappedKey
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return w, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	return w, w.UnmarshalBinary(b)
+}
+
+// Config holds the configuration of a Keyring.
+type Config struct {
+	Provider KeyProvider
+	// Algorithm of the payloads (default crypt.XChaCha20Poly1305, whose random nonces allow
+	// reusing the data keys without limit)
+	Algorithm crypt.Algorithm
+	// DataKeyTTL is how long a data key is reused to encrypt, saving calls to the provider
+	// (default 5m), negative to generate a key per payload
+	DataKeyTTL time.Duration
+	// MaxKeyUses is the number of payloads encrypted with a data key before generating
+	// another (default 1<<20)
+	MaxKeyUses int
+	// CacheSize is the number of unwrapped data keys kept to decrypt (default 1000)
+	CacheSize int
+	// CacheTTL is how long an unwrapped data key is kept (default 1h)
+	CacheTTL time.Duration
+	Logger   *zap.Logger
+}
+
+func (c *Config) setDefaults() {
+	if c.Algorithm == 0 {
+		c.Algorithm = crypt.XChaCha20Poly1305
+	}
+	if c.DataKeyTTL == 0 {
+		c.DataKeyTTL = 5 * time.Minute
+	}
+	if c.MaxKeyUses <= 0 {
+		c.MaxKeyUses = 1 << 20
+	}
+	if c.CacheSize <= 0 {
+		c.CacheSize = 1000
+	}
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = time.Hour
+	}
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+}
+
+// Keyring encrypts payloads with data keys of its provider. It is safe for concurrent use.
+type Keyring struct {
+	cfg   Config
+	group singleflight.Group // Generations of the data key of the encryptions
+
+	mu      sync.Mutex
+	current *dataKey // Data key of the encryptions
+	cache   map[string]*list.Element
+	lru     *list.List // Unwrapped data keys, most recently used first
+}
+
+type dataKey struct {
+	id      string // Binary wrapped key
+	wrapped WrappedKey
+	cipher  *crypt.Cipher
+	expires time.Time // End of the cache entry
+	retire  time.Time // End of the encryptions
+	uses    int
+}
+
+// New creates a Keyring.
+func New(cfg Config) (*Keyring, error) {
+	if cfg.Provider == nil {
+		return nil, errors.New("keyring: no provider")
+	}
+	cfg.setDefaults()
+	return &Keyring{cfg: cfg, cache: make(map[string]*list.Element), lru: list.New()}, nil
+}
+
+func (k *Keyring) newDataKey(plaintext []byte, wrapped WrappedKey) (*dataKey, error) {
+	id, err := wrapped.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	// The data key is identified by the envelope, the key ID of the payloads is their algorithm
+	// so that the payloads of either algorithm are decrypted once Config.Algorithm is changed
+	keys := make([]crypt.Key, 0, 2)
+	for _, alg := range []crypt.Algorithm{k.cfg.Algorithm, crypt.AESGCM, crypt.XChaCha20Poly1305} {
+		if alg != k.cfg.Algorithm || len(keys) == 0 {
+			keys = append(keys, crypt.Key{ID: strconv.Itoa(int(alg)), Algorithm: alg, Material: plaintext})
+		}
+	}
+	c, err := crypt.NewCipher(keys[0], keys[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return &dataKey{id: string(id), wrapped: wrapped, cipher: c, expires: time.Now().Add(k.cfg.CacheTTL)}, nil
+}
+
+// encryptionKey returns the current data key, generating one when needed. The provider is
+// called without k.mu held, once for the concurrent callers.
+func (k *Keyring) encryptionKey(ctx context.Context) (*dataKey, error) {
+	if k.cfg.DataKeyTTL < 0 {
+		return k.generateKey(ctx)
+	}
+	for {
+		if key := k.useCurrent(); key != nil {
+			return key, nil
+		}
+		ch := k.group.DoChan("current", func() (any, error) {
+			if k.currentValid() {
+				// Replaced by a generation that ended meanwhile
+				return nil, nil
+			}
+			// Not canceled with the first caller, the others may still wait for it
+			key, err := k.generateKey(context.WithoutCancel(ctx))
+			if err != nil {
+				return nil, err
+			}
+			key.uses = 0
+			key.retire = time.Now().Add(k.cfg.DataKeyTTL)
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			k.current = key
+			k.cache[key.id] = k.lru.PushFront(key)
+			k.evict()
+			return nil, nil
+		})
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case res := <-ch:
+			if res.Err != nil {
+				return nil, res.Err
+			}
+		}
+	}
+}
+
+// useCurrent returns the current data key counting a use, nil when it must be replaced
+func (k *Keyring) useCurrent() *dataKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.currentValidLocked() {
+		return nil
+	}
+	k.current.uses++
+	return k.current
+}
+
+func (k *Keyring) currentValid() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.currentValidLocked()
+}
+
+// currentValidLocked reports whether the current data key can still encrypt, with k.mu held
+func (k *Keyring) currentValidLocked() bool {
+	current := k.current
+	return current != nil && current.uses < k.cfg.MaxKeyUses && time.Now().Before(current.retire)
+}
+
+// generateKey returns a new data key of the provider, used once
+func (k *Keyring) generateKey(ctx context.Context) (*dataKey, error) {
+	plaintext, wrapped, err := k.cfg.Provider.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("keyring: generating data key: %w", err)
+	}
+	key, err := k.newDataKey(plaintext, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	key.uses = 1
+	k.cfg.Logger.Debug("generated data key", zap.String("master_key", wrapped.MasterKeyID))
+	return key, nil
+}
+
+// decryptionKey returns the data key of a wrapped key, from the cache or unwrapped
+func (k *Keyring) decryptionKey(ctx context.Context, wrapped WrappedKey) (*dataKey, error) {
+	id, err := wrapped.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	if e, ok := k.cache[string(id)]; ok {
+		key := e.Value.(*dataKey)
+		if time.Now().Before(key.expires) || key == k.current {
+			k.lru.MoveToFront(e)
+			k.mu.Unlock()
+			return key, nil
+		}
+		k.lru.Remove(e)
+		delete(k.cache, string(id))
+	}
+	k.mu.Unlock()
+
+	plaintext, err := k.cfg.Provider.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("keyring: unwrapping data key: %w", err)
+	}
+	key, err := k.newDataKey(plaintext, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if e, ok := k.cache[key.id]; ok {
+		// Unwrapped concurrently
+		return e.Value.(*dataKey), nil
+	}
+	k.cache[key.id] = k.lru.PushFront(key)
+	k.evict()
+	return key, nil
+}
+
+// evict removes the least recently used keys beyond the cache size, with k.mu held
+func (k *Keyring) evict() {
+	for k.lru.Len() > k.cfg.CacheSize {
+		e := k.lru.Back()
+		k.lru.Remove(e)
+		delete(k.cache, e.Value.(*dataKey).id)
+	}
+}
+
+// Seal encrypts plaintext into a self-contained envelope holding the wrapped data key, for a
+// column, a cache entry or a message. aad is authenticated and must be given again to Open.
+func (k *Keyring) Seal(ctx context.Context, plaintext, aad []byte) ([]byte, error) {
+	key, err := k.encryptionKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := key.cipher.Encrypt(plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	envelope := make([]byte, 0, 1+len(key.id)+len(payload))
+	envelope = append(envelope, envelopeVersion)
+	envelope = append(envelope, key.id...)
+	return append(envelope, payload...), nil
+}
+
+// parseEnvelope returns the wrapped key of an envelope and its payload
+func parseEnvelope(envelope []byte) (WrappedKey, []byte, error) {
+	var wrapped WrappedKey
+	if len(envelope) == 0 || envelope[0] != envelopeVersion {
+		return wrapped, nil, ErrMalformed
+	}
+	n, err := wrapped.decode(envelope[1:])
+	if err != nil {
+		return wrapped, nil, err
+	}
+	return wrapped, envelope[1+n:], nil
+}
+
+// Open decrypts an envelope of Seal.
+func (k *Keyring) Open(ctx context.Context, envelope, aad []byte) ([]byte, error) {
+	wrapped, payload, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+	key, err := k.decryptionKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return key.cipher.Decrypt(payload, aad)
+}
+
+// Rewrap returns the envelope with its data key wrapped by the current master key of the
+// provider, the payload is unchanged.
+func (k *Keyring) Rewrap(ctx context.Context, envelope []byte) ([]byte, error) {
+	wrapped, payload, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+	rewrapped, err := k.RewrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	id, err := rewrapped.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+len(id)+len(payload))
+	out = append(out, envelopeVersion)
+	out = append(out, id...)
+	return append(out, payload...), nil
+}
+
+// RewrapKey wraps a data key by the current master key of the provider.
+func (k *Keyring) RewrapKey(ctx context.Context, wrapped WrappedKey) (WrappedKey, error) {
+	plaintext, err := k.cfg.Provider.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return WrappedKey{}, fmt.Errorf("keyring: unwrapping data key: %w", err)
+	}
+	rewrapped, err := k.cfg.Provider.WrapKey(ctx, plaintext)
+	if err != nil {
+		return WrappedKey{}, fmt.Errorf("keyring: wrapping data key: %w", err)
+	}
+	return rewrapped, nil
+}
+
+// MasterKeyID returns the ID of the master key wrapping the data key of an envelope, e.g. to
+// find the envelopes to rewrap.
+func MasterKeyID(envelope []byte) (string, error) {
+	wrapped, _, err := parseEnvelope(envelope)
+	return wrapped.MasterKeyID, err
+}
+
+// EncryptWriter returns a writer encrypting a stream to w with the current data key, like Seal,
+// returned wrapped to be stored along with the stream, e.g. in the metadata of an object. Each
+// stream derives its own key from the data key and a random salt. Close must be called to write
+// the end of the stream, it does not close w.
+func (k *Keyring) EncryptWriter(ctx context.Context, w io.Writer, aad []byte) (io.WriteCloser, WrappedKey, error) {
+	key, err := k.encryptionKey(ctx)
+	if err != nil {
+		return nil, WrappedKey{}, err
+	}
+	ew, err := key.cipher.EncryptWriter(w, aad)
+	if err != nil {
+		return nil, WrappedKey{}, err
+	}
+	return ew, key.wrapped, nil
+}
+
+// DecryptReader returns a reader decrypting a stream of EncryptWriter.
+func (k *Keyring) DecryptReader(ctx context.Context, r io.Reader, wrapped WrappedKey, aad []byte) (io.Reader, error) {
+	key, err := k.decryptionKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return key.cipher.DecryptReader(r, aad)
+}
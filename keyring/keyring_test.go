@@ -0,0 +1,357 @@
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/seidu626/go-buildingblocks/crypt"
+	"github.com/seidu626/go-buildingblocks/rediskit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakeProvider()
+	kr, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+
+	var envelopes [][]byte
+	for i := 0; i < 10; i++ {
+		envelope, err := kr.Seal(ctx, []byte("msisdn=233200000001"), []byte("subscriber:42"))
+		require.NoError(t, err)
+		envelopes = append(envelopes, envelope)
+	}
+	assert.Equal(t, 1, provider.Generated, "data key reused")
+
+	for _, envelope := range envelopes {
+		plaintext, err := kr.Open(ctx, envelope, []byte("subscriber:42"))
+		require.NoError(t, err)
+		assert.Equal(t, "msisdn=233200000001", string(plaintext))
+	}
+	assert.Equal(t, 0, provider.Unwrapped, "data key cached")
+
+	_, err = kr.Open(ctx, envelopes[0], []byte("subscriber:43"))
+	assert.ErrorIs(t, err, crypt.ErrDecrypt)
+	for i := range envelopes[0] {
+		tampered := bytes.Clone(envelopes[0])
+		tampered[i] ^= 1
+		_, err = kr.Open(ctx, tampered, []byte("subscriber:42"))
+		assert.Error(t, err, "byte %d", i)
+	}
+
+	// Another keyring of the provider unwraps once
+	provider.Unwrapped = 0
+	other, err := New(Config{Provider: provider, Algorithm: crypt.AESGCM})
+	require.NoError(t, err)
+	for _, envelope := range envelopes {
+		plaintext, err := other.Open(ctx, envelope, []byte("subscriber:42"))
+		require.NoError(t, err)
+		assert.Equal(t, "msisdn=233200000001", string(plaintext))
+	}
+	assert.Equal(t, 1, provider.Unwrapped)
+}
+
+func TestDataKeyLimits(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakeProvider()
+	kr, err := New(Config{Provider: provider, MaxKeyUses: 3})
+	require.NoError(t, err)
+	for i := 0; i < 7; i++ {
+		_, err = kr.Seal(ctx, []byte("x"), nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, provider.Generated)
+
+	perPayload, err := New(Config{Provider: provider, DataKeyTTL: -1})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = perPayload.Seal(ctx, []byte("x"), nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 6, provider.Generated)
+
+	provider.Err = errors.New("throttled")
+	_, err = perPayload.Seal(ctx, []byte("x"), nil)
+	assert.ErrorIs(t, err, provider.Err)
+}
+
+// blockingProvider blocks GenerateDataKey until release is closed
+type blockingProvider struct {
+	*FakeProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) GenerateDataKey(ctx context.Context) ([]byte, WrappedKey, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return p.FakeProvider.GenerateDataKey(ctx)
+}
+
+func TestConcurrentGeneration(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeProvider()
+	writer, err := New(Config{Provider: fake})
+	require.NoError(t, err)
+	envelope, err := writer.Seal(ctx, []byte("x"), nil)
+	require.NoError(t, err)
+
+	provider := &blockingProvider{FakeProvider: fake, started: make(chan struct{}, 10), release: make(chan struct{})}
+	kr, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+	_, err = kr.Open(ctx, envelope, nil)
+	require.NoError(t, err)
+
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, err := kr.Seal(ctx, []byte("x"), nil)
+			errs <- err
+		}()
+	}
+	<-provider.started
+	// The cached keys decrypt while a data key is generated
+	_, err = kr.Open(ctx, envelope, nil)
+	require.NoError(t, err)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = kr.Seal(canceled, []byte("x"), nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(provider.release)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, <-errs)
+	}
+	assert.Equal(t, 2, fake.Generated, "one generation for the concurrent calls")
+}
+
+func TestCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakeProvider()
+	writer, err := New(Config{Provider: provider, DataKeyTTL: -1})
+	require.NoError(t, err)
+	var envelopes [][]byte
+	for i := 0; i < 3; i++ {
+		envelope, err := writer.Seal(ctx, []byte("x"), nil)
+		require.NoError(t, err)
+		envelopes = append(envelopes, envelope)
+	}
+
+	reader, err := New(Config{Provider: provider, CacheSize: 2})
+	require.NoError(t, err)
+	for _, envelope := range append(envelopes, envelopes...) {
+		_, err = reader.Open(ctx, envelope, nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 6, provider.Unwrapped, "least recently used evicted")
+
+	expiring, err := New(Config{Provider: provider, CacheTTL: time.Nanosecond})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = expiring.Open(ctx, envelopes[0], nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 8, provider.Unwrapped, "expired")
+}
+
+func TestRotation(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakeProvider()
+	kr, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+	envelope, err := kr.Seal(ctx, []byte("secret"), nil)
+	require.NoError(t, err)
+	id, err := MasterKeyID(envelope)
+	require.NoError(t, err)
+	assert.Equal(t, "fake-1", id)
+
+	assert.Equal(t, "fake-2", provider.Rotate())
+	rewrapped, err := kr.Rewrap(ctx, envelope)
+	require.NoError(t, err)
+	id, err = MasterKeyID(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "fake-2", id)
+
+	fresh, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+	plaintext, err := fresh.Open(ctx, rewrapped, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+
+	// The payload is unchanged
+	_, payload, err := parseEnvelope(envelope)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasSuffix(rewrapped, payload))
+
+	_, err = fresh.Open(ctx, []byte{9, 0, 0}, nil)
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	kr, err := New(Config{Provider: NewFakeProvider()})
+	require.NoError(t, err)
+	content := make([]byte, 3*crypt.ChunkSize+5)
+	_, _ = rand.Read(content)
+
+	var buf bytes.Buffer
+	w, wrapped, err := kr.EncryptWriter(ctx, &buf, []byte("report.csv"))
+	require.NoError(t, err)
+	_, err = io.Copy(w, bytes.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	parsed, err := ParseWrappedKey(wrapped.String())
+	require.NoError(t, err)
+	assert.Equal(t, wrapped, parsed)
+
+	r, err := kr.DecryptReader(ctx, bytes.NewReader(buf.Bytes()), parsed, []byte("report.csv"))
+	require.NoError(t, err)
+	decrypted, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(content, decrypted))
+}
+
+func TestLocalProvider(t *testing.T) {
+	ctx := context.Background()
+	k1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	k2 := base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	t.Setenv("KEYRING_TEST_KEYS", "2024:"+k1)
+	old, err := LocalProviderFromEnv("KEYRING_TEST_KEYS")
+	require.NoError(t, err)
+	plaintext, wrapped, err := old.GenerateDataKey(ctx)
+	require.NoError(t, err)
+	assert.Len(t, plaintext, DataKeySize)
+	assert.Equal(t, "2024", wrapped.MasterKeyID)
+
+	keys, err := ParseLocalKeys(strings.NewReader("# rotated\n2025:" + k2 + "\n\n2024:" + k1 + ",\n"))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	rotated, err := NewLocalProvider(keys[0], keys[1:]...)
+	require.NoError(t, err)
+	unwrapped, err := rotated.UnwrapKey(ctx, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, unwrapped)
+
+	rewrapped, err := rotated.WrapKey(ctx, plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, "2025", rewrapped.MasterKeyID)
+	_, err = old.UnwrapKey(ctx, rewrapped)
+	assert.ErrorIs(t, err, ErrUnknownMasterKey)
+
+	// The master key ID is authenticated
+	wrapped.MasterKeyID = "2025"
+	_, err = rotated.UnwrapKey(ctx, wrapped)
+	assert.ErrorIs(t, err, ErrUnknownMasterKey)
+
+	for _, invalid := range []string{"", "# none", "nokey", "k:!!", "k:" + base64.StdEncoding.EncodeToString([]byte("short"))} {
+		_, err = ParseLocalKeys(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+	_, err = LocalProviderFromEnv("KEYRING_TEST_UNSET")
+	assert.Error(t, err)
+}
+
+// fakeKMS wraps the data keys with a local key, as KMS does with its key
+type fakeKMS struct {
+	provider *LocalProvider
+	inputs   []any
+}
+
+func (f *fakeKMS) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, _ ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	f.inputs = append(f.inputs, params)
+	plaintext, wrapped, err := f.provider.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GenerateDataKeyOutput{KeyId: aws.String("arn:aws:kms:eu-west-1:1:key/" + wrapped.MasterKeyID), Plaintext: plaintext, CiphertextBlob: wrapped.Ciphertext}, nil
+}
+
+func (f *fakeKMS) Encrypt(ctx context.Context, params *kms.EncryptInput, _ ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	f.inputs = append(f.inputs, params)
+	wrapped, err := f.provider.WrapKey(ctx, params.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.EncryptOutput{KeyId: aws.String("arn:aws:kms:eu-west-1:1:key/" + wrapped.MasterKeyID), CiphertextBlob: wrapped.Ciphertext}, nil
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.inputs = append(f.inputs, params)
+	id, err := crypt.KeyID(params.CiphertextBlob)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := f.provider.UnwrapKey(ctx, WrappedKey{MasterKeyID: id, Ciphertext: params.CiphertextBlob})
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DecryptOutput{Plaintext: plaintext}, nil
+}
+
+func TestKMSProvider(t *testing.T) {
+	ctx := context.Background()
+	key, err := crypt.NewKey("k1", crypt.AESGCM)
+	require.NoError(t, err)
+	local, err := NewLocalProvider(key)
+	require.NoError(t, err)
+	client := &fakeKMS{provider: local}
+	provider := NewKMSProvider(client, "alias/app")
+	provider.EncryptionContext = map[string]string{"service": "billing"}
+
+	kr, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+	envelope, err := kr.Seal(ctx, []byte("secret"), nil)
+	require.NoError(t, err)
+	id, err := MasterKeyID(envelope)
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:kms:eu-west-1:1:key/k1", id)
+
+	fresh, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+	plaintext, err := fresh.Open(ctx, envelope, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+
+	require.Len(t, client.inputs, 2)
+	generate := client.inputs[0].(*kms.GenerateDataKeyInput)
+	assert.Equal(t, "alias/app", aws.ToString(generate.KeyId))
+	assert.Equal(t, "billing", generate.EncryptionContext["service"])
+	decrypt := client.inputs[1].(*kms.DecryptInput)
+	assert.Equal(t, id, aws.ToString(decrypt.KeyId))
+}
+
+func TestEncryptedEncoder(t *testing.T) {
+	kr, err := New(Config{Provider: NewFakeProvider()})
+	require.NoError(t, err)
+	encoder := rediskit.NewEncryptedEncoder(&rediskit.JSONEncoder{}, kr)
+
+	type session struct{ User string }
+	data, err := encoder.Marshal(session{User: "42"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "42")
+	var s session
+	require.NoError(t, encoder.Unmarshal(data, &s))
+	assert.Equal(t, "42", s.User)
+	assert.Error(t, encoder.Unmarshal([]byte(`{"User":"42"}`), &s))
+
+	// Bound to the cache key
+	ctx := context.Background()
+	data, err = encoder.MarshalKey(ctx, "session:1", session{User: "43"})
+	require.NoError(t, err)
+	require.NoError(t, encoder.UnmarshalKey(ctx, "session:1", data, &s))
+	assert.Equal(t, "43", s.User)
+	assert.ErrorIs(t, encoder.UnmarshalKey(ctx, "session:2", data, &s), crypt.ErrDecrypt)
+	assert.Error(t, encoder.Unmarshal(data, &s))
+}
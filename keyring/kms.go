@@ -0,0 +1,77 @@
+package keyring
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// KMSAPI is the subset of the KMS client used by KMSProvider. It is satisfied by *kms.Client.
+type KMSAPI interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+var _ KMSAPI = (*kms.Client)(nil)
+
+// KMSProvider wraps the data keys with a symmetric AWS KMS key. Rotating the KMS key, or
+// pointing its alias to another key, keeps the data keys wrapped before unwrappable.
+type KMSProvider struct {
+	client KMSAPI
+	keyID  string
+	// EncryptionContext is bound to the wrapped keys and must be the same to unwrap them, it is
+	// recorded in CloudTrail
+	EncryptionContext map[string]string
+}
+
+var _ KeyProvider = (*KMSProvider)(nil)
+
+// NewKMSProvider creates a KMSProvider wrapping with the KMS key keyID: a key ID, an ARN or
+// an alias such as "alias/app".
+func NewKMSProvider(client KMSAPI, keyID string) *KMSProvider {
+	return &KMSProvider{client: client, keyID: keyID}
+}
+
+// GenerateDataKey returns a data key generated by KMS.
+func (p *KMSProvider) GenerateDataKey(ctx context.Context) ([]byte, WrappedKey, error) {
+	out, err := p.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(p.keyID),
+		KeySpec:           types.DataKeySpecAes256,
+		EncryptionContext: p.EncryptionContext,
+	})
+	if err != nil {
+		return nil, WrappedKey{}, fmt.Errorf("kms generate data key: %w", err)
+	}
+	return out.Plaintext, WrappedKey{MasterKeyID: aws.ToString(out.KeyId), Ciphertext: out.CiphertextBlob}, nil
+}
+
+// WrapKey encrypts a data key with the KMS key.
+func (p *KMSProvider) WrapKey(ctx context.Context, plaintext []byte) (WrappedKey, error) {
+	out, err := p.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(p.keyID),
+		Plaintext:         plaintext,
+		EncryptionContext: p.EncryptionContext,
+	})
+	if err != nil {
+		return WrappedKey{}, fmt.Errorf("kms encrypt: %w", err)
+	}
+	return WrappedKey{MasterKeyID: aws.ToString(out.KeyId), Ciphertext: out.CiphertextBlob}, nil
+}
+
+// UnwrapKey decrypts a data key with the KMS key that wrapped it, given as the ARN of the key
+// so that the wrapped keys of other keys are refused.
+func (p *KMSProvider) UnwrapKey(ctx context.Context, wrapped WrappedKey) ([]byte, error) {
+	in := &kms.DecryptInput{CiphertextBlob: wrapped.Ciphertext, EncryptionContext: p.EncryptionContext}
+	if wrapped.MasterKeyID != "" {
+		in.KeyId = aws.String(wrapped.MasterKeyID)
+	}
+	out, err := p.client.Decrypt(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("kms decrypt: %w", err)
+	}
+	return out.Plaintext, nil
+}
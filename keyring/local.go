@@ -0,0 +1,152 @@
+package keyring
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/seidu626/go-buildingblocks/crypt"
+)
+
+// LocalProvider wraps the data keys with master keys held in memory, e.g. loaded from the
+// environment or a mounted secret, for development or when no KMS is available.
+type LocalProvider struct {
+	current string
+	cipher  *crypt.Cipher
+}
+
+var _ KeyProvider = (*LocalProvider)(nil)
+
+// NewLocalProvider creates a LocalProvider wrapping with current, older keys only unwrap.
+func NewLocalProvider(current crypt.Key, older ...crypt.Key) (*LocalProvider, error) {
+	c, err := crypt.NewCipher(current, older...)
+	if err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+	return &LocalProvider{current: current.ID, cipher: c}, nil
+}
+
+// LocalProviderFromEnv creates a LocalProvider from the keys in an environment variable, in the
+// format of ParseLocalKeys.
+func LocalProviderFromEnv(name string) (*LocalProvider, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("keyring: environment variable %s not set", name)
+	}
+	return localProvider(strings.NewReader(value))
+}
+
+// LocalProviderFromFile creates a LocalProvider from the keys in a file, e.g. a mounted
+// secret, in the format of ParseLocalKeys.
+func LocalProviderFromFile(path string) (*LocalProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+	defer f.Close()
+	return localProvider(f)
+}
+
+func localProvider(r io.Reader) (*LocalProvider, error) {
+	keys, err := ParseLocalKeys(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalProvider(keys[0], keys[1:]...)
+}
+
+// ParseLocalKeys parses master keys as "id:base64 key" entries separated by commas or new
+// lines, the current key first. Blank lines and lines starting with # are ignored. The keys
+// are 32 bytes, used with AES-GCM.
+//
+//	# Rotated on 2025-01-10
+//	2025-01:q0Rz...
+//	2024-07:Xb3v...
+func ParseLocalKeys(r io.Reader) ([]crypt.Key, error) {
+	var keys []crypt.Key
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			id, encoded, ok := strings.Cut(entry, ":")
+			if !ok || id == "" {
+				return nil, fmt.Errorf("keyring: key %q: expected id:base64 key", entry)
+			}
+			material, err := decodeKey(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("keyring: key %q: %w", id, err)
+			}
+			if len(material) != crypt.KeySize {
+				return nil, fmt.Errorf("keyring: key %q: %d bytes, expected %d", id, len(material), crypt.KeySize)
+			}
+			keys = append(keys, crypt.Key{ID: id, Algorithm: crypt.AESGCM, Material: material})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("keyring: no keys")
+	}
+	return keys, nil
+}
+
+// decodeKey decodes a key in standard or URL base64, padded or not
+func decodeKey(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// GenerateDataKey returns a random data key wrapped by the current master key.
+func (p *LocalProvider) GenerateDataKey(ctx context.Context) ([]byte, WrappedKey, error) {
+	plaintext := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		return nil, WrappedKey{}, err
+	}
+	wrapped, err := p.WrapKey(ctx, plaintext)
+	if err != nil {
+		return nil, WrappedKey{}, err
+	}
+	return plaintext, wrapped, nil
+}
+
+// WrapKey wraps a data key by the current master key.
+func (p *LocalProvider) WrapKey(_ context.Context, plaintext []byte) (WrappedKey, error) {
+	ciphertext, err := p.cipher.Encrypt(plaintext, []byte(p.current))
+	if err != nil {
+		return WrappedKey{}, err
+	}
+	return WrappedKey{MasterKeyID: p.current, Ciphertext: ciphertext}, nil
+}
+
+// UnwrapKey unwraps a data key wrapped by any of the master keys.
+func (p *LocalProvider) UnwrapKey(_ context.Context, wrapped WrappedKey) ([]byte, error) {
+	id, err := crypt.KeyID(wrapped.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if id != wrapped.MasterKeyID {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMasterKey, wrapped.MasterKeyID)
+	}
+	plaintext, err := p.cipher.Decrypt(wrapped.Ciphertext, []byte(wrapped.MasterKeyID))
+	if errors.Is(err, crypt.ErrUnknownKey) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMasterKey, wrapped.MasterKeyID)
+	}
+	return plaintext, err
+}
@@ -0,0 +1,265 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// PgxLogger is a pgx tracelog.Logger logging to a zap logger with the fields of the context of
+// the queries:
+//
+//	pool, err := dbx.NewDBXPool(ctx, logger, logging.NewPgxLogger(logger), tracelog.LogLevelWarn, cfg)
+type PgxLogger struct {
+	logger *zap.Logger
+}
+
+var _ tracelog.Logger = (*PgxLogger)(nil)
+
+// NewPgxLogger creates a PgxLogger.
+func NewPgxLogger(logger *zap.Logger) *PgxLogger {
+	return &PgxLogger{logger: logger.WithOptions(zap.AddCallerSkip(1))}
+}
+
+// Log implements tracelog.Logger.
+func (l *PgxLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+	var zl zapcore.Level
+	switch level {
+	case tracelog.LogLevelTrace, tracelog.LogLevelDebug:
+		zl = zapcore.DebugLevel
+	case tracelog.LogLevelInfo:
+		zl = zapcore.InfoLevel
+	case tracelog.LogLevelWarn:
+		zl = zapcore.WarnLevel
+	default:
+		zl = zapcore.ErrorLevel
+	}
+	ce := l.logger.Check(zl, msg)
+	if ce == nil {
+		return
+	}
+	fields := append([]zap.Field(nil), Fields(ctx)...)
+	for k, v := range data {
+		fields = append(fields, zap.Any(k, v))
+	}
+	ce.Write(fields...)
+}
+
+// GocqlLogger is a gocql.StdLogger logging to a zap logger, see cassandra.Config.Logger.
+type GocqlLogger struct {
+	logger *zap.Logger
+	level  zapcore.Level
+}
+
+var _ gocql.StdLogger = (*GocqlLogger)(nil)
+
+// NewGocqlLogger creates a GocqlLogger logging at level, gocql logging mostly the failures of
+// its connections.
+func NewGocqlLogger(logger *zap.Logger, level zapcore.Level) *GocqlLogger {
+	return &GocqlLogger{logger: logger.WithOptions(zap.AddCallerSkip(1)).Named("gocql"), level: level}
+}
+
+func (l *GocqlLogger) log(msg string) {
+	if ce := l.logger.Check(l.level, strings.TrimSuffix(msg, "\n")); ce != nil {
+		ce.Write()
+	}
+}
+
+// Print implements gocql.StdLogger.
+func (l *GocqlLogger) Print(v ...interface{}) { l.log(fmt.Sprint(v...)) }
+
+// Printf implements gocql.StdLogger.
+func (l *GocqlLogger) Printf(format string, v ...interface{}) { l.log(fmt.Sprintf(format, v...)) }
+
+// Println implements gocql.StdLogger.
+func (l *GocqlLogger) Println(v ...interface{}) { l.log(fmt.Sprintln(v...)) }
+
+// LogrusHook is a logrus.Hook sending the entries of a logrus logger to a zap logger, with
+// their data and the fields of their context.
+type LogrusHook struct {
+	logger *zap.Logger
+}
+
+var _ logrus.Hook = (*LogrusHook)(nil)
+
+// NewLogrusHook creates a LogrusHook.
+func NewLogrusHook(logger *zap.Logger) *LogrusHook {
+	return &LogrusHook{logger: logger}
+}
+
+// RedirectLogrus sends the entries of l to logger only, e.g. those of the package requests
+// with logrus.StandardLogger().
+func RedirectLogrus(l *logrus.Logger, logger *zap.Logger) {
+	l.ReplaceHooks(logrus.LevelHooks{})
+	l.AddHook(NewLogrusHook(logger))
+	l.SetOutput(io.Discard)
+	// Filtered by the level of logger
+	l.SetLevel(logrus.TraceLevel)
+}
+
+// Levels implements logrus.Hook.
+func (h *LogrusHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (h *LogrusHook) Fire(e *logrus.Entry) error {
+	var level zapcore.Level
+	switch e.Level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		level = zapcore.DebugLevel
+	case logrus.InfoLevel:
+		level = zapcore.InfoLevel
+	case logrus.WarnLevel:
+		level = zapcore.WarnLevel
+	default:
+		// Not panicking nor exiting: logrus does it
+		level = zapcore.ErrorLevel
+	}
+	ce := h.logger.Check(level, e.Message)
+	if ce == nil {
+		return nil
+	}
+	ce.Time = e.Time
+	if e.Caller != nil {
+		ce.Caller = zapcore.NewEntryCaller(e.Caller.PC, e.Caller.File, e.Caller.Line, true)
+	}
+	var fields []zap.Field
+	if e.Context != nil {
+		fields = append(fields, Fields(e.Context)...)
+	}
+	for k, v := range e.Data {
+		if err, ok := v.(error); ok && k == logrus.ErrorKey {
+			fields = append(fields, zap.Error(err))
+			continue
+		}
+		fields = append(fields, zap.Any(k, v))
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+// SlogHandler is a slog.Handler logging to a zap logger, with the fields of the context of
+// the records.
+type SlogHandler struct {
+	logger *zap.Logger
+	group  string // Prefix of the keys of the attributes
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// NewSlogHandler creates a SlogHandler:
+//
+//	slog.SetDefault(slog.New(logging.NewSlogHandler(logger)))
+func NewSlogHandler(logger *zap.Logger) *SlogHandler {
+	return &SlogHandler{logger: logger}
+}
+
+func slogLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	}
+	return zapcore.ErrorLevel
+}
+
+// Enabled implements slog.Handler.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Core().Enabled(slogLevel(level))
+}
+
+// Handle implements slog.Handler.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	ce := h.logger.Check(slogLevel(r.Level), r.Message)
+	if ce == nil {
+		return nil
+	}
+	if !r.Time.IsZero() {
+		ce.Time = r.Time
+	}
+	if r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ce.Caller = zapcore.NewEntryCaller(f.PC, f.File, f.Line, true)
+	}
+	fields := append([]zap.Field(nil), Fields(ctx)...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = h.appendAttr(fields, h.group, a)
+		return true
+	})
+	ce.Write(fields...)
+	return nil
+}
+
+// appendAttr appends the fields of an attribute, the groups being flattened with dots
+func (h *SlogHandler) appendAttr(fields []zap.Field, prefix string, a slog.Attr) []zap.Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		if a.Key == "" {
+			key = prefix
+		}
+		for _, ga := range a.Value.Group() {
+			fields = h.appendAttr(fields, key, ga)
+		}
+		return fields
+	case slog.KindString:
+		return append(fields, zap.String(key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(key, a.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(key, a.Value.Time()))
+	}
+	if err, ok := a.Value.Any().(error); ok {
+		return append(fields, zap.NamedError(key, err))
+	}
+	return append(fields, zap.Any(key, a.Value.Any()))
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []zap.Field
+	for _, a := range attrs {
+		fields = h.appendAttr(fields, h.group, a)
+	}
+	return &SlogHandler{logger: h.logger.With(fields...), group: h.group}
+}
+
+// WithGroup implements slog.Handler.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	group := name
+	if h.group != "" {
+		group = h.group + "." + name
+	}
+	return &SlogHandler{logger: h.logger, group: group}
+}
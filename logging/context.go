@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type contextKey struct{}
+
+// scope is the logger and the request-scoped fields of a context
+type scope struct {
+	logger *zap.Logger // nil when not set by WithContext
+	fields []zap.Field
+}
+
+func scopeOf(ctx context.Context) scope {
+	s, _ := ctx.Value(contextKey{}).(scope)
+	return s
+}
+
+// WithContext returns a context carrying logger, returned by FromContext.
+func WithContext(ctx context.Context, logger *zap.Logger) context.Context {
+	s := scopeOf(ctx)
+	s.logger = logger
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// WithFields returns a context carrying request-scoped fields, e.g. the request ID, added to
+// its logger and to the entries of the adapters logging with it.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	s := scopeOf(ctx)
+	s.fields = append(s.fields[:len(s.fields):len(s.fields)], fields...)
+	if s.logger != nil {
+		s.logger = s.logger.With(fields...)
+	}
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// Fields returns the request-scoped fields of ctx.
+func Fields(ctx context.Context) []zap.Field {
+	return scopeOf(ctx).fields
+}
+
+// FromContext returns the logger of ctx, otherwise the global logger of zap with the fields of
+// ctx. It never returns nil.
+func FromContext(ctx context.Context) *zap.Logger {
+	s := scopeOf(ctx)
+	if s.logger != nil {
+		return s.logger
+	}
+	return zap.L().With(s.fields...)
+}
@@ -0,0 +1,192 @@
+package logging
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/config"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SamplingConfig limits the entries of same level and message logged per tick: the first
+// Initial ones, then every Thereafter.
+type SamplingConfig struct {
+	Tick       time.Duration // Default 1s
+	Initial    int
+	Thereafter int
+}
+
+// Config holds the configuration of a Logger.
+type Config struct {
+	// Level is debug, info (default), warn, error, dpanic, panic or fatal
+	Level string
+	// Format of the console output, json (default) or console. The file is always JSON.
+	Format string
+	// DisableConsole does not log to stdout
+	DisableConsole bool
+	// File, when its Path is set, also logs to a rotated file
+	File RotationConfig
+	// Sampling, optional, limits the repeated entries
+	Sampling *SamplingConfig
+	// RedactKeys are redacted in addition to DefaultRedactKeys
+	RedactKeys []string
+	// DisableRedaction logs the fields as is
+	DisableRedaction bool
+	// Fields are added to all the entries, e.g. the name of the application
+	Fields map[string]string
+}
+
+// ConvertToLoggingConfig converts the Logging section of a config.Config to a Config, the
+// log file defaulting to Application.Log.Path.
+func ConvertToLoggingConfig(appConfig *config.Config) Config {
+	conf := appConfig.Logging
+	cfg := Config{
+		Level:      conf.Level,
+		Format:     conf.Format,
+		RedactKeys: conf.RedactKeys,
+		File: RotationConfig{
+			Path:       conf.File.Path,
+			MaxSize:    int64(conf.File.MaxSizeMB) << 20,
+			MaxAge:     conf.File.MaxAge,
+			MaxBackups: conf.File.MaxBackups,
+			Compress:   conf.File.Compress,
+		},
+	}
+	if cfg.File.Path == "" {
+		cfg.File.Path = appConfig.Application.Log.Path
+	}
+	if conf.Sampling.Initial > 0 {
+		cfg.Sampling = &SamplingConfig{Initial: conf.Sampling.Initial, Thereafter: conf.Sampling.Thereafter}
+	}
+	if appConfig.Application.Name != "" {
+		cfg.Fields = map[string]string{"app": appConfig.Application.Name}
+	}
+	return cfg
+}
+
+// Logger is a zap logger whose level can be changed at runtime.
+type Logger struct {
+	*zap.Logger
+	// Level is the level of the logger, its ServeHTTP serving it: GET returns it as JSON,
+	// PUT {"level":"debug"} changes it
+	Level zap.AtomicLevel
+	file  *RotatingFile
+}
+
+// New creates a Logger.
+func New(cfg Config) (*Logger, error) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("logging: invalid level %q", cfg.Level)
+		}
+	}
+
+	var cores []zapcore.Core
+	l := &Logger{Level: level}
+	if !cfg.DisableConsole {
+		enc, err := newEncoder(cfg.Format, true)
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores, zapcore.NewCore(enc, zapcore.Lock(os.Stdout), level))
+	}
+	errorOutput := zapcore.Lock(os.Stderr)
+	if cfg.File.Path != "" {
+		if cfg.File.ErrorOutput == nil {
+			cfg.File.ErrorOutput = errorOutput
+		}
+		file, err := NewRotatingFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		l.file = file
+		enc, _ := newEncoder("json", false)
+		cores = append(cores, zapcore.NewCore(enc, file, level))
+	}
+	if !cfg.DisableRedaction {
+		keys := append(append([]string(nil), DefaultRedactKeys...), cfg.RedactKeys...)
+		for i := range cores {
+			cores[i] = NewRedactCore(cores[i], keys)
+		}
+	}
+	core := zapcore.NewTee(cores...)
+	if cfg.Sampling != nil {
+		tick := cfg.Sampling.Tick
+		if tick <= 0 {
+			tick = time.Second
+		}
+		core = zapcore.NewSamplerWithOptions(core, tick, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+	}
+
+	opts := []zap.Option{zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel), zap.ErrorOutput(errorOutput)}
+	if len(cfg.Fields) > 0 {
+		fields := make([]zap.Field, 0, len(cfg.Fields))
+		for k, v := range cfg.Fields {
+			fields = append(fields, zap.String(k, v))
+		}
+		opts = append(opts, zap.Fields(fields...))
+	}
+	l.Logger = zap.New(core, opts...)
+	return l, nil
+}
+
+// newEncoder returns the encoder of a format, colored levels being used by the console format
+// when color is set
+func newEncoder(format string, color bool) (zapcore.Encoder, error) {
+	cfg := zapcore.EncoderConfig{
+		TimeKey:        "timestamp",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.CapitalLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	switch format {
+	case "", "json":
+		return zapcore.NewJSONEncoder(cfg), nil
+	case "console":
+		if color {
+			cfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		return zapcore.NewConsoleEncoder(cfg), nil
+	}
+	return nil, fmt.Errorf("logging: unknown format %q", format)
+}
+
+// LevelHandler returns the http.Handler of the level, see Level.
+func (l *Logger) LevelHandler() http.Handler {
+	return l.Level
+}
+
+// FastHTTPLevelHandler returns the fasthttp handler of the level, see Level.
+func (l *Logger) FastHTTPLevelHandler() fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(l.Level)
+}
+
+// Rotate rotates the log file, if any.
+func (l *Logger) Rotate() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Rotate()
+}
+
+// Close flushes the logger and closes its file.
+func (l *Logger) Close() error {
+	_ = l.Sync()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,254 @@
+package logging
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// entries returns the JSON entries of a log file
+func entries(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var out []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e), scanner.Text())
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestLoggerLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	l, err := New(Config{Level: "warn", DisableConsole: true, File: RotationConfig{Path: path}, Fields: map[string]string{"app": "billing"}})
+	require.NoError(t, err)
+	defer l.Close()
+
+	l.Info("hidden")
+	l.Warn("shown")
+
+	// Runtime change through the endpoint
+	srv := httptest.NewServer(l.LevelHandler())
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(`{"level":"debug"}`))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	l.Debug("debug shown")
+
+	got := entries(t, path)
+	require.Len(t, got, 2)
+	assert.Equal(t, "WARN", got[0]["level"], "no color codes in JSON")
+	assert.Equal(t, "billing", got[0]["app"])
+	assert.Equal(t, "debug shown", got[1]["msg"])
+
+	_, err = New(Config{Level: "verbose"})
+	assert.Error(t, err)
+	_, err = New(Config{Format: "xml"})
+	assert.Error(t, err)
+}
+
+func TestRedaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	l, err := New(Config{DisableConsole: true, File: RotationConfig{Path: path}, RedactKeys: []string{"msisdn"}})
+	require.NoError(t, err)
+	defer l.Close()
+
+	l.With(zap.String("Authorization", "Bearer abc")).Info("request",
+		zap.String("user_password", "hunter2"),
+		zap.String("api-key", "k"),
+		zap.String("note", "paid with 4111 1111 1111 1111 today"),
+		zap.String("order", "1234567890123"),
+		zap.String("msisdn", "233200000000"),
+		zap.Any("headers", map[string]string{"X-Auth-Token": "t", "Accept": "json"}),
+		zap.Int("pan", 3),
+	)
+	got := entries(t, path)
+	require.Len(t, got, 1)
+	e := got[0]
+	assert.Equal(t, Redacted, e["Authorization"])
+	assert.Equal(t, Redacted, e["user_password"])
+	assert.Equal(t, Redacted, e["api-key"])
+	assert.Equal(t, Redacted, e["msisdn"])
+	assert.Equal(t, Redacted, e["pan"])
+	assert.Equal(t, "paid with **** **** **** 1111 today", e["note"])
+	assert.Equal(t, "1234567890123", e["order"], "not a card number")
+	assert.Equal(t, map[string]any{"X-Auth-Token": Redacted, "Accept": "json"}, e["headers"])
+}
+
+func TestSampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	l, err := New(Config{DisableConsole: true, File: RotationConfig{Path: path}, Sampling: &SamplingConfig{Tick: time.Minute, Initial: 2, Thereafter: 5}})
+	require.NoError(t, err)
+	defer l.Close()
+	for i := 0; i < 12; i++ {
+		l.Info("repeated")
+	}
+	l.Info("other")
+	assert.Len(t, entries(t, path), 5, "2 first, 7th, 12th and other")
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(RotationConfig{Path: path, MaxSize: 100, MaxAge: time.Hour, MaxBackups: 2, Compress: true})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	f.openedAt = now
+
+	line := []byte(strings.Repeat("x", 59) + "\n")
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Second)
+		_, err = f.Write(line)
+		require.NoError(t, err)
+	}
+	// By age
+	now = now.Add(time.Hour)
+	_, err = f.Write([]byte("late\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	backups, err := f.Backups()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app-2024-01-02T15-04-09.000.log.gz"),
+		filepath.Join(dir, "app-2024-01-02T16-04-09.000.log.gz"),
+	}, backups, "oldest removed")
+
+	gz, err := os.Open(backups[1])
+	require.NoError(t, err)
+	defer gz.Close()
+	r, err := gzip.NewReader(gz)
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, string(line), string(content))
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "late\n", string(current))
+
+	_, err = f.Write(line)
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestRotationRenameFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	var errorOutput strings.Builder
+	f, err := NewRotatingFile(RotationConfig{Path: path, MaxSize: 10, ErrorOutput: zapcore.AddSync(&errorOutput)})
+	require.NoError(t, err)
+	defer f.Close()
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	// A non-empty directory in the way of the rotated file
+	backup := filepath.Join(dir, "app-2024-01-02T15-04-05.000.log")
+	require.NoError(t, os.MkdirAll(filepath.Join(backup, "x"), 0o755))
+	_, err = f.Write([]byte("first\n"))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = f.Write([]byte("second\n"))
+		require.NoError(t, err, "written to the current file")
+	}
+	assert.Equal(t, 1, strings.Count(errorOutput.String(), "logging: rotating log file"), "retried after a delay")
+	assert.Error(t, f.Rotate())
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n"+strings.Repeat("second\n", 3), string(current))
+
+	require.NoError(t, os.RemoveAll(backup))
+	now = now.Add(rotationRetry)
+	_, err = f.Write([]byte("third\n"))
+	require.NoError(t, err)
+	current, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(current))
+}
+
+func TestContext(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	base := zap.New(core)
+
+	ctx := WithFields(context.Background(), zap.String("request_id", "r1"))
+	assert.Len(t, Fields(ctx), 1)
+	assert.NotNil(t, FromContext(context.Background()), "global logger")
+
+	ctx = WithContext(ctx, base)
+	ctx = WithFields(ctx, zap.String("user", "u1"))
+	FromContext(ctx).Info("handled")
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]any{"user": "u1"}, logs.All()[0].ContextMap(), "fields added after the logger")
+	assert.Len(t, Fields(ctx), 2)
+
+	// The parent context is not modified
+	parent := WithFields(context.Background(), zap.String("request_id", "r1"))
+	child1 := WithFields(parent, zap.String("a", "1"))
+	child2 := WithFields(parent, zap.String("b", "2"))
+	assert.Equal(t, "a", Fields(child1)[1].Key)
+	assert.Equal(t, "b", Fields(child2)[1].Key)
+}
+
+func TestAdapters(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	base := zap.New(core)
+	ctx := WithFields(context.Background(), zap.String("request_id", "r1"))
+	last := func() observer.LoggedEntry {
+		t.Helper()
+		all := logs.TakeAll()
+		require.Len(t, all, 1)
+		return all[0]
+	}
+
+	NewPgxLogger(base).Log(ctx, tracelog.LogLevelWarn, "Query", map[string]any{"sql": "select 1"})
+	e := last()
+	assert.Equal(t, zapcore.WarnLevel, e.Level)
+	assert.Equal(t, map[string]any{"request_id": "r1", "sql": "select 1"}, e.ContextMap())
+
+	NewGocqlLogger(base, zapcore.InfoLevel).Printf("gocql: unable to dial %q\n", "10.0.0.1")
+	e = last()
+	assert.Equal(t, `gocql: unable to dial "10.0.0.1"`, e.Message)
+	assert.Equal(t, "gocql", e.LoggerName)
+
+	lr := logrus.New()
+	RedirectLogrus(lr, base)
+	lr.WithContext(ctx).WithField("status", 502).WithError(errors.New("bad gateway")).Error("request failed")
+	e = last()
+	assert.Equal(t, zapcore.ErrorLevel, e.Level)
+	assert.Equal(t, map[string]any{"request_id": "r1", "status": int64(502), "error": "bad gateway"}, e.ContextMap())
+
+	sl := slog.New(NewSlogHandler(base)).With("service", "billing").WithGroup("http")
+	sl.DebugContext(ctx, "sent", "status", 200, slog.Group("req", "method", "GET"))
+	e = last()
+	assert.Equal(t, zapcore.DebugLevel, e.Level)
+	assert.Equal(t, map[string]any{"request_id": "r1", "service": "billing", "http.status": int64(200), "http.req.method": "GET"}, e.ContextMap())
+	assert.Contains(t, e.Caller.File, "logging_test.go")
+
+	info := slog.New(NewSlogHandler(zap.New(core).WithOptions(zap.IncreaseLevel(zapcore.InfoLevel))))
+	assert.False(t, info.Enabled(ctx, slog.LevelDebug))
+}
@@ -0,0 +1,216 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the values of the sensitive fields.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys are the parts of the keys of the fields redacted by default, compared
+// ignoring the case, "_" and "-".
+var DefaultRedactKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "apikey", "cookie",
+	"cardnumber", "pan", "cvv", "cvc", "pin",
+}
+
+// redactCore replaces the values of the sensitive fields, and the card numbers of the string
+// fields, before they are encoded
+type redactCore struct {
+	zapcore.Core
+	keys []string
+}
+
+// NewRedactCore wraps core to redact the fields whose key contains one of keys, normalized as
+// DefaultRedactKeys, and to mask the card numbers of the string fields but their last 4 digits.
+// The fields of nested objects are not inspected, except string maps.
+func NewRedactCore(core zapcore.Core, keys []string) zapcore.Core {
+	normalized := make([]string, len(keys))
+	for i, k := range keys {
+		normalized[i] = normalizeKey(k)
+	}
+	return &redactCore{Core: core, keys: normalized}
+}
+
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "", ".", "").Replace(strings.ToLower(key))
+}
+
+func (c *redactCore) sensitive(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range c.keys {
+		if k == "pan" || k == "pin" {
+			// Too short to be searched inside the keys
+			if key == k {
+				return true
+			}
+			continue
+		}
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *redactCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		redacted, changed := c.redactField(f)
+		if changed && out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		if out != nil {
+			out[i] = redacted
+		}
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+func (c *redactCore) redactField(f zapcore.Field) (zapcore.Field, bool) {
+	if f.Type == zapcore.SkipType || f.Type == zapcore.ErrorType {
+		return f, false
+	}
+	if c.sensitive(f.Key) {
+		return zap.String(f.Key, Redacted), true
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		if masked, ok := maskCardNumbers(f.String); ok {
+			return zap.String(f.Key, masked), true
+		}
+	case zapcore.ByteStringType:
+		if masked, ok := maskCardNumbers(string(f.Interface.([]byte))); ok {
+			return zap.String(f.Key, masked), true
+		}
+	case zapcore.ReflectType:
+		switch m := f.Interface.(type) {
+		case map[string]string:
+			return zap.Any(f.Key, c.redactMap(m)), true
+		case map[string]interface{}:
+			redacted := make(map[string]interface{}, len(m))
+			for k, v := range m {
+				redacted[k] = v
+				if c.sensitive(k) {
+					redacted[k] = Redacted
+				} else if s, ok := v.(string); ok {
+					redacted[k], _ = maskCardNumbers(s)
+				}
+			}
+			return zap.Any(f.Key, redacted), true
+		}
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(fmt.Stringer); ok {
+			if masked, ok := maskCardNumbers(s.String()); ok {
+				return zap.String(f.Key, masked), true
+			}
+		}
+	}
+	return f, false
+}
+
+func (c *redactCore) redactMap(m map[string]string) map[string]string {
+	redacted := make(map[string]string, len(m))
+	for k, v := range m {
+		if c.sensitive(k) {
+			redacted[k] = Redacted
+		} else {
+			redacted[k], _ = maskCardNumbers(v)
+		}
+	}
+	return redacted
+}
+
+// maskCardNumbers masks the sequences of 13 to 19 digits, possibly separated by spaces or
+// dashes, passing the Luhn check, but their last 4 digits
+func maskCardNumbers(s string) (string, bool) {
+	var b []byte
+	start := -1
+	digits := 0
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		seq := s[start:end]
+		n := digits
+		start, digits = -1, 0
+		if n < 13 || n > 19 || !luhn(seq) {
+			return
+		}
+		if b == nil {
+			b = []byte(s)
+		}
+		kept := 0
+		for i := end - 1; i >= end-len(seq); i-- {
+			if b[i] >= '0' && b[i] <= '9' {
+				if kept < 4 {
+					kept++
+				} else {
+					b[i] = '*'
+				}
+			}
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= '0' && ch <= '9':
+			if start < 0 {
+				start = i
+			}
+			digits++
+		case (ch == ' ' || ch == '-') && start >= 0 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			// Separator inside a number
+		default:
+			flush(i)
+		}
+	}
+	flush(len(s))
+	if b == nil {
+		return s, false
+	}
+	return string(b), true
+}
+
+// luhn reports whether the digits of s pass the Luhn check
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redact(fields)), keys: c.keys}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.redact(fields))
+}
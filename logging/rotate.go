@@ -0,0 +1,264 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// backupTimeFormat is the timestamp of the names of the rotated files, sorted chronologically
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotationRetry is the delay before rotating again after a failed rotation
+const rotationRetry = time.Minute
+
+// RotationConfig holds the configuration of a RotatingFile.
+type RotationConfig struct {
+	Path string
+	// MaxSize is the size in bytes beyond which the file is rotated (default 100MiB)
+	MaxSize int64
+	// MaxAge is the time after which the file is rotated (default 24h), negative for never
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept (default 7), negative to keep them all
+	MaxBackups int
+	// Compress gzips the rotated files
+	Compress bool
+	// ErrorOutput receives the rotation and compression failures (default stderr), New sets
+	// the error output of the logger
+	ErrorOutput zapcore.WriteSyncer
+}
+
+func (c *RotationConfig) setDefaults() {
+	if c.MaxSize <= 0 {
+		c.MaxSize = 100 << 20
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = 24 * time.Hour
+	}
+	if c.MaxBackups == 0 {
+		c.MaxBackups = 7
+	}
+	if c.ErrorOutput == nil {
+		c.ErrorOutput = os.Stderr
+	}
+	// Written by the compressions as well
+	c.ErrorOutput = zapcore.Lock(c.ErrorOutput)
+}
+
+// RotatingFile is a zapcore.WriteSyncer writing to a file rotated by size and age: the file is
+// renamed with a timestamp, e.g. app-2024-01-02T15-04-05.000.log, compressed if configured,
+// and the oldest rotated files beyond MaxBackups are removed.
+type RotatingFile struct {
+	cfg RotationConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File // nil when closed, or when it could not be reopened after a rotation
+	closed   bool
+	size     int64
+	openedAt time.Time
+	retryAt  time.Time // After a failed rotation, when it is attempted again
+
+	mill sync.Mutex // Serializes the compression and the removal of the backups
+	wg   sync.WaitGroup
+}
+
+// NewRotatingFile opens a RotatingFile, appending to the file at cfg.Path when it exists.
+func NewRotatingFile(cfg RotationConfig) (*RotatingFile, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("logging: log file path required")
+	}
+	cfg.setDefaults()
+	f := &RotatingFile{cfg: cfg, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("logging: creating log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("logging: opening log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("logging: opening log file: %w", err)
+	}
+	f.file, f.size, f.openedAt = file, info.Size(), f.now()
+	return nil
+}
+
+// Write writes p, rotating the file first when it would exceed its size or is too old.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	tooBig := f.size > 0 && f.size+int64(len(p)) > f.cfg.MaxSize
+	tooOld := f.cfg.MaxAge > 0 && f.now().Sub(f.openedAt) >= f.cfg.MaxAge && f.size > 0
+	if (tooBig || tooOld) && !f.now().Before(f.retryAt) {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			// Still writing to the current file until the next attempt
+			f.retryAt = f.now().Add(rotationRetry)
+			fmt.Fprintf(f.cfg.ErrorOutput, "%v, retrying in %s\n", err, rotationRetry)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file now, e.g. on SIGHUP.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// rotate renames the file and opens a new one. When the renaming fails, the file is reopened
+// to keep writing to it; f.file is nil when it cannot be, the next write opening it again.
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		err := f.file.Close()
+		f.file = nil
+		if err != nil {
+			return fmt.Errorf("logging: rotating log file: %w", err)
+		}
+	}
+	ext := filepath.Ext(f.cfg.Path)
+	backup := strings.TrimSuffix(f.cfg.Path, ext) + "-" + f.now().Format(backupTimeFormat) + ext
+	if err := os.Rename(f.cfg.Path, backup); err != nil {
+		_ = f.open()
+		return fmt.Errorf("logging: rotating log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.retryAt = time.Time{}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.mill.Lock()
+		defer f.mill.Unlock()
+		if f.cfg.Compress {
+			if err := compress(backup); err != nil {
+				fmt.Fprintf(f.cfg.ErrorOutput, "logging: compressing %s: %v\n", backup, err)
+			}
+		}
+		f.prune()
+	}()
+	return nil
+}
+
+// compress gzips a rotated file
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Backups returns the rotated files, the oldest first.
+func (f *RotatingFile) Backups() ([]string, error) {
+	ext := filepath.Ext(f.cfg.Path)
+	prefix := strings.TrimSuffix(filepath.Base(f.cfg.Path), ext) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.cfg.Path))
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, filepath.Join(filepath.Dir(f.cfg.Path), name))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// prune removes the oldest backups beyond MaxBackups
+func (f *RotatingFile) prune() {
+	if f.cfg.MaxBackups < 0 {
+		return
+	}
+	backups, err := f.Backups()
+	if err != nil {
+		return
+	}
+	for len(backups) > f.cfg.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+// Sync commits the file to disk.
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file, waiting for the compression of the rotated files.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	file := f.file
+	f.file, f.closed = nil, true
+	f.mu.Unlock()
+	f.wg.Wait()
+	if file == nil {
+		return nil
+	}
+	return file.Close()
+}
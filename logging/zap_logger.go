@@ -7,6 +7,8 @@ import (
 )
 
 // NewZapLogger InitLogger initializes a zap logger with optional file output
+//
+// Deprecated: Use New, whose level and format are configured.
 func NewZapLogger(logFilePath string) (*zap.Logger, error) {
 	outputPaths := []string{"stdout"}
 	errorOutputPaths := []string{"stderr"}
@@ -25,7 +27,7 @@ func NewZapLogger(logFilePath string) (*zap.Logger, error) {
 		MessageKey:     "msg",
 		StacktraceKey:  "stacktrace",
 		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.CapitalColorLevelEncoder,
+		EncodeLevel:    zapcore.CapitalLevelEncoder,
 		EncodeTime:     zapcore.ISO8601TimeEncoder,
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 		EncodeCaller:   zapcore.ShortCallerEncoder,
@@ -50,19 +52,26 @@ func NewZapLogger(logFilePath string) (*zap.Logger, error) {
 	return logger, nil
 }
 
+// NewZapFileConsoleLogger logs at debug level to the console and to app_log.log. The file is
+// left out when it cannot be opened.
+//
+// Deprecated: Use NewFileConsoleLogger, which takes the path of the file and returns its errors.
 func NewZapFileConsoleLogger() *zap.Logger {
-	cfg := zap.NewProductionEncoderConfig()
-	cfg.EncodeTime = zapcore.ISO8601TimeEncoder
-	fileEncoder := zapcore.NewJSONEncoder(cfg)
-	consoleEncoder := zapcore.NewConsoleEncoder(cfg)
-	logFile, _ := os.OpenFile("app_log.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	writer := zapcore.AddSync(logFile)
-	defaultLogLevel := zapcore.DebugLevel
-	core := zapcore.NewTee(
-		zapcore.NewCore(fileEncoder, writer, defaultLogLevel),
-		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), defaultLogLevel),
-	)
-	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	if l, err := NewFileConsoleLogger("app_log.log"); err == nil {
+		return l
+	}
+	l, _ := New(Config{Level: "debug", Format: "console"})
+	return l.Logger
+}
+
+// NewFileConsoleLogger logs at debug level to the console and, as JSON, to the file at path,
+// rotated with the defaults of RotationConfig.
+func NewFileConsoleLogger(path string) (*zap.Logger, error) {
+	l, err := New(Config{Level: "debug", Format: "console", File: RotationConfig{Path: path}})
+	if err != nil {
+		return nil, err
+	}
+	return l.Logger, nil
 }
 
 // SetOutput replaces existing Core with new, that writes to passed WriteSyncer.
@@ -82,10 +91,11 @@ func SetOutput(ws zapcore.WriteSyncer, conf zap.Config) zap.Option {
 	})
 }
 
-func getWriteSyncer(logfileName string) zapcore.WriteSyncer {
-	swSugar := zapcore.NewMultiWriteSyncer(
-		zapcore.AddSync(os.Stdout),
-		getWriteSyncer(logfileName),
-	)
-	return swSugar
+// getWriteSyncer returns a WriteSyncer writing to stdout and to the rotated file logfileName
+func getWriteSyncer(logfileName string) (zapcore.WriteSyncer, error) {
+	file, err := NewRotatingFile(RotationConfig{Path: logfileName})
+	if err != nil {
+		return nil, err
+	}
+	return zapcore.NewMultiWriteSyncer(zapcore.Lock(os.Stdout), file), nil
 }
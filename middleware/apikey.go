@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/seidu626/go-buildingblocks/passwords"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// APIKeyMiddleware authenticates the requests by API key, from the X-API-Key header or a
+// bearer Authorization header, verified against the keys of store. The record of the key is
+// set in the "api_key" user value.
+func APIKeyMiddleware(next fasthttp.RequestHandler, store passwords.APIKeyStore) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		key := string(ctx.Request.Header.Peek("X-API-Key"))
+		if key == "" {
+			key = strings.TrimPrefix(string(ctx.Request.Header.Peek("Authorization")), "Bearer ")
+		}
+		if key == "" {
+			ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+			return
+		}
+
+		record, err := passwords.VerifyAPIKey(ctx, store, key)
+		if errors.Is(err, passwords.ErrInvalidAPIKey) {
+			ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			zap.L().Error("API key lookup failed", zap.Error(err))
+			ctx.Error("Internal Server Error", fasthttp.StatusInternalServerError)
+			return
+		}
+
+		ctx.SetUserValue("api_key", record)
+		next(ctx)
+	}
+}
@@ -1,40 +1,83 @@
 package middleware
 
 import (
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/valyala/fasthttp"
 	"strings"
 )
 
-// JWTMiddleware validates the JWT token
-func JWTMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
-	return func(ctx *fasthttp.RequestCtx) {
-		tokenString := string(ctx.Request.Header.Peek("Authorization"))
-		if tokenString == "" {
-			ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
-			return
+// ErrMissingToken is returned by ParseJWT for an empty token.
+var ErrMissingToken = errors.New("missing token")
+
+// defaultKeyFunc verifies RSA signatures
+// For simplicity, skip key fetching in this example
+func defaultKeyFunc(token *jwt.Token) (interface{}, error) {
+	// Verify signing method
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	// Get the public key
+	return []byte("your-public-key"), nil
+}
+
+// RSAKeyFunc returns the jwt.Keyfunc of the tokens signed with RSA by the key of publicKey.
+func RSAKeyFunc(publicKey *rsa.PublicKey) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		return publicKey, nil
+	}
+}
 
-		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+// HMACKeyFunc returns the jwt.Keyfunc of the tokens signed with HMAC by secret, e.g.
+// Auth.JwtToken.Secret of the configuration.
+func HMACKeyFunc(secret []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return secret, nil
+	}
+}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Verify signing method
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			// Get the public key
-			// For simplicity, skip key fetching in this example
-			return []byte("your-public-key"), nil
-		})
+// ParseJWT validates a token, with or without its "Bearer " prefix, and returns its claims.
+func ParseJWT(tokenString string, keyFunc jwt.Keyfunc) (jwt.MapClaims, error) {
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	if tokenString == "" {
+		return nil, ErrMissingToken
+	}
+	token, err := jwt.Parse(tokenString, keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !token.Valid || !ok {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
 
-		if err != nil || !token.Valid {
+// JWTMiddleware validates the JWT token
+func JWTMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return JWTMiddlewareWithKey(next, defaultKeyFunc)
+}
+
+// JWTMiddlewareWithKey validates the JWT token with the key returned by keyFunc, see RSAKeyFunc
+// and HMACKeyFunc.
+func JWTMiddlewareWithKey(next fasthttp.RequestHandler, keyFunc jwt.Keyfunc) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		claims, err := ParseJWT(string(ctx.Request.Header.Peek("Authorization")), keyFunc)
+		if err != nil {
 			ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
 			return
 		}
 
 		// Set user information in context
-		ctx.SetUserValue("user", token.Claims.(jwt.MapClaims))
+		ctx.SetUserValue("user", claims)
 		next(ctx)
 	}
 }
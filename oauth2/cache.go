@@ -0,0 +1,64 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/rediskit"
+)
+
+// TokenCache stores the tokens of the sources sharing it. Get returns a nil token, without
+// error, for a missing key.
+type TokenCache interface {
+	Get(ctx context.Context, key string) (*Token, error)
+	Set(ctx context.Context, key string, token *Token) error
+	Delete(ctx context.Context, key string) error
+}
+
+// RedisStore is the subset of the rediskit client used by RedisCache.
+type RedisStore interface {
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Get(ctx context.Context, key string, dest interface{}) error
+	Delete(ctx context.Context, key string) error
+}
+
+var _ RedisStore = (*rediskit.RedisKitClient)(nil)
+
+// RedisCache is a TokenCache backed by rediskit, sharing the tokens between the replicas of a
+// service so that they are not fetched by each of them.
+type RedisCache struct {
+	store RedisStore
+}
+
+// NewRedisCache creates a RedisCache. The store should have an encryption sealer configured,
+// see rediskit.Config.Encryption, the tokens being credentials.
+func NewRedisCache(store RedisStore) *RedisCache {
+	return &RedisCache{store: store}
+}
+
+// Get returns the token of key.
+func (c *RedisCache) Get(ctx context.Context, key string) (*Token, error) {
+	var token Token
+	if err := c.store.Get(ctx, key, &token); err != nil {
+		if errors.Is(err, rediskit.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Set stores the token until it expires.
+func (c *RedisCache) Set(ctx context.Context, key string, token *Token) error {
+	ttl := time.Until(token.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return c.store.Set(ctx, key, token, ttl)
+}
+
+// Delete removes the token of key.
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	return c.store.Delete(ctx, key)
+}
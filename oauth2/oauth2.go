@@ -0,0 +1,268 @@
+// Package oauth2 authenticates outbound requests with bearer tokens obtained with the OAuth2
+// client credentials grant (RFC 6749 section 4.4). A TokenSource caches the token until shortly
+// before it expires, fetching it once for all the goroutines, and optionally shares it with
+// other replicas through a TokenCache:
+//
+//	src, err := oauth2.NewTokenSource(oauth2.FromAuth(cfg, "https://auth.example.com/oauth/token"))
+//	...
+//	client := requests.NewClient(requests.Config{Middleware: []requests.Middleware{src.Middleware()}})
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/config"
+	"github.com/seidu626/go-buildingblocks/requests"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNoToken is returned when the token endpoint responds without an access token.
+var ErrNoToken = errors.New("oauth2: no access token in response")
+
+// AuthStyle is how the client credentials are sent to the token endpoint.
+type AuthStyle int
+
+const (
+	// AuthStyleHeader sends them in a basic Authorization header, as recommended by RFC 6749
+	AuthStyleHeader AuthStyle = iota
+	// AuthStyleParams sends them as client_id and client_secret form parameters
+	AuthStyleParams
+)
+
+// Config holds the configuration of a TokenSource.
+type Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Audience of the tokens, sent as the audience parameter (Auth0 and similar), optional
+	Audience string
+	Scopes   []string
+	// EndpointParams are additional form parameters of the token requests
+	EndpointParams url.Values
+	AuthStyle      AuthStyle
+
+	// EarlyRefresh is how long before its expiry a token is refreshed (default 1m). The cached
+	// token is still used if the refresh fails.
+	EarlyRefresh time.Duration
+	// DefaultTTL is the lifetime of the tokens whose response has no expires_in (default 5m)
+	DefaultTTL time.Duration
+	// FetchTimeout bounds a token request, shared by all the waiting callers (default 30s)
+	FetchTimeout time.Duration
+
+	// Cache, optional, shares the tokens between replicas, e.g. a RedisCache
+	Cache TokenCache
+	// Client sends the token requests (default a requests.Client retrying them)
+	Client *requests.Client
+	Logger *zap.Logger
+}
+
+func (c *Config) setDefaults() {
+	if c.EarlyRefresh <= 0 {
+		c.EarlyRefresh = time.Minute
+	}
+	if c.DefaultTTL <= 0 {
+		c.DefaultTTL = 5 * time.Minute
+	}
+	if c.FetchTimeout <= 0 {
+		c.FetchTimeout = 30 * time.Second
+	}
+	if c.Client == nil {
+		// Getting a token has no side effect, the POST can be retried
+		c.Client = requests.NewClient(requests.Config{Timeout: c.FetchTimeout, Retry: requests.RetryPolicy{RetryNonIdempotent: true}})
+	}
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+}
+
+// FromAuth returns the Config of the client credentials of cfg.Auth, with the token endpoint
+// tokenURL.
+func FromAuth(cfg *config.Config, tokenURL string) Config {
+	return Config{
+		TokenURL:     tokenURL,
+		ClientID:     cfg.Auth.ClientID,
+		ClientSecret: cfg.Auth.ClientSecret,
+		Audience:     cfg.Auth.Audience,
+	}
+}
+
+// FromTIMWE returns the Config of the TIMWE_MA block of cfg: its API key and authentication key
+// are the client credentials, tokenPath is resolved against its base URL.
+func FromTIMWE(cfg *config.Config, tokenPath string) Config {
+	timwe := cfg.Application.TIMWE
+	return Config{
+		TokenURL:     strings.TrimSuffix(timwe.BaseURL, "/") + "/" + strings.TrimPrefix(tokenPath, "/"),
+		ClientID:     timwe.APIKey,
+		ClientSecret: timwe.AuthenticationKey,
+	}
+}
+
+// Token is an access token.
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Valid reports whether the token is set and not expired at now.
+func (t *Token) Valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && now.Before(t.ExpiresAt)
+}
+
+// TokenSource returns the tokens of a client, from its cache or the token endpoint. It is safe
+// for concurrent use.
+type TokenSource struct {
+	cfg   Config
+	key   string // Key of the tokens in the cache
+	group singleflight.Group
+	now   func() time.Time
+
+	mu    sync.Mutex
+	token *Token
+}
+
+// NewTokenSource creates a TokenSource.
+func NewTokenSource(cfg Config) (*TokenSource, error) {
+	if cfg.TokenURL == "" || cfg.ClientID == "" {
+		return nil, errors.New("oauth2: token URL and client ID required")
+	}
+	cfg.setDefaults()
+	// The key identifies the grant without revealing the secret
+	h := sha256.New()
+	for _, part := range []string{cfg.TokenURL, cfg.ClientID, cfg.ClientSecret, cfg.Audience, strings.Join(cfg.Scopes, " ")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	key := "oauth2:token:" + hex.EncodeToString(h.Sum(nil)[:16])
+	return &TokenSource{cfg: cfg, key: key, now: time.Now}, nil
+}
+
+// Token returns a valid token, refreshed when it is about to expire.
+func (s *TokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	token := s.token
+	s.mu.Unlock()
+	now := s.now()
+	if token.Valid(now.Add(s.cfg.EarlyRefresh)) {
+		return token, nil
+	}
+
+	ch := s.group.DoChan("token", func() (any, error) {
+		// Not canceled with the first caller, the others may still wait for it
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.FetchTimeout)
+		defer cancel()
+		return s.refresh(ctx)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			if token.Valid(s.now()) {
+				s.cfg.Logger.Warn("token refresh failed, using the cached token", zap.Error(res.Err))
+				return token, nil
+			}
+			return nil, res.Err
+		}
+		return res.Val.(*Token), nil
+	}
+}
+
+// refresh returns the token of the cache when still fresh, otherwise fetches a new one
+func (s *TokenSource) refresh(ctx context.Context) (*Token, error) {
+	if s.cfg.Cache != nil {
+		token, err := s.cfg.Cache.Get(ctx, s.key)
+		if err != nil {
+			s.cfg.Logger.Warn("token cache get failed", zap.Error(err))
+		} else if token.Valid(s.now().Add(s.cfg.EarlyRefresh)) {
+			s.set(token)
+			return token, nil
+		}
+	}
+
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.set(token)
+	if s.cfg.Cache != nil {
+		if err = s.cfg.Cache.Set(ctx, s.key, token); err != nil {
+			s.cfg.Logger.Warn("token cache set failed", zap.Error(err))
+		}
+	}
+	return token, nil
+}
+
+func (s *TokenSource) set(token *Token) {
+	s.mu.Lock()
+	s.token = token
+	s.mu.Unlock()
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// fetch requests a token from the token endpoint
+func (s *TokenSource) fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.Audience != "" {
+		form.Set("audience", s.cfg.Audience)
+	}
+	for k, v := range s.cfg.EndpointParams {
+		form[k] = v
+	}
+	b := s.cfg.Client.Post(s.cfg.TokenURL).Header("Accept", "application/json").MaxResponseSize(1 << 20)
+	if s.cfg.AuthStyle == AuthStyleParams {
+		form.Set("client_id", s.cfg.ClientID)
+		form.Set("client_secret", s.cfg.ClientSecret)
+	} else {
+		// RFC 6749 section 2.3.1: the credentials are form-encoded in the basic header
+		b.Header("Authorization", "Basic "+basicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret)))
+	}
+
+	issued := s.now()
+	resp, err := requests.DecodeJSON[tokenResponse](b.Form(form).Do(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: fetching token: %w", err)
+	}
+	if resp.AccessToken == "" {
+		return nil, ErrNoToken
+	}
+	ttl := time.Duration(resp.ExpiresIn) * time.Second
+	if ttl <= 0 {
+		ttl = s.cfg.DefaultTTL
+	}
+	s.cfg.Logger.Debug("fetched token", zap.String("token_url", s.cfg.TokenURL), zap.Duration("ttl", ttl))
+	return &Token{AccessToken: resp.AccessToken, TokenType: resp.TokenType, ExpiresAt: issued.Add(ttl)}, nil
+}
+
+// Invalidate drops accessToken, refused by a server, from the source and its cache so that
+// the next call of Token fetches another one. A token refreshed meanwhile is kept.
+func (s *TokenSource) Invalidate(ctx context.Context, accessToken string) {
+	s.mu.Lock()
+	current := s.token != nil && s.token.AccessToken == accessToken
+	if current {
+		s.token = nil
+	}
+	s.mu.Unlock()
+	if current && s.cfg.Cache != nil {
+		if err := s.cfg.Cache.Delete(ctx, s.key); err != nil {
+			s.cfg.Logger.Warn("token cache delete failed", zap.Error(err))
+		}
+	}
+}
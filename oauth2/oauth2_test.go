@@ -0,0 +1,194 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenServer issues the tokens token-1, token-2... valid for expiresIn seconds
+func tokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var issued atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("token-%d", issued.Add(1)),
+			"token_type":   "bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &issued
+}
+
+func TestTokenSourceCaches(t *testing.T) {
+	srv, issued := tokenServer(t, 3600)
+	src, err := NewTokenSource(Config{TokenURL: srv.URL, ClientID: "client", ClientSecret: "s3cret", Scopes: []string{"read", "write"}})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := src.Token(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token.AccessToken)
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, issued.Load(), "fetched once")
+
+	// Refreshed a minute before its expiry
+	now := time.Now()
+	src.now = func() time.Time { return now.Add(59 * time.Minute) }
+	token, err := src.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token.AccessToken)
+
+	// The cached token is used while the endpoint fails
+	src.cfg.ClientSecret = "wrong"
+	src.now = func() time.Time { return now.Add(time.Hour + 58*time.Minute + 30*time.Second) }
+	token, err = src.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token.AccessToken)
+	src.now = func() time.Time { return now.Add(3 * time.Hour) }
+	_, err = src.Token(context.Background())
+	assert.ErrorContains(t, err, "invalid_client")
+}
+
+func TestTokenSourceParams(t *testing.T) {
+	var form atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form.Store(r.PostForm)
+		_, _ = w.Write([]byte(`{"access_token":"abc"}`))
+	}))
+	defer srv.Close()
+
+	src, err := NewTokenSource(Config{TokenURL: srv.URL, ClientID: "client", ClientSecret: "s3cret", Audience: "https://api", AuthStyle: AuthStyleParams})
+	require.NoError(t, err)
+	token, err := src.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token.AccessToken)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), token.ExpiresAt, time.Second, "default TTL")
+	got := form.Load().(url.Values)
+	assert.Equal(t, []string{"client"}, got["client_id"])
+	assert.Equal(t, []string{"s3cret"}, got["client_secret"])
+	assert.Equal(t, []string{"https://api"}, got["audience"])
+
+	_, err = NewTokenSource(Config{ClientID: "client"})
+	assert.Error(t, err)
+}
+
+type memoryCache struct {
+	mu     sync.Mutex
+	tokens map[string]*Token
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) (*Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[key], nil
+}
+
+func (c *memoryCache) Set(_ context.Context, key string, token *Token) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = token
+	return nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tokens, key)
+	return nil
+}
+
+func TestTokenSourceSharedCache(t *testing.T) {
+	srv, issued := tokenServer(t, 3600)
+	cache := &memoryCache{tokens: map[string]*Token{}}
+	cfg := Config{TokenURL: srv.URL, ClientID: "client", ClientSecret: "s3cret", Cache: cache}
+	a, err := NewTokenSource(cfg)
+	require.NoError(t, err)
+	b, err := NewTokenSource(cfg)
+	require.NoError(t, err)
+
+	ta, err := a.Token(context.Background())
+	require.NoError(t, err)
+	tb, err := b.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, ta.AccessToken, tb.AccessToken)
+	assert.EqualValues(t, 1, issued.Load())
+
+	b.Invalidate(context.Background(), tb.AccessToken)
+	assert.Empty(t, cache.tokens)
+	tb, err = b.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", tb.AccessToken)
+
+	// Stale, not dropped
+	a.Invalidate(context.Background(), "token-0")
+	assert.Len(t, cache.tokens, 1)
+}
+
+func TestTransport(t *testing.T) {
+	tokens, issued := tokenServer(t, 3600)
+	var revoked atomic.Value
+	revoked.Store("token-1")
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("Authorization") == "Bearer "+revoked.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(r.Header.Get("Authorization") + ":" + string(body)))
+	}))
+	defer api.Close()
+
+	src, err := NewTokenSource(Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret"})
+	require.NoError(t, err)
+	c := requests.NewClient(requests.Config{Middleware: []requests.Middleware{src.Middleware()}})
+
+	body, err := requests.ReadBody(c.Post(api.URL).JSON("payload").Do(context.Background()))
+	require.NoError(t, err)
+	assert.Equal(t, `Bearer token-2:"payload"`, string(body), "retried with a new token")
+	assert.EqualValues(t, 2, issued.Load())
+	assert.EqualValues(t, 2, calls.Load())
+
+	revoked.Store("token-2")
+	body, err = requests.ReadBody(c.Post(api.URL).Body(io.MultiReader(strings.NewReader("once")), "text/plain").Do(context.Background()))
+	assert.ErrorContains(t, err, "Unauthorized", "body not replayable")
+	assert.Nil(t, body)
+	assert.EqualValues(t, 3, calls.Load())
+
+	// Cached: one call
+	calls.Store(0)
+	_, err = requests.ReadBody(c.Get(api.URL).Do(context.Background()))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, calls.Load())
+	assert.EqualValues(t, 3, issued.Load(), "fetched after the invalidation")
+}
@@ -0,0 +1,78 @@
+package oauth2
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/seidu626/go-buildingblocks/requests"
+)
+
+// Transport is an http.RoundTripper authenticating the requests with the tokens of Source. A
+// request refused with 401 is sent again once with a new token, when its body can be replayed.
+type Transport struct {
+	Source *TokenSource
+	// Base sends the requests (default http.DefaultTransport)
+	Base http.RoundTripper
+}
+
+// Middleware returns the requests.Middleware authenticating the requests of a requests.Client.
+func (s *TokenSource) Middleware() requests.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &Transport{Source: s, Base: next}
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, token, err := t.send(base, req, req.Body)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The token may have been revoked before its expiry
+	t.Source.Invalidate(req.Context(), token)
+	var body io.ReadCloser
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		if body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+	resp, _, err = t.send(base, req, body)
+	return resp, err
+}
+
+// send sends a copy of req authenticated with the current token, returning the token
+func (t *Transport) send(base http.RoundTripper, req *http.Request, body io.ReadCloser) (*http.Response, string, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, "", err
+	}
+	// A RoundTripper must not modify the request
+	r := req.Clone(req.Context())
+	r.Body = body
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	r.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+	resp, err := base.RoundTrip(r)
+	return resp, token.AccessToken, err
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
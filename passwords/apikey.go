@@ -0,0 +1,160 @@
+package passwords
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidAPIKey is returned for the API keys that are malformed, unknown, revoked or
+	// expired, deliberately not told apart
+	ErrInvalidAPIKey = errors.New("passwords: invalid API key")
+	// ErrAPIKeyNotFound is returned by the APIKeyStore for unknown prefixes
+	ErrAPIKeyNotFound = errors.New("passwords: API key not found")
+)
+
+// keyEncoding encodes the parts of the API keys, without "_" nor padding
+var keyEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// APIKey is the stored record of an API key: its public prefix, by which it is looked up, and
+// the hash of the key, never the key itself.
+type APIKey struct {
+	Prefix    string // e.g. "sk_live_q3x9fk2mwa7p", unique, shown to the users to tell their keys apart
+	Hash      []byte // SHA-256 of the key
+	Name      string
+	Owner     string
+	Scopes    []string
+	CreatedAt time.Time
+	ExpiresAt time.Time // Zero for no expiration
+	RevokedAt time.Time // Zero when not revoked
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key is neither revoked nor expired at now.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt.IsZero() && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// APIKeyStore finds the stored API keys by prefix.
+type APIKeyStore interface {
+	// FindAPIKey returns the key of prefix, ErrAPIKeyNotFound when there is none
+	FindAPIKey(ctx context.Context, prefix string) (*APIKey, error)
+}
+
+// APIKeyStoreFunc adapts a function to an APIKeyStore.
+type APIKeyStoreFunc func(ctx context.Context, prefix string) (*APIKey, error)
+
+func (f APIKeyStoreFunc) FindAPIKey(ctx context.Context, prefix string) (*APIKey, error) {
+	return f(ctx, prefix)
+}
+
+// APIKeyGenerator issues API keys "<namespace>_<id>_<secret>", e.g.
+// "sk_live_q3x9fk2mwa7p_7gk...": the namespace tells what the key is for, e.g. in secret
+// scanners, the namespace and the random id are the prefix by which the key is looked up. The
+// secret ends with a checksum, so mistyped keys are refused without a lookup.
+type APIKeyGenerator struct {
+	Namespace   string // e.g. "sk_live"
+	SecretBytes int    // Random bytes of the secrets (default 32)
+	TTL         time.Duration
+	Now         func() time.Time // Clock, for tests (default time.Now)
+}
+
+func (g *APIKeyGenerator) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+	return time.Now()
+}
+
+// Generate returns a new key, to be given to its owner once, and its record, to be stored.
+func (g *APIKeyGenerator) Generate(name, owner string, scopes ...string) (string, APIKey, error) {
+	if g.Namespace == "" {
+		return "", APIKey{}, errors.New("passwords: API key namespace not set")
+	}
+	size := g.SecretBytes
+	if size == 0 {
+		size = 32
+	}
+	if size < 16 {
+		return "", APIKey{}, fmt.Errorf("passwords: API key secret of %d bytes, at least 16 expected", size)
+	}
+	random := make([]byte, 8+size)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return "", APIKey{}, err
+	}
+	prefix := g.Namespace + "_" + keyEncoding.EncodeToString(random[:8])
+	secret := binary.BigEndian.AppendUint32(random[8:], crc32.ChecksumIEEE([]byte(prefix+string(random[8:]))))
+	key := prefix + "_" + keyEncoding.EncodeToString(secret)
+
+	hash := sha256.Sum256([]byte(key))
+	created := g.now()
+	record := APIKey{Prefix: prefix, Hash: hash[:], Name: name, Owner: owner, Scopes: scopes, CreatedAt: created}
+	if g.TTL > 0 {
+		record.ExpiresAt = created.Add(g.TTL)
+	}
+	return key, record, nil
+}
+
+// ParseAPIKey returns the prefix of a key of APIKeyGenerator, after checking its checksum.
+func ParseAPIKey(key string) (string, error) {
+	i := strings.LastIndexByte(key, '_')
+	if i <= 0 || len(key) > 512 {
+		return "", ErrInvalidAPIKey
+	}
+	prefix := key[:i]
+	if !strings.Contains(prefix, "_") {
+		return "", ErrInvalidAPIKey
+	}
+	secret, err := keyEncoding.DecodeString(key[i+1:])
+	if err != nil || len(secret) < 4 {
+		return "", ErrInvalidAPIKey
+	}
+	n := len(secret) - 4
+	if crc32.ChecksumIEEE([]byte(prefix+string(secret[:n]))) != binary.BigEndian.Uint32(secret[n:]) {
+		return "", ErrInvalidAPIKey
+	}
+	return prefix, nil
+}
+
+// VerifyAPIKey returns the record of key, looked up in store by prefix, when key matches its
+// hash and is active. The keys are random, so a fast hash compared in constant time is enough.
+func VerifyAPIKey(ctx context.Context, store APIKeyStore, key string) (*APIKey, error) {
+	prefix, err := ParseAPIKey(key)
+	if err != nil {
+		return nil, err
+	}
+	record, err := store.FindAPIKey(ctx, prefix)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	hash := sha256.Sum256([]byte(key))
+	if subtle.ConstantTimeCompare(hash[:], record.Hash) != 1 || !record.Active(time.Now()) {
+		return nil, ErrInvalidAPIKey
+	}
+	return record, nil
+}
@@ -0,0 +1,117 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Hasher hashes passwords with Argon2id, encoded in the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+//
+// The zero value uses the defaults of RFC 9106 (64 MiB of memory, 3 passes, 4 threads).
+type Argon2Hasher struct {
+	Time       uint32 // Number of passes (default 3)
+	Memory     uint32 // Memory in KiB (default 64 MiB)
+	Threads    uint8  // Parallelism (default 4)
+	SaltLength uint32 // Length of the salts in bytes (default 16)
+	KeyLength  uint32 // Length of the hashes in bytes (default 32)
+}
+
+func (h *Argon2Hasher) setDefaults() {
+	if h.Time == 0 {
+		h.Time = 3
+	}
+	if h.Memory == 0 {
+		h.Memory = 64 * 1024
+	}
+	if h.Threads == 0 {
+		h.Threads = 4
+	}
+	if h.SaltLength == 0 {
+		h.SaltLength = 16
+	}
+	if h.KeyLength == 0 {
+		h.KeyLength = 32
+	}
+}
+
+func (h Argon2Hasher) Hash(password string) (string, error) {
+	if len(password) > MaxLength {
+		return "", ErrPasswordTooLong
+	}
+	h.setDefaults()
+	salt := make([]byte, h.SaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2Hasher) Verify(password, encoded string) error {
+	params, salt, key, err := parseArgon2(encoded)
+	if err != nil {
+		return err
+	}
+	if len(password) > MaxLength {
+		return ErrMismatch
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (h Argon2Hasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := parseArgon2(encoded)
+	if err != nil {
+		return true
+	}
+	h.setDefaults()
+	return params.Time != h.Time || params.Memory != h.Memory || params.Threads != h.Threads ||
+		uint32(len(salt)) != h.SaltLength || uint32(len(key)) != h.KeyLength
+}
+
+// parseArgon2 parses a hash of Argon2Hasher
+func parseArgon2(encoded string) (params Argon2Hasher, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=4", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnsupportedHash
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: argon2 version %d", ErrUnsupportedHash, version)
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if params.Time == 0 || params.Threads == 0 || params.Memory < 8*uint32(params.Threads) {
+		return params, nil, nil, fmt.Errorf("%w: invalid parameters", ErrMalformedHash)
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if len(salt) < 8 || len(key) < 16 {
+		return params, nil, nil, fmt.Errorf("%w: salt or hash too short", ErrMalformedHash)
+	}
+	return params, salt, key, nil
+}
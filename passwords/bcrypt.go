@@ -0,0 +1,59 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher hashes passwords with bcrypt, in its modular crypt format ($2a$10$...), for the
+// compatibility with existing hashes. bcrypt is limited to passwords of 72 bytes, Argon2Hasher
+// is preferred.
+type BcryptHasher struct {
+	Cost int // Cost of the hashes (default bcrypt.DefaultCost)
+}
+
+func (h BcryptHasher) cost() int {
+	if h.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.Cost
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost())
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", ErrPasswordTooLong
+	}
+	return string(hash), err
+}
+
+func (h BcryptHasher) Verify(password, encoded string) error {
+	if !isBcrypt(encoded) {
+		return ErrUnsupportedHash
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword), errors.Is(err, bcrypt.ErrPasswordTooLong):
+		return ErrMismatch
+	}
+	return fmt.Errorf("%w: %w", ErrMalformedHash, err)
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || !isBcrypt(encoded) || cost != h.cost()
+}
+
+func isBcrypt(encoded string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encoded, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Package passwords hashes and verifies passwords and API keys.
+//
+// Passwords are hashed with Argon2id, in the PHC string format, or bcrypt, in its modular
+// crypt format. The parameters are part of the hashes, so the hashes of former parameters are
+// still verified and can be upgraded on login:
+//
+//	rehashed, err := passwords.VerifyAndRehash(hasher, password, user.PasswordHash)
+//	if err != nil {
+//		return ErrInvalidCredentials
+//	}
+//	if rehashed != "" {
+//		// Save rehashed as the new hash of the user
+//	}
+package passwords
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrMismatch is returned when a password does not match its hash
+	ErrMismatch = errors.New("passwords: password does not match")
+	// ErrUnsupportedHash is returned for the hashes of an unknown algorithm
+	ErrUnsupportedHash = errors.New("passwords: unsupported hash")
+	// ErrMalformedHash is returned for the hashes that cannot be parsed
+	ErrMalformedHash = errors.New("passwords: malformed hash")
+	// ErrPasswordTooLong is returned for the passwords longer than MaxLength
+	ErrPasswordTooLong = errors.New("passwords: password too long")
+)
+
+// MaxLength is the maximum length of the passwords in bytes, against denial of service with
+// huge passwords. bcrypt is limited to 72 bytes.
+const MaxLength = 1024
+
+// Hasher hashes passwords with an algorithm and its parameters.
+type Hasher interface {
+	// Hash returns the encoded hash of password, with a random salt
+	Hash(password string) (string, error)
+	// Verify returns nil when password matches the encoded hash, ErrMismatch otherwise. The
+	// comparison is in constant time.
+	Verify(password, encoded string) error
+	// NeedsRehash reports whether encoded is not a hash of the algorithm and parameters of the
+	// hasher, and should be replaced once the password is verified
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	_ Hasher = (*Argon2Hasher)(nil)
+	_ Hasher = (*BcryptHasher)(nil)
+)
+
+// Verify returns nil when password matches the encoded hash of any supported algorithm, with
+// the parameters recorded in the hash.
+func Verify(password, encoded string) error {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return (&Argon2Hasher{}).Verify(password, encoded)
+	case isBcrypt(encoded):
+		return (&BcryptHasher{}).Verify(password, encoded)
+	}
+	return ErrUnsupportedHash
+}
+
+// VerifyAndRehash verifies password with Verify and, when the hash was not made by current,
+// e.g. with former parameters or another algorithm, returns the new hash of the password to
+// be stored. It returns "" when the hash is current.
+func VerifyAndRehash(current Hasher, password, encoded string) (string, error) {
+	if err := Verify(password, encoded); err != nil {
+		return "", err
+	}
+	if !current.NeedsRehash(encoded) {
+		return "", nil
+	}
+	return current.Hash(password)
+}
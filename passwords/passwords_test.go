@@ -0,0 +1,149 @@
+package passwords
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fast keeps the tests quick, the defaults are used in production
+var fast = Argon2Hasher{Time: 1, Memory: 1024, Threads: 1}
+
+func TestArgon2(t *testing.T) {
+	hash, err := fast.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"), hash)
+
+	assert.NoError(t, fast.Verify("correct horse", hash))
+	assert.ErrorIs(t, fast.Verify("wrong horse", hash), ErrMismatch)
+	assert.NoError(t, Verify("correct horse", hash))
+	assert.False(t, fast.NeedsRehash(hash))
+
+	again, err := fast.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again, "random salts")
+
+	// The parameters of the hash are used to verify
+	assert.NoError(t, Argon2Hasher{}.Verify("correct horse", hash))
+	assert.True(t, Argon2Hasher{}.NeedsRehash(hash))
+
+	for _, malformed := range []string{
+		"", "$argon2id$", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA",
+	} {
+		assert.Error(t, fast.Verify("x", malformed), malformed)
+		assert.True(t, fast.NeedsRehash(malformed), malformed)
+	}
+
+	_, err = fast.Hash(strings.Repeat("x", MaxLength+1))
+	assert.ErrorIs(t, err, ErrPasswordTooLong)
+}
+
+func TestBcrypt(t *testing.T) {
+	hasher := BcryptHasher{Cost: bcrypt.MinCost}
+	hash, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NoError(t, hasher.Verify("correct horse", hash))
+	assert.ErrorIs(t, hasher.Verify("wrong horse", hash), ErrMismatch)
+	assert.NoError(t, Verify("correct horse", hash))
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.True(t, BcryptHasher{}.NeedsRehash(hash))
+
+	_, err = hasher.Hash(strings.Repeat("x", 73))
+	assert.ErrorIs(t, err, ErrPasswordTooLong)
+	assert.ErrorIs(t, hasher.Verify("x", "$argon2id$"), ErrUnsupportedHash)
+	assert.ErrorIs(t, Verify("x", "plaintext"), ErrUnsupportedHash)
+}
+
+func TestVerifyAndRehash(t *testing.T) {
+	legacy, err := BcryptHasher{Cost: bcrypt.MinCost}.Hash("correct horse")
+	require.NoError(t, err)
+
+	_, err = VerifyAndRehash(fast, "wrong horse", legacy)
+	assert.ErrorIs(t, err, ErrMismatch)
+
+	rehashed, err := VerifyAndRehash(fast, "correct horse", legacy)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rehashed, "$argon2id$"))
+
+	rehashed2, err := VerifyAndRehash(fast, "correct horse", rehashed)
+	require.NoError(t, err)
+	assert.Empty(t, rehashed2, "already current")
+
+	stronger := Argon2Hasher{Time: 2, Memory: 1024, Threads: 1}
+	rehashed3, err := VerifyAndRehash(stronger, "correct horse", rehashed)
+	require.NoError(t, err)
+	assert.Contains(t, rehashed3, "t=2")
+}
+
+func TestAPIKeys(t *testing.T) {
+	now := time.Now()
+	g := APIKeyGenerator{Namespace: "sk_live", TTL: time.Hour, Now: func() time.Time { return now }}
+	key, record, err := g.Generate("billing", "team-a", "invoices:read")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, record.Prefix+"_"), key)
+	assert.True(t, strings.HasPrefix(record.Prefix, "sk_live_"))
+	assert.NotContains(t, string(record.Hash), key)
+	assert.Equal(t, now.Add(time.Hour), record.ExpiresAt)
+	assert.True(t, record.HasScope("invoices:read"))
+
+	prefix, err := ParseAPIKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, record.Prefix, prefix)
+
+	lookups := 0
+	store := APIKeyStoreFunc(func(_ context.Context, prefix string) (*APIKey, error) {
+		lookups++
+		if prefix != record.Prefix {
+			return nil, ErrAPIKeyNotFound
+		}
+		return &record, nil
+	})
+	ctx := context.Background()
+	found, err := VerifyAPIKey(ctx, store, key)
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", found.Owner)
+
+	// Mistyped keys are refused by their checksum
+	typo := []byte(key)
+	typo[len(typo)-5] ^= 1
+	_, err = VerifyAPIKey(ctx, store, string(typo))
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	assert.Equal(t, 1, lookups)
+
+	other, _, err := g.Generate("other", "team-b")
+	require.NoError(t, err)
+	_, err = VerifyAPIKey(ctx, store, other)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey, "unknown prefix")
+
+	// Same prefix, other secret
+	forged, _, err := g.Generate("forged", "team-b")
+	require.NoError(t, err)
+	forgedPrefix, _ := ParseAPIKey(forged)
+	record.Prefix = forgedPrefix
+	_, err = VerifyAPIKey(ctx, store, forged)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	record.Prefix = prefix
+
+	record.RevokedAt = now
+	_, err = VerifyAPIKey(ctx, store, key)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey, "revoked")
+
+	notFound := APIKeyStoreFunc(func(context.Context, string) (*APIKey, error) { return nil, nil })
+	_, err = VerifyAPIKey(ctx, notFound, key)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey, "nil record")
+
+	for _, invalid := range []string{"", "sk", "sk_live", "nounderscore_", "sk_live_abc_!!!"} {
+		_, err = ParseAPIKey(invalid)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey, invalid)
+	}
+	_, _, err = (&APIKeyGenerator{}).Generate("x", "y")
+	assert.Error(t, err)
+}
@@ -12,21 +12,33 @@ type RedisCache struct {
 	encoder Encoder
 }
 
-// NewRedisCache initializes a new RedisCache with the provided Redis client and encoder
+// NewRedisCache initializes a new RedisCache with the provided Redis client and encoder. The
+// values of a KeyedEncoder are encoded by Set and Get, the cache stores their bytes as is.
 func NewRedisCache(client UniversalClient, encoder Encoder, defaultExpiration time.Duration) *RedisCache {
+	opts := &cache.Options{
+		Redis:      client,
+		Marshal:    encoder.Marshal,
+		Unmarshal:  encoder.Unmarshal,
+		LocalCache: cache.NewTinyLFU(1000, defaultExpiration), // optional local cache
+	}
+	if _, ok := encoder.(KeyedEncoder); ok {
+		opts.Marshal, opts.Unmarshal = nil, nil
+	}
 	return &RedisCache{
-		cache: cache.New(&cache.Options{
-			Redis:      client,
-			Marshal:    encoder.Marshal,
-			Unmarshal:  encoder.Unmarshal,
-			LocalCache: cache.NewTinyLFU(1000, defaultExpiration), // optional local cache
-		}),
+		cache:   cache.New(opts),
 		encoder: encoder,
 	}
 }
 
 // Set sets a value in the cache with the specified key and expiration
 func (rc *RedisCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if ke, ok := rc.encoder.(KeyedEncoder); ok {
+		data, err := ke.MarshalKey(ctx, key, value)
+		if err != nil {
+			return err
+		}
+		value = data
+	}
 	return rc.cache.Set(&cache.Item{
 		Ctx:   ctx,
 		Key:   key,
@@ -37,7 +49,15 @@ func (rc *RedisCache) Set(ctx context.Context, key string, value interface{}, ex
 
 // Get retrieves a value from the cache and unmarshals it into dest
 func (rc *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
-	return HandleError(rc.cache.Get(ctx, key, dest))
+	ke, ok := rc.encoder.(KeyedEncoder)
+	if !ok {
+		return HandleError(rc.cache.Get(ctx, key, dest))
+	}
+	var data []byte
+	if err := rc.cache.Get(ctx, key, &data); err != nil {
+		return HandleError(err)
+	}
+	return ke.UnmarshalKey(ctx, key, data, dest)
 }
 
 // Delete removes a key from the cache
@@ -37,6 +37,9 @@ func NewRedisKitClient(cfg *Config) (*RedisKitClient, error) {
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Encryption != nil {
+		encoder = NewEncryptedEncoder(encoder, cfg.Encryption)
+	}
 
 	if cfg.IsCluster {
 		if len(cfg.Addrs) == 0 {
@@ -54,6 +57,9 @@ func NewRedisKitClient(cfg *Config) (*RedisKitClient, error) {
 			TLSConfig: cfg.TLSConfig,
 		}
 		clusterClient := redis.NewClusterClient(clusterOptions)
+		for _, hook := range cfg.Hooks {
+			clusterClient.AddHook(hook)
+		}
 		if err := clusterClient.Ping(context.Background()).Err(); err != nil {
 			return nil, fmt.Errorf("failed to connect to Redis cluster: %v", err)
 		}
@@ -72,6 +78,9 @@ func NewRedisKitClient(cfg *Config) (*RedisKitClient, error) {
 			TLSConfig:       cfg.TLSConfig,
 		}
 		redisClient := redis.NewClient(options)
+		for _, hook := range cfg.Hooks {
+			redisClient.AddHook(hook)
+		}
 		if err := redisClient.Ping(context.Background()).Err(); err != nil {
 			return nil, fmt.Errorf("failed to connect to Redis: %v", err)
 		}
@@ -3,6 +3,8 @@ package rediskit
 import (
 	"crypto/tls"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 // Config holds the configuration for the RedisKit client
@@ -31,6 +33,8 @@ type Config struct {
 
 	// Encoding type: "json", "msgpack", "protobuf"
 	Encoding string
+	// Encryption of the cached values (optional), e.g. a *keyring.Keyring
+	Encryption Sealer
 
 	// Cluster settings
 	IsCluster bool
@@ -38,4 +42,7 @@ type Config struct {
 
 	// TLS settings (optional)
 	TLSConfig *tls.Config
+
+	// Hooks are added to the client (optional), see telemetry.Provider.InstrumentRedis
+	Hooks []redis.Hook
 }
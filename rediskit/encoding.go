@@ -1,6 +1,7 @@
 package rediskit
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/golang/protobuf/proto"
 	"github.com/vmihailenco/msgpack/v5"
@@ -53,6 +54,68 @@ func (pe *ProtobufEncoder) Unmarshal(data []byte, v interface{}) error {
 	return proto.Unmarshal(data, pb)
 }
 
+// Sealer encrypts and authenticates the encoded values, e.g. a *keyring.Keyring.
+type Sealer interface {
+	Seal(ctx context.Context, plaintext, aad []byte) ([]byte, error)
+	Open(ctx context.Context, ciphertext, aad []byte) ([]byte, error)
+}
+
+// KeyedEncoder is an Encoder whose encoded values are bound to their key, RedisCache uses it
+// instead of Marshal and Unmarshal
+type KeyedEncoder interface {
+	Encoder
+	MarshalKey(ctx context.Context, key string, v interface{}) ([]byte, error)
+	UnmarshalKey(ctx context.Context, key string, data []byte, v interface{}) error
+}
+
+// EncryptedEncoder implements KeyedEncoder by encrypting the values of another Encoder, bound to
+// their key so that a value cannot be moved to another key
+type EncryptedEncoder struct {
+	Encoder Encoder
+	Sealer  Sealer
+}
+
+// NewEncryptedEncoder returns an Encoder encrypting the values of encoder with sealer
+func NewEncryptedEncoder(encoder Encoder, sealer Sealer) *EncryptedEncoder {
+	return &EncryptedEncoder{Encoder: encoder, Sealer: sealer}
+}
+
+// Marshal encrypts a value not bound to a key, see MarshalKey
+func (ee *EncryptedEncoder) Marshal(v interface{}) ([]byte, error) {
+	return ee.seal(context.Background(), v, nil)
+}
+
+// Unmarshal decrypts a value of Marshal
+func (ee *EncryptedEncoder) Unmarshal(data []byte, v interface{}) error {
+	return ee.open(context.Background(), data, v, nil)
+}
+
+// MarshalKey encrypts the value of key, authenticating key
+func (ee *EncryptedEncoder) MarshalKey(ctx context.Context, key string, v interface{}) ([]byte, error) {
+	return ee.seal(ctx, v, []byte(key))
+}
+
+// UnmarshalKey decrypts a value of MarshalKey, failing when it was stored under another key
+func (ee *EncryptedEncoder) UnmarshalKey(ctx context.Context, key string, data []byte, v interface{}) error {
+	return ee.open(ctx, data, v, []byte(key))
+}
+
+func (ee *EncryptedEncoder) seal(ctx context.Context, v interface{}, aad []byte) ([]byte, error) {
+	data, err := ee.Encoder.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return ee.Sealer.Seal(ctx, data, aad)
+}
+
+func (ee *EncryptedEncoder) open(ctx context.Context, data []byte, v interface{}, aad []byte) error {
+	plaintext, err := ee.Sealer.Open(ctx, data, aad)
+	if err != nil {
+		return err
+	}
+	return ee.Encoder.Unmarshal(plaintext, v)
+}
+
 // SelectEncoder returns the appropriate Encoder based on the encoding type
 func SelectEncoder(encoding string) (Encoder, error) {
 	switch encoding {
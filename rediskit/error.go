@@ -3,6 +3,7 @@ package rediskit
 import (
 	"errors"
 
+	"github.com/go-redis/cache/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -19,7 +20,7 @@ var (
 
 // HandleError processes Redis errors and returns appropriate custom errors
 func HandleError(err error) error {
-	if errors.Is(err, redis.Nil) {
+	if errors.Is(err, redis.Nil) || errors.Is(err, cache.ErrCacheMiss) {
 		return ErrNotFound
 	}
 	// Add more error handling as needed
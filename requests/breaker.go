@@ -0,0 +1,168 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without sending the request, while the circuit breaker of its
+// host is open.
+var ErrCircuitOpen = errors.New("requests: circuit breaker open")
+
+// BreakerConfig holds the configuration of the per-host circuit breakers. A breaker opens after
+// FailureThreshold consecutive failures, transport errors or 5xx responses, refusing the
+// requests to the host for OpenTimeout. It then lets HalfOpenRequests requests through: their
+// success closes it, a failure opens it again.
+type BreakerConfig struct {
+	FailureThreshold int           // Default 5, negative to disable the breakers
+	OpenTimeout      time.Duration // Default 30s
+	HalfOpenRequests int           // Default 1
+}
+
+func (c *BreakerConfig) setDefaults() {
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+}
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type breakers struct {
+	cfg   BreakerConfig
+	mu    sync.Mutex
+	hosts map[string]*breaker
+	now   func() time.Time
+}
+
+func newBreakers(cfg BreakerConfig) *breakers {
+	return &breakers{cfg: cfg, hosts: make(map[string]*breaker), now: time.Now}
+}
+
+// get returns the breaker of host, nil when the breakers are disabled
+func (b *breakers) get(host string) *breaker {
+	if b.cfg.FailureThreshold < 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br, ok := b.hosts[host]
+	if !ok {
+		br = &breaker{cfg: &b.cfg, now: b.now}
+		b.hosts[host] = br
+	}
+	return br
+}
+
+type breaker struct {
+	cfg      *BreakerConfig
+	now      func() time.Time
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	inFlight int // Requests let through while half-open
+	probes   int // Number of the current half-open period, identifying its requests
+}
+
+// allow returns ErrCircuitOpen when the request must not be sent. Otherwise it returns the
+// number of the half-open period of the request, 0 when the breaker was closed, to be passed
+// to record.
+func (b *breaker) allow() (int, error) {
+	if b == nil {
+		return 0, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return 0, ErrCircuitOpen
+		}
+		b.state, b.inFlight = BreakerHalfOpen, 0
+		b.probes++
+		fallthrough
+	case BreakerHalfOpen:
+		if b.inFlight >= b.cfg.HalfOpenRequests {
+			return 0, ErrCircuitOpen
+		}
+		b.inFlight++
+		return b.probes, nil
+	}
+	return 0, nil
+}
+
+// record updates the breaker with the outcome of a request, probe being the result of allow.
+// While half-open, only the requests let through by the current period count: the outcomes of
+// the requests sent before the breaker opened arrive late.
+func (b *breaker) record(ctx context.Context, probe int, resp *http.Response, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		if probe != b.probes {
+			return
+		}
+		b.inFlight--
+	}
+	if err != nil && isCanceled(ctx, err) {
+		// Says nothing of the host
+		return
+	}
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	switch {
+	case !failed:
+		b.state, b.failures = BreakerClosed, 0
+	case b.state == BreakerHalfOpen:
+		b.state, b.openedAt = BreakerOpen, b.now()
+	default:
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.state, b.openedAt = BreakerOpen, b.now()
+		}
+	}
+}
+
+// BreakerState returns the state of the circuit breaker of host, e.g. "api.example.com:443"
+// as in the URL of the requests.
+func (c *Client) BreakerState(host string) BreakerState {
+	br := c.breakers.get(host)
+	if br == nil {
+		return BreakerClosed
+	}
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.state == BreakerOpen && c.breakers.now().Sub(br.openedAt) >= br.cfg.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return br.state
+}
@@ -0,0 +1,314 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Builder builds a request fluently, its errors being reported by Build or Do:
+//
+//	user, err := requests.DecodeJSON[User](client.Post("/users").
+//		Query("notify", "true").
+//		JSON(newUser).
+//		Do(ctx))
+type Builder struct {
+	client      *Client
+	method      string
+	url         string
+	query       url.Values
+	header      http.Header
+	contentType string
+	data        []byte    // In-memory body, replayed at each attempt
+	reader      io.Reader // Streamed body, read once
+	hasBody     bool
+	fields      url.Values // Fields of the multipart body
+	files       []File
+	maxSize     int64
+	err         error
+}
+
+// NewRequest returns a Builder of a request to rawURL, resolved against Config.BaseURL.
+func (c *Client) NewRequest(method, rawURL string) *Builder {
+	return &Builder{client: c, method: method, url: rawURL, query: url.Values{}, header: http.Header{}}
+}
+
+// Get returns a Builder of a GET request.
+func (c *Client) Get(rawURL string) *Builder { return c.NewRequest(http.MethodGet, rawURL) }
+
+// Post returns a Builder of a POST request.
+func (c *Client) Post(rawURL string) *Builder { return c.NewRequest(http.MethodPost, rawURL) }
+
+// Put returns a Builder of a PUT request.
+func (c *Client) Put(rawURL string) *Builder { return c.NewRequest(http.MethodPut, rawURL) }
+
+// Patch returns a Builder of a PATCH request.
+func (c *Client) Patch(rawURL string) *Builder { return c.NewRequest(http.MethodPatch, rawURL) }
+
+// Delete returns a Builder of a DELETE request.
+func (c *Client) Delete(rawURL string) *Builder { return c.NewRequest(http.MethodDelete, rawURL) }
+
+// Query adds a query parameter, encoded along with those of the URL.
+func (b *Builder) Query(key string, values ...string) *Builder {
+	for _, v := range values {
+		b.query.Add(key, v)
+	}
+	return b
+}
+
+// Header sets a header.
+func (b *Builder) Header(key, value string) *Builder {
+	b.header.Set(key, value)
+	return b
+}
+
+// BearerToken sets the bearer token of the request.
+func (b *Builder) BearerToken(token string) *Builder {
+	return b.Header("Authorization", "Bearer "+token)
+}
+
+// IdempotencyKey sets the Idempotency-Key header, allowing the retries of a POST or a PATCH.
+func (b *Builder) IdempotencyKey(key string) *Builder {
+	return b.Header("Idempotency-Key", key)
+}
+
+func (b *Builder) setBody(contentType string, data []byte, r io.Reader) *Builder {
+	if b.hasBody || b.files != nil || b.fields != nil {
+		b.err = errors.New("requests: body already set")
+	}
+	b.contentType, b.data, b.reader, b.hasBody = contentType, data, r, true
+	return b
+}
+
+// JSON sets the body to the JSON encoding of v.
+func (b *Builder) JSON(v any) *Builder {
+	data, err := json.Marshal(v)
+	if err != nil {
+		b.err = fmt.Errorf("requests: encoding JSON body: %w", err)
+		return b
+	}
+	return b.setBody("application/json", data, nil)
+}
+
+// Form sets the body to URL-encoded form values.
+func (b *Builder) Form(values url.Values) *Builder {
+	return b.setBody("application/x-www-form-urlencoded", []byte(values.Encode()), nil)
+}
+
+// Body sets the body, streamed from r. The request is not retried, r being read once.
+func (b *Builder) Body(r io.Reader, contentType string) *Builder {
+	return b.setBody(contentType, nil, r)
+}
+
+// File is a file of a multipart body.
+type File struct {
+	Field       string // Name of the form field
+	Name        string // File name
+	ContentType string // Default application/octet-stream
+	// Open returns the content of the file, called at each attempt
+	Open func() (io.ReadCloser, error)
+	once bool // Open succeeds once
+}
+
+// FileFromPath returns the File of the file at path, read at each attempt.
+func FileFromPath(field, path string) File {
+	return File{Field: field, Name: filepath.Base(path), Open: func() (io.ReadCloser, error) { return os.Open(path) }}
+}
+
+// FileFromReader returns a File read from r, which can be read once: the request is then
+// not retried.
+func FileFromReader(field, name string, r io.Reader) File {
+	return File{Field: field, Name: name, once: true, Open: func() (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	}}
+}
+
+// Multipart adds files to a multipart/form-data body, streamed while the request is sent.
+func (b *Builder) Multipart(files ...File) *Builder {
+	if b.hasBody {
+		b.err = errors.New("requests: body already set")
+	}
+	if b.files == nil {
+		b.files = []File{}
+	}
+	b.files = append(b.files, files...)
+	return b
+}
+
+// MultipartField adds a field to a multipart/form-data body.
+func (b *Builder) MultipartField(name, value string) *Builder {
+	if b.hasBody {
+		b.err = errors.New("requests: body already set")
+	}
+	if b.fields == nil {
+		b.fields = url.Values{}
+	}
+	b.fields.Add(name, value)
+	return b
+}
+
+// multipartBody streams the multipart body through a pipe, with a random boundary when empty
+func (b *Builder) multipartBody(boundary string) (io.ReadCloser, string, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	if boundary != "" {
+		if err := mw.SetBoundary(boundary); err != nil {
+			return nil, "", err
+		}
+	}
+	go func() {
+		err := b.writeMultipart(mw)
+		if err == nil {
+			err = mw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return pr, mw.FormDataContentType(), nil
+}
+
+func (b *Builder) writeMultipart(mw *multipart.Writer) error {
+	for name, values := range b.fields {
+		for _, v := range values {
+			if err := mw.WriteField(name, v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range b.files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.Field), escapeQuotes(f.Name)))
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h.Set("Content-Type", contentType)
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		r, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("requests: opening %s: %w", f.Name, err)
+		}
+		_, err = io.Copy(w, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string { return quoteEscaper.Replace(s) }
+
+// MaxResponseSize limits the size of the body of the response: reading beyond fails with
+// ErrBodyTooLarge. The body is otherwise streamed without limit.
+func (b *Builder) MaxResponseSize(n int64) *Builder {
+	b.maxSize = n
+	return b
+}
+
+// Build returns the request.
+func (b *Builder) Build(ctx context.Context) (*http.Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	u, err := b.client.resolve(b.url)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.query) > 0 {
+		query := u.Query()
+		for k, values := range b.query {
+			query[k] = append(query[k], values...)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	var body io.Reader
+	contentType := b.contentType
+	multipartBody := b.files != nil || b.fields != nil
+	switch {
+	case multipartBody:
+		if body, contentType, err = b.multipartBody(""); err != nil {
+			return nil, err
+		}
+	case b.data != nil:
+		// Replayed at each attempt
+		body = bytes.NewReader(b.data)
+	case b.reader != nil:
+		body = b.reader
+	}
+	req, err := http.NewRequestWithContext(ctx, b.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range b.header {
+		req.Header[k] = v
+	}
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if multipartBody && b.replayableFiles() {
+		_, params, _ := mime.ParseMediaType(contentType)
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, _, err := b.multipartBody(params["boundary"])
+			return body, err
+		}
+	}
+	return req, nil
+}
+
+// replayableFiles reports whether the files of the multipart body can be opened again
+func (b *Builder) replayableFiles() bool {
+	for _, f := range b.files {
+		if f.once {
+			return false
+		}
+	}
+	return true
+}
+
+// Do builds and sends the request with the Client.
+func (b *Builder) Do(ctx context.Context) (*http.Response, error) {
+	req, err := b.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if b.maxSize > 0 {
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: b.maxSize}
+	}
+	return resp, nil
+}
+
+// resolve resolves a URL against the base URL of the client
+func (c *Client) resolve(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if c.baseURL != nil {
+		u = c.baseURL.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("requests: invalid URL %q", rawURL)
+	}
+	return u, nil
+}
@@ -0,0 +1,180 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	apperrors "github.com/seidu626/go-buildingblocks/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echo struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Query       url.Values        `json:"query"`
+	ContentType string            `json:"content_type"`
+	Length      int64             `json:"length"`
+	Body        string            `json:"body"`
+	Form        map[string]string `json:"form"`
+}
+
+// echoServer responds with the request it received, failing the first failures requests
+func echoServer(t *testing.T, failures int32) *httptest.Server {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := echo{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), ContentType: r.Header.Get("Content-Type"), Length: r.ContentLength}
+		if strings.HasPrefix(e.ContentType, "multipart/") {
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			e.Form = map[string]string{}
+			for k, v := range r.MultipartForm.Value {
+				e.Form[k] = v[0]
+			}
+			for k, files := range r.MultipartForm.File {
+				f, err := files[0].Open()
+				require.NoError(t, err)
+				content, _ := io.ReadAll(f)
+				f.Close()
+				e.Form[k] = files[0].Filename + ":" + string(content)
+			}
+		} else {
+			body, _ := io.ReadAll(r.Body)
+			e.Body = string(body)
+		}
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(e)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBuilderJSON(t *testing.T) {
+	srv := echoServer(t, 1)
+	c := NewClient(Config{BaseURL: srv.URL + "/v1/", Retry: fastRetries})
+
+	got, err := DecodeJSON[echo](c.Post("users").
+		Query("notify", "true").
+		Query("tag", "a b", "c&d").
+		IdempotencyKey("create-42").
+		JSON(map[string]string{"name": "Ama"}).
+		Do(context.Background()))
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, got.Method)
+	assert.Equal(t, "/v1/users", got.Path)
+	assert.Equal(t, url.Values{"notify": {"true"}, "tag": {"a b", "c&d"}}, got.Query)
+	assert.Equal(t, "application/json", got.ContentType)
+	assert.Equal(t, `{"name":"Ama"}`, got.Body, "replayed on the retry")
+	assert.EqualValues(t, len(got.Body), got.Length)
+
+	got, err = DecodeJSON[echo](c.Get(srv.URL+"/search?q=go").Query("page", "2").Do(context.Background()))
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{"q": {"go"}, "page": {"2"}}, got.Query)
+}
+
+func TestBuilderForm(t *testing.T) {
+	srv := echoServer(t, 0)
+	c := NewClient(Config{})
+	got, err := DecodeJSON[echo](c.Put(srv.URL).Form(url.Values{"msisdn": {"+233 20"}}).Do(context.Background()))
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", got.ContentType)
+	assert.Equal(t, "msisdn=%2B233+20", got.Body)
+
+	got, err = DecodeJSON[echo](c.Put(srv.URL).Body(strings.NewReader("raw"), "text/plain").Do(context.Background()))
+	require.NoError(t, err)
+	assert.Equal(t, "raw", got.Body)
+
+	_, err = c.Post(srv.URL).JSON(1).Form(nil).Build(context.Background())
+	assert.Error(t, err, "two bodies")
+	_, err = c.Post(srv.URL).JSON(func() {}).Build(context.Background())
+	assert.Error(t, err)
+	_, err = c.Get("ftp://example.com").Build(context.Background())
+	assert.Error(t, err)
+}
+
+func TestBuilderMultipart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a,b\n1,2\n"), 0o600))
+
+	srv := echoServer(t, 1)
+	c := NewClient(Config{Retry: fastRetries})
+	got, err := DecodeJSON[echo](c.Put(srv.URL).
+		MultipartField("kind", "daily").
+		Multipart(FileFromPath("report", path)).
+		Do(context.Background()))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got.ContentType, "multipart/form-data; boundary="))
+	assert.Equal(t, map[string]string{"kind": "daily", "report": "report.csv:a,b\n1,2\n"}, got.Form, "replayed on the retry")
+
+	// Read once: not retried
+	srv = echoServer(t, 1)
+	_, err = DecodeJSON[echo](c.Put(srv.URL).
+		Multipart(FileFromReader("upload", "x.txt", strings.NewReader("x"))).
+		Do(context.Background()))
+	var e *apperrors.Error
+	require.ErrorAs(t, err, &e)
+	assert.EqualValues(t, http.StatusServiceUnavailable, e.Code)
+}
+
+func TestResponseErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service":
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(apperrors.NotFound("users.get", "user %d not found", 42))
+		case "/plain":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("missing msisdn\n"))
+		default:
+			_, _ = w.Write([]byte(`{"items":[` + strings.Repeat(`"x",`, 100) + `"x"]}`))
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(Config{BaseURL: srv.URL})
+	ctx := context.Background()
+
+	_, err := DecodeJSON[echo](c.Get("/service").Do(ctx))
+	var e *apperrors.Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, "users.get", e.Id)
+	assert.Equal(t, "user 42 not found", e.Detail)
+	assert.EqualValues(t, 404, e.Code)
+
+	_, err = ReadBody(c.Get("/plain").Do(ctx))
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, "requests", e.Id)
+	assert.Equal(t, "missing msisdn", e.Detail)
+	assert.Equal(t, "GET "+srv.URL+"/plain", e.Source)
+	assert.EqualValues(t, 400, e.Code)
+
+	type items struct{ Items []string }
+	_, err = DecodeJSON[items](c.Get("/big").MaxResponseSize(64).Do(ctx))
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	got, err := DecodeJSON[items](c.Get("/big").Do(ctx))
+	require.NoError(t, err)
+	assert.Len(t, got.Items, 101)
+
+	// Streamed
+	resp, err := c.Get("/big").MaxResponseSize(1 << 10).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	n, err := io.Copy(io.Discard, resp.Body)
+	require.NoError(t, err)
+	assert.EqualValues(t, 415, n)
+
+	_, err = ReadBody(nil, errors.New("dial failed"))
+	assert.EqualError(t, err, "dial failed")
+}
@@ -0,0 +1,238 @@
+package requests
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Config holds the configuration of a Client.
+type Config struct {
+	// BaseURL against which the URLs of the Builder requests are resolved, e.g.
+	// "https://api.example.com/v1/"
+	BaseURL string
+	// Timeout of each attempt, until the headers of the response are received and while its
+	// body is read (default 30s), the overall deadline is the one of the context of Do
+	Timeout time.Duration
+	Retry   RetryPolicy
+	Breaker BreakerConfig
+
+	// Transport of the requests, by default a transport tuned with the pool settings below,
+	// shared by all the requests of the client
+	Transport           http.RoundTripper
+	MaxIdleConns        int           // Idle connections kept, all hosts (default 100)
+	MaxIdleConnsPerHost int           // Idle connections kept per host (default 10)
+	MaxConnsPerHost     int           // Connections per host, 0 for no limit
+	IdleConnTimeout     time.Duration // Default 90s
+	TLSConfig           *tls.Config
+
+	// Middleware wraps the transport, the first one being the outermost. They run at each
+	// attempt, e.g. to sign the requests or count the attempts.
+	Middleware []Middleware
+	Logger     *zap.Logger
+}
+
+func (c *Config) setDefaults() {
+	if c.Timeout == 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = 100
+	}
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = 10
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = 90 * time.Second
+	}
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+	c.Retry.setDefaults()
+	c.Breaker.setDefaults()
+}
+
+// NewTransport returns a transport with the connection pool settings of cfg, to be shared by
+// the requests rather than created for each.
+func NewTransport(cfg Config) *http.Transport {
+	cfg.setDefaults()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSClientConfig:       cfg.TLSConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// Client sends HTTP requests with retries, backoff and per-host circuit breakers. It is safe
+// for concurrent use and meant to be shared, to reuse its connections.
+type Client struct {
+	cfg       Config
+	transport http.RoundTripper // Transport wrapped by the middleware
+	breakers  *breakers
+	baseURL   *url.URL
+	logger    *zap.Logger
+}
+
+// NewClient creates a Client. It panics when Config.BaseURL is not a valid URL.
+func NewClient(cfg Config) *Client {
+	cfg.setDefaults()
+	var baseURL *url.URL
+	if cfg.BaseURL != "" {
+		var err error
+		if baseURL, err = url.Parse(cfg.BaseURL); err != nil {
+			panic(fmt.Sprintf("requests: invalid base URL: %v", err))
+		}
+	}
+	transport := cfg.Transport
+	if transport == nil {
+		transport = NewTransport(cfg)
+	}
+	for i := len(cfg.Middleware) - 1; i >= 0; i-- {
+		transport = cfg.Middleware[i](transport)
+	}
+	return &Client{cfg: cfg, transport: transport, breakers: newBreakers(cfg.Breaker), baseURL: baseURL, logger: cfg.Logger}
+}
+
+// Do sends req with ctx, retrying it according to the retry policy. The response of the last
+// attempt is returned whatever its status, its body must be closed. The requests with a body
+// are retried only when it can be obtained again, i.e. req.GetBody is set, as done by
+// http.NewRequest for in-memory bodies.
+func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	policy := c.cfg.Retry
+	retryable := policy.allows(req)
+	host := req.URL.Host
+
+	for attempt := 1; ; attempt++ {
+		breaker := c.breakers.get(host)
+		probe, err := breaker.allow()
+		if err != nil {
+			if attempt == 1 && req.Body != nil {
+				// Closed by the transport once sent, e.g. to stop the writer of a streamed body
+				req.Body.Close()
+			}
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Redacted(), err)
+		}
+
+		resp, err := c.attempt(ctx, req, attempt)
+		breaker.record(ctx, probe, resp, err)
+
+		if attempt >= policy.MaxAttempts || !retryable || ctx.Err() != nil || !policy.shouldRetry(resp, err) {
+			return resp, err
+		}
+		delay := policy.Backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = after
+				if after > policy.MaxRetryAfter {
+					// Not worth waiting, the caller gets the response
+					return resp, nil
+				}
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// No time left for another attempt
+			return resp, err
+		}
+		if resp != nil {
+			drain(resp.Body)
+		}
+		c.logger.Debug("retrying request",
+			zap.String("method", req.Method),
+			zap.String("url", req.URL.Redacted()),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+		if err = sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt sends a copy of req, with a fresh body and the timeout of an attempt
+func (c *Client) attempt(ctx context.Context, req *http.Request, attempt int) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, attemptKey{}, attempt), c.cfg.Timeout)
+	r := req.Clone(ctx)
+	if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+	resp, err := c.transport.RoundTrip(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout covers the reading of the body
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// HTTPClient returns an *http.Client sending its requests through c, for the libraries
+// expecting one. Its redirects are followed by the http.Client.
+func (c *Client) HTTPClient() *http.Client {
+	return &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return c.Do(req.Context(), req)
+	})}
+}
+
+type attemptKey struct{}
+
+// Attempt returns the number of the attempt of a request, from 1, in the context of the
+// requests given to the middleware; 0 out of a Client.
+func Attempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// drain reads what is left of a body, up to a limit, so that its connection is reused
+func drain(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	_ = body.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isCanceled reports whether err comes from the cancellation of the request by its caller
+func isCanceled(ctx context.Context, err error) bool {
+	return errors.Is(err, context.Canceled) && ctx.Err() != nil
+}
@@ -0,0 +1,275 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetries keeps the tests quick
+var fastRetries = RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// flaky returns a server failing with status the first failures requests
+func flaky(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write(append([]byte("ok:"), body...))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestClientRetries(t *testing.T) {
+	srv, calls := flaky(t, 2, http.StatusServiceUnavailable, nil)
+	c := NewClient(Config{Retry: fastRetries})
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+	require.NoError(t, err)
+	resp, err := c.Do(context.Background(), req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok:payload", string(body), "body replayed")
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestClientGivesUp(t *testing.T) {
+	srv, calls := flaky(t, 10, http.StatusBadGateway, nil)
+	c := NewClient(Config{Retry: fastRetries, Breaker: BreakerConfig{FailureThreshold: -1}})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(context.Background(), req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode, "last response returned")
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestClientIdempotency(t *testing.T) {
+	srv, calls := flaky(t, 1, http.StatusServiceUnavailable, nil)
+	c := NewClient(Config{Retry: fastRetries})
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("order"))
+	resp, err := c.Do(context.Background(), req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "POST not retried")
+	assert.EqualValues(t, 1, calls.Load())
+
+	req, _ = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("order"))
+	req.Header.Set("Idempotency-Key", "order-42")
+	calls.Store(0)
+	resp, err = c.Do(context.Background(), req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "retried with an idempotency key")
+
+	// A body that cannot be replayed
+	calls.Store(0)
+	req, _ = http.NewRequest(http.MethodPut, srv.URL, io.NopCloser(strings.NewReader("x")))
+	resp, err = c.Do(context.Background(), req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	srv, calls := flaky(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+	c := NewClient(Config{Retry: fastRetries})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	start := time.Now()
+	resp, err := c.Do(context.Background(), req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "Retry-After honoured")
+
+	// Too long to wait
+	srv, calls = flaky(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}})
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err = c.Do(context.Background(), req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.EqualValues(t, 1, calls.Load())
+
+	// Not within the deadline of the context
+	srv, calls = flaky(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}})
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err = c.Do(ctx, req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestClientTimeouts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("fast"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(Config{Timeout: 50 * time.Millisecond, Retry: fastRetries})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(context.Background(), req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "fast", string(body), "slow attempt timed out and retried")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Do(ctx, req)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBreaker(t *testing.T) {
+	srv, calls := flaky(t, 100, http.StatusInternalServerError, nil)
+	c := NewClient(Config{
+		Retry:   RetryPolicy{MaxAttempts: 1},
+		Breaker: BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute},
+	})
+	now := time.Now()
+	c.breakers.now = func() time.Time { return now }
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(context.Background(), req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, BreakerOpen, c.BreakerState(host))
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err := c.Do(context.Background(), req)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.EqualValues(t, 3, calls.Load(), "not sent while open")
+
+	// Half-open: a failure opens it again
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, c.BreakerState(host))
+	resp, err := c.Do(context.Background(), req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, BreakerOpen, c.BreakerState(host))
+
+	// A success closes it
+	now = now.Add(time.Minute)
+	calls.Store(1000)
+	resp, err = c.Do(context.Background(), req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, BreakerClosed, c.BreakerState(host))
+}
+
+func TestBreakerLateOutcomes(t *testing.T) {
+	cfg := BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 1}
+	now := time.Now()
+	b := &breaker{cfg: &cfg, now: func() time.Time { return now }}
+	ctx := context.Background()
+	down := errors.New("connection refused")
+
+	first, err := b.allow()
+	require.NoError(t, err)
+	late, err := b.allow()
+	require.NoError(t, err)
+	b.record(ctx, first, nil, down)
+	assert.Equal(t, BreakerOpen, b.state)
+
+	now = now.Add(time.Minute)
+	probe, err := b.allow()
+	require.NoError(t, err)
+	// Sent before the breaker opened: neither frees the probe slot nor opens it again
+	b.record(ctx, late, nil, down)
+	assert.Equal(t, BreakerHalfOpen, b.state)
+	_, err = b.allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	b.record(ctx, probe, &http.Response{StatusCode: http.StatusOK}, nil)
+	assert.Equal(t, BreakerClosed, b.state)
+}
+
+func TestMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(r.Header.Get("User-Agent")))
+	}))
+	defer srv.Close()
+
+	var tokens, observed atomic.Int32
+	var attempts []int
+	c := NewClient(Config{
+		Retry: fastRetries,
+		Middleware: []Middleware{
+			Header("User-Agent", "buildingblocks"),
+			BearerToken(func(ctx context.Context) (string, error) {
+				return "token-" + string(rune('0'+tokens.Add(1))), nil
+			}),
+			Observe(func(req *http.Request, resp *http.Response, err error, _ time.Duration) {
+				observed.Add(1)
+				attempts = append(attempts, Attempt(req.Context()))
+			}),
+		},
+	})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(context.Background(), req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "buildingblocks", string(body))
+	assert.EqualValues(t, 2, observed.Load())
+	assert.Equal(t, []int{1, 2}, attempts)
+	assert.Empty(t, req.Header.Get("Authorization"), "request of the caller unchanged")
+
+	failing := NewClient(Config{Middleware: []Middleware{BearerToken(func(context.Context) (string, error) {
+		return "", errors.New("no token")
+	})}})
+	_, err = failing.HTTPClient().Get(srv.URL)
+	assert.ErrorContains(t, err, "no token")
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: -1}
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 400*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, time.Second, p.Backoff(10))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(2)
+		assert.True(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond, d)
+	}
+}
@@ -0,0 +1,116 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	apperrors "github.com/seidu626/go-buildingblocks/errors"
+)
+
+// DefaultMaxBodySize is the size of the bodies read by ReadBody and DecodeJSON when the
+// response has no limit of its own, see Builder.MaxResponseSize.
+const DefaultMaxBodySize = 10 << 20
+
+// maxErrorBodySize is the size of the error responses read to build their error
+const maxErrorBodySize = 64 << 10
+
+// ErrBodyTooLarge is returned when reading a body beyond its limit.
+var ErrBodyTooLarge = errors.New("requests: body too large")
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Anything left is beyond the limit
+		var one [1]byte
+		n, err := b.ReadCloser.Read(one[:])
+		if n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// limit limits a body to DefaultMaxBodySize, unless already limited
+func limit(body io.ReadCloser) io.ReadCloser {
+	if _, ok := body.(*limitedBody); ok {
+		return body
+	}
+	return &limitedBody{ReadCloser: body, remaining: DefaultMaxBodySize}
+}
+
+// CheckStatus returns nil for the responses of status below 400, otherwise an *errors.Error
+// with the status as Code, read from the body when it is one, e.g. the response of a service
+// of this module. The body is then read and closed.
+func CheckStatus(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	e := &apperrors.Error{}
+	if json.Unmarshal(body, e) != nil || (e.Id == "" && e.Detail == "") {
+		e = &apperrors.Error{Detail: strings.TrimSpace(string(body))}
+	}
+	if e.Id == "" {
+		e.Id = "requests"
+	}
+	if e.Source == "" && resp.Request != nil {
+		e.Source = resp.Request.Method + " " + resp.Request.URL.Redacted()
+	}
+	if e.Detail == "" {
+		e.Detail = resp.Status
+	}
+	e.Code = int32(resp.StatusCode)
+	e.Status = http.StatusText(resp.StatusCode)
+	return e
+}
+
+// ReadBody reads and closes the body of resp, returning an *errors.Error for the error
+// statuses as CheckStatus.
+func ReadBody(resp *http.Response, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckStatus(resp); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(limit(resp.Body))
+}
+
+// DecodeJSON decodes the JSON body of resp into a T, returning an *errors.Error for the
+// error statuses as CheckStatus. It takes the results of Do, to be chained:
+//
+//	user, err := requests.DecodeJSON[User](client.Get("/users/42").Do(ctx))
+func DecodeJSON[T any](resp *http.Response, err error) (T, error) {
+	var v T
+	if err != nil {
+		return v, err
+	}
+	if err = CheckStatus(resp); err != nil {
+		return v, err
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(limit(resp.Body)).Decode(&v); err != nil {
+		if errors.Is(err, ErrBodyTooLarge) {
+			return v, err
+		}
+		return v, fmt.Errorf("requests: decoding JSON response: %w", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,106 @@
+package requests
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/logging"
+	"go.uber.org/zap"
+)
+
+// Middleware wraps the transport of a Client, to intercept each attempt of the requests.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to an http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Header sets a header on the requests, e.g. a User-Agent.
+func Header(key, value string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// The request of a middleware must not be modified, see http.RoundTripper
+			req = req.Clone(req.Context())
+			req.Header.Set(key, value)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// BasicAuth sets the basic authentication of the requests.
+func BasicAuth(username, password string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.SetBasicAuth(username, password)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// BearerToken sets the bearer token of the requests, obtained at each attempt from token so
+// that a refreshed token is used by the retries.
+func BearerToken(token func(ctx context.Context) (string, error)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			t, err := token(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+t)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Logging logs the attempts: at debug level when they succeed, at warn level on transport
+// errors and 5xx responses, with the request-scoped fields of their context, see
+// logging.WithFields.
+func Logging(logger *zap.Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			fields := []zap.Field{
+				zap.String("method", req.Method),
+				zap.String("url", req.URL.Redacted()),
+				zap.Int("attempt", Attempt(req.Context())),
+				zap.Duration("elapsed", time.Since(start)),
+			}
+			logger := logger
+			if scoped := logging.Fields(req.Context()); len(scoped) > 0 {
+				logger = logger.With(scoped...)
+			}
+			switch {
+			case err != nil:
+				logger.Warn("request failed", append(fields, zap.Error(err))...)
+			case resp.StatusCode >= http.StatusInternalServerError:
+				logger.Warn("request failed", append(fields, zap.Int("status", resp.StatusCode))...)
+			default:
+				logger.Debug("request sent", append(fields, zap.Int("status", resp.StatusCode))...)
+			}
+			return resp, err
+		})
+	}
+}
+
+// Observer receives the outcome of each attempt, e.g. to record metrics. resp is nil when err
+// is not.
+type Observer func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)
+
+// Observe calls observer after each attempt.
+func Observe(observer Observer) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			observer(req, resp, err, time.Since(start))
+			return resp, err
+		})
+	}
+}
@@ -197,6 +197,7 @@ func (req *Request) SetTransportLayer(tl *http.Transport) {
 // InitRequest is delegated to initialize a new request with the given parameter.
 // NOTE: it will use the default timeout -> NO TIMEOUT. In order to specify a different timeout you can use the delegated method
 // NOTE: headers have to be set with the delegated method
+// NOTE: Client.NewRequest builds requests with typed bodies, encoded queries and retries
 func InitRequest(url, method string, bodyData []byte, skipTLS, debug bool) (*Request, error) {
 	var err error
 	var req Request
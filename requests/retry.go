@@ -0,0 +1,120 @@
+package requests
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines which attempts are retried and how long to wait in between.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, the first included (default 3), 1 to disable the
+	// retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry (default 100ms), doubled at each retry
+	// up to MaxBackoff (default 10s)
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of the backoff randomized, to spread the retries of concurrent
+	// clients (default 0.5, negative for none)
+	Jitter float64
+	// MaxRetryAfter is the longest Retry-After of a response that is waited for, the response
+	// is returned otherwise (default 1m)
+	MaxRetryAfter time.Duration
+	// RetryStatuses are the statuses retried (default 429, 502, 503 and 504)
+	RetryStatuses []int
+	// RetryNonIdempotent retries POST and PATCH requests, otherwise only retried when they
+	// have an Idempotency-Key header
+	RetryNonIdempotent bool
+	// ShouldRetry, when set, replaces the checks of the statuses and errors
+	ShouldRetry func(resp *http.Response, err error) bool
+}
+
+func (p *RetryPolicy) setDefaults() {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 10 * time.Second
+	}
+	if p.Jitter == 0 {
+		p.Jitter = 0.5
+	}
+	if p.MaxRetryAfter <= 0 {
+		p.MaxRetryAfter = time.Minute
+	}
+	if p.RetryStatuses == nil {
+		p.RetryStatuses = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+}
+
+// Backoff returns the wait after the attempt-th attempt: exponential, capped and jittered.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	p.setDefaults()
+	d := float64(p.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := min(p.Jitter, 1)
+		d = d*(1-jitter) + d*jitter*rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// allows reports whether req can be retried: idempotent, with a body that can be replayed
+func (p *RetryPolicy) allows(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return p.RetryNonIdempotent || req.Header.Get("Idempotency-Key") != ""
+}
+
+func (p *RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if p.ShouldRetry != nil {
+		return p.ShouldRetry(resp, err)
+	}
+	if err != nil {
+		// The circuit breaker and the TLS errors are not transient
+		return !errors.Is(err, ErrCircuitOpen) && !isTLSError(err)
+	}
+	return slices.Contains(p.RetryStatuses, resp.StatusCode)
+}
+
+// retryAfter returns the delay of the Retry-After header of resp, in seconds or as a date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func isTLSError(err error) bool {
+	var (
+		verification *tls.CertificateVerificationError
+		unknown      x509.UnknownAuthorityError
+		hostname     x509.HostnameError
+		record       tls.RecordHeaderError
+	)
+	return errors.As(err, &verification) || errors.As(err, &unknown) || errors.As(err, &hostname) || errors.As(err, &record)
+}
@@ -0,0 +1,108 @@
+package sftputils
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/pkg/sftp"
+)
+
+var ErrPoolClosed = errors.New("SFTP pool closed")
+
+// Pool keeps up to size SFTP sessions open with the same configuration, reusing the idle ones.
+// It is safe for concurrent use.
+type Pool struct {
+	conf         SFTPConf
+	keyExchanges []string
+	slots        chan struct{}
+
+	mu     sync.Mutex
+	idle   []*SFTPClient
+	closed bool
+}
+
+// NewPool creates a pool of at most size sessions (default 4). Sessions are opened on demand.
+func NewPool(conf SFTPConf, size int, keyExchanges ...string) (*Pool, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		size = 4
+	}
+	return &Pool{conf: conf, keyExchanges: keyExchanges, slots: make(chan struct{}, size)}, nil
+}
+
+// Get returns an idle session or opens a new one, waiting while size sessions are in use.
+// The session must be given back with Put.
+func (p *Pool) Get(ctx context.Context) (*SFTPClient, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.slots
+			return nil, ErrPoolClosed
+		}
+		var client *SFTPClient
+		if n := len(p.idle); n > 0 {
+			client, p.idle = p.idle[n-1], p.idle[:n-1]
+		}
+		p.mu.Unlock()
+		if client == nil {
+			break
+		}
+		// Idle sessions may have been dropped by the server
+		if _, err := client.Client.Getwd(); err == nil {
+			return client, nil
+		}
+		_ = client.Close()
+	}
+
+	client, err := p.conf.NewConnContext(ctx, p.keyExchanges...)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return client, nil
+}
+
+// Put gives back a session obtained with Get. Broken sessions are closed instead of reused.
+func (p *Pool) Put(client *SFTPClient, broken bool) {
+	defer func() { <-p.slots }()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if broken || p.closed {
+		_ = client.Close()
+		return
+	}
+	p.idle = append(p.idle, client)
+}
+
+// Do runs fn with a session of the pool. The session is discarded if fn fails because the connection was lost.
+func (p *Pool) Do(ctx context.Context, fn func(client *SFTPClient) error) error {
+	client, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(client)
+	p.Put(client, errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection))
+	return err
+}
+
+// Close closes the idle sessions. Sessions in use are closed when given back.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var errs []error
+	for _, client := range p.idle {
+		errs = append(errs, client.Close())
+	}
+	p.idle = nil
+	return errors.Join(errs...)
+}
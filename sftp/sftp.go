@@ -2,20 +2,25 @@ package sftputils
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	arrayutils "github.com/seidu626/go-buildingblocks/array"
 	httputils "github.com/seidu626/go-buildingblocks/http"
 	stringutils "github.com/seidu626/go-buildingblocks/string"
 	"io"
+	"io/fs"
 	"log"
 	"net"
-	"path"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 var DEFAULT_KEY_EXCHANGE_ALGO = []string{"diffie-hellman-group-exchange-sha256"}
@@ -28,8 +33,25 @@ type SFTPConf struct {
 	Port     int    `json:"port"`
 	Timeout  int    `json:"timeout"`
 	PrivKey  string `json:"priv_key"`
+
+	// PrivKeyPassphrase decrypts PrivKey when it is encrypted
+	PrivKeyPassphrase string `json:"priv_key_passphrase"`
+	// UseAgent authenticates with the keys of the ssh-agent listening on SSH_AUTH_SOCK
+	UseAgent bool `json:"use_agent"`
+
+	// HostKeyFingerprints pins the accepted host keys, in the SHA256:... or legacy MD5 format of ssh-keygen -l.
+	// When empty the host key is checked against KnownHostsFile, or ~/.ssh/known_hosts if it exists.
+	HostKeyFingerprints []string `json:"host_key_fingerprints"`
+	KnownHostsFile      string   `json:"known_hosts_file"`
+	// InsecureIgnoreHostKey disables the host key verification when no fingerprint or known_hosts is available
+	InsecureIgnoreHostKey bool `json:"insecure_ignore_host_key"`
 }
 
+var (
+	ErrNoHostKeyVerification = errors.New("SFTP host key verification not configured: set host_key_fingerprints, known_hosts_file or insecure_ignore_host_key")
+	ErrHostKeyMismatch       = errors.New("SFTP host key does not match the pinned fingerprints")
+)
+
 func (c SFTPConf) Validate() error {
 	if stringutils.IsBlank(c.Host) {
 		return errors.New("SFTP host not provided")
@@ -37,7 +59,7 @@ func (c SFTPConf) Validate() error {
 	if stringutils.IsBlank(c.User) {
 		return errors.New("SFTP user not provided")
 	}
-	if stringutils.IsBlank(c.Password) && stringutils.IsBlank(c.PrivKey) {
+	if stringutils.IsBlank(c.Password) && stringutils.IsBlank(c.PrivKey) && !c.UseAgent {
 		return errors.New("SFTP password and priv_key not provided")
 	}
 	if !httputils.ValidatePort(c.Port) {
@@ -49,6 +71,7 @@ func (c SFTPConf) Validate() error {
 type SFTPClient struct {
 	Client *sftp.Client
 	Bucket string
+	conn   *ssh.Client
 }
 
 // RenameFile Example function for rename the file before upload to S3
@@ -61,6 +84,11 @@ func RenameFile(fName string) string {
 
 // NewConn Create a new SFTP connection by given parameters
 func (c SFTPConf) NewConn(keyExchanges ...string) (*SFTPClient, error) {
+	return c.NewConnContext(context.Background(), keyExchanges...)
+}
+
+// NewConnContext creates a new SFTP connection, ctx bounds the dial and the ssh handshake.
+func (c SFTPConf) NewConnContext(ctx context.Context, keyExchanges ...string) (*SFTPClient, error) {
 	if err := c.Validate(); err != nil {
 		return nil, err
 	}
@@ -71,66 +99,152 @@ func (c SFTPConf) NewConn(keyExchanges ...string) (*SFTPClient, error) {
 			keyExchanges = append(keyExchanges, algo)
 		}
 	}
-	var auth []ssh.AuthMethod
-
-	if !stringutils.IsBlank(c.PrivKey) {
-		key, err := ssh.ParsePrivateKey([]byte(c.PrivKey))
-		if err != nil {
-			return nil, err
-		}
-		auth = append(auth, ssh.PublicKeys(key))
-	} else if !stringutils.IsBlank(c.Password) && !stringutils.IsBlank(c.User) {
-		auth = append(auth, ssh.Password(c.Password))
+	auth, agentConn, err := c.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	if agentConn != nil {
+		// The agent is only needed during the handshake
+		defer agentConn.Close()
+	}
+	hostKeyCallback, err := c.hostKeyCallback()
+	if err != nil {
+		return nil, err
 	}
 
 	config := &ssh.ClientConfig{
 		User:            c.User,
 		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         time.Duration(c.Timeout) * time.Second,
 	}
 
-	config.Config.KeyExchanges = append(config.Config.KeyExchanges, keyExchanges...)
+	// Offer the key exchanges on top of the library defaults, so servers without them are still reachable
+	config.Config.SetDefaults()
+	config.Config.KeyExchanges = append([]string(nil), config.Config.KeyExchanges...)
+	for _, algo := range keyExchanges {
+		if !arrayutils.InStrings(config.Config.KeyExchanges, algo) {
+			config.Config.KeyExchanges = append(config.Config.KeyExchanges, algo)
+		}
+	}
 	addr := net.JoinHostPort(c.Host, fmt.Sprintf("%d", c.Port))
 	log.Printf("Connecting to: %s@%s\n", c.User, addr)
-	conn, err := ssh.Dial("tcp", addr, config)
+
+	dialer := net.Dialer{Timeout: config.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return nil, err
 	}
-	client, err := sftp.NewClient(conn) // create sftp client
-	if err != nil {
+	// Bound the handshake by ctx and the timeout, as ssh.Dial does
+	stop := context.AfterFunc(ctx, func() { _ = netConn.SetDeadline(time.Unix(1, 0)) })
+	if config.Timeout > 0 {
+		_ = netConn.SetDeadline(time.Now().Add(config.Timeout))
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
+	if !stop() || err != nil {
+		_ = netConn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, err
 	}
-	return &SFTPClient{Client: client}, nil
-}
+	_ = netConn.SetDeadline(time.Time{})
+	conn := ssh.NewClient(sshConn, chans, reqs)
 
-func (c SFTPClient) Get(remoteFile string) (*bytes.Buffer, error) {
-	srcFile, err := c.Client.Open(remoteFile)
+	client, err := sftp.NewClient(conn) // create sftp client
 	if err != nil {
+		_ = conn.Close()
 		return nil, err
 	}
-	defer srcFile.Close()
-	buf := new(bytes.Buffer)
-	_, err = io.Copy(buf, srcFile)
-	return buf, err
+	return &SFTPClient{Client: client, Bucket: c.Bucket, conn: conn}, nil
 }
-func (c SFTPClient) Put(data []byte, fpath string) error {
-	dirname := path.Dir(fpath)
-	exist, err := c.Exist(dirname)
-	if err != nil {
-		return err
+
+// authMethods returns the private key, agent and password methods in this order. The agent
+// connection, if any, must be closed once connected.
+func (c SFTPConf) authMethods() ([]ssh.AuthMethod, io.Closer, error) {
+	var auth []ssh.AuthMethod
+	if !stringutils.IsBlank(c.PrivKey) {
+		var key ssh.Signer
+		var err error
+		if c.PrivKeyPassphrase != "" {
+			key, err = ssh.ParsePrivateKeyWithPassphrase([]byte(c.PrivKey), []byte(c.PrivKeyPassphrase))
+		} else {
+			key, err = ssh.ParsePrivateKey([]byte(c.PrivKey))
+		}
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, nil, errors.New("SFTP priv_key is encrypted and priv_key_passphrase not provided")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(key))
 	}
-	if !exist {
-		if err := c.CreateDirectory(dirname); err != nil {
-			return err
+
+	var agentConn net.Conn
+	if c.UseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, nil, errors.New("SFTP use_agent set but SSH_AUTH_SOCK is empty")
+		}
+		var err error
+		if agentConn, err = net.Dial("unix", socket); err != nil {
+			return nil, nil, fmt.Errorf("connecting to ssh-agent: %w", err)
 		}
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
 	}
-	f, err := c.Client.Create(fpath)
-	if err != nil {
-		return err
+
+	if !stringutils.IsBlank(c.Password) {
+		auth = append(auth, ssh.Password(c.Password))
+	}
+	if agentConn == nil {
+		return auth, nil, nil
+	}
+	return auth, agentConn, nil
+}
+
+func (c SFTPConf) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if len(c.HostKeyFingerprints) > 0 {
+		return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			sha256, md5 := ssh.FingerprintSHA256(key), ssh.FingerprintLegacyMD5(key)
+			for _, fingerprint := range c.HostKeyFingerprints {
+				fingerprint = strings.TrimPrefix(strings.TrimSpace(fingerprint), "MD5:")
+				if fingerprint == sha256 || strings.EqualFold(fingerprint, md5) {
+					return nil
+				}
+			}
+			return fmt.Errorf("%w: got %s", ErrHostKeyMismatch, sha256)
+		}, nil
 	}
-	defer f.Close()
-	_, err = f.Write(data)
+	if c.KnownHostsFile != "" {
+		return knownhosts.New(c.KnownHostsFile)
+	}
+	if c.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if file := filepath.Join(home, ".ssh", "known_hosts"); fileExists(file) {
+			return knownhosts.New(file)
+		}
+	}
+	return nil, ErrNoHostKeyVerification
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// Get reads the whole remote file in memory, use Download to stream it.
+func (c SFTPClient) Get(remoteFile string) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	_, err := c.Download(context.Background(), remoteFile, buf)
+	return buf, err
+}
+
+// Put writes the data to the remote file, atomically as Upload.
+func (c SFTPClient) Put(data []byte, fpath string) error {
+	_, err := c.Upload(context.Background(), bytes.NewReader(data), fpath)
 	return err
 }
 
@@ -183,7 +297,7 @@ func (c SFTPClient) List(path string) ([]string, error) {
 
 func (c SFTPClient) Exist(path string) (bool, error) {
 	_, err := c.Client.Lstat(path)
-	if err != nil && err.Error() == "file does not exist" {
+	if errors.Is(err, fs.ErrNotExist) {
 		return false, nil
 	}
 	return err == nil, err
@@ -204,6 +318,13 @@ func (c SFTPClient) IsFile(path string) (bool, error) {
 	return !lstat.IsDir(), nil
 }
 
+// Close closes the SFTP session and the underlying ssh connection.
 func (c SFTPClient) Close() error {
-	return c.Client.Close()
+	err := c.Client.Close()
+	if c.conn != nil {
+		if connErr := c.conn.Close(); err == nil && !errors.Is(connErr, net.ErrClosed) {
+			err = connErr
+		}
+	}
+	return err
 }
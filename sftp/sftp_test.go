@@ -12,6 +12,8 @@ func TestCopyFile(t *testing.T) {
 		Password: "password",
 		Port:     22,
 		Timeout:  5,
+		// Public test server, its host key is not pinned
+		InsecureIgnoreHostKey: true,
 	}
 	conn, err := sftpConf.NewConn()
 	if err != nil {
@@ -0,0 +1,154 @@
+package sftputils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// PartSuffix is appended to the name of the files being uploaded or downloaded until they are complete.
+const PartSuffix = ".part"
+
+// Download streams the remote file to w and returns the number of bytes copied.
+func (c SFTPClient) Download(ctx context.Context, remote string, w io.Writer) (int64, error) {
+	return c.DownloadFrom(ctx, remote, 0, w)
+}
+
+// DownloadFrom streams the remote file to w starting at offset, to resume an interrupted download.
+func (c SFTPClient) DownloadFrom(ctx context.Context, remote string, offset int64, w io.Writer) (int64, error) {
+	f, err := c.Client.Open(remote)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if offset > 0 {
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	// Closing the file aborts the pending reads
+	stop := context.AfterFunc(ctx, func() { _ = f.Close() })
+	defer stop()
+	n, err := io.Copy(w, f)
+	if ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	return n, err
+}
+
+// DownloadFile downloads the remote file to the local path. The data is written to the local
+// path plus PartSuffix, which is renamed once complete: if a previous download was interrupted,
+// it resumes from the size of the partial file.
+func (c SFTPClient) DownloadFile(ctx context.Context, remote, local string) (int64, error) {
+	part := local + PartSuffix
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return 0, err
+	}
+	n, err := c.DownloadFrom(ctx, remote, info.Size(), f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, os.Rename(part, local)
+}
+
+// Upload streams r to the remote path and returns the number of bytes written. The data is
+// written to the remote path plus PartSuffix and renamed once complete, so readers never see a
+// partial file. Missing directories are created. On failure the partial file is kept for ResumeUpload.
+func (c SFTPClient) Upload(ctx context.Context, r io.Reader, remote string) (int64, error) {
+	return c.upload(ctx, r, remote, false)
+}
+
+// ResumeUpload continues an interrupted Upload of r: the bytes already in the partial remote
+// file are skipped from r, by seeking from its start if it is an io.Seeker, and the rest is
+// appended. It returns the number of bytes written by this call.
+func (c SFTPClient) ResumeUpload(ctx context.Context, r io.Reader, remote string) (int64, error) {
+	return c.upload(ctx, r, remote, true)
+}
+
+func (c SFTPClient) upload(ctx context.Context, r io.Reader, remote string, resume bool) (int64, error) {
+	if dir := path.Dir(remote); dir != "." && dir != "/" {
+		if err := c.Client.MkdirAll(dir); err != nil {
+			return 0, err
+		}
+	}
+	part := remote + PartSuffix
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	var offset int64
+	if resume {
+		info, err := c.Client.Stat(part)
+		switch {
+		case err == nil:
+			offset, flags = info.Size(), os.O_WRONLY|os.O_CREATE
+		case !errors.Is(err, fs.ErrNotExist):
+			return 0, err
+		}
+	}
+	if offset > 0 {
+		if err := skip(r, offset); err != nil {
+			return 0, err
+		}
+	}
+
+	f, err := c.Client.OpenFile(part, flags)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			_ = f.Close()
+			return 0, err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { _ = f.Close() })
+	n, err := io.Copy(f, r)
+	stop()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, c.Rename(part, remote)
+}
+
+// skip discards the first n bytes of r
+func skip(r io.Reader, n int64) error {
+	if seeker, ok := r.(io.Seeker); ok {
+		_, err := seeker.Seek(n, io.SeekStart)
+		return err
+	}
+	skipped, err := io.CopyN(io.Discard, r, n)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("partial remote file has %d bytes, source only %d", n, skipped)
+	}
+	return err
+}
+
+// Rename moves oldname to newname, replacing newname if it exists: atomically when the server
+// supports posix-rename, otherwise by removing newname first.
+func (c SFTPClient) Rename(oldname, newname string) error {
+	if _, ok := c.Client.HasExtension("posix-rename@openssh.com"); ok {
+		return c.Client.PosixRename(oldname, newname)
+	}
+	// Plain SFTP rename fails when the target exists
+	if err := c.Client.Remove(newname); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return c.Client.Rename(oldname, newname)
+}
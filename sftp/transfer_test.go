@@ -0,0 +1,319 @@
+package sftputils
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer is an in-process SSH server exposing the sftp subsystem on a temporary directory.
+// It accepts the "demo"/"secret" password and the authorized key.
+type testServer struct {
+	addr        string
+	hostKey     ssh.PublicKey
+	dir         string
+	connections atomic.Int32
+}
+
+func newTestServer(t *testing.T, authorized ssh.PublicKey) *testServer {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "demo" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized != nil && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &testServer{addr: listener.Addr().String(), hostKey: hostSigner.PublicKey(), dir: t.TempDir()}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+	return server
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	s.connections.Add(1)
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(s.dir))
+					if err == nil {
+						_ = server.Serve()
+					}
+					_ = channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (s *testServer) conf() SFTPConf {
+	host, port, _ := net.SplitHostPort(s.addr)
+	p, _ := strconv.Atoi(port)
+	return SFTPConf{Host: host, Port: p, User: "demo", Password: "secret", Timeout: 5, HostKeyFingerprints: []string{ssh.FingerprintSHA256(s.hostKey)}}
+}
+
+func connect(t *testing.T, conf SFTPConf) *SFTPClient {
+	client, err := conf.NewConn()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestHostKeyVerification(t *testing.T) {
+	server := newTestServer(t, nil)
+	connect(t, server.conf())
+
+	conf := server.conf()
+	conf.HostKeyFingerprints = []string{"SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}
+	_, err := conf.NewConn()
+	assert.ErrorIs(t, err, ErrHostKeyMismatch)
+
+	conf.HostKeyFingerprints = []string{ssh.FingerprintLegacyMD5(server.hostKey)}
+	connect(t, conf)
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, server.hostKey) + "\n"
+	require.NoError(t, os.WriteFile(knownHosts, []byte(line), 0o600))
+	conf.HostKeyFingerprints, conf.KnownHostsFile = nil, knownHosts
+	connect(t, conf)
+
+	t.Setenv("HOME", t.TempDir())
+	conf.KnownHostsFile = ""
+	_, err = conf.NewConn()
+	assert.ErrorIs(t, err, ErrNoHostKeyVerification)
+
+	conf.InsecureIgnoreHostKey = true
+	connect(t, conf)
+}
+
+func TestEncryptedKeyAuth(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshPub, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	server := newTestServer(t, sshPub)
+
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "test", []byte("passphrase"))
+	require.NoError(t, err)
+	conf := server.conf()
+	conf.Password, conf.PrivKey = "", string(pem.EncodeToMemory(block))
+
+	_, err = conf.NewConn()
+	assert.ErrorContains(t, err, "priv_key_passphrase")
+
+	conf.PrivKeyPassphrase = "passphrase"
+	connect(t, conf)
+}
+
+func TestAgentAuth(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyring := agent.NewKeyring()
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: priv}))
+	signers, err := keyring.Signers()
+	require.NoError(t, err)
+	server := newTestServer(t, signers[0].PublicKey())
+
+	dir, err := os.MkdirTemp("", "agent")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = agent.ServeAgent(keyring, conn) }()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	conf := server.conf()
+	conf.Password, conf.UseAgent = "", true
+	connect(t, conf)
+}
+
+func TestUploadDownload(t *testing.T) {
+	server := newTestServer(t, nil)
+	client := connect(t, server.conf())
+	ctx := context.Background()
+
+	data := make([]byte, 1<<20)
+	_, _ = rand.Read(data)
+	n, err := client.Upload(ctx, bytes.NewReader(data), "in/nested/data.bin")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data), n)
+
+	stored, err := os.ReadFile(filepath.Join(server.dir, "in/nested/data.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, data, stored)
+	exists, err := client.Exist("in/nested/data.bin" + PartSuffix)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	var buf bytes.Buffer
+	n, err = client.Download(ctx, "in/nested/data.bin", &buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data), n)
+	assert.Equal(t, data, buf.Bytes())
+
+	buf.Reset()
+	_, err = client.DownloadFrom(ctx, "in/nested/data.bin", 1000, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, data[1000:], buf.Bytes())
+
+	// Overwriting an existing file
+	require.NoError(t, client.Put([]byte("replaced"), "in/nested/data.bin"))
+	got, err := client.Get("in/nested/data.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", got.String())
+
+	_, err = client.Download(ctx, "missing.bin", &buf)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestResume(t *testing.T) {
+	server := newTestServer(t, nil)
+	client := connect(t, server.conf())
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+
+	// An interrupted upload left the first 30000 bytes
+	require.NoError(t, os.WriteFile(filepath.Join(server.dir, "up.bin"+PartSuffix), data[:30000], 0o644))
+	n, err := client.ResumeUpload(ctx, bytes.NewReader(data), "up.bin")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data)-30000, n)
+	stored, err := os.ReadFile(filepath.Join(server.dir, "up.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, data, stored)
+
+	// Readers that cannot seek are skipped by reading
+	require.NoError(t, os.WriteFile(filepath.Join(server.dir, "up2.bin"+PartSuffix), data[:50000], 0o644))
+	_, err = client.ResumeUpload(ctx, io.MultiReader(bytes.NewReader(data)), "up2.bin")
+	require.NoError(t, err)
+	stored, err = os.ReadFile(filepath.Join(server.dir, "up2.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, data, stored)
+
+	local := filepath.Join(t.TempDir(), "down.bin")
+	require.NoError(t, os.WriteFile(local+PartSuffix, data[:12345], 0o644))
+	n, err = client.DownloadFile(ctx, "up.bin", local)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data)-12345, n)
+	downloaded, err := os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+	assert.NoFileExists(t, local+PartSuffix)
+}
+
+func TestUploadCancelled(t *testing.T) {
+	server := newTestServer(t, nil)
+	client := connect(t, server.conf())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.Upload(ctx, bytes.NewReader(make([]byte, 1<<20)), "cancelled.bin")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, filepath.Join(server.dir, "cancelled.bin"))
+}
+
+func TestCloseReleasesConnection(t *testing.T) {
+	server := newTestServer(t, nil)
+	client, err := server.conf().NewConn()
+	require.NoError(t, err)
+
+	exists, err := client.Exist("nothing-here")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, client.Close())
+	_, _, err = client.conn.SendRequest("keepalive@openssh.com", true, nil)
+	assert.Error(t, err)
+}
+
+func TestPool(t *testing.T) {
+	server := newTestServer(t, nil)
+	pool, err := NewPool(server.conf(), 2)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, pool.Do(context.Background(), func(client *SFTPClient) error {
+				return client.Put([]byte(strconv.Itoa(i)), "pool/"+strconv.Itoa(i))
+			}))
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, server.connections.Load(), int32(2))
+	entries, err := os.ReadDir(filepath.Join(server.dir, "pool"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 8)
+
+	require.NoError(t, pool.Close())
+	_, err = pool.Get(context.Background())
+	assert.ErrorIs(t, err, ErrPoolClosed)
+}
@@ -0,0 +1,268 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPrimaryKeyRequired is returned by the dialects requiring a primary key, like Cassandra
+var ErrPrimaryKeyRequired = errors.New("schema: primary key required")
+
+// TableOptions holds the options of a CREATE TABLE statement.
+type TableOptions struct {
+	IfNotExists bool
+	// NotNull adds NOT NULL to the columns without null in the sample, where supported
+	NotNull bool
+	// PrimaryKey are the sanitized names of the key columns. With Cassandra, the first one is
+	// the partition key and the others the clustering columns.
+	PrimaryKey []string
+}
+
+// Dialect generates the DDL of a database.
+type Dialect interface {
+	Name() string
+	// ColumnType returns the type of the column in the dialect
+	ColumnType(c Column) string
+	// CreateTable returns the CREATE TABLE statement, to be executed as is
+	CreateTable(table string, columns []Column, opts TableOptions) (string, error)
+}
+
+var (
+	Postgres  Dialect = postgres{}
+	Cockroach Dialect = cockroach{}
+	Redshift  Dialect = redshift{}
+	Oracle    Dialect = oracle{}
+	Cassandra Dialect = cassandra{}
+)
+
+// DialectByName returns the dialect of a name, as returned by Dialect.Name.
+func DialectByName(name string) (Dialect, error) {
+	for _, d := range []Dialect{Postgres, Cockroach, Redshift, Oracle, Cassandra} {
+		if strings.EqualFold(d.Name(), name) {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("schema: unknown dialect %q", name)
+}
+
+// statement is the CREATE TABLE layout shared by the dialects
+type statement struct {
+	ifNotExists  bool
+	notNull      bool
+	partitioned  bool // The first key column is the partition key
+	unterminated bool // Without the final semicolon, rejected by the drivers in a single statement
+}
+
+func (s statement) build(d Dialect, table string, columns []Column, opts TableOptions) (string, error) {
+	if len(columns) == 0 {
+		return "", errors.New("schema: no columns")
+	}
+	known := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		known[c.Name] = true
+	}
+	for _, key := range opts.PrimaryKey {
+		if !known[key] {
+			return "", fmt.Errorf("schema: unknown primary key column %q", key)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("CREATE TABLE ")
+	if opts.IfNotExists && s.ifNotExists {
+		b.WriteString("IF NOT EXISTS ")
+	}
+	b.WriteString(table + " (\n")
+	for i, c := range columns {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		b.WriteString("\t" + c.Name + " " + d.ColumnType(c))
+		if opts.NotNull && s.notNull && !c.Nullable && c.Type != Null {
+			b.WriteString(" NOT NULL")
+		}
+	}
+	if len(opts.PrimaryKey) > 0 {
+		keys := append([]string(nil), opts.PrimaryKey...)
+		if s.partitioned && len(keys) > 1 {
+			keys[0] = "(" + keys[0] + ")"
+		}
+		b.WriteString(",\n\tPRIMARY KEY (" + strings.Join(keys, ", ") + ")")
+	}
+	b.WriteString(")")
+	if !s.unterminated {
+		b.WriteString(";")
+	}
+	return b.String(), nil
+}
+
+type postgres struct{}
+
+func (postgres) Name() string { return "postgres" }
+
+func (postgres) ColumnType(c Column) string {
+	switch c.Type {
+	case Boolean:
+		return "BOOLEAN"
+	case Integer:
+		return "INTEGER"
+	case BigInt:
+		return "BIGINT"
+	case Decimal:
+		return fmt.Sprintf("NUMERIC(%d,%d)", c.Precision, c.Scale)
+	case Float:
+		return "DOUBLE PRECISION"
+	case Date:
+		return "DATE"
+	case Timestamp:
+		return "TIMESTAMP"
+	case TimestampTZ:
+		return "TIMESTAMPTZ"
+	}
+	return "TEXT"
+}
+
+func (d postgres) CreateTable(table string, columns []Column, opts TableOptions) (string, error) {
+	return statement{ifNotExists: true, notNull: true}.build(d, table, columns, opts)
+}
+
+type cockroach struct{}
+
+func (cockroach) Name() string { return "cockroach" }
+
+func (cockroach) ColumnType(c Column) string {
+	switch c.Type {
+	case Boolean:
+		return "BOOL"
+	case Integer:
+		return "INT4"
+	case BigInt:
+		return "INT8"
+	case Decimal:
+		return fmt.Sprintf("DECIMAL(%d,%d)", c.Precision, c.Scale)
+	case Float:
+		return "FLOAT8"
+	case Date:
+		return "DATE"
+	case Timestamp:
+		return "TIMESTAMP"
+	case TimestampTZ:
+		return "TIMESTAMPTZ"
+	}
+	return "STRING"
+}
+
+func (d cockroach) CreateTable(table string, columns []Column, opts TableOptions) (string, error) {
+	return statement{ifNotExists: true, notNull: true}.build(d, table, columns, opts)
+}
+
+// redshiftMaxVarchar is the largest VARCHAR of Redshift, in bytes
+const redshiftMaxVarchar = 65535
+
+type redshift struct{}
+
+func (redshift) Name() string { return "redshift" }
+
+// ColumnType sizes the VARCHAR columns in bytes, as Redshift does, to the next power of two of
+// the longest value seen, and at least 256 (the size of TEXT).
+func (redshift) ColumnType(c Column) string {
+	switch c.Type {
+	case Boolean:
+		return "BOOLEAN"
+	case Integer:
+		return "INTEGER"
+	case BigInt:
+		return "BIGINT"
+	case Decimal:
+		return fmt.Sprintf("DECIMAL(%d,%d)", c.Precision, c.Scale)
+	case Float:
+		return "DOUBLE PRECISION"
+	case Date:
+		return "DATE"
+	case Timestamp:
+		return "TIMESTAMP"
+	case TimestampTZ:
+		return "TIMESTAMPTZ"
+	}
+	size := 256
+	for size < c.MaxBytes && size < redshiftMaxVarchar {
+		size *= 2
+	}
+	return fmt.Sprintf("VARCHAR(%d)", min(size, redshiftMaxVarchar))
+}
+
+func (d redshift) CreateTable(table string, columns []Column, opts TableOptions) (string, error) {
+	return statement{ifNotExists: true, notNull: true}.build(d, table, columns, opts)
+}
+
+// oracleMaxVarchar is the largest VARCHAR2 without extended data types
+const oracleMaxVarchar = 4000
+
+type oracle struct{}
+
+func (oracle) Name() string { return "oracle" }
+
+// ColumnType maps booleans to VARCHAR2(5), to load the true and false literals as is.
+func (oracle) ColumnType(c Column) string {
+	switch c.Type {
+	case Boolean:
+		return "VARCHAR2(5)"
+	case Integer:
+		return "NUMBER(10)"
+	case BigInt:
+		return "NUMBER(19)"
+	case Decimal:
+		return fmt.Sprintf("NUMBER(%d,%d)", c.Precision, c.Scale)
+	case Float:
+		return "BINARY_DOUBLE"
+	case Date:
+		return "DATE"
+	case Timestamp:
+		return "TIMESTAMP"
+	case TimestampTZ:
+		return "TIMESTAMP WITH TIME ZONE"
+	}
+	if c.MaxLength > oracleMaxVarchar {
+		return "CLOB"
+	}
+	return fmt.Sprintf("VARCHAR2(%d CHAR)", max(c.MaxLength, 1))
+}
+
+// CreateTable ignores IfNotExists, which Oracle does not support before 23c. The statement
+// has no final semicolon, which godror and go-ora reject.
+func (d oracle) CreateTable(table string, columns []Column, opts TableOptions) (string, error) {
+	return statement{notNull: true, unterminated: true}.build(d, table, columns, opts)
+}
+
+type cassandra struct{}
+
+func (cassandra) Name() string { return "cassandra" }
+
+func (cassandra) ColumnType(c Column) string {
+	switch c.Type {
+	case Boolean:
+		return "boolean"
+	case Integer:
+		return "int"
+	case BigInt:
+		return "bigint"
+	case Decimal:
+		return "decimal"
+	case Float:
+		return "double"
+	case Date:
+		return "date"
+	case Timestamp, TimestampTZ:
+		return "timestamp"
+	}
+	return "text"
+}
+
+// CreateTable requires a primary key and ignores NotNull, Cassandra has no such constraint.
+func (d cassandra) CreateTable(table string, columns []Column, opts TableOptions) (string, error) {
+	if len(opts.PrimaryKey) == 0 {
+		return "", ErrPrimaryKeyRequired
+	}
+	return statement{ifNotExists: true, partitioned: true}.build(d, table, columns, opts)
+}
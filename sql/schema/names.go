@@ -0,0 +1,91 @@
+package schema
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MaxNameLength is the length of the sanitized names, the limit of Postgres identifiers
+const MaxNameLength = 63
+
+// reserved are the keywords of the supported dialects that cannot be used as unquoted column names
+var reserved = map[string]bool{
+	"all": true, "alter": true, "and": true, "any": true, "as": true, "asc": true, "between": true,
+	"by": true, "case": true, "check": true, "column": true, "comment": true, "create": true,
+	"date": true, "default": true, "delete": true, "desc": true, "distinct": true, "drop": true,
+	"else": true, "end": true, "from": true, "grant": true, "group": true, "having": true,
+	"in": true, "index": true, "insert": true, "into": true, "is": true, "key": true,
+	"level": true, "limit": true, "not": true, "null": true, "number": true, "of": true,
+	"offset": true, "on": true, "or": true, "order": true, "primary": true, "select": true,
+	"session": true, "set": true, "size": true, "table": true, "then": true, "timestamp": true,
+	"to": true, "uid": true, "union": true, "unique": true, "update": true, "user": true,
+	"using": true, "values": true, "when": true, "where": true, "with": true,
+}
+
+// SanitizeName turns a header into an identifier usable unquoted in every dialect: lower case
+// letters, digits and underscores, not starting with a digit and not a keyword.
+//
+//	"Amount (USD)" -> "amount_usd"
+//	"2nd phone"    -> "c_2nd_phone"
+//	"Order"        -> "order_"
+//	"%%"           -> "column_"
+func SanitizeName(header string) string {
+	if name := sanitize(header); name != "" {
+		return name
+	}
+	// "column" is a keyword
+	return "column_"
+}
+
+// sanitize returns the identifier of a header, empty if it has no letter or digit
+func sanitize(header string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(header)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			underscore = false
+		case !underscore && b.Len() > 0:
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	name := strings.TrimSuffix(b.String(), "_")
+	switch {
+	case name == "":
+		return ""
+	case name[0] >= '0' && name[0] <= '9':
+		name = "c_" + name
+	case reserved[name]:
+		name += "_"
+	}
+	if len(name) > MaxNameLength {
+		name = strings.TrimSuffix(name[:MaxNameLength], "_")
+	}
+	return name
+}
+
+// SanitizeNames sanitizes the headers, numbering the duplicates: "id", "ID" -> "id", "id_2". The
+// headers without letter or digit are named after their position: "", "%" -> "c_1", "c_2".
+func SanitizeNames(headers []string) []string {
+	names := make([]string, len(headers))
+	used := make(map[string]bool, len(headers))
+	for i, header := range headers {
+		base := sanitize(header)
+		if base == "" {
+			base = "c_" + strconv.Itoa(i+1)
+		}
+		name := base
+		for n := 2; used[name]; n++ {
+			suffix := "_" + strconv.Itoa(n)
+			if len(base)+len(suffix) > MaxNameLength {
+				base = base[:MaxNameLength-len(suffix)]
+			}
+			name = base + suffix
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
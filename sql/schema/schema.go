@@ -0,0 +1,345 @@
+// Package schema infers the schema of tabular data, typically CSV files, from a sample of its
+// rows and generates the CREATE TABLE statements of several SQL dialects.
+//
+// Types only widen while rows are observed: a column seen as INTEGER becomes DECIMAL when a
+// decimal value appears, then STRING if a value is not a number, and never goes back.
+package schema
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Type is the inferred type of a column.
+type Type int
+
+const (
+	// Null is the type of a column without any value in the sample
+	Null Type = iota
+	Boolean
+	Integer // Fits in 32 bits
+	BigInt  // Fits in 64 bits
+	Decimal // Exact number, see Column.Precision and Column.Scale
+	Float
+	Date
+	Timestamp
+	TimestampTZ
+	String
+)
+
+func (t Type) String() string {
+	switch t {
+	case Null:
+		return "null"
+	case Boolean:
+		return "boolean"
+	case Integer:
+		return "integer"
+	case BigInt:
+		return "bigint"
+	case Decimal:
+		return "decimal"
+	case Float:
+		return "float"
+	case Date:
+		return "date"
+	case Timestamp:
+		return "timestamp"
+	case TimestampTZ:
+		return "timestamptz"
+	case String:
+		return "string"
+	}
+	return "Type(" + strconv.Itoa(int(t)) + ")"
+}
+
+// MaxPrecision is the largest precision of a Decimal column, wider numbers are Float
+const MaxPrecision = 38
+
+// widen returns the narrowest type holding the values of both types
+func widen(a, b Type) Type {
+	if a == b || b == Null {
+		return a
+	}
+	if a == Null {
+		return b
+	}
+	numeric := func(t Type) bool { return t >= Integer && t <= Float }
+	temporal := func(t Type) bool { return t >= Date && t <= TimestampTZ }
+	if (numeric(a) && numeric(b)) || (temporal(a) && temporal(b)) {
+		return max(a, b)
+	}
+	return String
+}
+
+// DateLayouts are the layouts recognized as dates
+var DateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+}
+
+// TimestampLayouts are the layouts recognized as timestamps. Fractional seconds are accepted
+// after the seconds of every layout.
+var TimestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+}
+
+// TimestampTZLayouts are the layouts recognized as timestamps with a time zone
+var TimestampTZLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z07",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.UnixDate,
+}
+
+// Column is the inferred schema of a column.
+type Column struct {
+	Header   string // Name in the source
+	Name     string // Sanitized identifier, see SanitizeNames
+	Type     Type
+	Nullable bool // A null value was seen in the sample
+	// MaxLength and MaxBytes are the length of the longest value, in characters and bytes
+	MaxLength int
+	MaxBytes  int
+	// Precision and Scale of the Decimal columns: digits in total and after the decimal point
+	Precision int
+	Scale     int
+	// Layout of the Date and Timestamp values, empty if the values use different layouts
+	Layout string
+}
+
+// Options holds the options of the inference.
+type Options struct {
+	// SampleRows is the number of rows read by Infer (default 1000), negative reads all the rows
+	SampleRows int
+	// NullValues are the values read as null (default: empty string)
+	NullValues []string
+	// Separator of Infer (default ',')
+	Separator rune
+}
+
+func (o *Options) setDefaults() {
+	if o.SampleRows == 0 {
+		o.SampleRows = 1000
+	}
+	if o.NullValues == nil {
+		o.NullValues = []string{""}
+	}
+	if o.Separator == 0 {
+		o.Separator = ','
+	}
+}
+
+// column is the inference state of a column
+type column struct {
+	Column
+	intDigits   int
+	layoutMixed bool
+}
+
+// Inferrer infers the columns from the records it observes.
+type Inferrer struct {
+	columns []column
+	nulls   map[string]bool
+	rows    int
+}
+
+// NewInferrer creates an Inferrer for the headers.
+func NewInferrer(headers []string, opts Options) *Inferrer {
+	opts.setDefaults()
+	names := SanitizeNames(headers)
+	columns := make([]column, len(headers))
+	for i := range headers {
+		columns[i].Header, columns[i].Name = headers[i], names[i]
+	}
+	nulls := make(map[string]bool, len(opts.NullValues))
+	for _, null := range opts.NullValues {
+		nulls[null] = true
+	}
+	return &Inferrer{columns: columns, nulls: nulls}
+}
+
+// Observe widens the columns with the values of a record. Missing values are nulls, extra values are ignored.
+func (in *Inferrer) Observe(record []string) {
+	in.rows++
+	for i := range in.columns {
+		c := &in.columns[i]
+		if i >= len(record) || in.nulls[record[i]] {
+			c.Nullable = true
+			continue
+		}
+		c.observe(record[i])
+	}
+}
+
+// Rows returns the number of observed records.
+func (in *Inferrer) Rows() int {
+	return in.rows
+}
+
+// Columns returns the columns inferred from the observed records.
+func (in *Inferrer) Columns() []Column {
+	columns := make([]Column, len(in.columns))
+	for i, c := range in.columns {
+		columns[i] = c.Column
+		if c.Type == Decimal {
+			columns[i].Precision = c.intDigits + c.Scale
+		}
+		if c.layoutMixed || (c.Type != Date && c.Type != Timestamp && c.Type != TimestampTZ) {
+			columns[i].Layout = ""
+		}
+	}
+	return columns
+}
+
+func (c *column) observe(value string) {
+	c.MaxLength = max(c.MaxLength, utf8.RuneCountInString(value))
+	c.MaxBytes = max(c.MaxBytes, len(value))
+	if c.Type == String {
+		return
+	}
+
+	t, intDigits, scale, layout := classify(value)
+	c.Type = widen(c.Type, t)
+	switch c.Type {
+	case Integer, BigInt, Decimal:
+		c.intDigits, c.Scale = max(c.intDigits, intDigits), max(c.Scale, scale)
+		if c.intDigits+c.Scale > MaxPrecision {
+			c.Type = Float
+		}
+	case Date, Timestamp, TimestampTZ:
+		if c.Layout == "" && !c.layoutMixed {
+			c.Layout = layout
+		} else if c.Layout != layout {
+			c.layoutMixed = true
+		}
+	}
+}
+
+// classify returns the narrowest type of a value, with the digits of the numbers and the layout of the times
+func classify(s string) (t Type, intDigits, scale int, layout string) {
+	// 1 and 0 are numbers
+	if _, err := strconv.ParseBool(s); err == nil && s != "1" && s != "0" {
+		return Boolean, 0, 0, ""
+	}
+	if t, intDigits, scale, ok := classifyNumber(s); ok {
+		return t, intDigits, scale, ""
+	}
+	for _, candidates := range []struct {
+		t       Type
+		layouts []string
+	}{{Date, DateLayouts}, {Timestamp, TimestampLayouts}, {TimestampTZ, TimestampTZLayouts}} {
+		for _, layout := range candidates.layouts {
+			if _, err := time.Parse(layout, s); err == nil {
+				return candidates.t, 0, 0, layout
+			}
+		}
+	}
+	return String, 0, 0, ""
+}
+
+func classifyNumber(s string) (t Type, intDigits, scale int, ok bool) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 || digits == "" {
+		return 0, 0, 0, false
+	}
+	intPart, fracPart, isDecimal := strings.Cut(digits, ".")
+	if isDecimal && !strings.ContainsAny(fracPart, "eE") {
+		if !allDigits(intPart) || !allDigits(fracPart) || (intPart == "" && fracPart == "") || hasLeadingZero(intPart) {
+			return 0, 0, 0, false
+		}
+		return Decimal, max(len(intPart), 1), len(fracPart), true
+	}
+	if !isDecimal && allDigits(intPart) {
+		// Identifiers like 00100 must keep their zeros
+		if hasLeadingZero(intPart) {
+			return 0, 0, 0, false
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		switch {
+		case err != nil:
+			return Decimal, len(intPart), 0, true
+		case n >= math.MinInt32 && n <= math.MaxInt32:
+			return Integer, len(intPart), 0, true
+		default:
+			return BigInt, len(intPart), 0, true
+		}
+	}
+	// Exponents, letters like NaN or Inf are strings
+	if strings.Trim(digits, "0123456789.eE+-") != "" {
+		return 0, 0, 0, false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	return Float, 0, 0, true
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func hasLeadingZero(s string) bool {
+	return len(s) > 1 && s[0] == '0'
+}
+
+// InferRecords infers the columns of the headers from the records.
+func InferRecords(headers []string, records [][]string, opts Options) []Column {
+	in := NewInferrer(headers, opts)
+	for _, record := range records {
+		in.Observe(record)
+	}
+	return in.Columns()
+}
+
+// Infer reads the header and up to opts.SampleRows rows of a CSV and infers its columns.
+func Infer(r io.Reader, opts Options) ([]Column, error) {
+	opts.setDefaults()
+	reader := csv.NewReader(r)
+	reader.Comma = opts.Separator
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("schema: empty input")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("schema: reading header: %w", err)
+	}
+	header = append([]string(nil), header...)
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	in := NewInferrer(header, opts)
+	for opts.SampleRows < 0 || in.Rows() < opts.SampleRows {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("schema: reading row %d: %w", in.Rows()+1, err)
+		}
+		in.Observe(record)
+	}
+	return in.Columns(), nil
+}
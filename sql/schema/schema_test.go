@@ -0,0 +1,134 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfer(t *testing.T) {
+	input := "id,amount,ratio,active,day,at,at_tz,code,note,big,empty\n" +
+		"1,10.5,1e3,true,2024-01-02,2024-01-02 10:00:00,2024-01-02T10:00:00Z,007,héllo,3000000000,\n" +
+		"2,3,0.25,false,2024-01-03,2024-01-02T10:00:00.123,2024-01-02 10:00:00+02:00,12,,1,\n" +
+		"3,-120.125,2,TRUE,2024/01/04,2024-01-02 11:00:00,2024-01-02T10:00:00Z,x,a longer note,2,\n"
+	columns, err := Infer(strings.NewReader(input), Options{})
+	require.NoError(t, err)
+	require.Len(t, columns, 11)
+
+	byName := make(map[string]Column, len(columns))
+	for _, c := range columns {
+		byName[c.Name] = c
+	}
+	assert.Equal(t, Integer, byName["id"].Type)
+	assert.False(t, byName["id"].Nullable)
+
+	amount := byName["amount"]
+	assert.Equal(t, Decimal, amount.Type)
+	assert.Equal(t, 6, amount.Precision)
+	assert.Equal(t, 3, amount.Scale)
+
+	assert.Equal(t, Float, byName["ratio"].Type)
+	assert.Equal(t, Boolean, byName["active"].Type)
+
+	day := byName["day"]
+	assert.Equal(t, Date, day.Type)
+	assert.Empty(t, day.Layout, "mixed layouts")
+	assert.Equal(t, Timestamp, byName["at"].Type)
+	assert.Equal(t, TimestampTZ, byName["at_tz"].Type)
+
+	assert.Equal(t, String, byName["code"].Type, "leading zeros are kept")
+	note := byName["note"]
+	assert.Equal(t, String, note.Type)
+	assert.True(t, note.Nullable)
+	assert.Equal(t, 13, note.MaxLength)
+
+	assert.Equal(t, BigInt, byName["big"].Type)
+	assert.Equal(t, Null, byName["empty"].Type)
+	assert.True(t, byName["empty"].Nullable)
+}
+
+func TestWidenIsMonotonic(t *testing.T) {
+	columns := InferRecords([]string{"v"}, [][]string{{"1"}, {"1.5"}, {"abc"}, {"2"}, {"2.5"}}, Options{})
+	assert.Equal(t, String, columns[0].Type)
+
+	columns = InferRecords([]string{"v"}, [][]string{{"2024-01-01"}, {"2024-01-01 10:00:00"}, {"2024-01-02"}}, Options{})
+	assert.Equal(t, Timestamp, columns[0].Type)
+
+	columns = InferRecords([]string{"v"}, [][]string{{"true"}, {"1"}}, Options{})
+	assert.Equal(t, String, columns[0].Type)
+
+	columns = InferRecords([]string{"v"}, [][]string{{"1234567890123456789012345678901234567890"}}, Options{})
+	assert.Equal(t, Float, columns[0].Type, "wider than the max precision")
+
+	columns = InferRecords([]string{"v"}, [][]string{{"99999999999999999999"}, {"1.5"}}, Options{})
+	assert.Equal(t, Decimal, columns[0].Type)
+	assert.Equal(t, 21, columns[0].Precision)
+	assert.Equal(t, 1, columns[0].Scale)
+
+	columns = InferRecords([]string{"v"}, [][]string{{"NULL"}, {"1"}}, Options{NullValues: []string{"NULL", ""}})
+	assert.Equal(t, Integer, columns[0].Type)
+	assert.True(t, columns[0].Nullable)
+
+	columns = InferRecords([]string{"v"}, [][]string{{"NaN"}, {"Inf"}}, Options{})
+	assert.Equal(t, String, columns[0].Type)
+}
+
+func TestSanitizeNames(t *testing.T) {
+	assert.Equal(t, "amount_usd", SanitizeName(" Amount (USD) "))
+	assert.Equal(t, "c_2nd_phone", SanitizeName("2nd phone"))
+	assert.Equal(t, "order_", SanitizeName("Order"))
+	assert.Equal(t, "column_", SanitizeName("%%"))
+	assert.Equal(t, "column_", SanitizeName(""))
+	assert.Equal(t, "customer_name", SanitizeName("customer.name"))
+	assert.Len(t, SanitizeName(strings.Repeat("a", 100)), MaxNameLength)
+	assert.Equal(t, []string{"id", "id_2", "id_3", "name"}, SanitizeNames([]string{"id", "ID", "Id ", "name"}))
+	assert.Equal(t, []string{"id", "c_2", "c_3", "c_2_2"}, SanitizeNames([]string{"id", "", "%", "c 2"}))
+
+	ddl, err := Postgres.CreateTable("t", InferRecords([]string{"%%"}, [][]string{{"1"}}, Options{}), TableOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE t (\n\tc_1 INTEGER);", ddl)
+}
+
+func TestDialects(t *testing.T) {
+	columns := InferRecords([]string{"ID", "Amount", "Name", "When"},
+		[][]string{{"1", "10.50", "ann", "2024-01-02T10:00:00Z"}, {"2", "", "bob", "2024-01-03T10:00:00Z"}}, Options{})
+
+	ddl, err := Postgres.CreateTable("orders", columns, TableOptions{IfNotExists: true, NotNull: true, PrimaryKey: []string{"id"}})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS orders (\n\tid INTEGER NOT NULL,\n\tamount NUMERIC(4,2),\n\tname TEXT NOT NULL,\n\twhen_ TIMESTAMPTZ NOT NULL,\n\tPRIMARY KEY (id));", ddl)
+
+	ddl, err = Cockroach.CreateTable("orders", columns, TableOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE orders (\n\tid INT4,\n\tamount DECIMAL(4,2),\n\tname STRING,\n\twhen_ TIMESTAMPTZ);", ddl)
+
+	ddl, err = Redshift.CreateTable("orders", columns, TableOptions{IfNotExists: true})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS orders (\n\tid INTEGER,\n\tamount DECIMAL(4,2),\n\tname VARCHAR(256),\n\twhen_ TIMESTAMPTZ);", ddl)
+
+	ddl, err = Oracle.CreateTable("orders", columns, TableOptions{IfNotExists: true})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE orders (\n\tid NUMBER(10),\n\tamount NUMBER(4,2),\n\tname VARCHAR2(3 CHAR),\n\twhen_ TIMESTAMP WITH TIME ZONE)", ddl)
+
+	_, err = Cassandra.CreateTable("orders", columns, TableOptions{})
+	assert.ErrorIs(t, err, ErrPrimaryKeyRequired)
+	ddl, err = Cassandra.CreateTable("ks.orders", columns, TableOptions{IfNotExists: true, NotNull: true, PrimaryKey: []string{"name", "id"}})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS ks.orders (\n\tid int,\n\tamount decimal,\n\tname text,\n\twhen_ timestamp,\n\tPRIMARY KEY ((name), id));", ddl)
+
+	_, err = Postgres.CreateTable("orders", columns, TableOptions{PrimaryKey: []string{"nope"}})
+	assert.Error(t, err)
+
+	d, err := DialectByName("Redshift")
+	require.NoError(t, err)
+	assert.Equal(t, Redshift, d)
+	_, err = DialectByName("mysql")
+	assert.Error(t, err)
+}
+
+func TestRedshiftVarcharSize(t *testing.T) {
+	assert.Equal(t, "VARCHAR(256)", Redshift.ColumnType(Column{Type: String, MaxBytes: 10}))
+	assert.Equal(t, "VARCHAR(1024)", Redshift.ColumnType(Column{Type: String, MaxBytes: 700}))
+	assert.Equal(t, "VARCHAR(65535)", Redshift.ColumnType(Column{Type: String, MaxBytes: 70000}))
+}
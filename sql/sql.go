@@ -15,6 +15,8 @@ func ExecuteStatement(connection *sql.DB, queries ...string) error {
 }
 
 // GetRedshiftTranslator is delegated to create a translator for csvutils.GetCSVDataType in order to create table
+//
+// Deprecated: use schema.Redshift, which sizes the columns from the inferred schema.
 func GetRedshiftTranslator() map[string]string {
 	var replacer = make(map[string]string)
 	replacer["string"] = "TEXT"
@@ -26,6 +28,8 @@ func GetRedshiftTranslator() map[string]string {
 }
 
 // GetOracleTranslator is delegated to create a translator for csvutils.GetCSVDataType in order to create table
+//
+// Deprecated: use schema.Oracle, which sizes the columns from the inferred schema.
 func GetOracleTranslator(charLength int) map[string]string {
 	var replacer = make(map[string]string)
 	replacer["string"] = fmt.Sprintf("VARCHAR2(%d)", charLength)
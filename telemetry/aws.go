@@ -0,0 +1,57 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentAWS traces the operations of the clients created with cfg, e.g. the one of
+// awsutils.New, if the AWS layer is instrumented. Each operation is a span, including its
+// retries.
+func (p *Provider) InstrumentAWS(cfg *aws.Config) {
+	if !p.cfg.Instrument.AWS {
+		return
+	}
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		// After the middleware registering the service and the operation
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("telemetry.Trace", p.traceAWS), middleware.After)
+	})
+}
+
+func (p *Provider) traceAWS(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	service, operation := awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx)
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String("aws-api"),
+		semconv.RPCService(service),
+		semconv.RPCMethod(operation),
+	}
+	ctx, span := p.tracer.Start(ctx, service+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(semconv.CloudRegion(awsmiddleware.GetRegion(ctx))))
+
+	start := time.Now()
+	out, metadata, err := next.HandleInitialize(ctx, in)
+	if resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	if id, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+		span.SetAttributes(attribute.String("aws.request_id", id))
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(respErr.HTTPStatusCode()), attribute.String("aws.request_id", respErr.ServiceRequestID()))
+	}
+	end(ctx, span, p.awsDuration, start, time.Now(), err, attrs...)
+	return out, metadata, err
+}
@@ -0,0 +1,64 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/gocql/gocql"
+	"github.com/seidu626/go-buildingblocks/database/cassandra"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// gocqlObserver traces the queries and batches of gocql, once they are done
+type gocqlObserver struct {
+	p *Provider
+}
+
+var (
+	_ gocql.QueryObserver = (*gocqlObserver)(nil)
+	_ gocql.BatchObserver = (*gocqlObserver)(nil)
+)
+
+// InstrumentCassandra traces the queries of the stores created with cfg by
+// cassandra.NewCassandraStore, if the cassandra layer is instrumented.
+func (p *Provider) InstrumentCassandra(cfg *cassandra.Config) {
+	if !p.cfg.Instrument.Cassandra {
+		return
+	}
+	o := &gocqlObserver{p: p}
+	cfg.QueryObserver, cfg.BatchObserver = o, o
+}
+
+func hostAttributes(host *gocql.HostInfo) []attribute.KeyValue {
+	if host == nil {
+		return nil
+	}
+	return []attribute.KeyValue{semconv.ServerAddress(host.ConnectAddress().String()), semconv.ServerPort(host.Port())}
+}
+
+// ObserveQuery implements gocql.QueryObserver: each attempt of a query is a span.
+func (o *gocqlObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	op := operationName(q.Statement)
+	attrs := append([]attribute.KeyValue{semconv.DBSystemCassandra, semconv.DBNamespace(q.Keyspace), semconv.DBOperationName(op)}, hostAttributes(q.Host)...)
+	_, span := o.p.tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(q.Start),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(semconv.DBQueryText(q.Statement), attribute.Int("db.cassandra.rows", q.Rows), attribute.Int("db.cassandra.attempt", q.Attempt)))
+	end(ctx, span, o.p.dbDuration, q.Start, q.End, q.Err, attrs...)
+}
+
+// ObserveBatch implements gocql.BatchObserver.
+func (o *gocqlObserver) ObserveBatch(ctx context.Context, b gocql.ObservedBatch) {
+	attrs := append([]attribute.KeyValue{semconv.DBSystemCassandra, semconv.DBNamespace(b.Keyspace), semconv.DBOperationName("BATCH")}, hostAttributes(b.Host)...)
+	_, span := o.p.tracer.Start(ctx, "BATCH",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(b.Start),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(attribute.Int("db.operation.batch.size", len(b.Statements)), attribute.Int("db.cassandra.attempt", b.Attempt)))
+	for i, stmt := range b.Statements {
+		span.AddEvent("query", trace.WithTimestamp(b.Start), trace.WithAttributes(semconv.DBQueryText(stmt), attribute.Int("db.cassandra.statement_index", i)))
+	}
+	end(ctx, span, o.p.dbDuration, b.Start, b.End, b.Err, attrs...)
+}
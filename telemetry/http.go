@@ -0,0 +1,130 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/requests"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextKey is the user value of the fasthttp requests holding their context
+const contextKey = "telemetry.context"
+
+// RequestsMiddleware returns the middleware tracing the attempts of the requests of a
+// requests.Client, and propagating the trace to the servers. It does nothing unless the HTTP
+// client layer is instrumented.
+func (p *Provider) RequestsMiddleware() requests.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if !p.cfg.Instrument.HTTPClient {
+			return next
+		}
+		return requests.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			attrs := []attribute.KeyValue{
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.ServerAddress(req.URL.Hostname()),
+			}
+			ctx, span := p.tracer.Start(ctx, req.Method,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+				trace.WithAttributes(semconv.URLFull(req.URL.Redacted()), attribute.Int("http.request.resend_count", requests.Attempt(ctx)-1)))
+			// The request of a middleware must not be modified, see http.RoundTripper
+			req = req.Clone(ctx)
+			p.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err == nil {
+				attrs = append(attrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+				span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+				if resp.StatusCode >= http.StatusBadRequest {
+					span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+					attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+				}
+			}
+			end(ctx, span, p.httpClientDuration, start, time.Now(), err, attrs...)
+			return resp, err
+		})
+	}
+}
+
+// InstrumentRequests traces the requests of the clients created with cfg by
+// requests.NewClient, if the HTTP client layer is instrumented. The middleware is the outermost
+// one, each attempt being a span.
+func (p *Provider) InstrumentRequests(cfg *requests.Config) {
+	if !p.cfg.Instrument.HTTPClient {
+		return
+	}
+	cfg.Middleware = append([]requests.Middleware{p.RequestsMiddleware()}, cfg.Middleware...)
+}
+
+// fasthttpCarrier is the propagation.TextMapCarrier of fasthttp request headers
+type fasthttpCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c fasthttpCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c fasthttpCarrier) Set(key, value string) {
+	c.header.Set(key, value)
+}
+
+func (c fasthttpCarrier) Keys() []string {
+	var keys []string
+	c.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// FastHTTPMiddleware traces the requests handled by next, continuing the traces propagated by
+// the clients. The handlers get the context of the span of their request with Context. It
+// returns next unless the HTTP server layer is instrumented.
+func (p *Provider) FastHTTPMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if !p.cfg.Instrument.HTTPServer {
+		return next
+	}
+	return func(rctx *fasthttp.RequestCtx) {
+		ctx := p.Propagator.Extract(context.Background(), fasthttpCarrier{&rctx.Request.Header})
+		method := string(rctx.Method())
+		attrs := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(method)}
+		ctx, span := p.tracer.Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+			trace.WithAttributes(semconv.URLPath(string(rctx.Path())), semconv.ClientAddress(rctx.RemoteIP().String())))
+		rctx.SetUserValue(contextKey, ctx)
+
+		start := time.Now()
+		defer func() {
+			status := rctx.Response.StatusCode()
+			attrs = append(attrs, semconv.HTTPResponseStatusCode(status))
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				// The 4xx are errors of the clients, not of the server
+				span.SetStatus(codes.Error, http.StatusText(status))
+				attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(status)))
+			}
+			end(ctx, span, p.httpServerDuration, start, time.Now(), nil, attrs...)
+		}()
+		next(rctx)
+	}
+}
+
+// Context returns the context of a request traced by FastHTTPMiddleware, carrying its span,
+// otherwise the context of the request.
+func Context(rctx *fasthttp.RequestCtx) context.Context {
+	if ctx, ok := rctx.UserValue(contextKey).(context.Context); ok {
+		return ctx
+	}
+	return rctx
+}
@@ -0,0 +1,144 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seidu626/go-buildingblocks/database"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pgxTracer traces the queries, batches and connections of pgx
+type pgxTracer struct {
+	p      *Provider
+	system attribute.KeyValue
+}
+
+var (
+	_ pgx.QueryTracer   = (*pgxTracer)(nil)
+	_ pgx.BatchTracer   = (*pgxTracer)(nil)
+	_ pgx.ConnectTracer = (*pgxTracer)(nil)
+)
+
+// pgxOperation is the operation traced with a context
+type pgxOperation struct {
+	start time.Time
+	attrs []attribute.KeyValue
+}
+
+type pgxOperationKey struct{}
+
+// PgxTracer returns the tracer of the pgx connections, see postgres.NewPGXPoolWithTracer, nil
+// unless the database layer is instrumented. system is the db.system of the spans, e.g.
+// "postgresql" or "cockroachdb".
+func (p *Provider) PgxTracer(system string) pgx.QueryTracer {
+	if !p.cfg.Instrument.Database {
+		return nil
+	}
+	return &pgxTracer{p: p, system: semconv.DBSystemKey.String(system)}
+}
+
+// InstrumentDatabase traces the pools created with cfg by dbx.NewDBXPool, if the database
+// layer is instrumented.
+func (p *Provider) InstrumentDatabase(cfg *database.Config, system string) {
+	if tracer := p.PgxTracer(system); tracer != nil {
+		cfg.Tracer = tracer
+	}
+}
+
+// operationName returns the first keyword of a statement, e.g. SELECT
+func operationName(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexAny(sql, " \t\r\n("); i > 0 {
+		sql = sql[:i]
+	}
+	return strings.ToUpper(sql)
+}
+
+// connAttributes returns the attributes of a connection
+func (t *pgxTracer) connAttributes(conn *pgx.Conn) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{t.system}
+	if conn == nil {
+		return attrs
+	}
+	cfg := conn.Config()
+	return append(attrs, semconv.DBNamespace(cfg.Database), semconv.ServerAddress(cfg.Host), semconv.ServerPort(int(cfg.Port)))
+}
+
+func (t *pgxTracer) start(ctx context.Context, name string, attrs []attribute.KeyValue, spanAttrs ...attribute.KeyValue) context.Context {
+	ctx, _ = t.p.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(spanAttrs...))
+	return context.WithValue(ctx, pgxOperationKey{}, &pgxOperation{start: time.Now(), attrs: attrs})
+}
+
+func (t *pgxTracer) end(ctx context.Context, err error) {
+	op, ok := ctx.Value(pgxOperationKey{}).(*pgxOperation)
+	if !ok {
+		return
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil
+	}
+	end(ctx, trace.SpanFromContext(ctx), t.p.dbDuration, op.start, time.Now(), err, op.attrs...)
+}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (t *pgxTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	op := operationName(data.SQL)
+	attrs := append(t.connAttributes(conn), semconv.DBOperationName(op))
+	return t.start(ctx, op, attrs, semconv.DBQueryText(data.SQL))
+}
+
+// TraceQueryEnd implements pgx.QueryTracer.
+func (t *pgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if data.Err == nil {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("db.response.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	t.end(ctx, data.Err)
+}
+
+// TraceBatchStart implements pgx.BatchTracer.
+func (t *pgxTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	attrs := append(t.connAttributes(conn), semconv.DBOperationName("BATCH"))
+	var size int
+	if data.Batch != nil {
+		size = data.Batch.Len()
+	}
+	return t.start(ctx, "BATCH", attrs, attribute.Int("db.operation.batch.size", size))
+}
+
+// TraceBatchQuery implements pgx.BatchTracer, adding an event per query to the span of the
+// batch.
+func (t *pgxTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	attrs := []attribute.KeyValue{semconv.DBQueryText(data.SQL)}
+	if data.Err != nil {
+		attrs = append(attrs, attribute.String("exception.message", data.Err.Error()))
+	}
+	trace.SpanFromContext(ctx).AddEvent("query", trace.WithAttributes(attrs...))
+}
+
+// TraceBatchEnd implements pgx.BatchTracer.
+func (t *pgxTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, data.Err)
+}
+
+// TraceConnectStart implements pgx.ConnectTracer.
+func (t *pgxTracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	attrs := []attribute.KeyValue{t.system, semconv.DBOperationName("CONNECT")}
+	if cfg := data.ConnConfig; cfg != nil {
+		attrs = append(attrs, semconv.DBNamespace(cfg.Database), semconv.ServerAddress(cfg.Host), semconv.ServerPort(int(cfg.Port)))
+	}
+	return t.start(ctx, "CONNECT", attrs)
+}
+
+// TraceConnectEnd implements pgx.ConnectTracer.
+func (t *pgxTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	t.end(ctx, data.Err)
+}
@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/seidu626/go-buildingblocks/rediskit"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisHook traces the commands, pipelines and dials of go-redis. The arguments of the commands
+// are not recorded, the cached values being possibly sensitive.
+type redisHook struct {
+	p *Provider
+}
+
+var _ redis.Hook = (*redisHook)(nil)
+
+// RedisHook returns the hook of the go-redis clients, nil unless the redis layer is
+// instrumented.
+func (p *Provider) RedisHook() redis.Hook {
+	if !p.cfg.Instrument.Redis {
+		return nil
+	}
+	return &redisHook{p: p}
+}
+
+// InstrumentRedis traces the commands of the clients created with cfg by
+// rediskit.NewRedisKitClient, if the redis layer is instrumented.
+func (p *Provider) InstrumentRedis(cfg *rediskit.Config) {
+	if hook := p.RedisHook(); hook != nil {
+		cfg.Hooks = append(cfg.Hooks, hook)
+	}
+}
+
+func (h *redisHook) trace(ctx context.Context, name string, spanAttrs []attribute.KeyValue, call func(ctx context.Context) error) error {
+	attrs := []attribute.KeyValue{semconv.DBSystemRedis, semconv.DBOperationName(name)}
+	ctx, span := h.p.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(spanAttrs...))
+	start := time.Now()
+	err := call(ctx)
+	recorded := err
+	if errors.Is(err, redis.Nil) {
+		// A cache miss
+		recorded = nil
+	}
+	end(ctx, span, h.p.dbDuration, start, time.Now(), recorded, attrs...)
+	return err
+}
+
+// DialHook implements redis.Hook.
+func (h *redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+		err = h.trace(ctx, "dial", []attribute.KeyValue{semconv.ServerAddress(addr)}, func(ctx context.Context) error {
+			conn, err = next(ctx, network, addr)
+			return err
+		})
+		return conn, err
+	}
+}
+
+// ProcessHook implements redis.Hook.
+func (h *redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.trace(ctx, cmd.FullName(), nil, func(ctx context.Context) error {
+			return next(ctx, cmd)
+		})
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook: a pipeline is a span, named after its commands.
+func (h *redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, 0, len(cmds))
+		for _, cmd := range cmds {
+			names = append(names, cmd.FullName())
+		}
+		attrs := []attribute.KeyValue{attribute.Int("db.operation.batch.size", len(cmds)), attribute.String("db.redis.commands", strings.Join(names, " "))}
+		return h.trace(ctx, "pipeline", attrs, func(ctx context.Context) error {
+			return next(ctx, cmds)
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Package telemetry bootstraps the OpenTelemetry tracer and meter providers, exporting to an
+// OTLP collector, to stdout or in memory for the tests, and instruments the building blocks:
+// the pgx pools of postgres and cockroach, cassandra, rediskit, the requests clients, the
+// fasthttp handlers and the AWS SDK clients. Each layer is opt-in, its Instrument method doing
+// nothing unless the layer is enabled in the configuration.
+//
+//	tel, err := telemetry.New(ctx, telemetry.ConvertToTelemetryConfig(appConfig))
+//	tel.SetGlobal()
+//	tel.InstrumentDatabase(dbConfig, "cockroachdb")
+//	tel.InstrumentAWS(awsConfig)
+//	handler = tel.FastHTTPMiddleware(handler)
+//	graceful.GracefulShutdown(ctx, logger, timeout, map[string]graceful.Operation{"telemetry": tel.Shutdown})
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// InstrumentationName is the name of the tracer and the meter of the package.
+const InstrumentationName = "github.com/seidu626/go-buildingblocks/telemetry"
+
+// Exporters of the spans and the metrics.
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterMemory = "memory"
+)
+
+// ErrNoMemory is returned by the methods of the in-memory exporter on other providers.
+var ErrNoMemory = errors.New("telemetry: not an in-memory provider")
+
+// Instrumentation selects the instrumented layers.
+type Instrumentation struct {
+	// Database traces the pgx pools, of postgres and cockroach
+	Database   bool
+	Cassandra  bool
+	Redis      bool
+	HTTPClient bool
+	HTTPServer bool
+	AWS        bool
+}
+
+// all returns the instrumentation of all the layers
+func all() Instrumentation {
+	return Instrumentation{Database: true, Cassandra: true, Redis: true, HTTPClient: true, HTTPServer: true, AWS: true}
+}
+
+// Config holds the configuration of a Provider.
+type Config struct {
+	// Enabled, otherwise the providers are no-op and nothing is instrumented
+	Enabled        bool
+	ServiceName    string
+	ServiceVersion string
+	Environment    string
+	// Exporter is ExporterOTLP (default), ExporterStdout or ExporterMemory
+	Exporter string
+	// Endpoint of the OTLP gRPC collector, default the OTEL_EXPORTER_OTLP_ENDPOINT environment
+	// variable or localhost:4317
+	Endpoint string
+	Insecure bool
+	// Headers are sent to the collector, e.g. an API key
+	Headers map[string]string
+	// Writer of ExporterStdout, default os.Stdout
+	Writer io.Writer
+	// SampleRatio of the traces started by the service, default 1 (all). The spans of the
+	// traces started upstream follow the decision of the caller.
+	SampleRatio float64
+	// MetricInterval is the export interval of the metrics, default 1m
+	MetricInterval time.Duration
+	Instrument     Instrumentation
+	Logger         *zap.Logger
+}
+
+func (c *Config) setDefaults() {
+	if c.Exporter == "" {
+		c.Exporter = ExporterOTLP
+	}
+	if c.Writer == nil {
+		c.Writer = os.Stdout
+	}
+	if c.SampleRatio <= 0 || c.SampleRatio > 1 {
+		c.SampleRatio = 1
+	}
+	if c.MetricInterval <= 0 {
+		c.MetricInterval = time.Minute
+	}
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+}
+
+// ConvertToTelemetryConfig converts the Telemetry section of a config.Config to a Config, the
+// service being described by the Application section.
+func ConvertToTelemetryConfig(appConfig *config.Config) Config {
+	conf := appConfig.Telemetry
+	return Config{
+		Enabled:        conf.Enabled,
+		ServiceName:    appConfig.Application.Name,
+		Environment:    string(appConfig.Application.Environment),
+		Exporter:       conf.Exporter,
+		Endpoint:       conf.Endpoint,
+		Insecure:       conf.Insecure,
+		Headers:        conf.Headers,
+		SampleRatio:    conf.SampleRatio,
+		MetricInterval: conf.MetricInterval,
+		Instrument: Instrumentation{
+			Database:   conf.Instrument.Database,
+			Cassandra:  conf.Instrument.Cassandra,
+			Redis:      conf.Instrument.Redis,
+			HTTPClient: conf.Instrument.HTTPClient,
+			HTTPServer: conf.Instrument.HTTPServer,
+			AWS:        conf.Instrument.AWS,
+		},
+	}
+}
+
+// Memory holds the spans and the metrics of ExporterMemory.
+type Memory struct {
+	Spans  *tracetest.InMemoryExporter
+	Reader *sdkmetric.ManualReader
+}
+
+// Provider holds the tracer and meter providers, and instruments the building blocks.
+type Provider struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	Propagator     propagation.TextMapPropagator
+	// Memory, with ExporterMemory only
+	Memory *Memory
+
+	cfg      Config
+	tracer   trace.Tracer
+	shutdown []func(context.Context) error
+
+	dbDuration         metric.Float64Histogram
+	httpClientDuration metric.Float64Histogram
+	httpServerDuration metric.Float64Histogram
+	awsDuration        metric.Float64Histogram
+}
+
+// New creates a Provider. The spans are exported in batches, except by ExporterMemory.
+func New(ctx context.Context, cfg Config) (*Provider, error) {
+	cfg.setDefaults()
+	p := &Provider{
+		cfg:        cfg,
+		Propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+	if !cfg.Enabled {
+		p.TracerProvider = tracenoop.NewTracerProvider()
+		p.MeterProvider = metricnoop.NewMeterProvider()
+		p.cfg.Instrument = Instrumentation{}
+		return p, p.init()
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceName(cfg.ServiceName)}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	if cfg.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(cfg.Environment))
+	}
+	res, err := resource.New(ctx, resource.WithAttributes(attrs...), resource.WithFromEnv(), resource.WithTelemetrySDK(), resource.WithHost())
+	if err != nil {
+		return nil, fmt.Errorf("telemetry: creating resource: %w", err)
+	}
+
+	var spans sdktrace.TracerProviderOption
+	var reader sdkmetric.Reader
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		traceOpts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(cfg.Headers)}
+		metricOpts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithHeaders(cfg.Headers)}
+		if cfg.Endpoint != "" {
+			traceOpts = append(traceOpts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+			metricOpts = append(metricOpts, otlpmetricgrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			traceOpts = append(traceOpts, otlptracegrpc.WithInsecure())
+			metricOpts = append(metricOpts, otlpmetricgrpc.WithInsecure())
+		}
+		traceExporter, err := otlptracegrpc.New(ctx, traceOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: creating OTLP trace exporter: %w", err)
+		}
+		metricExporter, err := otlpmetricgrpc.New(ctx, metricOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: creating OTLP metric exporter: %w", err)
+		}
+		spans = sdktrace.WithBatcher(traceExporter)
+		reader = sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(cfg.MetricInterval))
+	case ExporterStdout:
+		traceExporter, err := stdouttrace.New(stdouttrace.WithWriter(cfg.Writer))
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: creating stdout trace exporter: %w", err)
+		}
+		metricExporter, err := stdoutmetric.New(stdoutmetric.WithWriter(cfg.Writer))
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: creating stdout metric exporter: %w", err)
+		}
+		spans = sdktrace.WithBatcher(traceExporter)
+		reader = sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(cfg.MetricInterval))
+	case ExporterMemory:
+		p.Memory = &Memory{Spans: tracetest.NewInMemoryExporter(), Reader: sdkmetric.NewManualReader()}
+		spans = sdktrace.WithSyncer(p.Memory.Spans)
+		reader = p.Memory.Reader
+	default:
+		return nil, fmt.Errorf("telemetry: unknown exporter %q", cfg.Exporter)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		spans,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+	p.TracerProvider, p.MeterProvider = tp, mp
+	p.shutdown = []func(context.Context) error{tp.Shutdown, mp.Shutdown}
+	return p, p.init()
+}
+
+// NewMemoryProvider creates a Provider exporting in memory with all the layers instrumented,
+// for the tests.
+func NewMemoryProvider() *Provider {
+	p, err := New(context.Background(), Config{Enabled: true, ServiceName: "test", Exporter: ExporterMemory, Instrument: all()})
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// init creates the tracer and the instruments
+func (p *Provider) init() error {
+	p.tracer = p.TracerProvider.Tracer(InstrumentationName)
+	meter := p.MeterProvider.Meter(InstrumentationName)
+	var err error
+	histogram := func(name, description string) metric.Float64Histogram {
+		h, e := meter.Float64Histogram(name, metric.WithDescription(description), metric.WithUnit("s"))
+		err = errors.Join(err, e)
+		return h
+	}
+	p.dbDuration = histogram("db.client.operation.duration", "Duration of the database operations")
+	p.httpClientDuration = histogram("http.client.request.duration", "Duration of the HTTP client requests")
+	p.httpServerDuration = histogram("http.server.request.duration", "Duration of the HTTP server requests")
+	p.awsDuration = histogram("aws.client.operation.duration", "Duration of the AWS SDK operations")
+	if err != nil {
+		return fmt.Errorf("telemetry: creating instruments: %w", err)
+	}
+	return nil
+}
+
+// SetGlobal registers the providers and the propagator as the global ones of otel.
+func (p *Provider) SetGlobal() {
+	otel.SetTracerProvider(p.TracerProvider)
+	otel.SetMeterProvider(p.MeterProvider)
+	otel.SetTextMapPropagator(p.Propagator)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		p.cfg.Logger.Warn("telemetry error", zap.Error(err))
+	}))
+}
+
+// Tracer returns the tracer of the package, to start the spans of the application.
+func (p *Provider) Tracer() trace.Tracer {
+	return p.tracer
+}
+
+// Shutdown flushes the spans and the metrics and stops the exporters.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	var err error
+	for _, shutdown := range p.shutdown {
+		err = errors.Join(err, shutdown(ctx))
+	}
+	return err
+}
+
+// Spans returns the spans ended, with ExporterMemory.
+func (p *Provider) Spans() (tracetest.SpanStubs, error) {
+	if p.Memory == nil {
+		return nil, ErrNoMemory
+	}
+	return p.Memory.Spans.GetSpans(), nil
+}
+
+// Metrics collects the metrics, with ExporterMemory.
+func (p *Provider) Metrics(ctx context.Context) (metricdata.ResourceMetrics, error) {
+	var rm metricdata.ResourceMetrics
+	if p.Memory == nil {
+		return rm, ErrNoMemory
+	}
+	err := p.Memory.Reader.Collect(ctx, &rm)
+	return rm, err
+}
+
+// end ends a span at end, recording err if any, and its duration in histogram with attrs
+func end(ctx context.Context, span trace.Span, histogram metric.Float64Histogram, start, end time.Time, err error, attrs ...attribute.KeyValue) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errorType(err)))
+	}
+	histogram.Record(ctx, end.Sub(start).Seconds(), metric.WithAttributes(attrs...))
+	span.End(trace.WithTimestamp(end))
+}
+
+// errorType returns the error.type attribute of an error
+func errorType(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	return fmt.Sprintf("%T", err)
+}
@@ -0,0 +1,277 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+	"github.com/seidu626/go-buildingblocks/database"
+	"github.com/seidu626/go-buildingblocks/database/cassandra"
+	"github.com/seidu626/go-buildingblocks/rediskit"
+	"github.com/seidu626/go-buildingblocks/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attr returns the value of an attribute of a span, nil if none
+func attr(span tracetest.SpanStub, key attribute.Key) any {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+// spans returns the spans ended, and resets them
+func spans(t *testing.T, p *Provider) tracetest.SpanStubs {
+	t.Helper()
+	stubs, err := p.Spans()
+	require.NoError(t, err)
+	p.Memory.Spans.Reset()
+	return stubs
+}
+
+// histogramCount returns the number of values recorded by a histogram
+func histogramCount(t *testing.T, p *Provider, name string) uint64 {
+	t.Helper()
+	rm, err := p.Metrics(context.Background())
+	require.NoError(t, err)
+	var count uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == name {
+				for _, dp := range h.DataPoints {
+					count += dp.Count
+				}
+			}
+		}
+	}
+	return count
+}
+
+func TestDisabled(t *testing.T) {
+	p, err := New(context.Background(), Config{Instrument: all()})
+	require.NoError(t, err)
+	assert.Nil(t, p.PgxTracer("postgresql"))
+	assert.Nil(t, p.RedisHook())
+
+	var dbConfig database.Config
+	p.InstrumentDatabase(&dbConfig, "cockroachdb")
+	assert.Nil(t, dbConfig.Tracer)
+	var cqlConfig cassandra.Config
+	p.InstrumentCassandra(&cqlConfig)
+	assert.Nil(t, cqlConfig.QueryObserver)
+	var redisConfig rediskit.Config
+	p.InstrumentRedis(&redisConfig)
+	assert.Empty(t, redisConfig.Hooks)
+	var awsConfig aws.Config
+	p.InstrumentAWS(&awsConfig)
+	assert.Empty(t, awsConfig.APIOptions)
+
+	_, err = p.Spans()
+	assert.ErrorIs(t, err, ErrNoMemory)
+	assert.NoError(t, p.Shutdown(context.Background()))
+
+	_, err = New(context.Background(), Config{Enabled: true, Exporter: "zipkin"})
+	assert.ErrorContains(t, err, "unknown exporter")
+}
+
+func TestStdout(t *testing.T) {
+	var out bytes.Buffer
+	p, err := New(context.Background(), Config{Enabled: true, ServiceName: "billing", Exporter: ExporterStdout, Writer: &out})
+	require.NoError(t, err)
+	_, span := p.Tracer().Start(context.Background(), "charge")
+	span.End()
+	require.NoError(t, p.Shutdown(context.Background()))
+	assert.Contains(t, out.String(), `"Name":"charge"`)
+	assert.Contains(t, out.String(), "billing")
+}
+
+func TestPgx(t *testing.T) {
+	p := NewMemoryProvider()
+	var cfg database.Config
+	p.InstrumentDatabase(&cfg, "cockroachdb")
+	require.NotNil(t, cfg.Tracer)
+	tracer := cfg.Tracer.(*pgxTracer)
+	ctx, parent := p.Tracer().Start(context.Background(), "handler")
+
+	qctx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "select id from subscriptions where msisdn = $1"})
+	tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+	qctx = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "INSERT INTO subscriptions VALUES ($1)"})
+	tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: &pgconn.PgError{Code: "23505"}})
+
+	bctx := tracer.TraceBatchStart(ctx, nil, pgx.TraceBatchStartData{Batch: &pgx.Batch{}})
+	tracer.TraceBatchQuery(bctx, nil, pgx.TraceBatchQueryData{SQL: "UPDATE a SET b = 1"})
+	tracer.TraceBatchEnd(bctx, nil, pgx.TraceBatchEndData{})
+	parent.End()
+
+	stubs := spans(t, p)
+	require.Len(t, stubs, 4)
+	sel, ins, batch := stubs[0], stubs[1], stubs[2]
+	assert.Equal(t, "SELECT", sel.Name)
+	assert.Equal(t, trace.SpanKindClient, sel.SpanKind)
+	assert.Equal(t, parent.SpanContext().SpanID(), sel.Parent.SpanID())
+	assert.Equal(t, "cockroachdb", attr(sel, "db.system"))
+	assert.Equal(t, "select id from subscriptions where msisdn = $1", attr(sel, "db.query.text"))
+	assert.Equal(t, int64(1), attr(sel, "db.response.rows_affected"))
+	assert.Equal(t, otelcodes.Error, ins.Status.Code)
+	assert.Equal(t, "BATCH", batch.Name)
+	require.Len(t, batch.Events, 1)
+	assert.Equal(t, uint64(3), histogramCount(t, p, "db.client.operation.duration"))
+}
+
+func TestCassandra(t *testing.T) {
+	p := NewMemoryProvider()
+	var cfg cassandra.Config
+	p.InstrumentCassandra(&cfg)
+	require.NotNil(t, cfg.QueryObserver)
+	start := time.Now().Add(-time.Second)
+	cfg.QueryObserver.ObserveQuery(context.Background(), gocql.ObservedQuery{
+		Keyspace: "billing", Statement: "SELECT * FROM charges WHERE id = ?", Start: start, End: start.Add(20 * time.Millisecond), Rows: 1,
+	})
+	cfg.BatchObserver.ObserveBatch(context.Background(), gocql.ObservedBatch{
+		Keyspace: "billing", Statements: []string{"INSERT 1", "INSERT 2"}, Start: start, End: start.Add(time.Millisecond), Err: errors.New("timeout"),
+	})
+
+	stubs := spans(t, p)
+	require.Len(t, stubs, 2)
+	assert.Equal(t, "SELECT", stubs[0].Name)
+	assert.Equal(t, "billing", attr(stubs[0], "db.namespace"))
+	assert.Equal(t, 20*time.Millisecond, stubs[0].EndTime.Sub(stubs[0].StartTime), "times of the observation")
+	assert.Equal(t, otelcodes.Error, stubs[1].Status.Code)
+	assert.Len(t, stubs[1].Events, 3, "2 queries and the error")
+}
+
+func TestRedis(t *testing.T) {
+	p := NewMemoryProvider()
+	var cfg rediskit.Config
+	p.InstrumentRedis(&cfg)
+	require.Len(t, cfg.Hooks, 1)
+	hook := cfg.Hooks[0]
+	ctx := context.Background()
+
+	process := hook.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+		cmd.SetErr(redis.Nil)
+		return redis.Nil
+	})
+	assert.ErrorIs(t, process(ctx, redis.NewStringCmd(ctx, "get", "subscription:1")), redis.Nil)
+	pipeline := hook.ProcessPipelineHook(func(ctx context.Context, cmds []redis.Cmder) error { return nil })
+	require.NoError(t, pipeline(ctx, []redis.Cmder{redis.NewStatusCmd(ctx, "set", "k", "v"), redis.NewIntCmd(ctx, "expire", "k", 10)}))
+	dial := hook.DialHook(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	})
+	_, err := dial(ctx, "tcp", "10.0.0.1:6379")
+	assert.Error(t, err)
+
+	stubs := spans(t, p)
+	require.Len(t, stubs, 3)
+	assert.Equal(t, "get", stubs[0].Name)
+	assert.Equal(t, otelcodes.Unset, stubs[0].Status.Code, "cache miss")
+	assert.Nil(t, attr(stubs[0], "db.query.text"), "arguments not recorded")
+	assert.Equal(t, "set expire", attr(stubs[1], "db.redis.commands"))
+	assert.Equal(t, "dial", stubs[2].Name)
+	assert.Equal(t, otelcodes.Error, stubs[2].Status.Code)
+}
+
+func TestHTTP(t *testing.T) {
+	p := NewMemoryProvider()
+	handler := p.FastHTTPMiddleware(func(rctx *fasthttp.RequestCtx) {
+		_, span := p.Tracer().Start(Context(rctx), "charge")
+		span.End()
+		if string(rctx.Path()) == "/fail" {
+			rctx.SetStatusCode(fasthttp.StatusBadGateway)
+		}
+	})
+	// The fasthttp server behind the requests client
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rctx fasthttp.RequestCtx
+		rctx.Request.Header.SetMethod(r.Method)
+		rctx.Request.SetRequestURI(r.URL.RequestURI())
+		for k := range r.Header {
+			rctx.Request.Header.Set(k, r.Header.Get(k))
+		}
+		handler(&rctx)
+		w.WriteHeader(rctx.Response.StatusCode())
+	}))
+	defer srv.Close()
+
+	cfg := requests.Config{Retry: requests.RetryPolicy{MaxAttempts: 1}}
+	p.InstrumentRequests(&cfg)
+	client := requests.NewClient(cfg)
+	ctx, parent := p.Tracer().Start(context.Background(), "job")
+	for _, path := range []string{"/ok", "/fail"} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(ctx, req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	parent.End()
+
+	stubs := spans(t, p)
+	require.Len(t, stubs, 7, "2 x (charge, server, client) and job")
+	charge, server, client1 := stubs[0], stubs[1], stubs[2]
+	traceID := parent.SpanContext().TraceID()
+	for _, s := range stubs {
+		assert.Equal(t, traceID, s.SpanContext.TraceID(), s.Name)
+	}
+	assert.Equal(t, server.SpanContext.SpanID(), charge.Parent.SpanID())
+	assert.Equal(t, client1.SpanContext.SpanID(), server.Parent.SpanID(), "propagated")
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, "/ok", attr(server, "url.path"))
+	assert.Equal(t, trace.SpanKindClient, client1.SpanKind)
+	assert.Equal(t, int64(200), attr(client1, "http.response.status_code"))
+	assert.Equal(t, otelcodes.Error, stubs[4].Status.Code, "server 502")
+	assert.Equal(t, otelcodes.Error, stubs[5].Status.Code, "client 502")
+	assert.Equal(t, uint64(2), histogramCount(t, p, "http.client.request.duration"))
+	assert.Equal(t, uint64(2), histogramCount(t, p, "http.server.request.duration"))
+}
+
+func TestAWS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amzn-RequestId", "req-1")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.sqs#QueueDoesNotExist","message":"no queue"}`))
+	}))
+	defer srv.Close()
+
+	p := NewMemoryProvider()
+	cfg := aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		BaseEndpoint: aws.String(srv.URL),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	}
+	p.InstrumentAWS(&cfg)
+	_, err := sqs.NewFromConfig(cfg).GetQueueUrl(context.Background(), &sqs.GetQueueUrlInput{QueueName: aws.String("billing")})
+	require.Error(t, err)
+
+	stubs := spans(t, p)
+	require.Len(t, stubs, 1)
+	assert.Equal(t, "SQS.GetQueueUrl", stubs[0].Name)
+	assert.Equal(t, "eu-west-1", attr(stubs[0], "cloud.region"))
+	assert.Equal(t, int64(400), attr(stubs[0], "http.response.status_code"))
+	assert.Equal(t, "req-1", attr(stubs[0], "aws.request_id"))
+	assert.Equal(t, otelcodes.Error, stubs[0].Status.Code)
+	assert.Equal(t, uint64(1), histogramCount(t, p, "aws.client.operation.duration"))
+}
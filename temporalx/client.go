@@ -0,0 +1,52 @@
+package temporalx
+
+import (
+	"fmt"
+
+	"github.com/seidu626/go-buildingblocks/logging"
+	"go.temporal.io/sdk/client"
+)
+
+// NewClient creates a client of the Temporal service, logging with cfg.Logger. Its workers log
+// the workflows and the activities, and trace them if cfg.TracerProvider is set. Unless
+// cfg.Lazy is set, it fails if the service is unreachable.
+func NewClient(cfg ClientConfig) (client.Client, error) {
+	cfg.setDefaults()
+	options := client.Options{
+		HostPort:          cfg.HostPort,
+		Namespace:         cfg.Namespace,
+		Identity:          cfg.Identity,
+		Logger:            logging.NewZapAdapter(cfg.Logger),
+		DataConverter:     cfg.DataConverter,
+		ConnectionOptions: client.ConnectionOptions{TLS: cfg.TLSConfig},
+	}
+	if cfg.APIKey != "" {
+		options.Credentials = client.NewAPIKeyStaticCredentials(cfg.APIKey)
+	}
+	if cfg.Cipher != nil {
+		options.DataConverter = NewEncryptedDataConverter(cfg.DataConverter, cfg.Cipher)
+	}
+
+	// The tracing interceptor is the outermost one, the logs of the others being in its spans
+	if cfg.TracerProvider != nil {
+		tracing, err := NewTracingInterceptor(cfg.TracerProvider, cfg.Propagator)
+		if err != nil {
+			return nil, err
+		}
+		options.Interceptors = append(options.Interceptors, tracing)
+	}
+	options.Interceptors = append(options.Interceptors, NewLoggingInterceptor(cfg.Logger))
+	options.Interceptors = append(options.Interceptors, cfg.Interceptors...)
+
+	var c client.Client
+	var err error
+	if cfg.Lazy {
+		c, err = client.NewLazyClient(options)
+	} else {
+		c, err = client.Dial(options)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Temporal client for %s: %w", cfg.HostPort, err)
+	}
+	return c, nil
+}
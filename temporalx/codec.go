@@ -0,0 +1,88 @@
+package temporalx
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/seidu626/go-buildingblocks/crypt"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MetadataEncodingEncrypted is the encoding of the payloads encrypted by a Codec.
+	MetadataEncodingEncrypted = "binary/encrypted"
+	// MetadataEncryptionKeyID is the metadata holding the ID of the key of a payload.
+	MetadataEncryptionKeyID = "encryption-key-id"
+)
+
+// Codec is a converter.PayloadCodec encrypting the payloads with a crypt.Cipher, so the
+// Temporal service and its history only hold ciphertexts. The keys can be rotated, the
+// payloads encrypted with the older keys of the cipher remaining readable.
+type Codec struct {
+	cipher *crypt.Cipher
+}
+
+var _ converter.PayloadCodec = (*Codec)(nil)
+
+// NewCodec creates a Codec.
+func NewCodec(cipher *crypt.Cipher) *Codec {
+	return &Codec{cipher: cipher}
+}
+
+// NewEncryptedDataConverter returns parent with its payloads encrypted by cipher.
+func NewEncryptedDataConverter(parent converter.DataConverter, cipher *crypt.Cipher) converter.DataConverter {
+	return converter.NewCodecDataConverter(parent, NewCodec(cipher))
+}
+
+// Encode implements converter.PayloadCodec: the payloads are encrypted whole, with their
+// metadata.
+func (c *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	out := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		data, err := proto.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("temporalx: encoding payload: %w", err)
+		}
+		ciphertext, err := c.cipher.Encrypt(data, []byte(MetadataEncodingEncrypted))
+		if err != nil {
+			return nil, fmt.Errorf("temporalx: encrypting payload: %w", err)
+		}
+		keyID, _ := crypt.KeyID(ciphertext)
+		out[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{
+				converter.MetadataEncoding: []byte(MetadataEncodingEncrypted),
+				MetadataEncryptionKeyID:    []byte(keyID),
+			},
+			Data: ciphertext,
+		}
+	}
+	return out, nil
+}
+
+// Decode implements converter.PayloadCodec, the payloads not encrypted being returned as is.
+func (c *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	out := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.GetMetadata()[converter.MetadataEncoding]) != MetadataEncodingEncrypted {
+			out[i] = p
+			continue
+		}
+		data, err := c.cipher.Decrypt(p.Data, []byte(MetadataEncodingEncrypted))
+		if err != nil {
+			return nil, fmt.Errorf("temporalx: decrypting payload: %w", err)
+		}
+		out[i] = &commonpb.Payload{}
+		if err := proto.Unmarshal(data, out[i]); err != nil {
+			return nil, fmt.Errorf("temporalx: decoding payload: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// CodecHandler returns the handler of the codec server of the Temporal UI and CLI, decoding the
+// payloads for the operators. It must be served behind an authentication.
+func CodecHandler(cipher *crypt.Cipher) http.Handler {
+	return converter.NewPayloadCodecHTTPHandler(NewCodec(cipher))
+}
@@ -0,0 +1,134 @@
+// Package temporalx builds Temporal clients and workers: the clients log with
+// logging.ZapAdapter, log and trace the workflows and the activities of their workers, and
+// optionally encrypt the payloads with a crypt.Cipher.
+//
+//	cfg, err := temporalx.ConvertToClientConfig(appConfig)
+//	cfg.Logger, cfg.TracerProvider, cfg.Propagator = logger, tel.TracerProvider, tel.Propagator
+//	c, err := temporalx.NewClient(cfg)
+//	w, err := temporalx.NewWorker(c, temporalx.WorkerConfig{
+//		TaskQueue:  "billing",
+//		Workflows:  []interface{}{ChargeWorkflow},
+//		Activities: []interface{}{&Activities{store: store}},
+//	})
+//	go w.Run(ctx)
+//	graceful.GracefulShutdown(ctx, logger, timeout, map[string]graceful.Operation{"temporal": w.Shutdown})
+package temporalx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/seidu626/go-buildingblocks/config"
+	"github.com/seidu626/go-buildingblocks/crypt"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.uber.org/zap"
+)
+
+// ClientConfig holds the configuration of a client.
+type ClientConfig struct {
+	// HostPort of the frontend service, default localhost:7233
+	HostPort string
+	// Namespace default "default"
+	Namespace string
+	// Identity of the client and its workers, default the one of the SDK
+	Identity string
+	// APIKey, optional, authenticates to Temporal Cloud
+	APIKey string
+	// TLSConfig, plaintext when nil
+	TLSConfig *tls.Config
+	// DataConverter, default converter.GetDefaultDataConverter()
+	DataConverter converter.DataConverter
+	// Cipher, optional, encrypts the payloads of the DataConverter, see NewCodec
+	Cipher *crypt.Cipher
+	// TracerProvider, optional, traces the workflows and the activities, e.g. the one of
+	// telemetry.Provider
+	TracerProvider trace.TracerProvider
+	// Propagator propagates the traces in the headers of the workflows and the activities,
+	// default otel.GetTextMapPropagator()
+	Propagator propagation.TextMapPropagator
+	// Interceptors are chained after those of the package
+	Interceptors []interceptor.ClientInterceptor
+	// Lazy connects at the first call rather than when the client is created
+	Lazy   bool
+	Logger *zap.Logger
+}
+
+func (c *ClientConfig) setDefaults() {
+	if c.HostPort == "" {
+		c.HostPort = client.DefaultHostPort
+	}
+	if c.Namespace == "" {
+		c.Namespace = client.DefaultNamespace
+	}
+	if c.DataConverter == nil {
+		c.DataConverter = converter.GetDefaultDataConverter()
+	}
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+}
+
+// ConvertToClientConfig converts the Temporal section of a config.Config to a ClientConfig.
+// The encryption keys are 32 bytes encoded in base64, used with XChaCha20-Poly1305.
+func ConvertToClientConfig(appConfig *config.Config) (ClientConfig, error) {
+	conf := appConfig.Temporal
+	cfg := ClientConfig{HostPort: conf.HostPort, Namespace: conf.Namespace, APIKey: conf.APIKey}
+	if conf.TLSEnabled {
+		cfg.TLSConfig = &tls.Config{ServerName: conf.TLSServerName, MinVersion: tls.VersionTLS12}
+		if conf.TLSCertPath != "" && conf.TLSKeyPath != "" {
+			cert, err := tls.LoadX509KeyPair(conf.TLSCertPath, conf.TLSKeyPath)
+			if err != nil {
+				return ClientConfig{}, fmt.Errorf("failed to load TLS key pair: %v", err)
+			}
+			cfg.TLSConfig.Certificates = []tls.Certificate{cert}
+		}
+		if conf.TLSCaPath != "" {
+			pem, err := os.ReadFile(conf.TLSCaPath)
+			if err != nil {
+				return ClientConfig{}, fmt.Errorf("failed to read TLS CA certificate: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return ClientConfig{}, fmt.Errorf("failed to append CA certificate")
+			}
+			cfg.TLSConfig.RootCAs = pool
+		}
+	}
+	if conf.Encryption.KeyID != "" {
+		c, err := newCipher(conf.Encryption.KeyID, conf.Encryption.Keys)
+		if err != nil {
+			return ClientConfig{}, fmt.Errorf("temporal configuration error: %w", err)
+		}
+		cfg.Cipher = c
+	}
+	return cfg, nil
+}
+
+// newCipher returns the cipher of the base64 keys, encrypting with the key primary
+func newCipher(primary string, keys map[string]string) (*crypt.Cipher, error) {
+	var primaryKey crypt.Key
+	var older []crypt.Key
+	for id, encoded := range keys {
+		material, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		key := crypt.Key{ID: id, Algorithm: crypt.XChaCha20Poly1305, Material: material}
+		if id == primary {
+			primaryKey = key
+		} else {
+			older = append(older, key)
+		}
+	}
+	if primaryKey.ID == "" {
+		return nil, fmt.Errorf("no encryption key %q", primary)
+	}
+	return crypt.NewCipher(primaryKey, older...)
+}
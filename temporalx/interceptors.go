@@ -0,0 +1,103 @@
+package temporalx
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/logging"
+	"github.com/seidu626/go-buildingblocks/telemetry"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+	"go.uber.org/zap"
+)
+
+type loggingInterceptor struct {
+	interceptor.InterceptorBase
+	logger *zap.Logger
+}
+
+// NewLoggingInterceptor returns the interceptor logging the outcome and the duration of the
+// workflows and the activities. The activities get logger in their context with the fields of
+// the activity, see logging.FromContext; the workflows log with the replay-safe logger of the
+// client, see workflow.GetLogger.
+func NewLoggingInterceptor(logger *zap.Logger) interceptor.Interceptor {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &loggingInterceptor{logger: logger}
+}
+
+func (i *loggingInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityLogging{ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next}, logger: i.logger}
+}
+
+func (i *loggingInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &workflowLogging{WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next}}
+}
+
+type activityLogging struct {
+	interceptor.ActivityInboundInterceptorBase
+	logger *zap.Logger
+}
+
+func (a *activityLogging) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	info := activity.GetInfo(ctx)
+	ctx = logging.WithContext(ctx, a.logger)
+	ctx = logging.WithFields(ctx,
+		zap.String("workflow_id", info.WorkflowExecution.ID),
+		zap.String("run_id", info.WorkflowExecution.RunID),
+		zap.String("activity_type", info.ActivityType.Name),
+		zap.Int32("attempt", info.Attempt))
+	logger := logging.FromContext(ctx)
+
+	start := time.Now()
+	result, err := a.Next.ExecuteActivity(ctx, in)
+	if err != nil {
+		if errors.Is(err, activity.ErrResultPending) {
+			// Completed asynchronously, see client.Client.CompleteActivity
+			logger.Debug("activity pending", zap.Duration("duration", time.Since(start)))
+		} else {
+			logger.Error("activity failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+		}
+		return result, err
+	}
+	logger.Info("activity completed", zap.Duration("duration", time.Since(start)))
+	return result, nil
+}
+
+type workflowLogging struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (w *workflowLogging) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (interface{}, error) {
+	// The logger of the workflows drops the logs of the replays, and the time of the workflows
+	// is the one of their history
+	logger := workflow.GetLogger(ctx)
+	start := workflow.Now(ctx)
+	result, err := w.Next.ExecuteWorkflow(ctx, in)
+	duration := workflow.Now(ctx).Sub(start)
+	switch {
+	case err == nil:
+		logger.Info("workflow completed", "duration", duration)
+	case workflow.IsContinueAsNewError(err):
+		logger.Info("workflow continued as new", "duration", duration)
+	default:
+		logger.Error("workflow failed", "duration", duration, "error", err)
+	}
+	return result, err
+}
+
+// NewTracingInterceptor returns the interceptor tracing the workflows and the activities with
+// the tracers of tp, propagating the traces in their headers with propagator, default
+// otel.GetTextMapPropagator(). The spans of a workflow are created once, not at its replays.
+func NewTracingInterceptor(tp trace.TracerProvider, propagator propagation.TextMapPropagator) (interceptor.Interceptor, error) {
+	return opentelemetry.NewTracingInterceptor(opentelemetry.TracerOptions{
+		Tracer:            tp.Tracer(telemetry.InstrumentationName),
+		TextMapPropagator: propagator,
+	})
+}
@@ -0,0 +1,249 @@
+package temporalx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seidu626/go-buildingblocks/config"
+	"github.com/seidu626/go-buildingblocks/crypt"
+	"github.com/seidu626/go-buildingblocks/logging"
+	"github.com/seidu626/go-buildingblocks/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newKey(t *testing.T, id string) crypt.Key {
+	material := make([]byte, crypt.KeySize)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	return crypt.Key{ID: id, Algorithm: crypt.XChaCha20Poly1305, Material: material}
+}
+
+func newTestCipher(t *testing.T, primary crypt.Key, older ...crypt.Key) *crypt.Cipher {
+	c, err := crypt.NewCipher(primary, older...)
+	require.NoError(t, err)
+	return c
+}
+
+type Order struct {
+	ID     string
+	Amount int
+}
+
+func Charge(ctx context.Context, order Order) (string, error) {
+	logging.FromContext(ctx).Info("charging")
+	if order.Amount <= 0 {
+		return "", temporal.NewNonRetryableApplicationError("invalid amount", "InvalidAmount", nil)
+	}
+	return "receipt-" + order.ID, nil
+}
+
+func ChargeWorkflow(ctx workflow.Context, order Order) (string, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+	var receipt string
+	err := workflow.ExecuteActivity(ctx, Charge, order).Get(ctx, &receipt)
+	return receipt, err
+}
+
+func TestCodec(t *testing.T) {
+	key2024, key2025 := newKey(t, "2024"), newKey(t, "2025")
+	payload, err := converter.GetDefaultDataConverter().ToPayload(Order{ID: "o-1", Amount: 10})
+	require.NoError(t, err)
+
+	codec := NewCodec(newTestCipher(t, key2024))
+	encoded, err := codec.Encode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	require.Len(t, encoded, 1)
+	assert.Equal(t, MetadataEncodingEncrypted, string(encoded[0].Metadata[converter.MetadataEncoding]))
+	assert.Equal(t, "2024", string(encoded[0].Metadata[MetadataEncryptionKeyID]))
+	assert.NotContains(t, string(encoded[0].Data), "o-1")
+
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, payload.Data, decoded[0].Data)
+	assert.Equal(t, payload.Metadata, decoded[0].Metadata)
+
+	t.Run("rotation", func(t *testing.T) {
+		rotated := NewCodec(newTestCipher(t, key2025, key2024))
+		decoded, err := rotated.Decode(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, payload.Data, decoded[0].Data)
+
+		reencoded, err := rotated.Encode(decoded)
+		require.NoError(t, err)
+		assert.Equal(t, "2025", string(reencoded[0].Metadata[MetadataEncryptionKeyID]))
+	})
+
+	t.Run("plaintext payloads", func(t *testing.T) {
+		decoded, err := codec.Decode([]*commonpb.Payload{payload})
+		require.NoError(t, err)
+		assert.Same(t, payload, decoded[0])
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		tampered := &commonpb.Payload{Metadata: encoded[0].Metadata, Data: append([]byte(nil), encoded[0].Data...)}
+		tampered.Data[len(tampered.Data)-1] ^= 1
+		_, err := codec.Decode([]*commonpb.Payload{tampered})
+		assert.ErrorIs(t, err, crypt.ErrDecrypt)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := NewCodec(newTestCipher(t, key2025)).Decode(encoded)
+		assert.Error(t, err)
+	})
+}
+
+func TestWorkflowEnvironment(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	cfg := TestConfig{
+		Workflows:  []interface{}{ChargeWorkflow},
+		Activities: []interface{}{Charge},
+		Cipher:     newTestCipher(t, newKey(t, "k1")),
+		Logger:     zap.New(core),
+	}
+
+	env := NewWorkflowEnvironment(t, cfg)
+	env.ExecuteWorkflow(ChargeWorkflow, Order{ID: "o-1", Amount: 10})
+	receipt, err := WorkflowResult[string](env)
+	require.NoError(t, err)
+	assert.Equal(t, "receipt-o-1", receipt)
+
+	charging := logs.FilterMessage("charging").All()
+	require.Len(t, charging, 1)
+	fields := charging[0].ContextMap()
+	assert.Equal(t, "Charge", fields["activity_type"])
+	assert.EqualValues(t, 1, fields["attempt"])
+	assert.NotEmpty(t, fields["workflow_id"])
+	assert.Equal(t, 1, logs.FilterMessage("activity completed").Len())
+	assert.Equal(t, 1, logs.FilterMessage("workflow completed").Len())
+
+	t.Run("failure", func(t *testing.T) {
+		env := NewWorkflowEnvironment(t, cfg)
+		env.ExecuteWorkflow(ChargeWorkflow, Order{ID: "o-2"})
+		_, err := WorkflowResult[string](env)
+		var appErr *temporal.ApplicationError
+		require.ErrorAs(t, err, &appErr)
+		assert.Equal(t, "InvalidAmount", appErr.Type())
+		assert.Equal(t, 1, logs.FilterMessage("activity failed").Len())
+		assert.Equal(t, 1, logs.FilterMessage("workflow failed").Len())
+	})
+
+	t.Run("mocked activity", func(t *testing.T) {
+		env := NewWorkflowEnvironment(t, cfg)
+		env.OnActivity(Charge, mock.Anything, Order{ID: "o-3", Amount: 5}).Return("mocked", nil).Once()
+		env.ExecuteWorkflow(ChargeWorkflow, Order{ID: "o-3", Amount: 5})
+		receipt, err := WorkflowResult[string](env)
+		require.NoError(t, err)
+		assert.Equal(t, "mocked", receipt)
+	})
+
+	t.Run("not completed", func(t *testing.T) {
+		env := NewWorkflowEnvironment(t, cfg)
+		_, err := WorkflowResult[string](env)
+		assert.ErrorIs(t, err, ErrWorkflowNotCompleted)
+	})
+}
+
+func TestActivityEnvironment(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	env := NewActivityEnvironment(t, TestConfig{Activities: []interface{}{Charge}, Logger: zap.New(core)})
+
+	value, err := env.ExecuteActivity(Charge, Order{ID: "o-1", Amount: 10})
+	require.NoError(t, err)
+	var receipt string
+	require.NoError(t, value.Get(&receipt))
+	assert.Equal(t, "receipt-o-1", receipt)
+	assert.Equal(t, 1, logs.FilterMessage("activity completed").Len())
+}
+
+func TestTracingInterceptor(t *testing.T) {
+	tel := telemetry.NewMemoryProvider()
+	tracing, err := NewTracingInterceptor(tel.TracerProvider, tel.Propagator)
+	require.NoError(t, err)
+
+	env := NewWorkflowEnvironment(t, TestConfig{
+		Workflows:    []interface{}{ChargeWorkflow},
+		Activities:   []interface{}{Charge},
+		Interceptors: []interceptor.WorkerInterceptor{tracing},
+	})
+	env.ExecuteWorkflow(ChargeWorkflow, Order{ID: "o-1", Amount: 10})
+	_, err = WorkflowResult[string](env)
+	require.NoError(t, err)
+
+	spans, err := tel.Spans()
+	require.NoError(t, err)
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	assert.Contains(t, names, "RunWorkflow:ChargeWorkflow")
+	assert.Contains(t, names, "RunActivity:Charge")
+}
+
+func TestConvertToClientConfig(t *testing.T) {
+	key := make([]byte, crypt.KeySize)
+	appConfig := &config.Config{}
+	appConfig.Temporal.HostPort = "temporal:7233"
+	appConfig.Temporal.Namespace = "billing"
+	appConfig.Temporal.Encryption.KeyID = "2025"
+	appConfig.Temporal.Encryption.Keys = map[string]string{
+		"2024": base64.StdEncoding.EncodeToString(key),
+		"2025": base64.StdEncoding.EncodeToString(key),
+	}
+
+	cfg, err := ConvertToClientConfig(appConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "temporal:7233", cfg.HostPort)
+	assert.Equal(t, "billing", cfg.Namespace)
+	assert.Nil(t, cfg.TLSConfig)
+	require.NotNil(t, cfg.Cipher)
+	ciphertext, err := cfg.Cipher.Encrypt([]byte("payload"), nil)
+	require.NoError(t, err)
+	id, err := crypt.KeyID(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "2025", id)
+
+	appConfig.Temporal.Encryption.KeyID = "2026"
+	_, err = ConvertToClientConfig(appConfig)
+	assert.Error(t, err)
+
+	appConfig.Temporal.Encryption.KeyID = ""
+	appConfig.Temporal.TLSEnabled = true
+	appConfig.Temporal.TLSCaPath = "/nonexistent/ca.pem"
+	_, err = ConvertToClientConfig(appConfig)
+	assert.Error(t, err)
+}
+
+func TestWorker(t *testing.T) {
+	c, err := NewClient(ClientConfig{HostPort: "127.0.0.1:1", Lazy: true, Cipher: newTestCipher(t, newKey(t, "k1"))})
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = NewWorker(c, WorkerConfig{})
+	assert.True(t, errors.Is(err, ErrNoTaskQueue))
+
+	w, err := NewWorker(c, WorkerConfig{
+		TaskQueue:  "billing",
+		Workflows:  []interface{}{ChargeWorkflow},
+		Activities: []interface{}{Charge},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// The service is unreachable
+	assert.Error(t, w.Run(ctx))
+	assert.NoError(t, w.Shutdown(ctx))
+}
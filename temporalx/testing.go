@@ -0,0 +1,117 @@
+package temporalx
+
+import (
+	"errors"
+
+	"github.com/seidu626/go-buildingblocks/crypt"
+	"github.com/seidu626/go-buildingblocks/logging"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.uber.org/zap"
+)
+
+// ErrWorkflowNotCompleted is returned by WorkflowResult when the workflow of the environment
+// has not completed, e.g. blocked on a signal.
+var ErrWorkflowNotCompleted = errors.New("temporalx: workflow not completed")
+
+// TestingT is the subset of testing.TB used by the test helpers.
+type TestingT interface {
+	Helper()
+	Logf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	FailNow()
+	Cleanup(func())
+}
+
+// TestConfig holds the configuration of a test environment.
+type TestConfig struct {
+	Workflows  []interface{}
+	Activities []interface{}
+	// Cipher, optional, encrypts the payloads like a client of the same Cipher, see NewCodec
+	Cipher *crypt.Cipher
+	// Interceptors are chained after the logging interceptor
+	Interceptors []interceptor.WorkerInterceptor
+	Logger       *zap.Logger
+}
+
+func (c *TestConfig) setDefaults() {
+	if c.Logger == nil {
+		c.Logger = zap.NewNop()
+	}
+}
+
+func (c *TestConfig) options() worker.Options {
+	return worker.Options{Interceptors: append([]interceptor.WorkerInterceptor{NewLoggingInterceptor(c.Logger)}, c.Interceptors...)}
+}
+
+func (c *TestConfig) dataConverter() converter.DataConverter {
+	if c.Cipher == nil {
+		return converter.GetDefaultDataConverter()
+	}
+	return NewEncryptedDataConverter(converter.GetDefaultDataConverter(), c.Cipher)
+}
+
+// NewTestSuite returns a testsuite.WorkflowTestSuite logging with logger.
+func NewTestSuite(logger *zap.Logger) *testsuite.WorkflowTestSuite {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	s := &testsuite.WorkflowTestSuite{}
+	s.SetLogger(logging.NewZapAdapter(logger))
+	return s
+}
+
+// NewWorkflowEnvironment returns a test environment with the workflows and the activities of
+// cfg registered, and the interceptors and the data converter of a worker of NewClient. The
+// expectations of its mocks are asserted at the end of the test.
+//
+//	env := temporalx.NewWorkflowEnvironment(t, temporalx.TestConfig{Workflows: []interface{}{ChargeWorkflow}})
+//	env.OnActivity(Charge, mock.Anything, order).Return(receipt, nil)
+//	env.ExecuteWorkflow(ChargeWorkflow, order)
+//	got, err := temporalx.WorkflowResult[Receipt](env)
+func NewWorkflowEnvironment(t TestingT, cfg TestConfig) *testsuite.TestWorkflowEnvironment {
+	t.Helper()
+	cfg.setDefaults()
+	env := NewTestSuite(cfg.Logger).NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(cfg.options())
+	env.SetDataConverter(cfg.dataConverter())
+	for _, wf := range cfg.Workflows {
+		env.RegisterWorkflow(wf)
+	}
+	for _, a := range cfg.Activities {
+		env.RegisterActivity(a)
+	}
+	t.Cleanup(func() {
+		env.AssertExpectations(t)
+	})
+	return env
+}
+
+// NewActivityEnvironment returns a test environment executing the activities of cfg, with the
+// interceptors and the data converter of a worker of NewClient.
+func NewActivityEnvironment(t TestingT, cfg TestConfig) *testsuite.TestActivityEnvironment {
+	t.Helper()
+	cfg.setDefaults()
+	env := NewTestSuite(cfg.Logger).NewTestActivityEnvironment()
+	env.SetWorkerOptions(cfg.options())
+	env.SetDataConverter(cfg.dataConverter())
+	for _, a := range cfg.Activities {
+		env.RegisterActivity(a)
+	}
+	return env
+}
+
+// WorkflowResult returns the result of the workflow executed by env, or its error.
+func WorkflowResult[T any](env *testsuite.TestWorkflowEnvironment) (T, error) {
+	var result T
+	if !env.IsWorkflowCompleted() {
+		return result, ErrWorkflowNotCompleted
+	}
+	if err := env.GetWorkflowError(); err != nil {
+		return result, err
+	}
+	err := env.GetWorkflowResult(&result)
+	return result, err
+}
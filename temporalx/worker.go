@@ -0,0 +1,100 @@
+package temporalx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+// ErrNoTaskQueue is returned by NewWorker when the task queue is not set.
+var ErrNoTaskQueue = errors.New("temporalx: task queue required")
+
+// WorkerConfig holds the configuration of a worker.
+type WorkerConfig struct {
+	TaskQueue string
+	// Workflows are the workflow functions, registered with their function name
+	Workflows []interface{}
+	// Activities are the activity functions, or structs whose methods are activities,
+	// registered with their name
+	Activities []interface{}
+	// Options of the worker, e.g. its concurrency or worker.Options.WorkerStopTimeout, the time
+	// given to the running activities at Shutdown
+	Options worker.Options
+}
+
+// Worker polls a task queue and executes its workflows and activities.
+type Worker struct {
+	worker.Worker
+	interrupt chan interface{}
+	once      sync.Once
+	running   atomic.Bool
+	done      chan struct{}
+}
+
+// NewWorker creates a worker of the task queue of cfg, with its workflows and activities
+// registered. It polls the task queue once started with Run. The interceptors of c apply to
+// the worker, see NewClient.
+func NewWorker(c client.Client, cfg WorkerConfig) (*Worker, error) {
+	if cfg.TaskQueue == "" {
+		return nil, ErrNoTaskQueue
+	}
+	w := worker.New(c, cfg.TaskQueue, cfg.Options)
+	for _, wf := range cfg.Workflows {
+		w.RegisterWorkflow(wf)
+	}
+	for _, a := range cfg.Activities {
+		w.RegisterActivity(a)
+	}
+	return &Worker{Worker: w, interrupt: make(chan interface{}), done: make(chan struct{})}, nil
+}
+
+// Run starts the worker and blocks until ctx is done or Shutdown is called, then stops the
+// worker. It returns an error if the worker fails to start or stops on a fatal error, e.g.
+// its namespace being deleted.
+func (w *Worker) Run(ctx context.Context) error {
+	w.running.Store(true)
+	defer close(w.done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.stop()
+		case <-w.done:
+		}
+	}()
+	if err := w.Worker.Run(w.interrupt); err != nil {
+		return fmt.Errorf("failed to run Temporal worker: %w", err)
+	}
+	return nil
+}
+
+// Shutdown stops the worker, waiting for its running activities up to
+// worker.Options.WorkerStopTimeout, or until ctx is done. It can be used as a
+// graceful.Operation.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.stop()
+	done := w.done
+	if !w.running.Load() {
+		// Started with Start rather than Run
+		done = make(chan struct{})
+		go func() {
+			w.Worker.Stop()
+			close(done)
+		}()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop interrupts Run, which stops the worker
+func (w *Worker) stop() {
+	w.once.Do(func() { close(w.interrupt) })
+}
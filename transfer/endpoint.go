@@ -0,0 +1,31 @@
+// Package transfer moves files between SFTP servers and S3 buckets: a Pipeline polls a
+// directory of the source endpoint, streams the new files matching its patterns to the
+// destination, then archives or deletes them.
+package transfer
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// File is a file listed by an Endpoint.
+type File struct {
+	Path    string // Full path on the endpoint, e.g. "inbound/orders.csv" or an S3 key
+	Size    int64
+	ModTime time.Time
+}
+
+// Endpoint is one side of a transfer. Paths use forward slashes.
+type Endpoint interface {
+	// List returns the regular files directly under dir
+	List(ctx context.Context, dir string) ([]File, error)
+	// Open streams a file, the reader must be closed
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	// Write stores r under path, creating the missing directories. The file must only be
+	// visible once complete.
+	Write(ctx context.Context, path string, r io.Reader) error
+	// Move renames a file, replacing the target
+	Move(ctx context.Context, from, to string) error
+	Remove(ctx context.Context, path string) error
+}
@@ -0,0 +1,248 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AfterAction is what happens to a source file once transferred.
+type AfterAction int
+
+const (
+	// Keep leaves the source file, the state store prevents transferring it again.
+	Keep AfterAction = iota
+	// Delete removes the source file.
+	Delete
+	// Archive moves the source file to ArchiveDir.
+	Archive
+)
+
+// Config holds the configuration of a Pipeline.
+type Config struct {
+	Source         Endpoint
+	SourceDir      string
+	Destination    Endpoint
+	DestinationDir string
+
+	// Patterns are path.Match globs on the file names, e.g. "*.csv". All files match when empty.
+	Patterns []string
+	// MinAge skips the files modified more recently, which may still be being written.
+	MinAge time.Duration
+	// Rename maps a source file name to its name in DestinationDir, which may include
+	// directories (default: same name). sftputils.RenameFile can be used as is.
+	Rename func(name string) string
+
+	After      AfterAction
+	ArchiveDir string // Directory of the archived files on the source, Archive only
+
+	State        StateStore    // Defaults to a MemoryStore
+	Concurrency  int           // Files transferred in parallel (default 4)
+	PollInterval time.Duration // Delay between two runs of Poll (default 1m)
+	Logger       *zap.Logger
+}
+
+// Result is the outcome of the transfer of a file.
+type Result struct {
+	Source      File
+	Destination string
+	// Resumed is set when the file had already been transferred and only the after action ran
+	Resumed  bool
+	Duration time.Duration
+	Err      error
+}
+
+// Pipeline transfers the files of a source directory to a destination.
+type Pipeline struct {
+	config Config
+	log    *zap.Logger
+}
+
+// New creates a Pipeline.
+func New(cfg Config) (*Pipeline, error) {
+	if cfg.Source == nil || cfg.Destination == nil {
+		return nil, errors.New("transfer: source and destination are required")
+	}
+	if cfg.After == Archive && cfg.ArchiveDir == "" {
+		return nil, errors.New("transfer: archive directory is required")
+	}
+	for _, pattern := range cfg.Patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("transfer: invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if cfg.Rename == nil {
+		cfg.Rename = func(name string) string { return name }
+	}
+	if cfg.State == nil {
+		cfg.State = NewMemoryStore()
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Minute
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+	log := cfg.Logger.With(zap.String("source_dir", cfg.SourceDir), zap.String("destination_dir", cfg.DestinationDir))
+	return &Pipeline{config: cfg, log: log}, nil
+}
+
+// RunOnce transfers the matching files currently in the source directory, oldest first.
+// Files already recorded in the state store are not transferred again, but their after action
+// is retried. The error is only set when the directory cannot be listed, the failures of
+// single files are reported in their result.
+func (p *Pipeline) RunOnce(ctx context.Context) ([]Result, error) {
+	files, err := p.config.Source.List(ctx, p.config.SourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("transfer: listing %s: %w", p.config.SourceDir, err)
+	}
+	files = p.match(files)
+
+	results := make([]Result, len(files))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, p.config.Concurrency)
+	for i := range files {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return compact(results), ctx.Err()
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = p.transfer(ctx, files[i])
+		}(i)
+	}
+	wg.Wait()
+	return compact(results), nil
+}
+
+// Poll runs the pipeline every PollInterval until ctx is done, calling report with the results
+// of every run that transferred files. Listing errors are logged and retried on the next run.
+func (p *Pipeline) Poll(ctx context.Context, report func([]Result)) error {
+	for {
+		results, err := p.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			p.log.Error("transfer run failed", zap.Error(err))
+		}
+		if len(results) > 0 && report != nil {
+			report(results)
+		}
+		timer := time.NewTimer(p.config.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// match filters the files by pattern and age, sorted by modification time
+func (p *Pipeline) match(files []File) []File {
+	cutoff := time.Now().Add(-p.config.MinAge)
+	var matched []File
+	for _, file := range files {
+		if p.config.MinAge > 0 && file.ModTime.After(cutoff) {
+			continue
+		}
+		if len(p.config.Patterns) == 0 {
+			matched = append(matched, file)
+			continue
+		}
+		for _, pattern := range p.config.Patterns {
+			if ok, _ := path.Match(pattern, path.Base(file.Path)); ok {
+				matched = append(matched, file)
+				break
+			}
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].ModTime.Before(matched[j].ModTime) })
+	return matched
+}
+
+// stateKey identifies a version of a file: a file dropped again with the same name but another
+// size or modification time is transferred again.
+func stateKey(file File) string {
+	return file.Path + "|" + strconv.FormatInt(file.Size, 10) + "|" + strconv.FormatInt(file.ModTime.UnixNano(), 10)
+}
+
+func (p *Pipeline) transfer(ctx context.Context, file File) Result {
+	start := time.Now()
+	result := Result{Source: file, Destination: path.Join(p.config.DestinationDir, p.config.Rename(path.Base(file.Path)))}
+	log := p.log.With(zap.String("source", file.Path), zap.String("destination", result.Destination))
+
+	key := stateKey(file)
+	record, done, err := p.config.State.Get(ctx, key)
+	switch {
+	case err != nil:
+		result.Err = fmt.Errorf("reading state: %w", err)
+	case done:
+		result.Resumed, result.Destination = true, record.Destination
+	default:
+		if result.Err = p.copy(ctx, file.Path, result.Destination); result.Err == nil {
+			if err = p.config.State.Put(ctx, key, Record{Destination: result.Destination, Size: file.Size, TransferredAt: time.Now()}); err != nil {
+				result.Err = fmt.Errorf("recording state: %w", err)
+			}
+		}
+	}
+	if result.Err == nil {
+		result.Err = p.after(ctx, file)
+	}
+
+	result.Duration = time.Since(start)
+	if result.Err != nil {
+		log.Error("transfer failed", zap.Error(result.Err))
+	} else if !result.Resumed || p.config.After != Keep {
+		log.Info("file transferred", zap.Int64("size", file.Size), zap.Bool("resumed", result.Resumed), zap.Duration("duration", result.Duration))
+	}
+	return result
+}
+
+func (p *Pipeline) copy(ctx context.Context, from, to string) error {
+	r, err := p.config.Source.Open(ctx, from)
+	if err != nil {
+		return fmt.Errorf("opening source: %w", err)
+	}
+	defer r.Close()
+	if err = p.config.Destination.Write(ctx, to, r); err != nil {
+		return fmt.Errorf("writing destination: %w", err)
+	}
+	return nil
+}
+
+func (p *Pipeline) after(ctx context.Context, file File) error {
+	switch p.config.After {
+	case Delete:
+		if err := p.config.Source.Remove(ctx, file.Path); err != nil {
+			return fmt.Errorf("deleting source: %w", err)
+		}
+	case Archive:
+		if err := p.config.Source.Move(ctx, file.Path, path.Join(p.config.ArchiveDir, path.Base(file.Path))); err != nil {
+			return fmt.Errorf("archiving source: %w", err)
+		}
+	}
+	return nil
+}
+
+// compact drops the results of the files not processed because the context was cancelled
+func compact(results []Result) []Result {
+	out := results[:0]
+	for _, result := range results {
+		if result.Source.Path != "" {
+			out = append(out, result)
+		}
+	}
+	return out
+}
@@ -0,0 +1,246 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// memEndpoint is an in-memory Endpoint
+type memEndpoint struct {
+	mu       sync.Mutex
+	files    map[string]memFile
+	failOpen map[string]bool
+	writes   int
+}
+
+func newMemEndpoint() *memEndpoint {
+	return &memEndpoint{files: make(map[string]memFile), failOpen: make(map[string]bool)}
+}
+
+func (m *memEndpoint) add(name, data string, modTime time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = memFile{data: []byte(data), modTime: modTime}
+}
+
+func (m *memEndpoint) get(name string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[name]
+	return string(f.data), ok
+}
+
+func (m *memEndpoint) List(_ context.Context, dir string) ([]File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var files []File
+	for name, f := range m.files {
+		if path.Dir(name) == path.Clean(dir) {
+			files = append(files, File{Path: name, Size: int64(len(f.data)), ModTime: f.modTime})
+		}
+	}
+	return files, nil
+}
+
+func (m *memEndpoint) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failOpen[name] {
+		return nil, errors.New("open failed")
+	}
+	f, ok := m.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+func (m *memEndpoint) Write(_ context.Context, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writes++
+	m.files[name] = memFile{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (m *memEndpoint) Move(_ context.Context, from, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[from]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	delete(m.files, from)
+	m.files[to] = f
+	return nil
+}
+
+func (m *memEndpoint) Remove(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; !ok {
+		return fs.ErrNotExist
+	}
+	delete(m.files, name)
+	return nil
+}
+
+func TestNewValidation(t *testing.T) {
+	_, err := New(Config{Source: newMemEndpoint()})
+	assert.Error(t, err)
+	_, err = New(Config{Source: newMemEndpoint(), Destination: newMemEndpoint(), After: Archive})
+	assert.Error(t, err)
+	_, err = New(Config{Source: newMemEndpoint(), Destination: newMemEndpoint(), Patterns: []string{"["}})
+	assert.Error(t, err)
+}
+
+func TestRunOnceArchive(t *testing.T) {
+	src, dst := newMemEndpoint(), newMemEndpoint()
+	old := time.Now().Add(-time.Hour)
+	src.add("in/a.csv", "a", old)
+	src.add("in/b.csv", "bb", old.Add(time.Minute))
+	src.add("in/c.txt", "c", old)
+	src.add("in/fresh.csv", "f", time.Now())
+
+	p, err := New(Config{
+		Source: src, SourceDir: "in",
+		Destination: dst, DestinationDir: "out",
+		Patterns: []string{"*.csv"},
+		MinAge:   10 * time.Minute,
+		Rename:   strings.ToUpper,
+		After:    Archive, ArchiveDir: "in/archive",
+	})
+	require.NoError(t, err)
+
+	results, err := p.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "in/a.csv", results[0].Source.Path, "oldest first")
+	assert.Equal(t, "out/A.CSV", results[0].Destination)
+	for _, r := range results {
+		assert.NoError(t, r.Err)
+	}
+
+	data, ok := dst.get("out/B.CSV")
+	assert.True(t, ok)
+	assert.Equal(t, "bb", data)
+	_, ok = src.get("in/archive/a.csv")
+	assert.True(t, ok)
+	_, ok = src.get("in/a.csv")
+	assert.False(t, ok)
+	_, ok = src.get("in/c.txt")
+	assert.True(t, ok, "not matching")
+	_, ok = src.get("in/fresh.csv")
+	assert.True(t, ok, "too recent")
+}
+
+func TestRunOnceKeepIsIdempotent(t *testing.T) {
+	src, dst := newMemEndpoint(), newMemEndpoint()
+	modTime := time.Now().Add(-time.Hour)
+	src.add("in/a.csv", "a", modTime)
+
+	p, err := New(Config{Source: src, SourceDir: "in", Destination: dst, DestinationDir: "out"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		results, err := p.RunOnce(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, i > 0, results[0].Resumed)
+	}
+	assert.Equal(t, 1, dst.writes)
+
+	// A new version of the file is transferred again
+	src.add("in/a.csv", "a2", modTime.Add(time.Minute))
+	results, err := p.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.False(t, results[0].Resumed)
+	assert.Equal(t, 2, dst.writes)
+}
+
+func TestRunOnceFailureKeepsSource(t *testing.T) {
+	src, dst := newMemEndpoint(), newMemEndpoint()
+	src.add("in/a.csv", "a", time.Now())
+	src.add("in/b.csv", "b", time.Now())
+	src.failOpen["in/a.csv"] = true
+
+	p, err := New(Config{Source: src, SourceDir: "in", Destination: dst, DestinationDir: "out", After: Delete})
+	require.NoError(t, err)
+	results, err := p.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			assert.Equal(t, "in/a.csv", r.Source.Path)
+		}
+	}
+	assert.Equal(t, 1, failed)
+	_, ok := src.get("in/a.csv")
+	assert.True(t, ok)
+	_, ok = src.get("in/b.csv")
+	assert.False(t, ok)
+}
+
+func TestFileStore(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "state.json")
+	store, err := NewFileStore(name, time.Hour)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "old", Record{Destination: "x", TransferredAt: time.Now().Add(-2 * time.Hour)}))
+	require.NoError(t, store.Put(ctx, "new", Record{Destination: "y", Size: 3, TransferredAt: time.Now()}))
+
+	reloaded, err := NewFileStore(name, time.Hour)
+	require.NoError(t, err)
+	record, ok, err := reloaded.Get(ctx, "new")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "y", record.Destination)
+	_, ok, _ = reloaded.Get(ctx, "old")
+	assert.False(t, ok, "expired")
+}
+
+func TestPollStopsOnCancel(t *testing.T) {
+	src, dst := newMemEndpoint(), newMemEndpoint()
+	src.add("in/a.csv", "a", time.Now())
+	p, err := New(Config{Source: src, SourceDir: "in", Destination: dst, DestinationDir: "out", PollInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := make(chan []Result, 10)
+	done := make(chan error)
+	go func() { done <- p.Poll(ctx, func(r []Result) { reports <- r }) }()
+
+	first := <-reports
+	assert.False(t, first[0].Resumed)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestCopySource(t *testing.T) {
+	assert.Equal(t, "bucket/in/a%20b%2Bc.csv", copySource("bucket", "in/a b+c.csv"))
+}
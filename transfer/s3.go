@@ -0,0 +1,110 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3API is the subset of the S3 client used by the S3 endpoint. It is satisfied by *s3.Client.
+type S3API interface {
+	manager.UploadAPIClient
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+var _ S3API = (*s3.Client)(nil)
+
+// S3 is an Endpoint on a bucket. Directories are key prefixes.
+type S3 struct {
+	client   S3API
+	bucket   string
+	uploader *manager.Uploader
+}
+
+var _ Endpoint = (*S3)(nil)
+
+// NewS3 creates an endpoint on the bucket. Files are uploaded with multipart uploads, so they
+// are streamed without knowing their size.
+func NewS3(client S3API, bucket string) *S3 {
+	return &S3{client: client, bucket: bucket, uploader: manager.NewUploader(client)}
+}
+
+func (s *S3) List(ctx context.Context, dir string) ([]File, error) {
+	prefix := strings.Trim(dir, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	var files []File
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if strings.HasSuffix(key, "/") {
+				// Folder placeholder created by the console
+				continue
+			}
+			files = append(files, File{Path: key, Size: aws.ToInt64(object.Size), ModTime: aws.ToTime(object.LastModified)})
+		}
+	}
+	return files, nil
+}
+
+func (s *S3) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(name))})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *S3) Write(ctx context.Context, name string, r io.Reader) error {
+	_, err := s.uploader.Upload(ctx, &s3.PutObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(name)), Body: r})
+	return err
+}
+
+func (s *S3) Move(ctx context.Context, from, to string) error {
+	if _, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(s.key(to)),
+		CopySource: aws.String(copySource(s.bucket, s.key(from))),
+	}); err != nil {
+		return err
+	}
+	return s.Remove(ctx, from)
+}
+
+func (s *S3) Remove(ctx context.Context, name string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(name))})
+	return err
+}
+
+func (s *S3) key(name string) string {
+	return strings.TrimPrefix(path.Clean(name), "/")
+}
+
+// copySource returns the URL encoded bucket/key source of CopyObject. "+" is escaped too, as
+// S3 decodes it to a space.
+func copySource(bucket, key string) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segments[i]), "+", "%20")
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,82 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+
+	"github.com/pkg/sftp"
+	sftputils "github.com/seidu626/go-buildingblocks/sftp"
+)
+
+// SFTP is an Endpoint on an SFTP server, using the sessions of the pool.
+type SFTP struct {
+	pool *sftputils.Pool
+}
+
+var _ Endpoint = (*SFTP)(nil)
+
+// NewSFTP creates an SFTP endpoint.
+func NewSFTP(pool *sftputils.Pool) *SFTP {
+	return &SFTP{pool: pool}
+}
+
+// List skips the partial files of interrupted uploads.
+func (s *SFTP) List(ctx context.Context, dir string) ([]File, error) {
+	var files []File
+	err := s.pool.Do(ctx, func(client *sftputils.SFTPClient) error {
+		entries, err := client.Client.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.Mode().IsRegular() || path.Ext(entry.Name()) == sftputils.PartSuffix {
+				continue
+			}
+			files = append(files, File{Path: path.Join(dir, entry.Name()), Size: entry.Size(), ModTime: entry.ModTime()})
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (s *SFTP) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	client, err := s.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, w := io.Pipe()
+	go func() {
+		_, err := client.Download(ctx, name, w)
+		s.pool.Put(client, isConnectionError(err))
+		_ = w.CloseWithError(err)
+	}()
+	return r, nil
+}
+
+func (s *SFTP) Write(ctx context.Context, name string, r io.Reader) error {
+	return s.pool.Do(ctx, func(client *sftputils.SFTPClient) error {
+		_, err := client.Upload(ctx, r, name)
+		return err
+	})
+}
+
+func (s *SFTP) Move(ctx context.Context, from, to string) error {
+	return s.pool.Do(ctx, func(client *sftputils.SFTPClient) error {
+		if err := client.Client.MkdirAll(path.Dir(to)); err != nil {
+			return err
+		}
+		return client.Rename(from, to)
+	})
+}
+
+func (s *SFTP) Remove(ctx context.Context, name string) error {
+	return s.pool.Do(ctx, func(client *sftputils.SFTPClient) error {
+		return client.Client.Remove(name)
+	})
+}
+
+func isConnectionError(err error) bool {
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection)
+}
@@ -0,0 +1,114 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Record is the state of a transferred file.
+type Record struct {
+	Destination   string    `json:"destination"`
+	Size          int64     `json:"size"`
+	TransferredAt time.Time `json:"transferred_at"`
+}
+
+// StateStore records the transferred files, so a restarted pipeline does not transfer them again.
+type StateStore interface {
+	// Get returns the record of the key, false if the file was never transferred
+	Get(ctx context.Context, key string) (Record, bool, error)
+	Put(ctx context.Context, key string, record Record) error
+}
+
+// MemoryStore is a StateStore kept in memory, for pipelines that do not need to survive restarts.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[key]
+	return record, ok, nil
+}
+
+func (m *MemoryStore) Put(_ context.Context, key string, record Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[key] = record
+	return nil
+}
+
+// FileStore is a StateStore persisted as a JSON file, rewritten atomically on every Put.
+// Records older than the retention are dropped on write.
+type FileStore struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	records   map[string]Record
+}
+
+// NewFileStore loads the state from the file, if it exists. A zero retention keeps the records forever.
+func NewFileStore(path string, retention time.Duration) (*FileStore, error) {
+	store := &FileStore{path: path, retention: retention, records: make(map[string]Record)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &store.records); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (f *FileStore) Get(_ context.Context, key string) (Record, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record, ok := f.records[key]
+	return record, ok, nil
+}
+
+func (f *FileStore) Put(_ context.Context, key string, record Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records[key] = record
+	if f.retention > 0 {
+		cutoff := time.Now().Add(-f.retention)
+		for k, r := range f.records {
+			if r.TransferredAt.Before(cutoff) {
+				delete(f.records, k)
+			}
+		}
+	}
+	data, err := json.Marshal(f.records)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}